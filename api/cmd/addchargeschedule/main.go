@@ -0,0 +1,55 @@
+package main
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/env"
+	"arthveda/internal/feature/charge"
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// The JSON file has the same shape as internal/feature/charge/default_schedule.json, but only needs
+// the rates that change. The ones it leaves out are carried over from the schedule before it.
+// Another file can be passed as the first argument.
+const inputFile = "charge_schedule.json"
+
+// Adds a schedule of charge rates to the `charge_schedule` table. The charges of trades on or after
+// its effective date are calculated with it from then on, without a deploy. Adding a schedule for
+// an effective date that already has one replaces it with the next version.
+func main() {
+	ctx := context.Background()
+
+	env.Init("./.env")
+
+	file := inputFile
+	if len(os.Args) > 1 {
+		file = os.Args[1]
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalf("unable to read charge schedule: %v", err)
+	}
+
+	schedule, err := charge.New(data)
+	if err != nil {
+		log.Fatalf("invalid charge schedule: %v", err)
+	}
+
+	db, err := dbx.Init()
+	if err != nil {
+		log.Fatalf("unable to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	chargeScheduleRepository := charge.NewRepository(db)
+
+	err = chargeScheduleRepository.Create(ctx, schedule)
+	if err != nil {
+		log.Fatalf("unable to save charge schedule: %v", err)
+	}
+
+	log.Printf("✅ Done. Charge schedule effective from %s added as version %d.\n", schedule.EffectiveFrom.Format(time.DateOnly), schedule.Version)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/position"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func createCashFlowHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		var payload cashflow.CreatePayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		cashFlow, errKind, err := s.CreateCashFlow(ctx, userID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusCreated, "Cash flow created successfully", map[string]any{"cash_flow": cashFlow})
+	}
+}
+
+func updateCashFlowHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		cashFlowID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid cash flow ID"))
+			return
+		}
+
+		var payload cashflow.UpdatePayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		cashFlow, errKind, err := s.UpdateCashFlow(ctx, userID, cashFlowID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Cash flow updated successfully", map[string]any{"cash_flow": cashFlow})
+	}
+}
+
+func deleteCashFlowHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		cashFlowID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid cash flow ID"))
+			return
+		}
+
+		errKind, err := s.DeleteCashFlow(ctx, userID, cashFlowID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Cash flow deleted successfully", nil)
+	}
+}
+
+func searchCashFlowsHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		var filter cashflow.SearchFilter
+		if err := decodeJSONRequest(&filter, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		cashFlows, errKind, err := s.SearchCashFlows(ctx, userID, filter)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", map[string]any{"cash_flows": cashFlows})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"arthveda/internal/apires"
+	"arthveda/internal/feature/chargereconciliation"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/pdfx"
+	"arthveda/internal/service"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxChargesFileSize is the most bytes a charges summary can have. A contract note of a busy year
+// is a few MB, and the whole file is read into memory.
+const maxChargesFileSize = 20 << 20
+
+func reconcileChargesHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxChargesFileSize)
+
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				badRequestResponse(w, r, fmt.Errorf("File is too large. Files up to %d MB are supported.", maxChargesFileSize>>20))
+				return
+			}
+
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Unable to read file", "", "file", nil)))
+			return
+		}
+
+		defer file.Close()
+
+		userBrokerAccountID, err := uuid.Parse(r.FormValue("user_broker_account_id"))
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Broker Account is invalid", "", "user_broker_account_id", r.FormValue("user_broker_account_id"))))
+			return
+		}
+
+		date, err := time.Parse(time.DateOnly, r.FormValue("date"))
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Date must be in YYYY-MM-DD format", "", "date", r.FormValue("date"))))
+			return
+		}
+
+		apply := false
+		if applyStr := r.FormValue("apply"); applyStr != "" {
+			apply, err = strconv.ParseBool(applyStr)
+			if err != nil {
+				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("", "Apply must be a boolean", "apply", applyStr)))
+				return
+			}
+		}
+
+		var rows [][]string
+
+		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+
+		switch ext {
+		case ".csv":
+			rows, err = csv.NewReader(file).ReadAll()
+			if err != nil {
+				badRequestResponse(w, r, fmt.Errorf("Unable to read csv file: %v. Please ensure the file is a valid CSV file.", err))
+				return
+			}
+		case ".xlsx":
+			excelFile, err := excelize.OpenReader(file)
+			if err != nil {
+				badRequestResponse(w, r, fmt.Errorf("Unable to read excel file: %v. Please ensure the file is a valid .xlsx Excel file.", err))
+				return
+			}
+
+			defer excelFile.Close()
+
+			// The charges summary is expected on the first sheet.
+			rows, err = excelFile.GetRows(excelFile.GetSheetName(0))
+			if err != nil {
+				internalServerErrorResponse(w, r, fmt.Errorf("failed to read rows from excel file: %w", err))
+				return
+			}
+		case ".pdf":
+			data, err := io.ReadAll(file)
+			if err != nil {
+				internalServerErrorResponse(w, r, fmt.Errorf("failed to read pdf file: %w", err))
+				return
+			}
+
+			rows, err = pdfx.ReadRows(data)
+			if err != nil {
+				badRequestResponse(w, r, err)
+				return
+			}
+		default:
+			badRequestResponse(w, r, fmt.Errorf("Unsupported file type: %s. Only .xlsx, .csv and .pdf files are supported.", ext))
+			return
+		}
+
+		payload := position.ReconcileChargesPayload{
+			UserBrokerAccountID: userBrokerAccountID,
+			Date:                date,
+			Rows:                rows,
+			Apply:               apply,
+		}
+
+		reconciliations, errKind, err := s.ReconcileCharges(ctx, userID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Charges reconciled successfully", map[string]any{"reconciliations": reconciliations})
+	}
+}
+
+func applyChargeReconciliationHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		reconciliationID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid charge reconciliation ID"))
+			return
+		}
+
+		reconciliation, errKind, err := s.ApplyChargeReconciliation(ctx, userID, reconciliationID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Actual charges applied successfully", map[string]any{"reconciliation": reconciliation})
+	}
+}
+
+func searchChargeReconciliationsHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		var filter chargereconciliation.SearchFilter
+		if err := decodeJSONRequest(&filter, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		reconciliations, errKind, err := s.SearchChargeReconciliations(ctx, userID, filter)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", map[string]any{"reconciliations": reconciliations})
+	}
+}
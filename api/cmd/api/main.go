@@ -2,18 +2,25 @@ package main
 
 import (
 	"arthveda/internal/dbx"
+	"arthveda/internal/domain/price"
 	"arthveda/internal/domain/subscription"
 	"arthveda/internal/env"
 	"arthveda/internal/feature/broker"
 	"arthveda/internal/feature/calendar"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/charge"
+	"arthveda/internal/feature/chargereconciliation"
+	"arthveda/internal/feature/corporateaction"
 	"arthveda/internal/feature/currency"
 	"arthveda/internal/feature/dashboard"
+	"arthveda/internal/feature/importjob"
 	"arthveda/internal/feature/insight"
 	"arthveda/internal/feature/journal_entry"
 	"arthveda/internal/feature/journal_entry_content"
 	"arthveda/internal/feature/notification"
 	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/report"
+	"arthveda/internal/feature/strategy"
 	"arthveda/internal/feature/symbol"
 	"arthveda/internal/feature/tag"
 	"arthveda/internal/feature/trade"
@@ -32,11 +39,14 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // This contains all the global state that we need to run the API.
 // Like all the services and repositories of Arthveda.
 type app struct {
+	db         *pgxpool.Pool
 	service    services
 	repository repositories
 }
@@ -52,11 +62,13 @@ type services struct {
 	SymbolService            *symbol.Service
 	UploadService            *upload.Service
 	UserBrokerAccountService *userbrokeraccount.Service
+	ImportJobService         *importjob.Service
 	UserIdentityService      *user_identity.Service
 	UserProfileService       *userprofile.Service
 	TagService               *tag.Service
 	ReportService            *report.Service
 	InsightService           *insight.Service
+	StrategyService          *strategy.Service
 }
 
 // Access to all repositories for reading.
@@ -72,6 +84,7 @@ type repositories struct {
 	UserProfile       userprofile.Reader
 	Tag               tag.Reader
 	Analytics         report.Reader
+	Strategy          strategy.Reader
 }
 
 func main() {
@@ -113,13 +126,22 @@ func main() {
 	userBrokerAccountRepository := userbrokeraccount.NewRepository(db)
 	userIdentityRepository := user_identity.NewRepository(db)
 	tagRepository := tag.NewRepository(db)
-	positionRepository := position.NewRepository(db, tradeRepository, tagRepository)
+	corporateActionRepository := corporateaction.NewRepository(db)
+	cashFlowRepository := cashflow.NewRepository(db)
+	chargeScheduleRepository := charge.NewRepository(db)
+	chargeReconciliationRepository := chargereconciliation.NewRepository(db)
+	positionRepository := position.NewRepository(db, tradeRepository, tagRepository, corporateActionRepository, cashFlowRepository)
 	analyticsRepository := report.NewRepository(db)
+	strategyRepository := strategy.NewRepository(db)
+	importJobRepository := importjob.NewRepository(db)
+
+	priceStore := price.NewLocalStore(env.PRICE_STORE_DIR)
+	priceFeed := price.NewLocalFeed(env.PRICE_STORE_DIR)
 
 	brokerService := broker.NewService(brokerRepository)
-	calendarService := calendar.NewService(positionRepository)
+	calendarService := calendar.NewService(positionRepository, cashFlowRepository)
 	currencyService := currency.NewService(currencyRepository)
-	dashboardService := dashboard.NewService(dashboardRepository, positionRepository, tradeRepository)
+	dashboardService := dashboard.NewService(dashboardRepository, positionRepository, tradeRepository, priceFeed, cashFlowRepository)
 	journalEntryService := journal_entry.NewService(journalEntryRepository, journalEntryContentRepository)
 	subscriptionService := subscription.NewService(subscriptionRepository)
 	symbolService := symbol.NewService(positionRepository)
@@ -129,9 +151,12 @@ func main() {
 	userProfileService := userprofile.NewService(userProfileRepository, subscriptionRepository,
 		positionRepository, uploadRepository, subscriptionService)
 	tagService := tag.NewService(tagRepository)
-	positionService := position.NewService(brokerRepository, positionRepository, tradeRepository,
-		userBrokerAccountRepository, journalEntryService, uploadRepository, tagService, tagRepository)
-	reportService := report.NewService(positionRepository, tagRepository, calendarService)
+	positionService := position.NewService(db, brokerRepository, positionRepository, tradeRepository,
+		userBrokerAccountRepository, journalEntryService, uploadRepository, tagService, tagRepository, priceStore, priceFeed, corporateActionRepository, cashFlowRepository, chargeScheduleRepository,
+		chargeReconciliationRepository, userProfileService)
+	strategyService := strategy.NewService(db, strategyRepository, positionRepository)
+	importJobService := importjob.NewService(importJobRepository, positionService, strategyService)
+	reportService := report.NewService(positionRepository, tagRepository, calendarService, strategyService, priceStore)
 	insightService := insight.NewService(positionRepository, reportService)
 
 	services := services{
@@ -144,11 +169,13 @@ func main() {
 		SymbolService:            symbolService,
 		UploadService:            uploadService,
 		UserBrokerAccountService: userBrokerAccountService,
+		ImportJobService:         importJobService,
 		UserIdentityService:      userIdentityService,
 		UserProfileService:       userProfileService,
 		TagService:               tagService,
 		ReportService:            reportService,
 		InsightService:           insightService,
+		StrategyService:          strategyService,
 	}
 
 	repositories := repositories{
@@ -162,13 +189,20 @@ func main() {
 		UserProfile:       userProfileRepository,
 		Tag:               tagRepository,
 		Analytics:         analyticsRepository,
+		Strategy:          strategyRepository,
 	}
 
 	a := &app{
+		db:         db,
 		service:    services,
 		repository: repositories,
 	}
 
+	// The import jobs that were running when the API stopped are run again.
+	if err := importJobService.Resume(context.Background()); err != nil {
+		logger.Get().Errorw("failed to resume import jobs", "error", err)
+	}
+
 	r := initRouter(a)
 
 	err = run(r)
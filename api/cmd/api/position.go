@@ -2,14 +2,19 @@ package main
 
 import (
 	"arthveda/internal/apires"
+	"arthveda/internal/domain/broker_integration"
 	"arthveda/internal/feature/currency"
+	"arthveda/internal/feature/importjob"
 	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/strategy"
 	"arthveda/internal/logger"
 	"arthveda/internal/service"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -25,6 +30,7 @@ import (
 func computePositionHandler(s *position.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
 
 		var payload position.ComputePayload
 		if err := decodeJSONRequest(&payload, r); err != nil {
@@ -32,7 +38,7 @@ func computePositionHandler(s *position.Service) http.HandlerFunc {
 			return
 		}
 
-		result, errKind, err := s.Compute(ctx, payload)
+		result, errKind, err := s.Compute(ctx, userID, payload)
 
 		if err != nil {
 			serviceErrResponse(w, r, errKind, err)
@@ -168,207 +174,449 @@ func searchPositionsHandler(s *position.Service) http.HandlerFunc {
 	}
 }
 
-func importPositionsHandler(s *position.Service) http.HandlerFunc {
+func importPositionsHandler(s *position.Service, ss *strategy.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		l := logger.FromCtx(ctx)
 		userID := getUserIDFromContext(ctx)
 
-		file, fileHeader, err := r.FormFile("file")
-		if err != nil {
-			badRequestResponse(w, r, errors.New("Unable to read file"))
-			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Unable to read file", "", "file", nil)))
+		payload, sheets, ok := parseFileImportRequest(w, r)
+		if !ok {
 			return
 		}
 
-		defer file.Close()
+		var finalResult *position.ImportResult = nil
 
-		brokerID, err := uuid.Parse(r.FormValue("broker_id"))
-		if err != nil {
-			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Broker is invalid or not supported", "", "broker_id", r.FormValue("broker_id"))))
+		for _, sheet := range sheets {
+			payload.Rows = sheet.Rows
+			payload.Sheet = sheet.Name
+
+			result, errKind, err := s.FileImport(ctx, userID, *payload)
+			if err != nil {
+				serviceErrResponse(w, r, errKind, err)
+				return
+			}
+
+			if finalResult == nil {
+				finalResult = result
+			} else {
+				finalResult.Merge(result)
+			}
+		}
+
+		if payload.Confirm && !payload.DryRun {
+			autoDetectStrategies(ctx, ss, userID, finalResult)
+		}
+
+		successResponse(w, r, http.StatusOK, "Positions imported successfully", finalResult)
+	}
+}
+
+// createImportJobHandler starts importing the file in the background, for a tradebook too large
+// to import within the request's timeout.
+func createImportJobHandler(s *importjob.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		payload, sheets, ok := parseFileImportRequest(w, r)
+		if !ok {
 			return
 		}
 
-		userBrokerAccountID, err := uuid.Parse(r.FormValue("user_broker_account_id"))
+		job, errKind, err := s.Create(ctx, userID, &importjob.Input{Payload: *payload, Sheets: sheets})
 		if err != nil {
-			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Broker Account is invalid or not supported", "", "user_broker_account_id", r.FormValue("user_broker_account_id"))))
+			serviceErrResponse(w, r, errKind, err)
 			return
 		}
 
-		var currencyCode currency.CurrencyCode
-		currencyStr := r.FormValue("currency")
+		successResponse(w, r, http.StatusAccepted, "Import started", job)
+	}
+}
+
+func getImportJobHandler(s *importjob.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		id := chi.URLParam(r, "id")
 
-		if currencyStr == "" {
-			currencyCode = "INR"
-		} else {
-			currencyCode = currency.ParseCurrencyCode(currencyStr)
+		jobID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("Invalid import job ID", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid import job ID"))
+			return
 		}
 
-		if currencyStr != "" {
-			currencyCode = currency.ParseCurrencyCode(currencyStr)
+		job, errKind, err := s.Get(ctx, userID, jobID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
 		}
 
-		riskAmount := decimal.Zero
-		riskAmountStr := r.FormValue("risk_amount")
+		successResponse(w, r, http.StatusOK, "", job)
+	}
+}
+
+func listImportBatchesHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
 
-		if riskAmountStr != "" {
-			riskAmount, err = decimal.NewFromString(riskAmountStr)
+		var userBrokerAccountID *uuid.UUID
+		if v := r.URL.Query().Get("user_broker_account_id"); v != "" {
+			id, err := uuid.Parse(v)
 			if err != nil {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("Invalid risk amount", "", "risk_amount", riskAmountStr),
-				))
+				badRequestResponse(w, r, errors.New("Invalid user broker account ID"))
 				return
 			}
+			userBrokerAccountID = &id
+		}
 
-			if riskAmount.IsNegative() {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("Risk amount cannot be negative", "", "risk_amount", riskAmountStr),
-				))
-				return
-			}
+		batches, errKind, err := s.ListImportBatches(ctx, userID, userBrokerAccountID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
 		}
 
-		var chargesCalculationMethod position.ChargesCalculationMethod
-		chargesCalculationMethodStr := r.FormValue("charges_calculation_method")
+		successResponse(w, r, http.StatusOK, "", batches)
+	}
+}
+
+func rollbackImportBatchHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		id := chi.URLParam(r, "id")
 
-		if chargesCalculationMethodStr != "" {
-			chargesCalculationMethod = position.ChargesCalculationMethod(chargesCalculationMethodStr)
+		batchID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("Invalid import batch ID", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid import batch ID"))
+			return
 		}
 
-		var manualChargeAmount decimal.Decimal
-		manualChargeAmountStr := r.FormValue("manual_charge_amount")
+		batch, errKind, err := s.RollbackImportBatch(ctx, userID, batchID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
 
-		if manualChargeAmountStr != "" {
-			manualChargeAmount, err = decimal.NewFromString(manualChargeAmountStr)
-			if err != nil {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("Invalid manual charge amount", "", "manual_charge_amount", manualChargeAmountStr),
-				))
-				return
-			}
-			if manualChargeAmount.IsNegative() {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("Manual charge amount cannot be negative", "", "manual_charge_amount", manualChargeAmountStr),
-				))
-				return
-			}
+		successResponse(w, r, http.StatusOK, "Import rolled back successfully", batch)
+	}
+}
+
+// detectImportFileBrokerHandler returns the brokers whose tradebook the uploaded file matches,
+// so that the broker can be selected for the user. Only the first sheet with rows is looked at.
+func detectImportFileBrokerHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Unable to read file", "", "file", nil)))
+			return
 		}
 
-		confirm := false
-		confirmStr := r.FormValue("confirm")
+		defer file.Close()
 
-		if confirmStr != "" {
-			confirm, err = strconv.ParseBool(confirmStr)
-			if err != nil {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("", "Confirm must be a boolean", "confirm", confirmStr),
-				))
-				return
+		sheets, ok := readImportFileSheets(w, r, file, fileHeader)
+		if !ok {
+			return
+		}
+
+		var rows [][]string
+		for _, sheet := range sheets {
+			if len(sheet.Rows) > 0 {
+				rows = sheet.Rows
+				break
 			}
 		}
 
-		force := false
-		forceStr := r.FormValue("force")
+		result, errKind, err := s.DetectBroker(ctx, rows)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
 
-		if forceStr != "" {
-			force, err = strconv.ParseBool(forceStr)
-			if err != nil {
-				invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
-					apires.NewApiError("", "Force must be a boolean", "confirm", confirmStr),
-				))
-				return
-			}
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+// parseFileImportRequest returns the payload of a file import and the rows of each sheet of its file,
+// or writes the error response and returns false. A CSV file has one sheet without a name.
+func parseFileImportRequest(w http.ResponseWriter, r *http.Request) (*position.FileImportPayload, []importjob.Sheet, bool) {
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		badRequestResponse(w, r, errors.New("Unable to read file"))
+		invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Unable to read file", "", "file", nil)))
+		return nil, nil, false
+	}
+
+	defer file.Close()
+
+	// Without a broker, it is detected from the file.
+	brokerID := uuid.Nil
+	if brokerIDStr := r.FormValue("broker_id"); brokerIDStr != "" {
+		brokerID, err = uuid.Parse(brokerIDStr)
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Broker is invalid or not supported", "", "broker_id", brokerIDStr)))
+			return nil, nil, false
 		}
+	}
 
-		payload := position.FileImportPayload{
-			BrokerID:                 brokerID,
-			UserBrokerAccountID:      userBrokerAccountID,
-			CurrencyCode:             currencyCode,
-			RiskAmount:               riskAmount,
-			ChargesCalculationMethod: chargesCalculationMethod,
-			ManualChargeAmount:       manualChargeAmount,
-			Confirm:                  confirm,
-			Force:                    force,
+	userBrokerAccountID, err := uuid.Parse(r.FormValue("user_broker_account_id"))
+	if err != nil {
+		invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(apires.NewApiError("Broker Account is invalid or not supported", "", "user_broker_account_id", r.FormValue("user_broker_account_id"))))
+		return nil, nil, false
+	}
+
+	var currencyCode currency.CurrencyCode
+	currencyStr := r.FormValue("currency")
+
+	if currencyStr == "" {
+		currencyCode = "INR"
+	} else {
+		currencyCode = currency.ParseCurrencyCode(currencyStr)
+	}
+
+	if currencyStr != "" {
+		currencyCode = currency.ParseCurrencyCode(currencyStr)
+	}
+
+	riskAmount := decimal.Zero
+	riskAmountStr := r.FormValue("risk_amount")
+
+	if riskAmountStr != "" {
+		riskAmount, err = decimal.NewFromString(riskAmountStr)
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("Invalid risk amount", "", "risk_amount", riskAmountStr),
+			))
+			return nil, nil, false
 		}
 
-		var errKind service.Error
-		var finalResult *position.ImportResult = nil
+		if riskAmount.IsNegative() {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("Risk amount cannot be negative", "", "risk_amount", riskAmountStr),
+			))
+			return nil, nil, false
+		}
+	}
 
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	var chargesCalculationMethod position.ChargesCalculationMethod
+	chargesCalculationMethodStr := r.FormValue("charges_calculation_method")
 
-		// Save it temporarily (excelize works with file paths or io.Reader)
-		tempFile, err := os.CreateTemp("", "upload-*"+ext)
+	if chargesCalculationMethodStr != "" {
+		chargesCalculationMethod = position.ChargesCalculationMethod(chargesCalculationMethodStr)
+	}
+
+	var manualChargeAmount decimal.Decimal
+	manualChargeAmountStr := r.FormValue("manual_charge_amount")
+
+	if manualChargeAmountStr != "" {
+		manualChargeAmount, err = decimal.NewFromString(manualChargeAmountStr)
 		if err != nil {
-			internalServerErrorResponse(w, r, fmt.Errorf("failed to create temp file: %w", err))
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("Invalid manual charge amount", "", "manual_charge_amount", manualChargeAmountStr),
+			))
+			return nil, nil, false
 		}
+		if manualChargeAmount.IsNegative() {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("Manual charge amount cannot be negative", "", "manual_charge_amount", manualChargeAmountStr),
+			))
+			return nil, nil, false
+		}
+	}
 
-		defer os.Remove(tempFile.Name()) // clean up
+	confirm := false
+	confirmStr := r.FormValue("confirm")
 
-		io.Copy(tempFile, file) // copy the uploaded file to the temp file
-		tempFile.Close()
+	if confirmStr != "" {
+		confirm, err = strconv.ParseBool(confirmStr)
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("", "Confirm must be a boolean", "confirm", confirmStr),
+			))
+			return nil, nil, false
+		}
+	}
 
-		switch ext {
-		case ".xlsx":
-			excelFile, err := excelize.OpenFile(tempFile.Name())
-			if err != nil {
-				l.Warnw("Unable to read excel file", "error", err)
-				badRequestResponse(w, r, fmt.Errorf("Unable to read excel file: %v. Please ensure the file is a valid .xlsx Excel file.", err))
-				return
-			}
+	force := false
+	forceStr := r.FormValue("force")
+
+	if forceStr != "" {
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("", "Force must be a boolean", "confirm", confirmStr),
+			))
+			return nil, nil, false
+		}
+	}
+
+	dryRun := false
+	dryRunStr := r.FormValue("dry_run")
+
+	if dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("", "Dry run must be a boolean", "dry_run", dryRunStr),
+			))
+			return nil, nil, false
+		}
+	}
+
+	var fileMapping *broker_integration.FileMapping
+	fileMappingStr := r.FormValue("file_mapping")
+
+	if fileMappingStr != "" {
+		fileMapping = &broker_integration.FileMapping{}
+		if err := json.Unmarshal([]byte(fileMappingStr), fileMapping); err != nil {
+			invalidInputResponse(w, r, service.NewInputValidationErrorsWithError(
+				apires.NewApiError("Invalid file mapping", "", "file_mapping", fileMappingStr),
+			))
+			return nil, nil, false
+		}
+	}
 
-			defer excelFile.Close()
+	payload := &position.FileImportPayload{
+		BrokerID:                 brokerID,
+		UserBrokerAccountID:      userBrokerAccountID,
+		CurrencyCode:             currencyCode,
+		RiskAmount:               riskAmount,
+		ChargesCalculationMethod: chargesCalculationMethod,
+		ManualChargeAmount:       manualChargeAmount,
+		Confirm:                  confirm,
+		Force:                    force,
+		FileMapping:              fileMapping,
+		DryRun:                   dryRun,
+		FileName:                 fileHeader.Filename,
+	}
+
+	sheets, ok := readImportFileSheets(w, r, file, fileHeader)
+	if !ok {
+		return nil, nil, false
+	}
 
-			sheets := excelFile.GetSheetList()
+	return payload, sheets, true
+}
 
-			for _, sheet := range sheets {
-				rows, err := excelFile.GetRows(sheet)
-				if err != nil {
-					internalServerErrorResponse(w, r, fmt.Errorf("failed to read rows from excel file: %w", err))
-					return
-				}
+// readImportFileSheets returns the rows of each sheet of an uploaded .xlsx or .csv file,
+// or writes the error response and returns false.
+func readImportFileSheets(w http.ResponseWriter, r *http.Request, file multipart.File, fileHeader *multipart.FileHeader) ([]importjob.Sheet, bool) {
+	l := logger.FromCtx(r.Context())
 
-				payload.Rows = rows
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
 
-				result, errKind, err := s.FileImport(ctx, userID, payload)
-				if err != nil {
-					serviceErrResponse(w, r, errKind, err)
-					return
-				}
+	// Save it temporarily (excelize works with file paths or io.Reader)
+	tempFile, err := os.CreateTemp("", "upload-*"+ext)
+	if err != nil {
+		internalServerErrorResponse(w, r, fmt.Errorf("failed to create temp file: %w", err))
+		return nil, false
+	}
 
-				if finalResult == nil {
-					finalResult = result
-				} else {
-					finalResult.Merge(result)
-				}
-			}
-		case ".csv":
-			f, err := os.Open(tempFile.Name())
+	defer os.Remove(tempFile.Name()) // clean up
+
+	io.Copy(tempFile, file) // copy the uploaded file to the temp file
+	tempFile.Close()
+
+	sheets := []importjob.Sheet{}
+
+	switch ext {
+	case ".xlsx":
+		excelFile, err := excelize.OpenFile(tempFile.Name())
+		if err != nil {
+			l.Warnw("Unable to read excel file", "error", err)
+			badRequestResponse(w, r, fmt.Errorf("Unable to read excel file: %v. Please ensure the file is a valid .xlsx Excel file.", err))
+			return nil, false
+		}
+
+		defer excelFile.Close()
+
+		for _, sheet := range excelFile.GetSheetList() {
+			rows, err := excelFile.GetRows(sheet)
 			if err != nil {
-				internalServerErrorResponse(w, r, fmt.Errorf("failed to open csv file: %w", err))
-				return
+				internalServerErrorResponse(w, r, fmt.Errorf("failed to read rows from excel file: %w", err))
+				return nil, false
 			}
 
-			defer f.Close()
+			sheets = append(sheets, importjob.Sheet{Name: sheet, Rows: rows})
+		}
+	case ".csv":
+		f, err := os.Open(tempFile.Name())
+		if err != nil {
+			internalServerErrorResponse(w, r, fmt.Errorf("failed to open csv file: %w", err))
+			return nil, false
+		}
 
-			csvReader := csv.NewReader(f)
-			rows, err := csvReader.ReadAll()
-			if err != nil {
-				badRequestResponse(w, r, fmt.Errorf("Unable to read csv file: %v. Please ensure the file is a valid CSV file.", err))
+		defer f.Close()
+
+		csvReader := csv.NewReader(f)
+		// Tradebooks can have title rows above the header, with fewer fields than the trades.
+		csvReader.FieldsPerRecord = -1
+		rows, err := csvReader.ReadAll()
+		if err != nil {
+			badRequestResponse(w, r, fmt.Errorf("Unable to read csv file: %v. Please ensure the file is a valid CSV file.", err))
+			return nil, false
+		}
+
+		sheets = append(sheets, importjob.Sheet{Rows: rows})
+	default:
+		badRequestResponse(w, r, fmt.Errorf("Unsupported file type: %s. Only .xlsx and .csv files are supported.", ext))
+		return nil, false
+	}
+
+	return sheets, true
+}
+
+func computePositionExcursionHandler(s *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+		id := chi.URLParam(r, "id")
+
+		positionID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("Invalid position ID", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid position ID"))
+			return
+		}
+
+		// The candles file is optional. Without it, the candles are fetched from the price store.
+		var rows [][]string
+
+		file, fileHeader, err := r.FormFile("file")
+		if err == nil {
+			defer file.Close()
+
+			ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+			if ext != ".csv" {
+				badRequestResponse(w, r, fmt.Errorf("Unsupported file type: %s. Only .csv files are supported.", ext))
 				return
 			}
 
-			payload.Rows = rows
-
-			finalResult, errKind, err = s.FileImport(ctx, userID, payload)
+			rows, err = csv.NewReader(file).ReadAll()
 			if err != nil {
-				serviceErrResponse(w, r, errKind, err)
+				badRequestResponse(w, r, fmt.Errorf("Unable to read csv file: %v. Please ensure the file is a valid CSV file.", err))
 				return
 			}
-		default:
-			badRequestResponse(w, r, fmt.Errorf("Unsupported file type: %s. Only .xlsx and .csv files are supported.", ext))
+		} else if !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+			badRequestResponse(w, r, errors.New("Unable to read file"))
+			return
 		}
 
-		successResponse(w, r, http.StatusOK, "Positions imported successfully", finalResult)
+		position, errKind, err := s.ComputeExcursion(ctx, userID, positionID, tz, rows)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Excursion computed successfully", map[string]any{"position": position})
 	}
 }
 
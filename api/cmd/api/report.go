@@ -1,10 +1,18 @@
 package main
 
 import (
+	"arthveda/internal/domain/subscription"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/report"
+	"arthveda/internal/service"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/mudgallabs/tantra/httpx"
+	"github.com/xuri/excelize/v2"
 )
 
 func getAnalyticsTagsHandler(service *report.Service) http.HandlerFunc {
@@ -14,7 +22,13 @@ func getAnalyticsTagsHandler(service *report.Service) http.HandlerFunc {
 		tz := getUserTimezoneFromCtx(ctx)
 		enforcer := getPlanEnforcerFromCtx(ctx)
 
-		result, errKind, err := service.GetTags(r.Context(), userID, tz, enforcer)
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetTags(r.Context(), userID, tz, enforcer, segment)
 		if err != nil {
 			httpx.ServiceErrResponse(w, r, errKind, err)
 			return
@@ -31,7 +45,13 @@ func getAnalyticsTimeframesHandler(service *report.Service) http.HandlerFunc {
 		tz := getUserTimezoneFromCtx(ctx)
 		enforcer := getPlanEnforcerFromCtx(ctx)
 
-		result, errKind, err := service.GetTimeframes(r.Context(), userID, tz, enforcer)
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetTimeframes(r.Context(), userID, tz, enforcer, segment)
 		if err != nil {
 			httpx.ServiceErrResponse(w, r, errKind, err)
 			return
@@ -48,7 +68,13 @@ func getAnalyticsSymbolsHandler(service *report.Service) http.HandlerFunc {
 		tz := getUserTimezoneFromCtx(ctx)
 		enforcer := getPlanEnforcerFromCtx(ctx)
 
-		result, errKind, err := service.GetSymbols(r.Context(), userID, tz, enforcer)
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetSymbols(r.Context(), userID, tz, enforcer, segment)
 		if err != nil {
 			httpx.ServiceErrResponse(w, r, errKind, err)
 			return
@@ -65,7 +91,59 @@ func getAnalyticsInstrumentsHandler(service *report.Service) http.HandlerFunc {
 		tz := getUserTimezoneFromCtx(ctx)
 		enforcer := getPlanEnforcerFromCtx(ctx)
 
-		result, errKind, err := service.GetInstruments(r.Context(), userID, tz, enforcer)
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetInstruments(r.Context(), userID, tz, enforcer, segment)
+		if err != nil {
+			httpx.ServiceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+func getAnalyticsStrategiesHandler(service *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+		enforcer := getPlanEnforcerFromCtx(ctx)
+
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetStrategies(r.Context(), userID, tz, enforcer, segment)
+		if err != nil {
+			httpx.ServiceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+func getAnalyticsDerivativesHandler(service *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+		enforcer := getPlanEnforcerFromCtx(ctx)
+
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetDerivatives(r.Context(), userID, tz, enforcer, segment)
 		if err != nil {
 			httpx.ServiceErrResponse(w, r, errKind, err)
 			return
@@ -74,3 +152,140 @@ func getAnalyticsInstrumentsHandler(service *report.Service) http.HandlerFunc {
 		successResponse(w, r, http.StatusOK, "", result)
 	}
 }
+
+func getAnalyticsChargesHandler(service *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+		enforcer := getPlanEnforcerFromCtx(ctx)
+
+		segment, err := getSegmentFromQuery(r)
+		if err != nil {
+			badRequestResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := service.GetCharges(r.Context(), userID, tz, enforcer, segment)
+		if err != nil {
+			httpx.ServiceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+// getSegmentFromQuery returns the segment the report is filtered by, or nil if it isn't.
+func getSegmentFromQuery(r *http.Request) (*types.Segment, error) {
+	segment := types.Segment(r.URL.Query().Get("segment"))
+	if segment == "" {
+		return nil, nil
+	}
+
+	if !segment.IsValid() {
+		return nil, position.ErrInvalidSegment
+	}
+
+	return &segment, nil
+}
+
+func getTaxPnLHandler(service *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, ok := getTaxPnL(w, r, service)
+		if !ok {
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+func exportTaxPnLHandler(service *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, ok := getTaxPnL(w, r, service)
+		if !ok {
+			return
+		}
+
+		f := excelize.NewFile()
+		report.WriteTaxPnLToSheets(f, result)
+
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=tax-pnl-%s.xlsx", result.FinancialYear))
+		w.Header().Set("Content-Length", strconv.Itoa(len(buf.Bytes())))
+
+		w.Write(buf.Bytes())
+	}
+}
+
+// getTaxPnL returns the tax P&L of the financial year in the "financial_year" query param,
+// or writes the error response and returns false.
+func getTaxPnL(w http.ResponseWriter, r *http.Request, s *report.Service) (*report.TaxPnLResult, bool) {
+	ctx := r.Context()
+	userID := getUserIDFromContext(ctx)
+	tz := getUserTimezoneFromCtx(ctx)
+
+	fy, ok := getFinancialYearFromQuery(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	result, errKind, err := s.GetTaxPnL(ctx, userID, tz, fy)
+	if err != nil {
+		httpx.ServiceErrResponse(w, r, errKind, err)
+		return nil, false
+	}
+
+	return result, true
+}
+
+func getTurnoverHandler(s *report.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+
+		fy, ok := getFinancialYearFromQuery(w, r)
+		if !ok {
+			return
+		}
+
+		result, errKind, err := s.GetTurnover(ctx, userID, tz, fy)
+		if err != nil {
+			httpx.ServiceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+// getFinancialYearFromQuery returns the financial year in the "financial_year" query param,
+// or writes the error response and returns false. A plan that can't access all the positions
+// can only get the financial years of the last year.
+func getFinancialYearFromQuery(w http.ResponseWriter, r *http.Request) (report.FinancialYear, bool) {
+	ctx := r.Context()
+	tz := getUserTimezoneFromCtx(ctx)
+	enforcer := getPlanEnforcerFromCtx(ctx)
+
+	fy, err := report.ParseFinancialYear(r.URL.Query().Get("financial_year"))
+	if err != nil {
+		badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	from, _ := fy.Range(tz)
+	if !enforcer.CanAccessAllPositions() && from.Before(time.Now().In(tz).AddDate(-1, 0, 0)) {
+		serviceErrResponse(w, r, service.ErrPlanLimitExceeded, subscription.NewPlanLimitError(subscription.FeatureTaxReports))
+		return 0, false
+	}
+
+	return fy, true
+}
@@ -105,14 +105,50 @@ func initRouter(a *app) http.Handler {
 			r.Get("/{id}", getPositionHandler(a.service.PositionService))
 			r.Patch("/{id}", updatePositionHandler(a.service.PositionService))
 			r.Delete("/{id}", deletePositionHandler(a.service.PositionService))
+			r.Post("/{id}/excursion", computePositionExcursionHandler(a.service.PositionService))
 
 			r.Post("/compute", computePositionHandler(a.service.PositionService))
 			r.Post("/search", searchPositionsHandler(a.service.PositionService))
-			r.Post("/import", importPositionsHandler(a.service.PositionService))
+			r.Post("/import/detect", detectImportFileBrokerHandler(a.service.PositionService))
+			r.Post("/import", importPositionsHandler(a.service.PositionService, a.service.StrategyService))
+			r.Post("/import/jobs", createImportJobHandler(a.service.ImportJobService))
+			r.Get("/import/jobs/{id}", getImportJobHandler(a.service.ImportJobService))
+			r.Get("/import/batches", listImportBatchesHandler(a.service.PositionService))
+			r.Post("/import/batches/{id}/rollback", rollbackImportBatchHandler(a.service.PositionService))
 
 			r.Post("/export", exportPositionsHandler(a.service.PositionService))
 		})
 
+		r.Route("/cash-flows", func(r chi.Router) {
+			r.Use(authMiddleware)
+
+			r.Post("/", createCashFlowHandler(a.service.PositionService))
+			r.Patch("/{id}", updateCashFlowHandler(a.service.PositionService))
+			r.Delete("/{id}", deleteCashFlowHandler(a.service.PositionService))
+
+			r.Post("/search", searchCashFlowsHandler(a.service.PositionService))
+		})
+
+		r.Route("/charge-reconciliations", func(r chi.Router) {
+			r.Use(authMiddleware)
+
+			r.Post("/", reconcileChargesHandler(a.service.PositionService))
+			r.Post("/{id}/apply", applyChargeReconciliationHandler(a.service.PositionService))
+			r.Post("/search", searchChargeReconciliationsHandler(a.service.PositionService))
+		})
+
+		r.Route("/strategies", func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Use(planEnforcerMiddleware(a.service.SubscriptionService))
+
+			r.Post("/", createStrategyHandler(a.service.StrategyService))
+			r.Get("/{id}", getStrategyHandler(a.service.StrategyService))
+			r.Patch("/{id}", updateStrategyHandler(a.service.StrategyService))
+			r.Delete("/{id}", deleteStrategyHandler(a.service.StrategyService))
+
+			r.Post("/search", searchStrategiesHandler(a.service.StrategyService))
+		})
+
 		r.Route("/symbols", func(r chi.Router) {
 			r.Use(authMiddleware)
 
@@ -148,6 +184,7 @@ func initRouter(a *app) http.Handler {
 			r.Post("/onboarded", markAsOnboardedHandler(a.service.UserProfileService))
 			r.Get("/can_update_home_currency", canUpdateHomeCurrency(a.service.UserProfileService))
 			r.Patch("/home_currency", updateHomeCurrency(a.service.UserProfileService))
+			r.Patch("/lot_matching_method", updateLotMatchingMethod(a.db, a.service.UserProfileService, a.service.PositionService))
 		})
 
 		r.Route("/subscriptions", func(r chi.Router) {
@@ -165,10 +202,13 @@ func initRouter(a *app) http.Handler {
 			r.Post("/", createUserBrokerAccountHandler(a.service.UserBrokerAccountService))
 			r.Get("/", listUserBrokerAccountsHandler(a.service.UserBrokerAccountService))
 			r.Put("/{id}", updateUserBrokerAccountHandler(a.service.UserBrokerAccountService))
+			r.Put("/{id}/brokerage-plan", updateUserBrokerAccountBrokeragePlanHandler(a.service.UserBrokerAccountService))
+			r.Put("/{id}/fee-model", updateUserBrokerAccountFeeModelHandler(a.service.UserBrokerAccountService))
+			r.Put("/{id}/file-mapping", updateUserBrokerAccountFileMappingHandler(a.service.UserBrokerAccountService))
 			r.Delete("/{id}", deleteUserBrokerAccountHandler(a.service.UserBrokerAccountService))
 			r.Post("/{id}/connect", connectUserBrokerAccountHandler(a.service.UserBrokerAccountService))
 			r.Post("/{id}/disconnect", disconnectUserBrokerAccountHandler(a.service.UserBrokerAccountService))
-			r.Post("/{id}/sync", syncUserBrokerAccountHandler(a.service.UserBrokerAccountService, a.service.PositionService))
+			r.Post("/{id}/sync", syncUserBrokerAccountHandler(a.service.UserBrokerAccountService, a.service.PositionService, a.service.StrategyService))
 		})
 
 		r.Route("/webhooks", func(r chi.Router) {
@@ -183,6 +223,12 @@ func initRouter(a *app) http.Handler {
 			r.Get("/timeframes", getAnalyticsTimeframesHandler(a.service.ReportService))
 			r.Get("/symbols", getAnalyticsSymbolsHandler(a.service.ReportService))
 			r.Get("/instruments", getAnalyticsInstrumentsHandler(a.service.ReportService))
+			r.Get("/strategies", getAnalyticsStrategiesHandler(a.service.ReportService))
+			r.Get("/derivatives", getAnalyticsDerivativesHandler(a.service.ReportService))
+			r.Get("/charges", getAnalyticsChargesHandler(a.service.ReportService))
+			r.Get("/tax-pnl", getTaxPnLHandler(a.service.ReportService))
+			r.Get("/tax-pnl/export", exportTaxPnLHandler(a.service.ReportService))
+			r.Get("/turnover", getTurnoverHandler(a.service.ReportService))
 		})
 
 		r.Route("/insights", func(r chi.Router) {
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/strategy"
+	"arthveda/internal/logger"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func createStrategyHandler(s *strategy.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		var payload strategy.CreatePayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		strategy, errKind, err := s.Create(ctx, userID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusCreated, "Strategy created successfully", map[string]any{"strategy": strategy})
+	}
+}
+
+func getStrategyHandler(s *strategy.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		strategyID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid strategy ID"))
+			return
+		}
+
+		strategy, errKind, err := s.Get(ctx, userID, strategyID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", map[string]any{"strategy": strategy})
+	}
+}
+
+func updateStrategyHandler(s *strategy.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		strategyID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid strategy ID"))
+			return
+		}
+
+		var payload strategy.UpdatePayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		strategy, errKind, err := s.Update(ctx, userID, strategyID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Strategy updated successfully", map[string]any{"strategy": strategy})
+	}
+}
+
+func deleteStrategyHandler(s *strategy.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		strategyID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			badRequestResponse(w, r, errors.New("Invalid strategy ID"))
+			return
+		}
+
+		errKind, err := s.Delete(ctx, userID, strategyID)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Strategy deleted successfully", nil)
+	}
+}
+
+func searchStrategiesHandler(s *strategy.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+		tz := getUserTimezoneFromCtx(ctx)
+
+		var payload strategy.SearchPayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		result, errKind, err := s.Search(ctx, userID, tz, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "", result)
+	}
+}
+
+// autoDetectStrategies groups the newly imported option positions into strategies.
+// The import has already succeeded, so a failure here is only logged.
+func autoDetectStrategies(ctx context.Context, s *strategy.Service, userID uuid.UUID, result *position.ImportResult) {
+	if result == nil {
+		return
+	}
+
+	imported := []*position.Position{}
+	for _, p := range result.Positions {
+		// Duplicates were either skipped or were already in Arthveda.
+		if !p.IsDuplicate {
+			imported = append(imported, p)
+		}
+	}
+
+	_, _, err := s.AutoDetect(ctx, userID, imported)
+	if err != nil {
+		logger.FromCtx(ctx).Errorw("failed to auto detect strategies", "error", err.Error())
+	}
+}
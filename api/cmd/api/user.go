@@ -1,8 +1,14 @@
 package main
 
 import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/userprofile"
+	"arthveda/internal/service"
+	"context"
 	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func getMeHandler(s *userprofile.Service) http.HandlerFunc {
@@ -70,3 +76,43 @@ func updateHomeCurrency(s *userprofile.Service) http.HandlerFunc {
 		successResponse(w, r, http.StatusOK, "Home currency updated successfully.", nil)
 	}
 }
+
+func updateLotMatchingMethod(db *pgxpool.Pool, s *userprofile.Service, positionService *position.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		userID := getUserIDFromContext(ctx)
+
+		var payload userprofile.UpdateLotMatchingMethodPayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		// The method is only updated if all the positions following the user's default
+		// are recomputed with it.
+		errKind := service.ErrInternalServerError
+		err := dbx.WithTx(ctx, db, func(ctx context.Context) error {
+			changed, svcErr, err := s.UpdateLotMatchingMethod(ctx, userID, payload)
+			if err != nil {
+				errKind = svcErr
+				return err
+			}
+
+			if changed {
+				_, svcErr, err = positionService.RecomputeForUser(ctx, userID)
+				if err != nil {
+					errKind = svcErr
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Lot matching method updated successfully.", nil)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"arthveda/internal/env"
 	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/strategy"
 	"arthveda/internal/feature/userbrokeraccount"
 	"arthveda/internal/logger"
 	"errors"
@@ -80,6 +81,96 @@ func updateUserBrokerAccountHandler(s *userbrokeraccount.Service) http.HandlerFu
 	}
 }
 
+func updateUserBrokerAccountBrokeragePlanHandler(s *userbrokeraccount.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		id := chi.URLParam(r, "id")
+
+		accountID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("invalid user broker account id", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid Broker Account ID"))
+			return
+		}
+
+		var payload userbrokeraccount.UpdateBrokeragePlanPayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		account, errKind, err := s.UpdateBrokeragePlan(ctx, userID, accountID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Brokerage plan updated successfully", account)
+	}
+}
+
+func updateUserBrokerAccountFeeModelHandler(s *userbrokeraccount.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		id := chi.URLParam(r, "id")
+
+		accountID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("invalid user broker account id", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid Broker Account ID"))
+			return
+		}
+
+		var payload userbrokeraccount.UpdateFeeModelPayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		account, errKind, err := s.UpdateFeeModel(ctx, userID, accountID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "Fee model updated successfully", account)
+	}
+}
+
+func updateUserBrokerAccountFileMappingHandler(s *userbrokeraccount.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromCtx(ctx)
+		userID := getUserIDFromContext(ctx)
+		id := chi.URLParam(r, "id")
+
+		accountID, err := uuid.Parse(id)
+		if err != nil {
+			l.Warnw("invalid user broker account id", "id", id, "error", err.Error())
+			badRequestResponse(w, r, errors.New("Invalid Broker Account ID"))
+			return
+		}
+
+		var payload userbrokeraccount.UpdateFileMappingPayload
+		if err := decodeJSONRequest(&payload, r); err != nil {
+			malformedJSONResponse(w, r, err)
+			return
+		}
+
+		account, errKind, err := s.UpdateFileMapping(ctx, userID, accountID, payload)
+		if err != nil {
+			serviceErrResponse(w, r, errKind, err)
+			return
+		}
+
+		successResponse(w, r, http.StatusOK, "File mapping updated successfully", account)
+	}
+}
+
 func deleteUserBrokerAccountHandler(s *userbrokeraccount.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -158,7 +249,7 @@ func disconnectUserBrokerAccountHandler(s *userbrokeraccount.Service) http.Handl
 	}
 }
 
-func syncUserBrokerAccountHandler(s *userbrokeraccount.Service, ps *position.Service) http.HandlerFunc {
+func syncUserBrokerAccountHandler(s *userbrokeraccount.Service, ps *position.Service, ss *strategy.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		l := logger.FromCtx(ctx)
@@ -212,6 +303,8 @@ func syncUserBrokerAccountHandler(s *userbrokeraccount.Service, ps *position.Ser
 			return
 		}
 
+		autoDetectStrategies(ctx, ss, userID, importResult)
+
 		result := finalResult{
 			SyncResult:   syncResult,
 			ImportResult: importResult,
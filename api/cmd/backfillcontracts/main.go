@@ -0,0 +1,69 @@
+package main
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/env"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/tag"
+	"arthveda/internal/feature/trade"
+	"context"
+	"log"
+)
+
+// Backfills the contract (underlying, expiry, strike and right) of the future and option
+// positions that were created before it was stored in the `position` table.
+func main() {
+	ctx := context.Background()
+
+	env.Init("./.env")
+
+	db, err := dbx.Init()
+	if err != nil {
+		log.Fatalf("unable to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	tradeRepository := trade.NewRepository(db)
+	tagRepository := tag.NewRepository(db)
+	corporateActionRepository := corporateaction.NewRepository(db)
+	cashFlowRepository := cashflow.NewRepository(db)
+	positionRepository := position.NewRepository(db, tradeRepository, tagRepository, corporateActionRepository, cashFlowRepository)
+
+	log.Println("Starting backfill of position contracts...")
+
+	positions := []*position.Position{}
+	for _, instrument := range []types.Instrument{types.InstrumentFuture, types.InstrumentOption} {
+		found, _, err := positionRepository.Search(ctx, position.SearchPayload{
+			Filters: position.SearchFilter{Instrument: &instrument},
+		}, false, false)
+		if err != nil {
+			log.Fatalf("unable to fetch positions: %v", err)
+		}
+
+		positions = append(positions, found...)
+	}
+
+	log.Printf("Found %d positions to backfill\n", len(positions))
+
+	backfilledCount := 0
+	for _, pos := range positions {
+		position.ApplyContractToPosition(pos)
+
+		if pos.Underlying == nil {
+			log.Printf("Skipping position %s, unable to parse symbol %s\n", pos.ID, pos.Symbol)
+			continue
+		}
+
+		err = positionRepository.Update(ctx, pos)
+		if err != nil {
+			log.Fatalf("unable to update position %s: %v", pos.ID, err)
+		}
+
+		backfilledCount++
+	}
+
+	log.Printf("✅ Backfill complete. %d positions backfilled.\n", backfilledCount)
+}
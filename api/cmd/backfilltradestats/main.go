@@ -0,0 +1,62 @@
+package main
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/env"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/tag"
+	"arthveda/internal/feature/trade"
+	"context"
+	"log"
+)
+
+// Backfills the realised stats (PnL, ROI, R and matched lots) of the trades that were
+// created before they were stored in the `trade` table.
+func main() {
+	ctx := context.Background()
+
+	env.Init("./.env")
+
+	db, err := dbx.Init()
+	if err != nil {
+		log.Fatalf("unable to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	tradeRepository := trade.NewRepository(db)
+	tagRepository := tag.NewRepository(db)
+	corporateActionRepository := corporateaction.NewRepository(db)
+	cashFlowRepository := cashflow.NewRepository(db)
+	positionRepository := position.NewRepository(db, tradeRepository, tagRepository, corporateActionRepository, cashFlowRepository)
+
+	log.Println("Starting backfill of trade realised stats...")
+
+	positions, _, err := positionRepository.Search(ctx, position.SearchPayload{}, true, false)
+	if err != nil {
+		log.Fatalf("unable to fetch positions: %v", err)
+	}
+
+	log.Printf("Found %d positions to backfill\n", len(positions))
+
+	backfilledCount := 0
+	for _, pos := range positions {
+		computeResult, err := position.Compute(pos.ComputePayload())
+		if err != nil {
+			log.Printf("Skipping position %s, unable to compute: %v\n", pos.ID, err)
+			continue
+		}
+
+		position.ApplyComputeResultToPosition(pos, computeResult)
+
+		err = tradeRepository.UpdateRealisedStats(ctx, pos.Trades)
+		if err != nil {
+			log.Fatalf("unable to update trades of position %s: %v", pos.ID, err)
+		}
+
+		backfilledCount++
+	}
+
+	log.Printf("✅ Backfill complete. %d positions backfilled.\n", backfilledCount)
+}
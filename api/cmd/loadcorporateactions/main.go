@@ -0,0 +1,129 @@
+package main
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/env"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/tag"
+	"arthveda/internal/feature/trade"
+	"context"
+	"encoding/csv"
+	"log"
+	"os"
+)
+
+// The CSV file has a header row with "symbol", "kind" (split or bonus), "ex_date" (YYYY-MM-DD),
+// "new_shares" and "held_shares" columns. Another file can be passed as the first argument.
+const inputFile = "corporate_actions.csv"
+
+// Loads the splits and bonus issues of stocks into the `corporate_action` table and recomputes
+// the equity positions of those stocks so that their stats account for them.
+func main() {
+	ctx := context.Background()
+
+	env.Init("./.env")
+
+	file := inputFile
+	if len(os.Args) > 1 {
+		file = os.Args[1]
+	}
+
+	actions, err := readCorporateActions(file)
+	if err != nil {
+		log.Fatalf("unable to read corporate actions: %v", err)
+	}
+
+	db, err := dbx.Init()
+	if err != nil {
+		log.Fatalf("unable to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	tradeRepository := trade.NewRepository(db)
+	tagRepository := tag.NewRepository(db)
+	corporateActionRepository := corporateaction.NewRepository(db)
+	cashFlowRepository := cashflow.NewRepository(db)
+	positionRepository := position.NewRepository(db, tradeRepository, tagRepository, corporateActionRepository, cashFlowRepository)
+
+	err = corporateActionRepository.Upsert(ctx, actions)
+	if err != nil {
+		log.Fatalf("unable to save corporate actions: %v", err)
+	}
+
+	log.Printf("Loaded %d corporate actions\n", len(actions))
+
+	recomputedCount := 0
+	for symbol := range corporateaction.BySymbol(actions) {
+		equity := types.InstrumentEquity
+		positions, _, err := positionRepository.Search(ctx, position.SearchPayload{
+			Filters: position.SearchFilter{Symbol: &symbol, Instrument: &equity},
+		}, true, false)
+		if err != nil {
+			log.Fatalf("unable to fetch positions of %s: %v", symbol, err)
+		}
+
+		for _, pos := range positions {
+			// The symbol filter matches the symbols that start with it.
+			if pos.Symbol != symbol {
+				continue
+			}
+
+			// A trade imported before the split or bonus was known can reverse the position, as an import does.
+			reversedPositions, err := position.SplitReversedPosition(pos)
+			if err != nil {
+				log.Fatalf("unable to split position %s: %v", pos.ID, err)
+			}
+
+			if len(reversedPositions) > 0 {
+				err = dbx.WithTx(ctx, db, func(ctx context.Context) error {
+					return position.SaveSplitPosition(ctx, positionRepository, tradeRepository, pos, reversedPositions)
+				})
+				if err != nil {
+					log.Fatalf("unable to save split position %s: %v", pos.ID, err)
+				}
+
+				log.Printf("Split position %s into %d more positions\n", pos.ID, len(reversedPositions))
+			}
+
+			computeResult, err := position.Compute(pos.ComputePayload())
+			if err != nil {
+				log.Printf("Skipping position %s, unable to compute: %v\n", pos.ID, err)
+				continue
+			}
+
+			position.ApplyComputeResultToPosition(pos, computeResult)
+
+			err = positionRepository.Update(ctx, pos)
+			if err != nil {
+				log.Fatalf("unable to update position %s: %v", pos.ID, err)
+			}
+
+			err = tradeRepository.UpdateRealisedStats(ctx, pos.Trades)
+			if err != nil {
+				log.Fatalf("unable to update trades of position %s: %v", pos.ID, err)
+			}
+
+			recomputedCount++
+		}
+	}
+
+	log.Printf("✅ Done. %d positions recomputed.\n", recomputedCount)
+}
+
+func readCorporateActions(file string) ([]*corporateaction.CorporateAction, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return corporateaction.ParseRows(rows)
+}
@@ -266,6 +266,7 @@ type Exchange string
 const (
 	ExchangeNSE Exchange = "nse"
 	ExchangeBSE Exchange = "bse"
+	ExchangeMCX Exchange = "mcx"
 	// add more as needed
 )
 
@@ -276,6 +277,7 @@ const AsiaKolkataTZ ExchangeTZ = "Asia/Kolkata"
 var exchangeTZByExchange = map[Exchange]ExchangeTZ{
 	ExchangeNSE: AsiaKolkataTZ,
 	ExchangeBSE: AsiaKolkataTZ,
+	ExchangeMCX: AsiaKolkataTZ,
 	// add more as needed
 }
 
@@ -0,0 +1,121 @@
+package broker_integration
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"sort"
+)
+
+// detectSampleRows is how many rows after the header a file's format is detected from.
+const detectSampleRows = 50
+
+// FileAdapterMatch is how well the rows of a file match the tradebook of a broker.
+type FileAdapterMatch struct {
+	BrokerName broker.Name `json:"broker_name"`
+
+	// The share, from 0 to 1, of the sampled rows that the broker's FileAdapter could parse.
+	Score float64 `json:"score"`
+
+	SampledRowsCount int `json:"sampled_rows_count"`
+	ParsedRowsCount  int `json:"parsed_rows_count"`
+
+	// The Instruments of the trades of the parsed rows.
+	Instruments []types.Instrument `json:"instruments"`
+}
+
+// DetectFileAdapters runs every broker's FileAdapter against the rows and returns the brokers
+// whose adapter parsed at least a trade, the best match first.
+// Rows are sampled from the header row to the first empty row, as an import reads them.
+func DetectFileAdapters(rows [][]string) []FileAdapterMatch {
+	matches := []FileAdapterMatch{}
+
+	for brokerName, adapter := range fileAdapterByBrokerName {
+		match, ok := matchFileAdapter(adapter, rows)
+		if !ok {
+			continue
+		}
+
+		match.BrokerName = brokerName
+		matches = append(matches, match)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+
+		if matches[i].ParsedRowsCount != matches[j].ParsedRowsCount {
+			return matches[i].ParsedRowsCount > matches[j].ParsedRowsCount
+		}
+
+		return matches[i].BrokerName < matches[j].BrokerName
+	})
+
+	return matches
+}
+
+func matchFileAdapter(adapter FileAdapter, rows [][]string) (FileAdapterMatch, bool) {
+	var match FileAdapterMatch
+
+	metadata, err := adapter.GetMetadata(rows)
+	if err != nil || metadata.HeaderRowIdx >= len(rows) {
+		return match, false
+	}
+
+	hasInstrument := map[types.Instrument]bool{}
+	tradesCount := 0
+
+	for _, row := range rows[metadata.HeaderRowIdx+1:] {
+		if len(row) == 0 || match.SampledRowsCount == detectSampleRows {
+			break
+		}
+
+		match.SampledRowsCount++
+
+		trade, err := adapter.ParseRow(row, metadata)
+		if err != nil {
+			continue
+		}
+
+		match.ParsedRowsCount++
+
+		if trade.ShouldIgnore {
+			continue
+		}
+
+		tradesCount++
+		if !hasInstrument[trade.Instrument] {
+			hasInstrument[trade.Instrument] = true
+			match.Instruments = append(match.Instruments, trade.Instrument)
+		}
+	}
+
+	if tradesCount == 0 {
+		return match, false
+	}
+
+	sort.Slice(match.Instruments, func(i, j int) bool {
+		return match.Instruments[i] < match.Instruments[j]
+	})
+
+	match.Score = float64(match.ParsedRowsCount) / float64(match.SampledRowsCount)
+
+	return match, true
+}
+
+// detectMinScore is the score a match needs for its broker to be selected without asking the user.
+const detectMinScore = 0.9
+
+// SelectFileAdapterMatch returns the match of DetectFileAdapters to select without asking the user,
+// if there is exactly one best match and it parsed nearly all the sampled rows.
+func SelectFileAdapterMatch(matches []FileAdapterMatch) (*FileAdapterMatch, bool) {
+	if len(matches) == 0 || matches[0].Score < detectMinScore {
+		return nil, false
+	}
+
+	if len(matches) > 1 && matches[1].Score == matches[0].Score {
+		return nil, false
+	}
+
+	return &matches[0], true
+}
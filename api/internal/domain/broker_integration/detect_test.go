@@ -0,0 +1,121 @@
+package broker_integration
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"testing"
+)
+
+func TestDetectFileAdapters(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        [][]string
+		brokerName  broker.Name
+		instruments []types.Instrument
+	}{
+		{
+			name: "zerodha tradebook",
+			rows: [][]string{
+				{"Symbol", "ISIN", "Trade Date", "Exchange", "Segment", "Series", "Trade Type", "Auction", "Quantity", "Price", "Trade ID", "Order ID", "Order Execution Time"},
+				{"RELIANCE", "INE002A01018", "2024-06-03", "NSE", "EQ", "EQ", "buy", "false", "10", "2900.5", "1001", "1300000000000001", "2024-06-03T09:16:05"},
+				{"RELIANCE", "INE002A01018", "2024-06-03", "NSE", "EQ", "EQ", "sell", "false", "10", "2920", "1002", "1300000000000002", "2024-06-03T14:02:11"},
+				{"NIFTY24JUN23000CE", "", "2024-06-04", "NFO", "FO", "", "buy", "false", "50", "120", "1003", "1300000000000003", "2024-06-04T10:00:00"},
+			},
+			brokerName:  broker.BrokerNameZerodha,
+			instruments: []types.Instrument{types.InstrumentEquity, types.InstrumentOption},
+		},
+		{
+			name: "groww order history",
+			rows: [][]string{
+				{"Name", "Client Code", "ABC123"},
+				{},
+				{"Stock name", "Symbol", "ISIN", "Type", "Quantity", "Value", "Exchange", "Exchange Order Id", "Execution date and time", "Order status"},
+				{"Infosys", "INFY", "INE009A01021", "BUY", "2", "2840", "NSE", "1100000000000001", "03-06-2024 09:20 AM", "Executed"},
+				{"Infosys", "INFY", "INE009A01021", "SELL", "2", "2900", "NSE", "1100000000000002", "05-06-2024 02:45 PM", "Executed"},
+			},
+			brokerName:  broker.BrokerNameGroww,
+			instruments: []types.Instrument{types.InstrumentEquity},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := DetectFileAdapters(tt.rows)
+
+			selected, ok := SelectFileAdapterMatch(matches)
+			if !ok {
+				t.Fatalf("expected a broker to be selected, got matches %+v", matches)
+			}
+
+			if selected.BrokerName != tt.brokerName {
+				t.Fatalf("expected %s, got %s", tt.brokerName, selected.BrokerName)
+			}
+
+			if len(selected.Instruments) != len(tt.instruments) {
+				t.Fatalf("expected instruments %v, got %v", tt.instruments, selected.Instruments)
+			}
+			for i := range tt.instruments {
+				if selected.Instruments[i] != tt.instruments[i] {
+					t.Errorf("expected instruments %v, got %v", tt.instruments, selected.Instruments)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectFileAdapters_NoMatch(t *testing.T) {
+	rows := [][]string{
+		{"Date", "Description", "Debit", "Credit", "Balance"},
+		{"03/06/2024", "Funds added", "", "10000", "10000"},
+		{"04/06/2024", "Withdrawal", "500", "", "9500"},
+	}
+
+	matches := DetectFileAdapters(rows)
+	if len(matches) != 0 {
+		t.Fatalf("expected no matches for a bank statement, got %+v", matches)
+	}
+
+	if _, ok := SelectFileAdapterMatch(matches); ok {
+		t.Errorf("expected no broker to be selected")
+	}
+}
+
+func TestSelectFileAdapterMatch_Ambiguous(t *testing.T) {
+	matches := []FileAdapterMatch{
+		{BrokerName: broker.BrokerNameGroww, Score: 1, ParsedRowsCount: 4, SampledRowsCount: 4},
+		{BrokerName: broker.BrokerNameINDmoney, Score: 1, ParsedRowsCount: 4, SampledRowsCount: 4},
+	}
+
+	if _, ok := SelectFileAdapterMatch(matches); ok {
+		t.Errorf("expected no broker to be selected when two match equally")
+	}
+}
+
+func TestFileAdapters_ShortRows(t *testing.T) {
+	// The rows of another broker's file can be shorter than the columns of the adapter's header,
+	// and have derivatives named unlike its own.
+	metadata := &importFileMetadata{
+		HeaderRowIdx: 0, symbolColumnIdx: 0, segmentColumnIdx: 1, exchangeColumnIdx: 1, tradeTypeColumnIdx: 2,
+		quantityColumnIdx: 3, priceColumnIdx: 4, buyPriceColumnIdx: 4, sellPriceColumnIdx: 4, orderIDColumnIdx: 5,
+		dateTimeColumnIdx: 6, dateColumnIdx: 6, timeColumnIdx: 7, orderExecutionTimeColumnIdx: 7,
+		instrumentTypeColumnIdx: 8, expiryTypeColumnIdx: 9, strikePriceColumnIdx: 10, optionTypeColumnIdx: 11, scripCodeIdx: 12,
+	}
+
+	rows := [][]string{
+		{},
+		{"RELIANCE"},
+		{"OPTIDX NIFTY CE", "FUTURES", "buy", "50", "120", "1", "6/3/24 09:20"},
+		{"OPTIDXNIFTY 31JUL", "NSE DERV", "buy", "50", "120", "1", "03/06/2024", "09:20:00"},
+		{"FUTIDX", "FUTURES", "buy", "50", "120", "1", "6/3/24 09:20", "09:20:00"},
+		{"NIFTY", "FO", "buy", "50", "120", "1", "03-06-2024", "09:20:00", "European Call", "27-06-2024", "23000"},
+	}
+
+	for brokerName, adapter := range fileAdapterByBrokerName {
+		for _, row := range rows {
+			// Fails the test with a panic if the adapter indexes past the row or a symbol's fields.
+			if _, err := adapter.ParseRow(row, metadata); err == nil && len(row) < 2 {
+				t.Errorf("%s: expected an error for the row %q", brokerName, row)
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"arthveda/internal/domain/symbol"
 	"arthveda/internal/domain/types"
 	"arthveda/internal/feature/broker"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -42,6 +43,11 @@ type importFileMetadata struct {
 	// The strike price column index for options.
 	strikePriceColumnIdx int
 
+	// The option type column index. Call or Put.
+	//
+	// [HDFC Securities] and [ICICI Direct] have the contract of a derivative in its own columns.
+	optionTypeColumnIdx int
+
 	// The trade type column index. Buy or Sell.
 	tradeTypeColumnIdx int
 
@@ -82,31 +88,134 @@ type importFileMetadata struct {
 	orderExecutionTimeColumnIdx int
 }
 
+// segmentByCode maps the segment codes of the Zerodha and Upstox tradebooks to the Segments.
+// The equity codes aren't in it, as the empty Segment is the equity segment.
+var segmentByCode = map[string]types.Segment{
+	"COM": types.SegmentCommodity,
+	"CDS": types.SegmentCurrency,
+	"CD":  types.SegmentCurrency,
+}
+
+// ErrPnLStatement is returned for a P&L statement, which has the P&L of each symbol but not its trades.
+var ErrPnLStatement = errors.New("File is a P&L statement, which has no trades. Import the tradebook instead.")
+
 type FileAdapter interface {
 	GetMetadata(rows [][]string) (*importFileMetadata, error)
 	ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error)
 }
 
+// fileAdapterByBrokerName is the FileAdapter of each broker whose tradebook can be imported.
+// The adapters hold no state, so they are shared.
+var fileAdapterByBrokerName = map[broker.Name]FileAdapter{
+	broker.BrokerName5paisa:          &fivePaisaFileAdapter{},
+	broker.BrokerNameAngelOne:        &angelOneFileAdapter{},
+	broker.BrokerNameDhan:            &dhanFileAdapter{},
+	broker.BrokerNameFyers:           &fyersFileAdapter{},
+	broker.BrokerNameGroww:           &growwFileAdapter{},
+	broker.BrokerNameHDFCSecurities:  &hdfcSecuritiesFileAdapter{},
+	broker.BrokerNameICICIDirect:     &iciciDirectFileAdapter{},
+	broker.BrokerNameINDmoney:        &indmoneyFileAdapter{},
+	broker.BrokerNameKotakSecurities: &kotakSecuritiesFileAdapter{},
+	broker.BrokerNameMotilalOswal:    &motilalOswalFileAdapter{},
+	broker.BrokerNameUpstox:          &upstoxFileAdapter{},
+	broker.BrokerNameZerodha:         &zerodhaFileAdapter{},
+}
+
 // GetFileAdapter returns an importer for the given broker.
-func GetFileAdapter(b *broker.Broker) (FileAdapter, error) {
-	switch b.Name {
-	case broker.BrokerNameAngelOne:
-		return &angelOneFileAdapter{}, nil
-	case broker.BrokerNameFyers:
-		return &fyersFileAdapter{}, nil
-	case broker.BrokerNameGroww:
-		return &growwFileAdapter{}, nil
-	case broker.BrokerNameINDmoney:
-		return &indmoneyFileAdapter{}, nil
-	case broker.BrokerNameKotakSecurities:
-		return &kotakSecuritiesFileAdapter{}, nil
-	case broker.BrokerNameUpstox:
-		return &upstoxFileAdapter{}, nil
-	case broker.BrokerNameZerodha:
-		return &zerodhaFileAdapter{}, nil
-	default:
+// The user's FileMapping, if any, takes precedence over the broker's own adapter.
+func GetFileAdapter(b *broker.Broker, mapping *FileMapping) (FileAdapter, error) {
+	if mapping != nil {
+		return &mappedFileAdapter{mapping}, nil
+	}
+
+	adapter, ok := fileAdapterByBrokerName[b.Name]
+	if !ok {
 		return nil, fmt.Errorf("unsupported broker: %s", b.Name)
 	}
+
+	return adapter, nil
+}
+
+// fivePaisaFileAdapter imports the trade book of 5paisa. It names derivatives like
+// "NIFTY 27 Jun 2024 CE 23000.00" and "NIFTY 27 Jun 2024", and tells them apart from equity with "Exch Type".
+type fivePaisaFileAdapter struct{}
+
+func (adapter *fivePaisaFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	headerRowIdx, columnIdxs, err := findHeaderRow(rows,
+		[]string{"Trade Date", "Trade Time", "Scrip Name", "Exch Type", "Buy/Sell", "Qty", "Rate"},
+		"Order No",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importFileMetadata{
+		HeaderRowIdx:       headerRowIdx,
+		dateColumnIdx:      columnIdxs["Trade Date"],
+		timeColumnIdx:      columnIdxs["Trade Time"],
+		symbolColumnIdx:    columnIdxs["Scrip Name"],
+		segmentColumnIdx:   columnIdxs["Exch Type"],
+		tradeTypeColumnIdx: columnIdxs["Buy/Sell"],
+		quantityColumnIdx:  columnIdxs["Qty"],
+		priceColumnIdx:     columnIdxs["Rate"],
+		orderIDColumnIdx:   columnIdxs["Order No"],
+	}, nil
+}
+
+func (adapter *fivePaisaFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	scripName := strings.ToUpper(cellAt(row, metadata.symbolColumnIdx))
+	if scripName == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	trade, err := parseTradeColumns(row, metadata, "02/01/2006 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+
+	trade.Symbol = scripName
+	trade.Instrument = types.InstrumentEquity
+
+	// C is cash, D is derivatives and U is currency derivatives.
+	exchType := strings.ToUpper(cellAt(row, metadata.segmentColumnIdx))
+	if exchType == "U" {
+		trade.Segment = types.SegmentCurrency
+	}
+
+	fields := strings.Fields(scripName)
+	n := len(fields)
+
+	if exchType != "C" {
+		switch {
+		case n >= 6 && optionRightByValue[fields[n-2]] != "":
+			expiry, err := time.Parse("2 Jan 2006", strings.Join(fields[n-5:n-2], " "))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid expiry date in row: %s", scripName)
+			}
+
+			strike, err := parseAmount(fields[n-1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid strike price in row: %s", scripName)
+			}
+
+			trade.Instrument = types.InstrumentOption
+			trade.Symbol = optionSymbol(strings.Join(fields[:n-5], ""), expiry, strike, optionRightByValue[fields[n-2]])
+		case n >= 4:
+			expiry, err := time.Parse("2 Jan 2006", strings.Join(fields[n-3:], " "))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid expiry date in row: %s", scripName)
+			}
+
+			trade.Instrument = types.InstrumentFuture
+			trade.Symbol = futureSymbol(strings.Join(fields[:n-3], ""), expiry)
+		default:
+			return nil, fmt.Errorf("Unknown derivative in row: %s", scripName)
+		}
+	}
+
+	trade.OrderID = orderIDOfRow(row, metadata, trade)
+
+	return trade, nil
 }
 
 type angelOneFileAdapter struct{}
@@ -173,25 +282,25 @@ func (adapter *angelOneFileAdapter) GetMetadata(rows [][]string) (*importFileMet
 }
 
 func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
-	symbol := row[metadata.symbolColumnIdx]
+	symbol := cellAt(row, metadata.symbolColumnIdx)
 	if symbol == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	segment := row[metadata.segmentColumnIdx]
+	segment := cellAt(row, metadata.segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
 
-	orderID := row[metadata.orderIDColumnIdx]
+	orderID := cellAt(row, metadata.orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
-	tradeTypeStr := row[metadata.tradeTypeColumnIdx]
+	tradeTypeStr := cellAt(row, metadata.tradeTypeColumnIdx)
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 
-	quantityStr := row[metadata.quantityColumnIdx]
+	quantityStr := cellAt(row, metadata.quantityColumnIdx)
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid quantity at row : %s", quantityStr)
@@ -200,13 +309,13 @@ func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileM
 	var price decimal.Decimal
 	switch tradeKind {
 	case types.TradeKindBuy:
-		buyPriceStr := row[metadata.buyPriceColumnIdx]
+		buyPriceStr := cellAt(row, metadata.buyPriceColumnIdx)
 		price, err = decimal.NewFromString(buyPriceStr)
 		if err != nil {
 			return nil, fmt.Errorf("Invalid buy price at row : %s", buyPriceStr)
 		}
 	case types.TradeKindSell:
-		sellPriceStr := row[metadata.sellPriceColumnIdx]
+		sellPriceStr := cellAt(row, metadata.sellPriceColumnIdx)
 		price, err = decimal.NewFromString(sellPriceStr)
 		if err != nil {
 			return nil, fmt.Errorf("Invalid sell price at row : %s", sellPriceStr)
@@ -215,7 +324,7 @@ func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileM
 		return nil, fmt.Errorf("Invalid trade kind at row : %s", tradeTypeStr)
 	}
 
-	timeStr := row[metadata.dateTimeColumnIdx]
+	timeStr := cellAt(row, metadata.dateTimeColumnIdx)
 
 	tz, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
 	ist, err := time.LoadLocation(string(tz))
@@ -259,6 +368,9 @@ func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileM
 	switch instrument {
 	case types.InstrumentOption:
 		fields := strings.Fields(symbol)
+		if len(fields) < 7 || len(fields[2]) < 3 {
+			return nil, fmt.Errorf("Invalid option symbol in row: %s", symbol)
+		}
 
 		underlying := fields[1]                    // e.g. "NIFTY"
 		month := strings.ToUpper(fields[2][:3])    // "Oct" -> "OCT"
@@ -272,6 +384,9 @@ func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileM
 
 	case types.InstrumentFuture:
 		fields := strings.Fields(symbol)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("Invalid future symbol in row: %s", symbol)
+		}
 
 		underlying := fields[1]              // e.g. "NIFTY"
 		expiry := strings.ToUpper(fields[2]) // "20OCT25"
@@ -290,6 +405,79 @@ func (adapter *angelOneFileAdapter) ParseRow(row []string, metadata *importFileM
 	}, nil
 }
 
+// dhanFileAdapter imports the trade history of Dhan. It names derivatives like "NIFTY 27 JUN 23000 CALL"
+// and "NIFTY 27 JUN FUT", with the day and month of the expiry but not its year.
+type dhanFileAdapter struct{}
+
+func (adapter *dhanFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	headerRowIdx, columnIdxs, err := findHeaderRow(rows,
+		[]string{"Date", "Time", "Name", "Buy/Sell", "Segment", "Quantity", "Trade Price"},
+		"Exchange Order ID",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importFileMetadata{
+		HeaderRowIdx:       headerRowIdx,
+		dateColumnIdx:      columnIdxs["Date"],
+		timeColumnIdx:      columnIdxs["Time"],
+		symbolColumnIdx:    columnIdxs["Name"],
+		segmentColumnIdx:   columnIdxs["Segment"],
+		tradeTypeColumnIdx: columnIdxs["Buy/Sell"],
+		quantityColumnIdx:  columnIdxs["Quantity"],
+		priceColumnIdx:     columnIdxs["Trade Price"],
+		orderIDColumnIdx:   columnIdxs["Exchange Order ID"],
+	}, nil
+}
+
+func (adapter *dhanFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	name := strings.ToUpper(cellAt(row, metadata.symbolColumnIdx))
+	if name == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	trade, err := parseTradeColumns(row, metadata, "02-01-2006 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+
+	trade.Symbol = name
+	trade.Instrument = types.InstrumentEquity
+	trade.Segment = segmentByName[strings.ToLower(cellAt(row, metadata.segmentColumnIdx))]
+
+	fields := strings.Fields(name)
+	n := len(fields)
+
+	switch {
+	case n >= 5 && (fields[n-1] == "CALL" || fields[n-1] == "PUT"):
+		expiry, err := expiryWithoutYear(fields[n-4]+" "+fields[n-3], trade.Time)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expiry date in row: %s", name)
+		}
+
+		strike, err := parseAmount(fields[n-2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid strike price in row: %s", name)
+		}
+
+		trade.Instrument = types.InstrumentOption
+		trade.Symbol = optionSymbol(strings.Join(fields[:n-4], ""), expiry, strike, optionRightByValue[fields[n-1]])
+	case n >= 4 && fields[n-1] == "FUT":
+		expiry, err := expiryWithoutYear(fields[n-3]+" "+fields[n-2], trade.Time)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expiry date in row: %s", name)
+		}
+
+		trade.Instrument = types.InstrumentFuture
+		trade.Symbol = futureSymbol(strings.Join(fields[:n-3], ""), expiry)
+	}
+
+	trade.OrderID = orderIDOfRow(row, metadata, trade)
+
+	return trade, nil
+}
+
 type fyersFileAdapter struct{}
 
 func (adapter *fyersFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
@@ -362,15 +550,15 @@ func (adapter *fyersFileAdapter) ParseRow(row []string, metadata *importFileMeta
 	dateColumnIdx := metadata.dateColumnIdx
 	timeColumnIdx := metadata.timeColumnIdx
 
-	orderID := row[orderIDColumnIdx]
+	orderID := cellAt(row, orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
 	// Parse trade details from the row
-	tradeTypeStr := row[tradeTypeColumnIdx]
-	quantityStr := row[quantityColumnIdx]
-	priceStr := row[priceColumnIdx]
+	tradeTypeStr := cellAt(row, tradeTypeColumnIdx)
+	quantityStr := cellAt(row, quantityColumnIdx)
+	priceStr := cellAt(row, priceColumnIdx)
 
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
@@ -389,8 +577,8 @@ func (adapter *fyersFileAdapter) ParseRow(row []string, metadata *importFileMeta
 		return nil, fmt.Errorf("Failed to load timezone for trade: %s", tz)
 	}
 
-	dateStr := row[dateColumnIdx]
-	timeStr := row[timeColumnIdx]
+	dateStr := cellAt(row, dateColumnIdx)
+	timeStr := cellAt(row, timeColumnIdx)
 
 	dateTimeStr := dateStr + " " + timeStr
 
@@ -399,12 +587,12 @@ func (adapter *fyersFileAdapter) ParseRow(row []string, metadata *importFileMeta
 		return nil, fmt.Errorf("Invalid datetime at row: %s (err: %v)", dateTimeStr, err)
 	}
 
-	symbolStr := row[symbolColumnIdx]
+	symbolStr := cellAt(row, symbolColumnIdx)
 	if symbolStr == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	segment := row[segmentColumnIdx]
+	segment := cellAt(row, segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
@@ -520,26 +708,26 @@ func (adapter *growwFileAdapter) ParseRow(row []string, metadata *importFileMeta
 	orderIDColumnIdx := metadata.orderIDColumnIdx
 	dateTimeColumnIdx := metadata.dateTimeColumnIdx
 
-	symbol := row[symbolColumnIdx]
+	symbol := cellAt(row, symbolColumnIdx)
 	if symbol == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	segment := row[segmentColumnIdx]
+	segment := cellAt(row, segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
 
-	orderID := row[orderIDColumnIdx]
+	orderID := cellAt(row, orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
 	// Parse trade details from the row
-	tradeTypeStr := row[tradeTypeColumnIdx]
-	quantityStr := row[quantityColumnIdx]
-	priceStr := row[priceColumnIdx]
-	timeStr := row[dateTimeColumnIdx]
+	tradeTypeStr := cellAt(row, tradeTypeColumnIdx)
+	quantityStr := cellAt(row, quantityColumnIdx)
+	priceStr := cellAt(row, priceColumnIdx)
+	timeStr := cellAt(row, dateTimeColumnIdx)
 
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
@@ -579,6 +767,148 @@ func (adapter *growwFileAdapter) ParseRow(row []string, metadata *importFileMeta
 	}, nil
 }
 
+// hdfcSecuritiesFileAdapter imports the trade book of HDFC Securities. It has the exchange's instrument
+// type, like OPTIDX or FUTSTK, and the expiry, strike and option type of a contract in their own columns.
+type hdfcSecuritiesFileAdapter struct{}
+
+func (adapter *hdfcSecuritiesFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	headerRowIdx, columnIdxs, err := findHeaderRow(rows,
+		[]string{"Trade Date", "Trade Time", "Symbol", "Instrument Type", "Buy/Sell", "Quantity", "Price"},
+		"Order No", "Expiry Date", "Strike Price", "Option Type", "Exchange",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importFileMetadata{
+		HeaderRowIdx:            headerRowIdx,
+		dateColumnIdx:           columnIdxs["Trade Date"],
+		timeColumnIdx:           columnIdxs["Trade Time"],
+		symbolColumnIdx:         columnIdxs["Symbol"],
+		instrumentTypeColumnIdx: columnIdxs["Instrument Type"],
+		tradeTypeColumnIdx:      columnIdxs["Buy/Sell"],
+		quantityColumnIdx:       columnIdxs["Quantity"],
+		priceColumnIdx:          columnIdxs["Price"],
+		orderIDColumnIdx:        columnIdxs["Order No"],
+		expiryTypeColumnIdx:     columnIdxs["Expiry Date"],
+		strikePriceColumnIdx:    columnIdxs["Strike Price"],
+		optionTypeColumnIdx:     columnIdxs["Option Type"],
+		exchangeColumnIdx:       columnIdxs["Exchange"],
+	}, nil
+}
+
+func (adapter *hdfcSecuritiesFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	symbol := strings.ToUpper(cellAt(row, metadata.symbolColumnIdx))
+	if symbol == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	trade, err := parseTradeColumns(row, metadata, "02/01/2006 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+
+	trade.Symbol = symbol
+	trade.Instrument = types.InstrumentEquity
+	trade.Segment = segmentByExchange[strings.ToUpper(cellAt(row, metadata.exchangeColumnIdx))]
+
+	// Like EQ, FUTIDX, OPTSTK, FUTCOM or OPTCUR.
+	instrumentType := strings.ToUpper(cellAt(row, metadata.instrumentTypeColumnIdx))
+	switch {
+	case strings.HasSuffix(instrumentType, "COM"):
+		trade.Segment = types.SegmentCommodity
+	case strings.HasSuffix(instrumentType, "CUR"):
+		trade.Segment = types.SegmentCurrency
+	}
+
+	switch {
+	case strings.HasPrefix(instrumentType, "FUT"):
+		trade.Instrument = types.InstrumentFuture
+	case strings.HasPrefix(instrumentType, "OPT"):
+		trade.Instrument = types.InstrumentOption
+	}
+
+	if trade.Instrument != types.InstrumentEquity {
+		trade.Symbol, err = contractSymbol(symbol, trade.Instrument,
+			cellAt(row, metadata.expiryTypeColumnIdx), "02-Jan-2006",
+			cellAt(row, metadata.strikePriceColumnIdx), cellAt(row, metadata.optionTypeColumnIdx))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	trade.OrderID = orderIDOfRow(row, metadata, trade)
+
+	return trade, nil
+}
+
+// iciciDirectFileAdapter imports the trade book of ICICI Direct. Its "Product" tells futures and options
+// from equity, and it has the expiry, strike and option type of a contract in their own columns.
+type iciciDirectFileAdapter struct{}
+
+func (adapter *iciciDirectFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	headerRowIdx, columnIdxs, err := findHeaderRow(rows,
+		[]string{"Trade Date", "Trade Time", "Stock", "Product", "Action", "Quantity", "Price"},
+		"Order Ref.", "Expiry Date", "Strike Price", "Option Type", "Exchange",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importFileMetadata{
+		HeaderRowIdx:            headerRowIdx,
+		dateColumnIdx:           columnIdxs["Trade Date"],
+		timeColumnIdx:           columnIdxs["Trade Time"],
+		symbolColumnIdx:         columnIdxs["Stock"],
+		instrumentTypeColumnIdx: columnIdxs["Product"],
+		tradeTypeColumnIdx:      columnIdxs["Action"],
+		quantityColumnIdx:       columnIdxs["Quantity"],
+		priceColumnIdx:          columnIdxs["Price"],
+		orderIDColumnIdx:        columnIdxs["Order Ref."],
+		expiryTypeColumnIdx:     columnIdxs["Expiry Date"],
+		strikePriceColumnIdx:    columnIdxs["Strike Price"],
+		optionTypeColumnIdx:     columnIdxs["Option Type"],
+		exchangeColumnIdx:       columnIdxs["Exchange"],
+	}, nil
+}
+
+func (adapter *iciciDirectFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	stock := strings.ToUpper(cellAt(row, metadata.symbolColumnIdx))
+	if stock == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	trade, err := parseTradeColumns(row, metadata, "02-Jan-2006 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+
+	trade.Symbol = stock
+	trade.Instrument = types.InstrumentEquity
+	trade.Segment = segmentByExchange[strings.ToUpper(cellAt(row, metadata.exchangeColumnIdx))]
+
+	// Cash, Margin and MTF are equity.
+	switch strings.ToLower(cellAt(row, metadata.instrumentTypeColumnIdx)) {
+	case "futures":
+		trade.Instrument = types.InstrumentFuture
+	case "options":
+		trade.Instrument = types.InstrumentOption
+	}
+
+	if trade.Instrument != types.InstrumentEquity {
+		trade.Symbol, err = contractSymbol(stock, trade.Instrument,
+			cellAt(row, metadata.expiryTypeColumnIdx), "02-Jan-2006",
+			cellAt(row, metadata.strikePriceColumnIdx), cellAt(row, metadata.optionTypeColumnIdx))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	trade.OrderID = orderIDOfRow(row, metadata, trade)
+
+	return trade, nil
+}
+
 type indmoneyFileAdapter struct{}
 
 func (adapter *indmoneyFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
@@ -641,26 +971,26 @@ func (adapter *indmoneyFileAdapter) ParseRow(row []string, metadata *importFileM
 	orderIDColumnIdx := metadata.orderIDColumnIdx
 	dateTimeColumnIdx := metadata.dateTimeColumnIdx
 
-	symbol := row[symbolColumnIdx]
+	symbol := cellAt(row, symbolColumnIdx)
 	if symbol == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	segment := row[segmentColumnIdx]
+	segment := cellAt(row, segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
 
-	orderID := row[orderIDColumnIdx]
+	orderID := cellAt(row, orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
 	// Parse trade details from the row
-	tradeTypeStr := row[tradeTypeColumnIdx]
-	quantityStr := row[quantityColumnIdx]
-	priceStr := row[priceColumnIdx]
-	timeStr := row[dateTimeColumnIdx]
+	tradeTypeStr := cellAt(row, tradeTypeColumnIdx)
+	quantityStr := cellAt(row, quantityColumnIdx)
+	priceStr := cellAt(row, priceColumnIdx)
+	timeStr := cellAt(row, dateTimeColumnIdx)
 
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
@@ -774,33 +1104,33 @@ func (adapter *kotakSecuritiesFileAdapter) GetMetadata(rows [][]string) (*import
 }
 
 func (adapter *kotakSecuritiesFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
-	symbol := row[metadata.symbolColumnIdx]
+	symbol := cellAt(row, metadata.symbolColumnIdx)
 	if symbol == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	exchange := row[metadata.exchangeColumnIdx]
+	exchange := cellAt(row, metadata.exchangeColumnIdx)
 	if exchange == "" {
 		return nil, fmt.Errorf("Exchange is empty in row")
 	}
 
-	tradeTypeStr := row[metadata.tradeTypeColumnIdx]
+	tradeTypeStr := cellAt(row, metadata.tradeTypeColumnIdx)
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 
-	quantityStr := row[metadata.quantityColumnIdx]
+	quantityStr := cellAt(row, metadata.quantityColumnIdx)
 	quantity, err := decimal.NewFromString(quantityStr)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid quantity at row : %s", quantityStr)
 	}
 
-	priceStr := row[metadata.priceColumnIdx]
+	priceStr := cellAt(row, metadata.priceColumnIdx)
 	price, err := decimal.NewFromString(priceStr)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid price at row : %s", priceStr)
 	}
 
-	dateStr := row[metadata.dateColumnIdx]
-	timeStr := row[metadata.timeColumnIdx]
+	dateStr := cellAt(row, metadata.dateColumnIdx)
+	timeStr := cellAt(row, metadata.timeColumnIdx)
 	dateTimeStr := dateStr + " " + timeStr
 
 	tz, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
@@ -816,7 +1146,7 @@ func (adapter *kotakSecuritiesFileAdapter) ParseRow(row []string, metadata *impo
 
 	// We will create our own order ID because Kotak Securities does not provide a unique order ID for each order.
 	// We will use the "Symbol + Trade Date + Order Exec Time" as the order ID.
-	orderExecTimeStr := row[metadata.orderExecutionTimeColumnIdx]
+	orderExecTimeStr := cellAt(row, metadata.orderExecutionTimeColumnIdx)
 	orderID := symbol + " " + dateStr + " " + orderExecTimeStr
 
 	instrument := types.InstrumentEquity
@@ -827,6 +1157,9 @@ func (adapter *kotakSecuritiesFileAdapter) ParseRow(row []string, metadata *impo
 			instrument = types.InstrumentOption
 
 			fields := strings.Fields(symbol) // splits by spaces
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("Invalid option symbol in row: %s", symbol)
+			}
 
 			var underlying string
 			isStockOption := false
@@ -881,6 +1214,10 @@ func (adapter *kotakSecuritiesFileAdapter) ParseRow(row []string, metadata *impo
 
 				symbol = fmt.Sprintf("%s%s%s%s", underlying, expiry, strike, optionType)
 			} else {
+				if len(fields) < 3 || len(fields[1]) < 5 {
+					return nil, fmt.Errorf("Invalid option symbol in row: %s", symbol)
+				}
+
 				expiryAndType := fields[1]                 // e.g. "31JUL2025CE"
 				strike := strings.Split(fields[2], ".")[0] // remove decimals
 
@@ -932,6 +1269,79 @@ func (adapter *kotakSecuritiesFileAdapter) ParseRow(row []string, metadata *impo
 	}, nil
 }
 
+// motilalOswalFileAdapter imports the trade book of Motilal Oswal. It names derivatives like
+// "OPTIDX NIFTY 27-Jun-2024 CE 23000" and "FUTSTK RELIANCE 27-Jun-2024".
+type motilalOswalFileAdapter struct{}
+
+func (adapter *motilalOswalFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	headerRowIdx, columnIdxs, err := findHeaderRow(rows,
+		[]string{"Trade Date", "Trade Time", "Scrip Name", "Buy/Sell", "Qty", "Net Rate"},
+		"Order No", "Exchange",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importFileMetadata{
+		HeaderRowIdx:       headerRowIdx,
+		dateColumnIdx:      columnIdxs["Trade Date"],
+		timeColumnIdx:      columnIdxs["Trade Time"],
+		symbolColumnIdx:    columnIdxs["Scrip Name"],
+		tradeTypeColumnIdx: columnIdxs["Buy/Sell"],
+		quantityColumnIdx:  columnIdxs["Qty"],
+		priceColumnIdx:     columnIdxs["Net Rate"],
+		orderIDColumnIdx:   columnIdxs["Order No"],
+		exchangeColumnIdx:  columnIdxs["Exchange"],
+	}, nil
+}
+
+func (adapter *motilalOswalFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	scripName := strings.ToUpper(cellAt(row, metadata.symbolColumnIdx))
+	if scripName == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	trade, err := parseTradeColumns(row, metadata, "02/01/2006 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(scripName)
+	n := len(fields)
+
+	trade.Symbol = fields[0]
+	trade.Instrument = types.InstrumentEquity
+	trade.Segment = segmentByExchange[strings.ToUpper(cellAt(row, metadata.exchangeColumnIdx))]
+
+	// Like OPTIDX, FUTSTK, FUTCOM or OPTCUR for a derivative.
+	instrumentType := fields[0]
+	if strings.HasPrefix(instrumentType, "OPT") || strings.HasPrefix(instrumentType, "FUT") {
+		switch {
+		case strings.HasSuffix(instrumentType, "COM"):
+			trade.Segment = types.SegmentCommodity
+		case strings.HasSuffix(instrumentType, "CUR"):
+			trade.Segment = types.SegmentCurrency
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(instrumentType, "OPT") && n >= 5:
+		trade.Instrument = types.InstrumentOption
+		trade.Symbol, err = contractSymbol(fields[1], trade.Instrument, fields[2], "02-Jan-2006", fields[4], fields[3])
+	case strings.HasPrefix(instrumentType, "FUT") && n >= 3:
+		trade.Instrument = types.InstrumentFuture
+		trade.Symbol, err = contractSymbol(fields[1], trade.Instrument, fields[2], "02-Jan-2006", "", "")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	trade.OrderID = orderIDOfRow(row, metadata, trade)
+
+	return trade, nil
+}
+
 type upstoxFileAdapter struct{}
 
 func (adapter *upstoxFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
@@ -1027,7 +1437,7 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 	dateColumnIdx := metadata.dateColumnIdx
 	timeColumnIdx := metadata.timeColumnIdx
 
-	segment := row[segmentColumnIdx]
+	segment := cellAt(row, segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
@@ -1037,8 +1447,9 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 	switch segment {
 	case "EQ":
 		instrument = types.InstrumentEquity
-	case "FO", "COM":
-		instrumentTypeStr = row[instrumentTypeColumnIdx]
+	case "FO", "COM", "CD":
+		// FO is equity Futures and Options, COM is Commodities and CD is Currency Derivatives.
+		instrumentTypeStr = cellAt(row, instrumentTypeColumnIdx)
 		if segment == "" {
 			return nil, fmt.Errorf("Instrument Type is empty in row")
 		}
@@ -1054,7 +1465,7 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 
 	var expiryStr string
 	if instrument == types.InstrumentOption || instrument == types.InstrumentFuture {
-		expiryStr = row[metadata.expiryTypeColumnIdx]
+		expiryStr = cellAt(row, metadata.expiryTypeColumnIdx)
 		if expiryStr == "" {
 			return nil, fmt.Errorf("Expiry is empty in row")
 		}
@@ -1070,13 +1481,13 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 		expiryStr = expiryDate.Format("02JAN")
 	}
 
-	symbolStr := row[symbolColumnIdx]
+	symbolStr := cellAt(row, symbolColumnIdx)
 	if symbolStr == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
 	// In Upstox, the symbol is actually the company name, so we need to use teh "exchange_token" to get the actual symbol.
-	scripCode := row[scripCodeColumnIdx]
+	scripCode := cellAt(row, scripCodeColumnIdx)
 
 	if scripCode != "" {
 		// If we have a scrip code, we will use it to get the actual symbol.
@@ -1092,7 +1503,7 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 	case types.InstrumentOption:
 		var strikePriceStr string
 		if instrument == types.InstrumentOption {
-			strikePriceStr = row[metadata.strikePriceColumnIdx]
+			strikePriceStr = cellAt(row, metadata.strikePriceColumnIdx)
 			if strikePriceStr == "" {
 				return nil, fmt.Errorf("Strike Price is empty in row")
 			}
@@ -1109,15 +1520,20 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 		symbolStr = symbolStr + expiryStr + strikePriceStr + callOptionStr
 	}
 
-	orderID := row[orderIDColumnIdx]
+	orderID := cellAt(row, orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
+	// The order of a trade is told by the first 3 characters of its order ID, as below.
+	if len(orderID) < 3 {
+		return nil, fmt.Errorf("Invalid order ID in row: %s", orderID)
+	}
+
 	// Parse trade details from the row
-	tradeTypeStr := row[tradeTypeColumnIdx]
-	quantityStr := row[quantityColumnIdx]
-	priceStr := row[priceColumnIdx]
+	tradeTypeStr := cellAt(row, tradeTypeColumnIdx)
+	quantityStr := cellAt(row, quantityColumnIdx)
+	priceStr := cellAt(row, priceColumnIdx)
 
 	tradeKind := types.TradeKind(strings.ToLower(tradeTypeStr))
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
@@ -1146,8 +1562,8 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 		return nil, fmt.Errorf("Failed to load timezone for trade: %s", tz)
 	}
 
-	dateStr := row[dateColumnIdx]
-	timeStr := row[timeColumnIdx]
+	dateStr := cellAt(row, dateColumnIdx)
+	timeStr := cellAt(row, timeColumnIdx)
 
 	// Combine date and time strings
 	dateTimeStr := dateStr + " " + timeStr
@@ -1172,6 +1588,7 @@ func (adapter *upstoxFileAdapter) ParseRow(row []string, metadata *importFileMet
 	return &types.ImportableTrade{
 		Symbol:     symbolStr,
 		Instrument: instrument,
+		Segment:    segmentByCode[segment],
 		TradeKind:  tradeKind,
 		Quantity:   decimal.NewFromFloat(quantity),
 		Price:      price,
@@ -1214,6 +1631,11 @@ func (adapter zerodhaFileAdapter) GetMetadata(rows [][]string) (*importFileMetad
 				orderIDColumnIdx = columnIdx
 			}
 
+			// The P&L statement of Console has a "Symbol" header too, but no trades.
+			if strings.Contains(colCell, "Realized P&L") {
+				return nil, ErrPnLStatement
+			}
+
 			if strings.Contains(colCell, "Order Execution Time") {
 				dateTimeColumnIdx = columnIdx
 			}
@@ -1246,25 +1668,25 @@ func (adapter zerodhaFileAdapter) ParseRow(row []string, metadata *importFileMet
 	orderIDColumnIdx := metadata.orderIDColumnIdx
 	dateTimeColumnIdx := metadata.dateTimeColumnIdx
 
-	symbol := row[symbolColumnIdx]
+	symbol := cellAt(row, symbolColumnIdx)
 	if symbol == "" {
 		return nil, fmt.Errorf("Symbol is empty in row")
 	}
 
-	segment := row[segmentColumnIdx]
+	segment := cellAt(row, segmentColumnIdx)
 	if segment == "" {
 		return nil, fmt.Errorf("Segment is empty in row")
 	}
 
-	orderID := row[orderIDColumnIdx]
+	orderID := cellAt(row, orderIDColumnIdx)
 	if orderID == "" {
 		return nil, fmt.Errorf("Order ID is empty in row")
 	}
 
-	tradeTypeStr := row[tradeTypeColumnIdx]
-	quantityStr := row[quantityColumnIdx]
-	priceStr := row[priceColumnIdx]
-	timeStr := row[dateTimeColumnIdx]
+	tradeTypeStr := cellAt(row, tradeTypeColumnIdx)
+	quantityStr := cellAt(row, quantityColumnIdx)
+	priceStr := cellAt(row, priceColumnIdx)
+	timeStr := cellAt(row, dateTimeColumnIdx)
 
 	tradeKind := types.TradeKind(tradeTypeStr)
 	quantity, err := strconv.ParseFloat(quantityStr, 64)
@@ -1284,6 +1706,10 @@ func (adapter zerodhaFileAdapter) ParseRow(row []string, metadata *importFileMet
 	}
 
 	tradeTime, err := time.ParseInLocation("2006-01-02T15:04:05", timeStr, ist)
+	if err != nil {
+		// The XLSX export of the tradebook has a space in place of the "T".
+		tradeTime, err = time.ParseInLocation("2006-01-02 15:04:05", timeStr, ist)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Invalid time in row: %s", timeStr)
 	}
@@ -1292,8 +1718,8 @@ func (adapter zerodhaFileAdapter) ParseRow(row []string, metadata *importFileMet
 	switch segment {
 	case "EQ":
 		instrument = types.InstrumentEquity
-	case "FO", "COM":
-		// FO is Futures and Options, COM is Commodities.
+	case "FO", "COM", "CDS":
+		// FO is Futures and Options, COM is Commodities and CDS is Currency Derivatives.
 
 		// CE = Call Option, PE = Put Option & FUT = Future.
 		// Example of Options - NIFTY24DEC25000CE, NIFTY25JAN23500PE
@@ -1308,6 +1734,7 @@ func (adapter zerodhaFileAdapter) ParseRow(row []string, metadata *importFileMet
 	return &types.ImportableTrade{
 		Symbol:     symbol,
 		Instrument: instrument,
+		Segment:    segmentByCode[segment],
 		TradeKind:  tradeKind,
 		Quantity:   decimal.NewFromFloat(quantity),
 		Price:      price,
@@ -1315,3 +1742,188 @@ func (adapter zerodhaFileAdapter) ParseRow(row []string, metadata *importFileMet
 		Time:       tradeTime,
 	}, nil
 }
+
+// findHeaderRow returns the index of the first row that has all the required headers, and the index of
+// the column of each header in it. Headers are matched case-insensitively, and the optional headers the
+// row doesn't have are -1.
+func findHeaderRow(rows [][]string, required []string, optional ...string) (int, map[string]int, error) {
+	for rowIdx, row := range rows {
+		columnIdxByHeader := map[string]int{}
+		for columnIdx, colCell := range row {
+			columnIdxByHeader[strings.ToLower(strings.TrimSpace(colCell))] = columnIdx
+		}
+
+		columnIdxs := map[string]int{}
+		for _, header := range required {
+			if idx, ok := columnIdxByHeader[strings.ToLower(header)]; ok {
+				columnIdxs[header] = idx
+			}
+		}
+
+		if len(columnIdxs) < len(required) {
+			continue
+		}
+
+		for _, header := range optional {
+			idx, ok := columnIdxByHeader[strings.ToLower(header)]
+			if !ok {
+				idx = -1
+			}
+			columnIdxs[header] = idx
+		}
+
+		return rowIdx, columnIdxs, nil
+	}
+
+	return 0, nil, fmt.Errorf("no row has the headers: %s", strings.Join(required, ", "))
+}
+
+// cellAt returns the trimmed cell of the row at the column index, or "" if the row has no such cell.
+func cellAt(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+// parseAmount parses a quantity or price with thousands separators, like "1,420.50".
+func parseAmount(value string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.ReplaceAll(value, ",", ""))
+}
+
+// parseTimeIST parses the time of a trade on an Indian exchange.
+func parseTimeIST(layout, value string) (time.Time, error) {
+	tz, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
+	ist, err := time.LoadLocation(string(tz))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to load timezone for trade: %s", tz)
+	}
+
+	return time.ParseInLocation(layout, value, ist)
+}
+
+// optionRightByValue maps the upper-cased values tradebooks use for the right of an option.
+var optionRightByValue = map[string]string{
+	"CE":   "CE",
+	"CALL": "CE",
+	"C":    "CE",
+	"PE":   "PE",
+	"PUT":  "PE",
+	"P":    "PE",
+}
+
+// optionSymbol returns the symbol of an option in the exchange's weekly format, like NIFTY2462723000CE,
+// which has the full date of the expiry, so that symbol.ParseContract doesn't have to guess it.
+func optionSymbol(underlying string, expiry time.Time, strike decimal.Decimal, right string) string {
+	monthChar := "123456789OND"[expiry.Month()-1]
+	return fmt.Sprintf("%s%02d%c%02d%s%s", underlying, expiry.Year()%100, monthChar, expiry.Day(), strike.String(), right)
+}
+
+// futureSymbol returns the symbol of a future with the day and year of its expiry, like NIFTY27JUN24FUT.
+func futureSymbol(underlying string, expiry time.Time) string {
+	return fmt.Sprintf("%s%02d%s%02dFUT", underlying, expiry.Day(), strings.ToUpper(expiry.Format("Jan")), expiry.Year()%100)
+}
+
+// contractSymbol returns the symbol of the future or option of the underlying,
+// for the tradebooks that have the expiry, strike and right of a contract in their own columns.
+func contractSymbol(underlying string, instrument types.Instrument, expiryStr, expiryLayout, strikeStr, rightStr string) (string, error) {
+	expiry, err := time.Parse(expiryLayout, expiryStr)
+	if err != nil {
+		return "", fmt.Errorf("Invalid expiry date in row: %s", expiryStr)
+	}
+
+	if instrument == types.InstrumentFuture {
+		return futureSymbol(underlying, expiry), nil
+	}
+
+	strike, err := parseAmount(strikeStr)
+	if err != nil {
+		return "", fmt.Errorf("Invalid strike price in row: %s", strikeStr)
+	}
+
+	right, ok := optionRightByValue[strings.ToUpper(rightStr)]
+	if !ok {
+		return "", fmt.Errorf("Invalid option type in row: %s", rightStr)
+	}
+
+	return optionSymbol(underlying, expiry, strike, right), nil
+}
+
+// expiryWithoutYear returns the first date on or after the trade that has the day and month of the expiry,
+// like "27 JUN", for the tradebooks that leave out the year of an expiry.
+func expiryWithoutYear(dayAndMonth string, tradedAt time.Time) (time.Time, error) {
+	dayMonth, err := time.Parse("2 Jan", dayAndMonth)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiry := time.Date(tradedAt.Year(), dayMonth.Month(), dayMonth.Day(), 0, 0, 0, 0, time.UTC)
+	tradeDay := time.Date(tradedAt.Year(), tradedAt.Month(), tradedAt.Day(), 0, 0, 0, 0, time.UTC)
+	if expiry.Before(tradeDay) {
+		expiry = expiry.AddDate(1, 0, 0)
+	}
+
+	return expiry, nil
+}
+
+// segmentByName maps the lower-cased segment names of the tradebooks to the Segments.
+// Equity and equity derivatives aren't in it, as the empty Segment is the equity segment.
+var segmentByName = map[string]types.Segment{
+	"commodity": types.SegmentCommodity,
+	"mcx":       types.SegmentCommodity,
+	"currency":  types.SegmentCurrency,
+	"cds":       types.SegmentCurrency,
+}
+
+// segmentByExchange maps the upper-cased commodity and currency exchanges of the tradebooks to the Segments.
+var segmentByExchange = map[string]types.Segment{
+	"MCX":   types.SegmentCommodity,
+	"NCDEX": types.SegmentCommodity,
+	"CDS":   types.SegmentCurrency,
+	"BCD":   types.SegmentCurrency,
+}
+
+// parseTradeColumns parses the trade type, quantity, price, date and time columns of a row of the
+// tradebooks that have separate date and time columns. The time is parsed from "<date> <time>" with the layout.
+func parseTradeColumns(row []string, metadata *importFileMetadata, timeLayout string) (*types.ImportableTrade, error) {
+	tradeTypeStr := cellAt(row, metadata.tradeTypeColumnIdx)
+	tradeKind, ok := tradeKindByValue[strings.ToLower(tradeTypeStr)]
+	if !ok {
+		return nil, fmt.Errorf("Invalid trade type in row: %s", tradeTypeStr)
+	}
+
+	quantityStr := cellAt(row, metadata.quantityColumnIdx)
+	quantity, err := parseAmount(quantityStr)
+	if err != nil || quantity.IsZero() {
+		return nil, fmt.Errorf("Invalid quantity in row: %s", quantityStr)
+	}
+
+	priceStr := cellAt(row, metadata.priceColumnIdx)
+	price, err := parseAmount(priceStr)
+	if err != nil || price.IsNegative() {
+		return nil, fmt.Errorf("Invalid price in row: %s", priceStr)
+	}
+
+	dateTimeStr := cellAt(row, metadata.dateColumnIdx) + " " + cellAt(row, metadata.timeColumnIdx)
+	tradeTime, err := parseTimeIST(timeLayout, dateTimeStr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid time in row: %s", dateTimeStr)
+	}
+
+	return &types.ImportableTrade{
+		TradeKind: tradeKind,
+		Quantity:  quantity.Abs(),
+		Price:     price,
+		Time:      tradeTime,
+	}, nil
+}
+
+// orderIDOfRow returns the order ID of the row. Without one, the trades of a symbol at the same time
+// on the same side are of the same order.
+func orderIDOfRow(row []string, metadata *importFileMetadata, trade *types.ImportableTrade) string {
+	if orderID := cellAt(row, metadata.orderIDColumnIdx); orderID != "" {
+		return orderID
+	}
+
+	return fmt.Sprintf("%s-%s-%d", trade.Symbol, trade.TradeKind, trade.Time.Unix())
+}
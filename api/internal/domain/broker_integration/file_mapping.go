@@ -0,0 +1,269 @@
+package broker_integration
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/domain/types"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FileMapping is the user's mapping of the columns of a tradebook that we have no FileAdapter for.
+// The columns are mapped by their header, so the order of the columns in the file doesn't matter.
+type FileMapping struct {
+	SymbolColumn    string `json:"symbol_column"`
+	TradeTypeColumn string `json:"trade_type_column"`
+	QuantityColumn  string `json:"quantity_column"`
+	PriceColumn     string `json:"price_column"`
+	TimeColumn      string `json:"time_column"`
+
+	// Optional. The trades of a row are their own order if there is no order ID column.
+	OrderIDColumn string `json:"order_id_column,omitempty"`
+
+	// Optional. Without it, every trade is of the Instrument,
+	// or the Instrument is guessed from the symbol if there is none.
+	InstrumentColumn string           `json:"instrument_column,omitempty"`
+	Instrument       types.Instrument `json:"instrument,omitempty"`
+
+	// The format of the time column, like "DD-MM-YYYY HH:mm:ss".
+	// YYYY, YY, MMM, MM, DD, HH, hh, mm, ss and A (AM or PM) are replaced by the parts of the time.
+	TimeFormat string `json:"time_format"`
+
+	// The IANA time zone of the time column. The time zone of NSE if empty.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+var (
+	ErrFileMappingColumnRequired = errors.New("Symbol, trade type, quantity, price and time columns are required")
+	ErrFileMappingTimeFormat     = errors.New("Time format is required")
+	ErrFileMappingTimeZone       = errors.New("Time zone is invalid")
+	ErrFileMappingInstrument     = errors.New("Instrument must be one of equity, future, option or crypto")
+)
+
+func (m *FileMapping) Validate() error {
+	for _, column := range []string{m.SymbolColumn, m.TradeTypeColumn, m.QuantityColumn, m.PriceColumn, m.TimeColumn} {
+		if strings.TrimSpace(column) == "" {
+			return ErrFileMappingColumnRequired
+		}
+	}
+
+	if strings.TrimSpace(m.TimeFormat) == "" {
+		return ErrFileMappingTimeFormat
+	}
+
+	if m.Instrument != "" {
+		if _, ok := parseMappedInstrument(string(m.Instrument)); !ok {
+			return ErrFileMappingInstrument
+		}
+	}
+
+	if _, err := m.location(); err != nil {
+		return ErrFileMappingTimeZone
+	}
+
+	return nil
+}
+
+var timeFormatReplacer = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MMM", "Jan",
+	"MM", "01",
+	"DD", "02",
+	"HH", "15",
+	"hh", "03",
+	"mm", "04",
+	"ss", "05",
+	"A", "PM",
+)
+
+// layout returns the Go layout of the TimeFormat.
+func (m *FileMapping) layout() string {
+	return timeFormatReplacer.Replace(strings.TrimSpace(m.TimeFormat))
+}
+
+func (m *FileMapping) location() (*time.Location, error) {
+	tz := m.TimeZone
+	if tz == "" {
+		exchangeTZ, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
+		tz = string(exchangeTZ)
+	}
+
+	return time.LoadLocation(tz)
+}
+
+// instrumentByValue maps the lower-cased values of the instrument column that tradebooks commonly use.
+var instrumentByValue = map[string]types.Instrument{
+	"equity":  types.InstrumentEquity,
+	"eq":      types.InstrumentEquity,
+	"stock":   types.InstrumentEquity,
+	"stocks":  types.InstrumentEquity,
+	"cash":    types.InstrumentEquity,
+	"future":  types.InstrumentFuture,
+	"futures": types.InstrumentFuture,
+	"fut":     types.InstrumentFuture,
+	"option":  types.InstrumentOption,
+	"options": types.InstrumentOption,
+	"opt":     types.InstrumentOption,
+	"ce":      types.InstrumentOption,
+	"pe":      types.InstrumentOption,
+	"call":    types.InstrumentOption,
+	"put":     types.InstrumentOption,
+	"crypto":  types.InstrumentCrypto,
+}
+
+func parseMappedInstrument(value string) (types.Instrument, bool) {
+	instrument, ok := instrumentByValue[strings.ToLower(strings.TrimSpace(value))]
+	return instrument, ok
+}
+
+// tradeKindByValue maps the lower-cased values of the trade type column that tradebooks commonly use.
+var tradeKindByValue = map[string]types.TradeKind{
+	"buy":    types.TradeKindBuy,
+	"b":      types.TradeKindBuy,
+	"bought": types.TradeKindBuy,
+	"sell":   types.TradeKindSell,
+	"s":      types.TradeKindSell,
+	"sold":   types.TradeKindSell,
+}
+
+type mappedFileAdapter struct {
+	mapping *FileMapping
+}
+
+func (adapter *mappedFileAdapter) GetMetadata(rows [][]string) (*importFileMetadata, error) {
+	m := adapter.mapping
+
+	for rowIdx, row := range rows {
+		columnIdxByHeader := map[string]int{}
+		for columnIdx, colCell := range row {
+			columnIdxByHeader[strings.ToLower(strings.TrimSpace(colCell))] = columnIdx
+		}
+
+		columnIdx := func(header string) int {
+			if header == "" {
+				return -1
+			}
+
+			idx, ok := columnIdxByHeader[strings.ToLower(strings.TrimSpace(header))]
+			if !ok {
+				return -1
+			}
+			return idx
+		}
+
+		metadata := &importFileMetadata{
+			HeaderRowIdx:            rowIdx,
+			symbolColumnIdx:         columnIdx(m.SymbolColumn),
+			tradeTypeColumnIdx:      columnIdx(m.TradeTypeColumn),
+			quantityColumnIdx:       columnIdx(m.QuantityColumn),
+			priceColumnIdx:          columnIdx(m.PriceColumn),
+			dateTimeColumnIdx:       columnIdx(m.TimeColumn),
+			orderIDColumnIdx:        columnIdx(m.OrderIDColumn),
+			instrumentTypeColumnIdx: columnIdx(m.InstrumentColumn),
+		}
+
+		if metadata.symbolColumnIdx < 0 || metadata.tradeTypeColumnIdx < 0 || metadata.quantityColumnIdx < 0 ||
+			metadata.priceColumnIdx < 0 || metadata.dateTimeColumnIdx < 0 {
+			continue
+		}
+
+		if (m.OrderIDColumn != "" && metadata.orderIDColumnIdx < 0) || (m.InstrumentColumn != "" && metadata.instrumentTypeColumnIdx < 0) {
+			continue
+		}
+
+		return metadata, nil
+	}
+
+	return nil, fmt.Errorf("no row has all the mapped columns")
+}
+
+func (adapter *mappedFileAdapter) ParseRow(row []string, metadata *importFileMetadata) (*types.ImportableTrade, error) {
+	m := adapter.mapping
+
+	cell := func(idx int) string {
+		if idx < 0 || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	symbol := strings.ToUpper(cell(metadata.symbolColumnIdx))
+	if symbol == "" {
+		return nil, fmt.Errorf("Symbol is empty in row")
+	}
+
+	tradeTypeStr := cell(metadata.tradeTypeColumnIdx)
+	tradeKind, ok := tradeKindByValue[strings.ToLower(tradeTypeStr)]
+	if !ok {
+		return nil, fmt.Errorf("Invalid trade type in row: %s", tradeTypeStr)
+	}
+
+	quantityStr := cell(metadata.quantityColumnIdx)
+	quantity, err := decimal.NewFromString(strings.ReplaceAll(quantityStr, ",", ""))
+	if err != nil || quantity.IsZero() {
+		return nil, fmt.Errorf("Invalid quantity in row: %s", quantityStr)
+	}
+
+	priceStr := cell(metadata.priceColumnIdx)
+	price, err := decimal.NewFromString(strings.ReplaceAll(priceStr, ",", ""))
+	if err != nil || price.IsNegative() {
+		return nil, fmt.Errorf("Invalid price in row: %s", priceStr)
+	}
+
+	loc, err := m.location()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load timezone for trade: %s", m.TimeZone)
+	}
+
+	timeStr := cell(metadata.dateTimeColumnIdx)
+	tradeTime, err := time.ParseInLocation(m.layout(), timeStr, loc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid time in row: %s", timeStr)
+	}
+
+	instrument := m.Instrument
+	if metadata.instrumentTypeColumnIdx >= 0 {
+		instrumentStr := cell(metadata.instrumentTypeColumnIdx)
+		instrument, ok = parseMappedInstrument(instrumentStr)
+		if !ok {
+			return nil, fmt.Errorf("Invalid instrument in row: %s", instrumentStr)
+		}
+	} else if instrument == "" {
+		instrument = guessInstrumentFromSymbol(symbol)
+	} else {
+		instrument, _ = parseMappedInstrument(string(instrument))
+	}
+
+	// Without an order ID, the trades of a symbol at the same time on the same side are of the same order.
+	orderID := cell(metadata.orderIDColumnIdx)
+	if orderID == "" {
+		orderID = fmt.Sprintf("%s-%s-%d", symbol, tradeKind, tradeTime.Unix())
+	}
+
+	return &types.ImportableTrade{
+		Symbol:     symbol,
+		Instrument: instrument,
+		TradeKind:  tradeKind,
+		Quantity:   quantity.Abs(),
+		Price:      price,
+		OrderID:    orderID,
+		Time:       tradeTime,
+	}, nil
+}
+
+// guessInstrumentFromSymbol returns the Instrument of an NSE style symbol,
+// like NIFTY24DEC25000CE for an option or NATURALGAS25MAYFUT for a future.
+func guessInstrumentFromSymbol(symbol string) types.Instrument {
+	switch {
+	case strings.HasSuffix(symbol, "FUT"):
+		return types.InstrumentFuture
+	case (strings.HasSuffix(symbol, "CE") || strings.HasSuffix(symbol, "PE")) && strings.ContainsAny(symbol, "0123456789"):
+		return types.InstrumentOption
+	default:
+		return types.InstrumentEquity
+	}
+}
@@ -0,0 +1,78 @@
+package broker_integration
+
+import (
+	"arthveda/internal/domain/types"
+	"testing"
+	"time"
+)
+
+func TestMappedFileAdapter(t *testing.T) {
+	mapping := &FileMapping{
+		SymbolColumn:     "Ticker",
+		TradeTypeColumn:  "Side",
+		QuantityColumn:   "Qty",
+		PriceColumn:      "Avg Price",
+		TimeColumn:       "Traded At",
+		InstrumentColumn: "Product",
+		TimeFormat:       "DD/MM/YYYY HH:mm:ss",
+	}
+
+	if err := mapping.Validate(); err != nil {
+		t.Fatalf("expected a valid mapping, got %v", err)
+	}
+
+	rows := [][]string{
+		{"Tradebook of client ABC123"},
+		{},
+		{"Traded At", "Ticker", "Product", "Side", "Qty", "Avg Price"},
+		{"03/06/2024 09:16:05", "infy", "EQ", "B", "1,000", "1,420.50"},
+		{"03/06/2024 09:16:05", "NIFTY24JUN23000CE", "Options", "Sell", "-50", "120"},
+	}
+
+	adapter, err := GetFileAdapter(nil, mapping)
+	if err != nil {
+		t.Fatalf("expected the mapped adapter, got %v", err)
+	}
+
+	metadata, err := adapter.GetMetadata(rows)
+	if err != nil {
+		t.Fatalf("expected the header row to be found, got %v", err)
+	}
+
+	if metadata.HeaderRowIdx != 2 {
+		t.Errorf("expected the header at row 2, got %d", metadata.HeaderRowIdx)
+	}
+
+	equity, err := adapter.ParseRow(rows[3], metadata)
+	if err != nil {
+		t.Fatalf("expected the equity row to parse, got %v", err)
+	}
+
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	if equity.Symbol != "INFY" || equity.Instrument != types.InstrumentEquity || equity.TradeKind != types.TradeKindBuy ||
+		equity.Quantity.String() != "1000" || equity.Price.String() != "1420.5" || !equity.Time.Equal(time.Date(2024, 6, 3, 9, 16, 5, 0, ist)) {
+		t.Errorf("unexpected equity trade %+v", equity)
+	}
+
+	option, err := adapter.ParseRow(rows[4], metadata)
+	if err != nil {
+		t.Fatalf("expected the option row to parse, got %v", err)
+	}
+
+	if option.Instrument != types.InstrumentOption || option.TradeKind != types.TradeKindSell || option.Quantity.String() != "50" {
+		t.Errorf("unexpected option trade %+v", option)
+	}
+
+	// Without an order ID column, the trades of the same order are grouped by symbol, side and time.
+	if option.OrderID == "" || option.OrderID == equity.OrderID {
+		t.Errorf("expected distinct generated order IDs, got %q and %q", equity.OrderID, option.OrderID)
+	}
+
+	if _, err := adapter.ParseRow([]string{"03/06/2024", "INFY", "EQ", "B", "1", "1"}, metadata); err == nil {
+		t.Errorf("expected an error for a time not in the format")
+	}
+
+	if _, err := adapter.GetMetadata([][]string{{"Symbol", "Qty"}}); err == nil {
+		t.Errorf("expected an error for a file without the mapped columns")
+	}
+}
@@ -0,0 +1,192 @@
+package broker_integration
+
+import (
+	"arthveda/internal/domain/symbol"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func readFixture(t *testing.T, name string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	return rows
+}
+
+type expectedTrade struct {
+	symbol     string
+	instrument types.Instrument
+	segment    types.Segment
+	tradeKind  types.TradeKind
+	quantity   string
+	price      string
+	orderID    string
+	time       string // In IST, like "2024-06-03 09:16:05".
+}
+
+func TestFileAdapters(t *testing.T) {
+	tests := []struct {
+		brokerName broker.Name
+		fixture    string
+		trades     []expectedTrade
+	}{
+		{
+			brokerName: broker.BrokerNameDhan,
+			fixture:    "dhan_trade_history.csv",
+			trades: []expectedTrade{
+				{"RELIANCE", types.InstrumentEquity, "", types.TradeKindBuy, "10", "2900.5", "1100000012345678", "2024-06-03 09:16:05"},
+				{"RELIANCE", types.InstrumentEquity, "", types.TradeKindSell, "10", "2920", "1100000012345679", "2024-06-04 14:02:11"},
+				{"NIFTY2462723000CE", types.InstrumentOption, "", types.TradeKindBuy, "50", "120.5", "1200000012345670", "2024-06-05 10:00:00"},
+				{"BANKNIFTY26JUN24FUT", types.InstrumentFuture, "", types.TradeKindSell, "15", "49800", "1200000012345671", "2024-06-05 10:30:00"},
+			},
+		},
+		{
+			brokerName: broker.BrokerName5paisa,
+			fixture:    "5paisa_trade_book.csv",
+			trades: []expectedTrade{
+				{"INFY", types.InstrumentEquity, "", types.TradeKindBuy, "5", "1420", "2100000000000001", "2024-06-03 09:20:00"},
+				{"NIFTY2462723000CE", types.InstrumentOption, "", types.TradeKindSell, "25", "118.75", "2100000000000002", "2024-06-03 11:05:30"},
+				{"NIFTY27JUN24FUT", types.InstrumentFuture, "", types.TradeKindBuy, "25", "23150", "2100000000000003", "2024-06-04 09:45:10"},
+			},
+		},
+		{
+			brokerName: broker.BrokerNameICICIDirect,
+			fixture:    "icici_direct_trade_book.csv",
+			trades: []expectedTrade{
+				{"TCS", types.InstrumentEquity, "", types.TradeKindBuy, "2", "3850.25", "20240603N100001", "2024-06-03 09:15:45"},
+				{"NIFTY2462723500PE", types.InstrumentOption, "", types.TradeKindSell, "50", "95", "20240603N100002", "2024-06-03 10:12:00"},
+				{"RELIANCE27JUN24FUT", types.InstrumentFuture, "", types.TradeKindBuy, "250", "2912.4", "20240603N100003", "2024-06-03 10:40:00"},
+			},
+		},
+		{
+			brokerName: broker.BrokerNameHDFCSecurities,
+			fixture:    "hdfc_securities_trade_book.csv",
+			trades: []expectedTrade{
+				{"HDFCBANK", types.InstrumentEquity, "", types.TradeKindBuy, "20", "1530.1", "1000000000000101", "2024-06-03 09:30:00"},
+				{"BANKNIFTY2462650000CE", types.InstrumentOption, "", types.TradeKindSell, "15", "410.5", "1000000000000102", "2024-06-03 13:15:20"},
+				{"CRUDEOIL19JUN24FUT", types.InstrumentFuture, types.SegmentCommodity, types.TradeKindBuy, "100", "6450", "1000000000000103", "2024-06-04 10:00:00"},
+			},
+		},
+		{
+			brokerName: broker.BrokerNameMotilalOswal,
+			fixture:    "motilal_oswal_trade_book.csv",
+			trades: []expectedTrade{
+				{"SBIN", types.InstrumentEquity, "", types.TradeKindBuy, "100", "830.4", "1300000000000201", "2024-06-03 09:25:00"},
+				{"NIFTY2462722500PE", types.InstrumentOption, "", types.TradeKindBuy, "50", "88.2", "1300000000000202", "2024-06-03 11:00:00"},
+				{"TATAMOTORS27JUN24FUT", types.InstrumentFuture, "", types.TradeKindSell, "1425", "961.35", "1300000000000203", "2024-06-04 12:30:00"},
+			},
+		},
+	}
+
+	ist, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("load IST: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.brokerName), func(t *testing.T) {
+			rows := readFixture(t, tt.fixture)
+
+			adapter, err := GetFileAdapter(&broker.Broker{Name: tt.brokerName}, nil)
+			if err != nil {
+				t.Fatalf("GetFileAdapter: %v", err)
+			}
+
+			metadata, err := adapter.GetMetadata(rows)
+			if err != nil {
+				t.Fatalf("GetMetadata: %v", err)
+			}
+
+			dataRows := rows[metadata.HeaderRowIdx+1:]
+			if len(dataRows) != len(tt.trades) {
+				t.Fatalf("expected %d rows after the header, got %d", len(tt.trades), len(dataRows))
+			}
+
+			for i, row := range dataRows {
+				trade, err := adapter.ParseRow(row, metadata)
+				if err != nil {
+					t.Fatalf("row %d: ParseRow: %v", i, err)
+				}
+
+				want := tt.trades[i]
+				wantTime, _ := time.ParseInLocation("2006-01-02 15:04:05", want.time, ist)
+
+				if trade.Symbol != want.symbol || trade.Instrument != want.instrument || trade.Segment != want.segment ||
+					trade.TradeKind != want.tradeKind || trade.OrderID != want.orderID || !trade.Time.Equal(wantTime) {
+					t.Errorf("row %d: expected %+v, got %+v", i, want, trade)
+				}
+
+				if !trade.Quantity.Equal(decimal.RequireFromString(want.quantity)) || !trade.Price.Equal(decimal.RequireFromString(want.price)) {
+					t.Errorf("row %d: expected %s at %s, got %s at %s", i, want.quantity, want.price, trade.Quantity, trade.Price)
+				}
+
+				// The symbols of derivatives must have an expiry we can read back.
+				if trade.Instrument != types.InstrumentEquity {
+					if _, ok := symbol.ParseContract(trade.Symbol, trade.Time); !ok {
+						t.Errorf("row %d: expected %s to parse as a contract", i, trade.Symbol)
+					}
+				}
+			}
+
+			// The fixture must be detected as the broker's tradebook too.
+			selected, ok := SelectFileAdapterMatch(DetectFileAdapters(rows))
+			if !ok || selected.BrokerName != tt.brokerName {
+				t.Errorf("expected the fixture to be detected as %s, got %+v", tt.brokerName, selected)
+			}
+		})
+	}
+}
+
+func TestZerodhaFileAdapter_XLSXVariants(t *testing.T) {
+	adapter := zerodhaFileAdapter{}
+
+	tradebook := [][]string{
+		{"", "Client ID", "AB1234"},
+		{},
+		{"", "Symbol", "ISIN", "Trade Date", "Exchange", "Segment", "Series", "Trade Type", "Auction", "Quantity", "Price", "Trade ID", "Order ID", "Order Execution Time"},
+		{"", "INFY", "INE009A01021", "2024-06-03", "NSE", "EQ", "EQ", "buy", "false", "5", "1420", "1001", "1300000000000001", "2024-06-03 09:16:05"},
+	}
+
+	metadata, err := adapter.GetMetadata(tradebook)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+
+	trade, err := adapter.ParseRow(tradebook[3], metadata)
+	if err != nil {
+		t.Fatalf("expected the XLSX time to parse, got %v", err)
+	}
+
+	if trade.Time.Hour() != 9 || trade.Time.Minute() != 16 {
+		t.Errorf("expected the trade at 09:16, got %s", trade.Time)
+	}
+
+	pnl := [][]string{
+		{"", "Symbol", "ISIN", "Quantity", "Buy Value", "Sell Value", "Realized P&L"},
+		{"", "INFY", "INE009A01021", "5", "7100", "7250", "150"},
+	}
+
+	if _, err := adapter.GetMetadata(pnl); err != ErrPnLStatement {
+		t.Errorf("expected ErrPnLStatement for a P&L statement, got %v", err)
+	}
+}
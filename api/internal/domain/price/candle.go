@@ -0,0 +1,135 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Candle is the OHLC price of a symbol for an interval that starts at Time.
+type Candle struct {
+	Time  time.Time       `json:"time"`
+	Open  decimal.Decimal `json:"open"`
+	High  decimal.Decimal `json:"high"`
+	Low   decimal.Decimal `json:"low"`
+	Close decimal.Decimal `json:"close"`
+}
+
+// Store provides the candles of a symbol. It is pluggable so that candles can come
+// from a local directory today and from a market data provider later.
+type Store interface {
+	// GetCandles returns the candles of the symbol that start in [from, to], sorted by time.
+	GetCandles(ctx context.Context, symbol string, from, to time.Time) ([]Candle, error)
+}
+
+var ErrNoCandles = errors.New("No candles found for the symbol in the given time range")
+
+// candleTimeLayouts are the layouts we try, in order, to parse the time of a candle.
+var candleTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseCandles parses rows of a CSV file with a header row that has
+// "time" (or "date"/"datetime"/"timestamp"), "open", "high", "low" and "close" columns.
+// The candles are returned sorted by time. Times without a zone are parsed in `loc`.
+func ParseCandles(rows [][]string, loc *time.Location) ([]Candle, error) {
+	if len(rows) < 2 {
+		return nil, ErrNoCandles
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	columnIdx := map[string]int{}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch h {
+		case "date", "datetime", "timestamp":
+			h = "time"
+		}
+		columnIdx[h] = i
+	}
+
+	for _, c := range []string{"time", "open", "high", "low", "close"} {
+		if _, ok := columnIdx[c]; !ok {
+			return nil, fmt.Errorf("Column %q is missing in the candles file", c)
+		}
+	}
+
+	candles := []Candle{}
+
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+
+		// Skip empty rows.
+		if len(row) == 0 || strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		value := func(column string) string {
+			idx := columnIdx[column]
+			if idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+
+		candleTime, err := parseCandleTime(value("time"), loc)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time %q at row %d", value("time"), rowNum)
+		}
+
+		candle := Candle{Time: candleTime}
+
+		for _, f := range []struct {
+			column string
+			dst    *decimal.Decimal
+		}{
+			{"open", &candle.Open},
+			{"high", &candle.High},
+			{"low", &candle.Low},
+			{"close", &candle.Close},
+		} {
+			*f.dst, err = decimal.NewFromString(value(f.column))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s price %q at row %d", f.column, value(f.column), rowNum)
+			}
+		}
+
+		if candle.High.LessThan(candle.Low) {
+			return nil, fmt.Errorf("High price is less than the low price at row %d", rowNum)
+		}
+
+		candles = append(candles, candle)
+	}
+
+	if len(candles) == 0 {
+		return nil, ErrNoCandles
+	}
+
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+
+	return candles, nil
+}
+
+func parseCandleTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range candleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time format: %s", s)
+}
@@ -0,0 +1,163 @@
+package price
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Quote is the last traded price (LTP) of a symbol.
+type Quote struct {
+	Price decimal.Decimal `json:"price"`
+	Time  time.Time       `json:"time"`
+}
+
+// Feed provides the last traded price of symbols. It is pluggable so that quotes can come
+// from a local directory today and from a broker's LTP API later.
+type Feed interface {
+	// GetQuotes returns the quotes of the symbols, keyed by symbol.
+	// Symbols without a quote are left out instead of failing the whole call.
+	GetQuotes(ctx context.Context, symbols []string) (map[string]Quote, error)
+}
+
+// quotesFileName is the file in the price store's directory with the manually maintained quotes.
+const quotesFileName = "quotes.csv"
+
+// localFeed reads manually maintained quotes from "<dir>/quotes.csv" which has the columns
+// "symbol", "price" and an optional "time". A symbol that is not in the quotes file is quoted
+// at the close of the last candle in "<dir>/<SYMBOL>.csv", if there is one.
+type localFeed struct {
+	dir   string
+	store Store
+}
+
+func NewLocalFeed(dir string) Feed {
+	return &localFeed{dir, NewLocalStore(dir)}
+}
+
+func (f *localFeed) GetQuotes(ctx context.Context, symbols []string) (map[string]Quote, error) {
+	quotes := map[string]Quote{}
+
+	if f.dir == "" || len(symbols) == 0 {
+		return quotes, nil
+	}
+
+	manualQuotes, err := f.readQuotesFile()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", quotesFileName, err)
+	}
+
+	for _, symbol := range symbols {
+		if q, ok := manualQuotes[strings.ToUpper(symbol)]; ok {
+			quotes[symbol] = q
+			continue
+		}
+
+		candles, err := f.store.GetCandles(ctx, symbol, time.Time{}, time.Now())
+		if err != nil {
+			if errors.Is(err, ErrNoCandles) {
+				continue
+			}
+			return nil, fmt.Errorf("get candles for %s: %w", symbol, err)
+		}
+
+		if len(candles) == 0 {
+			continue
+		}
+
+		last := candles[len(candles)-1]
+		quotes[symbol] = Quote{Price: last.Close, Time: last.Time}
+	}
+
+	return quotes, nil
+}
+
+func (f *localFeed) readQuotesFile() (map[string]Quote, error) {
+	file, err := os.Open(filepath.Join(f.dir, quotesFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Quote{}, nil
+		}
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read csv: %w", err)
+	}
+
+	return ParseQuotes(rows)
+}
+
+// ParseQuotes parses rows of a CSV file with a header row that has "symbol", "price" (or "ltp"/"last_price")
+// and optionally "time" columns. The quotes are keyed by the upper case symbol.
+func ParseQuotes(rows [][]string) (map[string]Quote, error) {
+	quotes := map[string]Quote{}
+
+	if len(rows) == 0 {
+		return quotes, nil
+	}
+
+	columnIdx := map[string]int{}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch h {
+		case "ltp", "last_price":
+			h = "price"
+		}
+		columnIdx[h] = i
+	}
+
+	for _, c := range []string{"symbol", "price"} {
+		if _, ok := columnIdx[c]; !ok {
+			return nil, fmt.Errorf("Column %q is missing in the quotes file", c)
+		}
+	}
+
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+
+		value := func(column string) string {
+			idx, ok := columnIdx[column]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+
+		symbol := strings.ToUpper(value("symbol"))
+		if symbol == "" {
+			continue
+		}
+
+		p, err := decimal.NewFromString(value("price"))
+		if err != nil || !p.IsPositive() {
+			return nil, fmt.Errorf("Invalid price %q at row %d", value("price"), rowNum)
+		}
+
+		q := Quote{Price: p}
+
+		if value("time") != "" {
+			q.Time, err = parseCandleTime(value("time"), time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid time %q at row %d", value("time"), rowNum)
+			}
+		}
+
+		quotes[symbol] = q
+	}
+
+	return quotes, nil
+}
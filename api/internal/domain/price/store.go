@@ -0,0 +1,69 @@
+package price
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localStore reads the candles of a symbol from "<dir>/<SYMBOL>.csv".
+// The CSV files have the same format as the one accepted by ParseCandles.
+type localStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) Store {
+	return &localStore{dir}
+}
+
+var ErrStoreNotConfigured = errors.New("Price store is not configured. Upload the candles instead")
+
+func (s *localStore) GetCandles(ctx context.Context, symbol string, from, to time.Time) ([]Candle, error) {
+	if s.dir == "" {
+		return nil, ErrStoreNotConfigured
+	}
+
+	// The symbol is part of a path, so we make sure it can't escape the store's directory.
+	name := strings.ToUpper(filepath.Base(filepath.Clean("/" + symbol)))
+
+	f, err := os.Open(filepath.Join(s.dir, name+".csv"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoCandles
+		}
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read csv: %w", err)
+	}
+
+	candles, err := ParseCandles(rows, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterCandles(candles, from, to), nil
+}
+
+// FilterCandles returns the candles that start in [from, to].
+func FilterCandles(candles []Candle, from, to time.Time) []Candle {
+	filtered := []Candle{}
+
+	for _, c := range candles {
+		if c.Time.Before(from) || c.Time.After(to) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+
+	return filtered
+}
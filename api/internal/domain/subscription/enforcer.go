@@ -5,6 +5,7 @@ type Feature string
 const (
 	FeatureAddUserBrokerAccount Feature = "add_user_broker_account"
 	FeatureUpload               Feature = "upload"
+	FeatureTaxReports           Feature = "tax_reports"
 )
 
 type PlanLimitError struct {
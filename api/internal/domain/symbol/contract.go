@@ -0,0 +1,188 @@
+package symbol
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type OptionRight string
+
+const (
+	OptionRightCall OptionRight = "call"
+	OptionRightPut  OptionRight = "put"
+)
+
+// Contract is a future or option symbol decomposed into its parts.
+type Contract struct {
+	Underlying string
+
+	// The expiry date, at midnight UTC. `nil` if it can't be resolved from the symbol.
+	Expiry *time.Time
+
+	// Strike and Right are `nil` for futures.
+	Strike *decimal.Decimal
+	Right  *OptionRight
+}
+
+func (c Contract) IsOption() bool {
+	return c.Right != nil
+}
+
+// DaysToExpiry returns the number of calendar days from `t` to the expiry, or false if the expiry is unknown.
+func (c Contract) DaysToExpiry(t time.Time) (int, bool) {
+	if c.Expiry == nil {
+		return 0, false
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(c.Expiry.Sub(day).Hours() / 24), true
+}
+
+const monthsPattern = `JAN|FEB|MAR|APR|MAY|JUN|JUL|AUG|SEP|OCT|NOV|DEC`
+
+var (
+	// Like NIFTY24JAN22000CE. Some of our file adapters put the day of the expiry in place of the year,
+	// like NIFTY31JUL24900CE, which is resolved with the time of the trade. See resolveNumberedMonth.
+	optionMonthlyRe = regexp.MustCompile(`^(.+?)(\d{2})(` + monthsPattern + `)(\d+(?:\.\d+)?)(CE|PE)$`)
+
+	// Like NIFTY2411822000CE, the year, month and day of a weekly expiry followed by the strike.
+	// The month is 1-9, O, N or D.
+	optionWeeklyRe = regexp.MustCompile(`^(.+?)(\d{2})([1-9OND])(\d{2})(\d+(?:\.\d+)?)(CE|PE)$`)
+
+	// Like NIFTY24JANFUT or NIFTY20OCT25FUT, with the day and year of the expiry.
+	futureRe = regexp.MustCompile(`^(.+?)(\d{2})(` + monthsPattern + `)(\d{2})?FUT$`)
+)
+
+var monthByAbbr = map[string]time.Month{
+	"JAN": time.January, "FEB": time.February, "MAR": time.March, "APR": time.April,
+	"MAY": time.May, "JUN": time.June, "JUL": time.July, "AUG": time.August,
+	"SEP": time.September, "OCT": time.October, "NOV": time.November, "DEC": time.December,
+}
+
+var weeklyMonthByChar = map[byte]time.Month{
+	'1': time.January, '2': time.February, '3': time.March, '4': time.April, '5': time.May,
+	'6': time.June, '7': time.July, '8': time.August, '9': time.September,
+	'O': time.October, 'N': time.November, 'D': time.December,
+}
+
+// ParseContract decomposes a future or option symbol, as built by our broker adapters, into a Contract.
+// The time of a trade of the contract is used to resolve the expiry of symbols that don't have a year.
+// It returns false if the symbol is not a future or option that we recognise.
+func ParseContract(symbol string, tradedAt time.Time) (Contract, bool) {
+	if m := optionMonthlyRe.FindStringSubmatch(symbol); m != nil {
+		nn, _ := strconv.Atoi(m[2])
+		expiry := resolveNumberedMonth(nn, monthByAbbr[m[3]], tradedAt)
+		return newOptionContract(m[1], expiry, m[4], m[5])
+	}
+
+	if m := optionWeeklyRe.FindStringSubmatch(symbol); m != nil {
+		year, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[4])
+		expiry := date(2000+year, weeklyMonthByChar[m[3][0]], day)
+		return newOptionContract(m[1], expiry, m[5], m[6])
+	}
+
+	if m := futureRe.FindStringSubmatch(symbol); m != nil {
+		nn, _ := strconv.Atoi(m[2])
+		month := monthByAbbr[m[3]]
+
+		var expiry *time.Time
+		if m[4] != "" {
+			year, _ := strconv.Atoi(m[4])
+			expiry = date(2000+year, month, nn)
+		} else {
+			expiry = resolveNumberedMonth(nn, month, tradedAt)
+		}
+
+		return Contract{Underlying: m[1], Expiry: expiry}, true
+	}
+
+	return Contract{}, false
+}
+
+func newOptionContract(underlying string, expiry *time.Time, strikeStr, rightStr string) (Contract, bool) {
+	strike, err := decimal.NewFromString(strikeStr)
+	if err != nil {
+		return Contract{}, false
+	}
+
+	right := OptionRightCall
+	if rightStr == "PE" {
+		right = OptionRightPut
+	}
+
+	return Contract{
+		Underlying: underlying,
+		Expiry:     expiry,
+		Strike:     &strike,
+		Right:      &right,
+	}, true
+}
+
+// maxMonthlyContractSpan is how far ahead of a trade we expect the expiry of a monthly contract to be.
+// NSE lists monthly contracts for the next three months.
+const maxMonthlyContractSpan = 100 * 24 * time.Hour
+
+// resolveNumberedMonth resolves the expiry of a symbol with a number before the month, like "24JAN".
+// The number is the year of a monthly contract in the exchange's format, but some of our adapters put
+// the day of the expiry there instead. We prefer the monthly contract if it expires within a few months
+// after the trade, otherwise we take the number as the day of the month of the next such date.
+func resolveNumberedMonth(nn int, month time.Month, tradedAt time.Time) *time.Time {
+	asYear := monthlyExpiry(2000+nn, month)
+
+	if tradedAt.IsZero() {
+		return &asYear
+	}
+
+	tradedOn := time.Date(tradedAt.Year(), tradedAt.Month(), tradedAt.Day(), 0, 0, 0, 0, time.UTC)
+
+	if !asYear.Before(tradedOn) && asYear.Sub(tradedOn) <= maxMonthlyContractSpan {
+		return &asYear
+	}
+
+	asDay := date(tradedOn.Year(), month, nn)
+	if asDay != nil && asDay.Before(tradedOn) {
+		asDay = date(tradedOn.Year()+1, month, nn)
+	}
+
+	if asDay != nil {
+		return asDay
+	}
+
+	if !asYear.Before(tradedOn) {
+		return &asYear
+	}
+
+	return nil
+}
+
+// nseMonthlyExpiryChangedOn is when NSE moved the monthly expiry from the last Thursday to the last Tuesday of the month.
+var nseMonthlyExpiryChangedOn = time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+// monthlyExpiry returns the NSE monthly expiry of the month. It ignores exchange holidays.
+func monthlyExpiry(year int, month time.Month) time.Time {
+	weekday := time.Thursday
+	if !time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Before(nseMonthlyExpiryChangedOn) {
+		weekday = time.Tuesday
+	}
+
+	// Start from the last day of the month and walk back to the weekday.
+	d := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	for d.Weekday() != weekday {
+		d = d.AddDate(0, 0, -1)
+	}
+
+	return d
+}
+
+// date returns the date, or nil if the day doesn't exist in the month.
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if day < 1 || d.Month() != month {
+		return nil
+	}
+	return &d
+}
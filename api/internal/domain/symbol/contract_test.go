@@ -0,0 +1,83 @@
+package symbol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseContract(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		symbol     string
+		tradedAt   time.Time
+		underlying string
+		expiry     time.Time
+		strike     string // Empty for futures.
+		right      OptionRight
+	}{
+		// Monthly option with the year, expiring on the last Thursday.
+		{"NIFTY24JAN22000CE", day(2024, 1, 10), "NIFTY", day(2024, 1, 25), "22000", OptionRightCall},
+		// Monthly option with the day of the expiry in place of the year.
+		{"BANKNIFTY31JUL48500PE", day(2024, 7, 15), "BANKNIFTY", day(2024, 7, 31), "48500", OptionRightPut},
+		// Weekly options.
+		{"NIFTY2411822000CE", day(2024, 1, 15), "NIFTY", day(2024, 1, 18), "22000", OptionRightCall},
+		{"NIFTY24O1722000PE", day(2024, 10, 14), "NIFTY", day(2024, 10, 17), "22000", OptionRightPut},
+		{"SENSEX27NOV100000CE", day(2025, 11, 20), "SENSEX", day(2025, 11, 27), "100000", OptionRightCall},
+		{"NIFTY24JAN21950.5PE", day(2024, 1, 10), "NIFTY", day(2024, 1, 25), "21950.5", OptionRightPut},
+		// Monthly expiry moved to the last Tuesday.
+		{"NIFTY25DEC26000CE", day(2025, 12, 1), "NIFTY", day(2025, 12, 30), "26000", OptionRightCall},
+		// Futures.
+		{"NIFTY24JANFUT", day(2024, 1, 10), "NIFTY", day(2024, 1, 25), "", ""},
+		{"NIFTY20OCT25FUT", day(2025, 10, 1), "NIFTY", day(2025, 10, 20), "", ""},
+	}
+
+	for _, tt := range tests {
+		c, ok := ParseContract(tt.symbol, tt.tradedAt)
+		if !ok {
+			t.Errorf("%s: expected to parse", tt.symbol)
+			continue
+		}
+
+		if c.Underlying != tt.underlying {
+			t.Errorf("%s: expected underlying %s, got %s", tt.symbol, tt.underlying, c.Underlying)
+		}
+
+		if c.Expiry == nil || !c.Expiry.Equal(tt.expiry) {
+			t.Errorf("%s: expected expiry %s, got %v", tt.symbol, tt.expiry.Format(time.DateOnly), c.Expiry)
+		}
+
+		if tt.strike == "" {
+			if c.IsOption() || c.Strike != nil {
+				t.Errorf("%s: expected a future, got %+v", tt.symbol, c)
+			}
+			continue
+		}
+
+		if !c.IsOption() || !c.Strike.Equal(decimal.RequireFromString(tt.strike)) || *c.Right != tt.right {
+			t.Errorf("%s: expected strike %s and right %s, got %+v", tt.symbol, tt.strike, tt.right, c)
+		}
+	}
+
+	for _, symbol := range []string{"RELIANCE", "NIFTY 50", "NIFTY24JAN"} {
+		if _, ok := ParseContract(symbol, day(2024, 1, 10)); ok {
+			t.Errorf("%s: expected not to parse", symbol)
+		}
+	}
+}
+
+func TestContract_DaysToExpiry(t *testing.T) {
+	c, _ := ParseContract("NIFTY24JAN22000CE", time.Time{})
+
+	days, ok := c.DaysToExpiry(time.Date(2024, 1, 22, 15, 10, 0, 0, time.UTC))
+	if !ok || days != 3 {
+		t.Errorf("expected 3 days to expiry, got %d", days)
+	}
+
+	days, ok = c.DaysToExpiry(time.Date(2024, 1, 25, 9, 15, 0, 0, time.UTC))
+	if !ok || days != 0 {
+		t.Errorf("expected 0 days to expiry on the expiry day, got %d", days)
+	}
+}
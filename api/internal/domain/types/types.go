@@ -22,11 +22,38 @@ const (
 	InstrumentCrypto Instrument = "crypto"
 )
 
+// Segment is the market segment of an Instrument's contract. Futures and options are traded on
+// the equity, commodity (MCX) and currency (NSE-CDS) segments, and have different charges on each.
+type Segment string
+
+const (
+	SegmentEquity    Segment = "equity"
+	SegmentCommodity Segment = "commodity"
+	SegmentCurrency  Segment = "currency"
+)
+
+func (s Segment) IsValid() bool {
+	switch s {
+	case SegmentEquity, SegmentCommodity, SegmentCurrency:
+		return true
+	}
+	return false
+}
+
+// OrDefault returns the Segment, or SegmentEquity if it is empty.
+func (s Segment) OrDefault() Segment {
+	if s == "" {
+		return SegmentEquity
+	}
+	return s
+}
+
 // ImportableTrade represents a standardized trade format that can be imported
 // from various sources (file imports, broker APIs, etc.)
 type ImportableTrade struct {
 	Symbol     string          `json:"symbol"`
 	Instrument Instrument      `json:"instrument"`
+	Segment    Segment         `json:"segment"` // Empty for the equity segment.
 	TradeKind  TradeKind       `json:"trade_kind"`
 	Quantity   decimal.Decimal `json:"quantity"`
 	Price      decimal.Decimal `json:"price"`
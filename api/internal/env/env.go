@@ -29,6 +29,7 @@ var (
 	ZERODHA_API_SECRET    string // ClientSecret
 	BODHVEDA_API_URL      string
 	BODHVEDA_API_KEY      string
+	PRICE_STORE_DIR       string // Directory with a "<SYMBOL>.csv" file of candles for every symbol and a "quotes.csv" of last prices.
 )
 
 func IsProd() bool {
@@ -64,6 +65,7 @@ func Init(path string) {
 	ZERODHA_API_SECRET = os.Getenv("ARTHVEDA_ZERODHA_API_SECRET")
 	BODHVEDA_API_URL = os.Getenv("ARTHVEDA_BODHVEDA_API_URL")
 	BODHVEDA_API_KEY = os.Getenv("ARTHVEDA_BODHVEDA_SERVER_API_KEY")
+	PRICE_STORE_DIR = os.Getenv("ARTHVEDA_PRICE_STORE_DIR")
 
 	// TODO: We should validate the environment variables here to ensure they are set correctly.
 
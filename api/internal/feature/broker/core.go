@@ -17,22 +17,32 @@ type Broker struct {
 type Name string
 
 const (
+	BrokerName5paisa          Name = "5paisa"
 	BrokerNameAngelOne        Name = "Angel One"
+	BrokerNameDhan            Name = "Dhan"
 	BrokerNameFyers           Name = "Fyers"
 	BrokerNameGroww           Name = "Groww"
+	BrokerNameHDFCSecurities  Name = "HDFC Securities"
+	BrokerNameICICIDirect     Name = "ICICI Direct"
 	BrokerNameINDmoney        Name = "INDmoney"
 	BrokerNameKotakSecurities Name = "Kotak Securities"
+	BrokerNameMotilalOswal    Name = "Motilal Oswal"
 	BrokerNameUpstox          Name = "Upstox"
 	BrokerNameZerodha         Name = "Zerodha"
 	BrokerNameOther           Name = "Other"
 )
 
 var supportedInstrumentsByBroker = map[Name][]types.Instrument{
+	BrokerName5paisa:          {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
 	BrokerNameAngelOne:        {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
+	BrokerNameDhan:            {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
 	BrokerNameFyers:           {types.InstrumentOption},
 	BrokerNameGroww:           {types.InstrumentEquity},
+	BrokerNameHDFCSecurities:  {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
+	BrokerNameICICIDirect:     {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
 	BrokerNameINDmoney:        {types.InstrumentEquity, types.InstrumentOption},
 	BrokerNameKotakSecurities: {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
+	BrokerNameMotilalOswal:    {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
 	BrokerNameUpstox:          {types.InstrumentEquity, types.InstrumentOption},
 	BrokerNameZerodha:         {types.InstrumentEquity, types.InstrumentFuture, types.InstrumentOption},
 }
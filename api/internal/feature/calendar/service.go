@@ -3,6 +3,7 @@ package calendar
 import (
 	"arthveda/internal/common"
 	"arthveda/internal/domain/subscription"
+	"arthveda/internal/feature/cashflow"
 	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/trade"
 	"arthveda/internal/logger"
@@ -17,11 +18,13 @@ import (
 
 type Service struct {
 	positionRepository position.ReadWriter
+	cashFlowRepository cashflow.Reader
 }
 
-func NewService(positionRepository position.ReadWriter) *Service {
+func NewService(positionRepository position.ReadWriter, cashFlowRepository cashflow.Reader) *Service {
 	return &Service{
 		positionRepository,
+		cashFlowRepository,
 	}
 }
 
@@ -65,7 +68,6 @@ type calendarYearly struct {
 type GetCalendarAllResult map[int]calendarYearly // key is year (e.g., 2025)
 
 func (s *Service) GetAll(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer) (*GetCalendarAllResult, service.Error, error) {
-	l := logger.Get()
 	result := GetCalendarAllResult{}
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	tradeTimeRange := &common.DateRangeFilter{}
@@ -93,21 +95,25 @@ func (s *Service) GetAll(ctx context.Context, userID uuid.UUID, tz *time.Locatio
 	rangeStart, rangeEnd := position.GetRangeBasedOnTrades(positions)
 	positionsFiltered := position.FilterPositionsWithRealisingTradesUpTo(positions, rangeEnd, tz)
 
-	for _, pos := range positionsFiltered {
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			l.Errorw("failed to compute smart trades for position", "position_id", pos.ID, "error", err)
-			continue
-		}
-	}
-
 	// Maps to track unique position IDs for each day, month, and year.
 	positionIDsByYear := make(map[int]map[uuid.UUID]struct{})
 	positionIDsByMonth := make(map[int]map[string]map[uuid.UUID]struct{})
 	positionIDsByDay := make(map[int]map[string]map[int]map[uuid.UUID]struct{})
 
+	cashFlows, err := s.cashFlowRepository.Search(ctx, userID, cashflow.SearchFilter{
+		Time: &common.DateRangeFilter{From: &rangeStart, To: &rangeEnd},
+	})
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	cashFlowPositions, err := position.FindCashFlowPositions(ctx, s.positionRepository, userID, cashFlows)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
 	// Use position.GetPnLBuckets to get daily buckets for the calendar.
-	buckets := position.GetPnLBuckets(positionsFiltered, common.BucketPeriodDaily, rangeStart, rangeEnd, tz)
+	buckets := position.GetPnLBuckets(positionsFiltered, cashFlows, cashFlowPositions, common.BucketPeriodDaily, rangeStart, rangeEnd, tz)
 
 	// Build the calendar result from buckets
 	for _, bucket := range buckets {
@@ -343,12 +349,6 @@ func (s *Service) GetDay(ctx context.Context, userID uuid.UUID, tz *time.Locatio
 	filteredPositions := []*position.Position{}
 
 	for _, pos := range positionsWithRealizedTrades {
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			l.Errorw("failed to compute smart trades for position", "position_id", pos.ID, "error", err)
-			continue
-		}
-
 		realisedStatsByTradeID := position.GetRealisedStatsUptoATradeByTradeID([]*position.Position{pos})
 
 		filteredTrades := []*trade.Trade{}
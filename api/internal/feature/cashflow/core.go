@@ -0,0 +1,144 @@
+// Package cashflow holds the dividends, interest and other cash credited to or debited from
+// a Position or a UserBrokerAccount that aren't trades.
+package cashflow
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type Kind string
+
+const (
+	KindDividend Kind = "dividend"
+	KindInterest Kind = "interest"
+	KindOther    Kind = "other"
+)
+
+func (k Kind) IsValid() bool {
+	switch k {
+	case KindDividend, KindInterest, KindOther:
+		return true
+	}
+	return false
+}
+
+// CashFlow represents the `cash_flow` table in the database.
+// A CashFlow belongs to a Position, a UserBrokerAccount, or both.
+type CashFlow struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	PositionID          *uuid.UUID `json:"position_id" db:"position_id"`
+	UserBrokerAccountID *uuid.UUID `json:"user_broker_account_id" db:"user_broker_account_id"`
+
+	Kind Kind      `json:"kind" db:"kind"`
+	Time time.Time `json:"time" db:"time"`
+
+	// Positive for a credit and negative for a debit. It is in the currency of the Position
+	// for a CashFlow of a Position and in the home currency otherwise.
+	Amount decimal.Decimal `json:"amount" db:"amount"`
+
+	Note string `json:"note" db:"note"`
+}
+
+type CreatePayload struct {
+	PositionID          *uuid.UUID      `json:"position_id"`
+	UserBrokerAccountID *uuid.UUID      `json:"user_broker_account_id"`
+	Kind                Kind            `json:"kind"`
+	Time                time.Time       `json:"time"`
+	Amount              decimal.Decimal `json:"amount"`
+	Note                string          `json:"note"`
+}
+
+type UpdatePayload struct {
+	Kind   Kind            `json:"kind"`
+	Time   time.Time       `json:"time"`
+	Amount decimal.Decimal `json:"amount"`
+	Note   string          `json:"note"`
+}
+
+var (
+	ErrInvalidKind   = errors.New("Cash flow kind must be one of dividend, interest or other")
+	ErrInvalidTime   = errors.New("Cash flow time is required")
+	ErrInvalidAmount = errors.New("Cash flow amount must not be zero")
+	ErrNoOwner       = errors.New("Cash flow must belong to a position or a broker account")
+)
+
+func validate(kind Kind, t time.Time, amount decimal.Decimal) error {
+	if !kind.IsValid() {
+		return ErrInvalidKind
+	}
+
+	if t.IsZero() {
+		return ErrInvalidTime
+	}
+
+	if amount.IsZero() {
+		return ErrInvalidAmount
+	}
+
+	return nil
+}
+
+func New(userID uuid.UUID, payload CreatePayload) (*CashFlow, error) {
+	if payload.PositionID == nil && payload.UserBrokerAccountID == nil {
+		return nil, ErrNoOwner
+	}
+
+	if err := validate(payload.Kind, payload.Time, payload.Amount); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CashFlow{
+		ID:                  id,
+		CreatedBy:           userID,
+		CreatedAt:           time.Now().UTC(),
+		PositionID:          payload.PositionID,
+		UserBrokerAccountID: payload.UserBrokerAccountID,
+		Kind:                payload.Kind,
+		Time:                payload.Time.UTC(),
+		Amount:              payload.Amount,
+		Note:                strings.TrimSpace(payload.Note),
+	}, nil
+}
+
+func (c *CashFlow) Update(payload UpdatePayload) error {
+	if err := validate(payload.Kind, payload.Time, payload.Amount); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	c.Kind = payload.Kind
+	c.Time = payload.Time.UTC()
+	c.Amount = payload.Amount
+	c.Note = strings.TrimSpace(payload.Note)
+	c.UpdatedAt = &now
+
+	return nil
+}
+
+// Sum returns the total amount of the CashFlows that happened at or before `end`.
+// A zero `end` includes all of them.
+func Sum(cashFlows []*CashFlow, end time.Time) decimal.Decimal {
+	total := decimal.Zero
+	for _, c := range cashFlows {
+		if !end.IsZero() && c.Time.After(end) {
+			continue
+		}
+		total = total.Add(c.Amount)
+	}
+	return total
+}
@@ -0,0 +1,183 @@
+package cashflow
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/dbx"
+	"arthveda/internal/repository"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	GetByID(ctx context.Context, userID, cashFlowID uuid.UUID) (*CashFlow, error)
+	// ListByPositionIDs returns the CashFlows of the Positions, sorted by time.
+	ListByPositionIDs(ctx context.Context, positionIDs []uuid.UUID) ([]*CashFlow, error)
+	// Search returns the CashFlows of the user that match the filter, sorted by time.
+	Search(ctx context.Context, userID uuid.UUID, filter SearchFilter) ([]*CashFlow, error)
+}
+
+type Writer interface {
+	Create(ctx context.Context, cashFlow *CashFlow) error
+	Update(ctx context.Context, cashFlow *CashFlow) error
+	Delete(ctx context.Context, cashFlowID uuid.UUID) error
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+type SearchFilter struct {
+	PositionID          *uuid.UUID              `json:"position_id"`
+	UserBrokerAccountID *uuid.UUID              `json:"user_broker_account_id"`
+	Kind                *Kind                   `json:"kind"`
+	Time                *common.DateRangeFilter `json:"time"`
+}
+
+//
+// PostgreSQL implementation
+//
+
+type cashFlowRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *cashFlowRepository {
+	return &cashFlowRepository{db}
+}
+
+const cashFlowColumns = `id, created_by, created_at, updated_at, position_id, user_broker_account_id, kind, time, amount, note`
+
+func scanCashFlows(rows pgx.Rows) ([]*CashFlow, error) {
+	defer rows.Close()
+
+	cashFlows := []*CashFlow{}
+	for rows.Next() {
+		var c CashFlow
+		err := rows.Scan(&c.ID, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt, &c.PositionID, &c.UserBrokerAccountID, &c.Kind, &c.Time, &c.Amount, &c.Note)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		cashFlows = append(cashFlows, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return cashFlows, nil
+}
+
+func (r *cashFlowRepository) GetByID(ctx context.Context, userID, cashFlowID uuid.UUID) (*CashFlow, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `SELECT `+cashFlowColumns+` FROM cash_flow WHERE id = $1 AND created_by = $2`, cashFlowID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	cashFlows, err := scanCashFlows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cashFlows) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return cashFlows[0], nil
+}
+
+func (r *cashFlowRepository) ListByPositionIDs(ctx context.Context, positionIDs []uuid.UUID) ([]*CashFlow, error) {
+	if len(positionIDs) == 0 {
+		return []*CashFlow{}, nil
+	}
+
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		SELECT `+cashFlowColumns+`
+		FROM cash_flow
+		WHERE position_id = ANY($1)
+		ORDER BY time ASC
+	`, positionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanCashFlows(rows)
+}
+
+func (r *cashFlowRepository) Search(ctx context.Context, userID uuid.UUID, filter SearchFilter) ([]*CashFlow, error) {
+	where := []string{"created_by = @created_by"}
+	args := pgx.NamedArgs{"created_by": userID}
+
+	if filter.PositionID != nil {
+		where = append(where, "position_id = @position_id")
+		args["position_id"] = *filter.PositionID
+	}
+
+	if filter.UserBrokerAccountID != nil {
+		where = append(where, "user_broker_account_id = @user_broker_account_id")
+		args["user_broker_account_id"] = *filter.UserBrokerAccountID
+	}
+
+	if filter.Kind != nil && *filter.Kind != "" {
+		where = append(where, "kind = @kind")
+		args["kind"] = *filter.Kind
+	}
+
+	if filter.Time != nil {
+		if filter.Time.From != nil {
+			where = append(where, "time >= @time_from")
+			args["time_from"] = *filter.Time.From
+		}
+		if filter.Time.To != nil {
+			where = append(where, "time <= @time_to")
+			args["time_to"] = *filter.Time.To
+		}
+	}
+
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		SELECT `+cashFlowColumns+`
+		FROM cash_flow
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY time ASC
+	`, args)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanCashFlows(rows)
+}
+
+func (r *cashFlowRepository) Create(ctx context.Context, c *CashFlow) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO cash_flow (`+cashFlowColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, c.ID, c.CreatedBy, c.CreatedAt, c.UpdatedAt, c.PositionID, c.UserBrokerAccountID, c.Kind, c.Time, c.Amount, c.Note)
+	return err
+}
+
+func (r *cashFlowRepository) Update(ctx context.Context, c *CashFlow) error {
+	tag, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		UPDATE cash_flow
+		SET kind = $1, time = $2, amount = $3, note = $4, updated_at = $5
+		WHERE id = $6
+	`, c.Kind, c.Time, c.Amount, c.Note, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *cashFlowRepository) Delete(ctx context.Context, cashFlowID uuid.UUID) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `DELETE FROM cash_flow WHERE id = $1`, cashFlowID)
+	return err
+}
@@ -0,0 +1,111 @@
+package charge
+
+import (
+	"arthveda/internal/domain/types"
+	"errors"
+	"fmt"
+)
+
+type BrokeragePlanRuleKind string
+
+const (
+	BrokeragePlanRuleKindPerOrder BrokeragePlanRuleKind = "per_order" // A flat amount per order.
+	BrokeragePlanRuleKindPercent  BrokeragePlanRuleKind = "percent"   // A percentage of the trade value.
+	BrokeragePlanRuleKindPerLot   BrokeragePlanRuleKind = "per_lot"   // An amount per lot.
+)
+
+func (k BrokeragePlanRuleKind) IsValid() bool {
+	switch k {
+	case BrokeragePlanRuleKindPerOrder, BrokeragePlanRuleKindPercent, BrokeragePlanRuleKindPerLot:
+		return true
+	default:
+		return false
+	}
+}
+
+// BrokeragePlan is a user's own brokerage plan on a broker account, for negotiated or flat-fee plans
+// and for brokers we don't have rates of. It replaces the brokerage of the broker's default plan,
+// the statutory charges are still calculated with the Schedule.
+type BrokeragePlan struct {
+	// The first rule that matches a trade is used. The broker's default brokerage is used if none matches.
+	Rules []BrokeragePlanRule `json:"rules"`
+}
+
+// BrokeragePlanRule is the brokerage on the trades it matches.
+// An empty Instrument, Segment or EquityTradeKind matches all of them.
+type BrokeragePlanRule struct {
+	Instrument types.Instrument `json:"instrument,omitempty"`
+	Segment    types.Segment    `json:"segment,omitempty"`
+	// Only for equity, one of EquityTradeIntraday or EquityTradeDelivery.
+	EquityTradeKind string `json:"equity_trade_kind,omitempty"`
+
+	Kind BrokeragePlanRuleKind `json:"kind"`
+
+	// The amount per order for BrokeragePlanRuleKindPerOrder, or per lot for BrokeragePlanRuleKindPerLot.
+	Amount float64 `json:"amount"`
+
+	// The percentage of the trade value for BrokeragePlanRuleKindPercent.
+	Percent float64 `json:"percent"`
+
+	// The quantity in a lot for BrokeragePlanRuleKindPerLot. The quantity is in lots if 0.
+	LotSize float64 `json:"lot_size"`
+
+	// The brokerage of a trade is floored at Min and capped at Max. It isn't capped if Max is 0.
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+var ErrInvalidBrokeragePlanRuleKind = errors.New("Brokerage plan rule kind must be one of per_order, percent or per_lot")
+
+// Validate returns the first invalid rule of the plan as an error.
+func (p *BrokeragePlan) Validate() error {
+	for i, r := range p.Rules {
+		if !r.Kind.IsValid() {
+			return ErrInvalidBrokeragePlanRuleKind
+		}
+
+		if r.Segment != "" && !r.Segment.IsValid() {
+			return fmt.Errorf("Brokerage plan rule %d has an invalid segment", i+1)
+		}
+
+		if r.EquityTradeKind != "" && r.EquityTradeKind != EquityTradeIntraday && r.EquityTradeKind != EquityTradeDelivery {
+			return fmt.Errorf("Brokerage plan rule %d equity trade kind must be intraday or delivery", i+1)
+		}
+
+		if r.Amount < 0 || r.Percent < 0 || r.LotSize < 0 || r.Min < 0 || r.Max < 0 {
+			return fmt.Errorf("Brokerage plan rule %d cannot have negative amounts", i+1)
+		}
+
+		if r.Max > 0 && r.Min > r.Max {
+			return fmt.Errorf("Brokerage plan rule %d minimum cannot be more than its maximum", i+1)
+		}
+	}
+
+	return nil
+}
+
+// Rule returns the first rule of the plan that matches the trade.
+// `equityTradeKind` is ignored for other instruments.
+func (p *BrokeragePlan) Rule(instrument types.Instrument, segment types.Segment, equityTradeKind string) (BrokeragePlanRule, bool) {
+	if instrument != types.InstrumentEquity {
+		equityTradeKind = ""
+	}
+
+	for _, r := range p.Rules {
+		if r.Instrument != "" && r.Instrument != instrument {
+			continue
+		}
+
+		if r.Segment != "" && r.Segment != segment.OrDefault() {
+			continue
+		}
+
+		if r.EquityTradeKind != "" && r.EquityTradeKind != equityTradeKind {
+			continue
+		}
+
+		return r, true
+	}
+
+	return BrokeragePlanRule{}, false
+}
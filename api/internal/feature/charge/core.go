@@ -0,0 +1,306 @@
+// Package charge holds the versioned, effective-dated schedules of the brokerage and statutory
+// charge rates that the charges of trades are calculated with.
+package charge
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The equity trade kinds that equity rates are for.
+const (
+	EquityTradeIntraday = "intraday"
+	EquityTradeDelivery = "delivery"
+)
+
+// Schedule represents the `charge_schedule` table in the database.
+// A Schedule has all the rates that apply to trades from its effective date until the next Schedule's.
+type Schedule struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// The date, in IST, from which the Schedule applies. At midnight UTC.
+	EffectiveFrom time.Time `json:"effective_from" db:"effective_from"`
+
+	// A Schedule added for the same effective date as an existing one gets the next
+	// version and replaces it. Used to correct a Schedule.
+	Version int `json:"version" db:"version"`
+
+	Note  string `json:"note" db:"note"`
+	Rates Rates  `json:"rates" db:"rates"`
+}
+
+type Rates struct {
+	Statutory []StatutoryRates `json:"statutory"`
+	Brokerage []BrokerageRates `json:"brokerage"`
+	// The fees of the FeeModelKindUS fee model. The default Schedule's are used if nil.
+	US *USRates `json:"us,omitempty"`
+}
+
+// StatutoryRates are the taxes and exchange charges on an instrument. All the rates are percentages.
+type StatutoryRates struct {
+	Instrument types.Instrument `json:"instrument"`
+	Segment    types.Segment    `json:"segment,omitempty"` // The equity segment if empty.
+	// Only for equity, one of EquityTradeIntraday or EquityTradeDelivery.
+	EquityTradeKind string `json:"equity_trade_kind,omitempty"`
+
+	// STT, or CTT for the commodity segment.
+	STTPercentOnBuy  float64 `json:"stt_percent_on_buy"`
+	STTPercentOnSell float64 `json:"stt_percent_on_sell"`
+
+	ExchangeTransactionPercentForNSE float64 `json:"exchange_transaction_percent_for_nse"`
+	ExchangeTransactionPercentForBSE float64 `json:"exchange_transaction_percent_for_bse"`
+	ExchangeTransactionPercentForMCX float64 `json:"exchange_transaction_percent_for_mcx"`
+
+	StampPercentOnBuy                float64 `json:"stamp_percent_on_buy"`
+	SEBIPercent                      float64 `json:"sebi_percent"`
+	NSEInvestorProtectionFundPercent float64 `json:"nse_investor_protection_fund_percent"`
+
+	// GST is charged on the brokerage, SEBI and exchange transaction charges.
+	GSTPercent float64 `json:"gst_percent"`
+}
+
+// BrokerageRates are the charges of a broker on an instrument.
+type BrokerageRates struct {
+	Broker     broker.Name      `json:"broker"`
+	Instrument types.Instrument `json:"instrument"`
+	Segment    types.Segment    `json:"segment,omitempty"` // The equity segment if empty.
+	// Only for equity, one of EquityTradeIntraday or EquityTradeDelivery.
+	EquityTradeKind string `json:"equity_trade_kind,omitempty"`
+
+	Brokerage Slab `json:"brokerage"`
+	// Only charged on the sell trades of equity delivery.
+	DPCharges Slab `json:"dp_charges"`
+}
+
+// Slab is a percentage of the trade value, capped at Max and floored at Min.
+type Slab struct {
+	Percent float64 `json:"percent"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+// StatutoryRates returns the statutory rates of the instrument on the segment.
+// `equityTradeKind` is ignored for other instruments.
+func (s *Schedule) StatutoryRates(instrument types.Instrument, segment types.Segment, equityTradeKind string) (StatutoryRates, bool) {
+	if instrument != types.InstrumentEquity {
+		equityTradeKind = ""
+	}
+
+	for _, r := range s.Rates.Statutory {
+		if r.Instrument == instrument && r.Segment.OrDefault() == segment.OrDefault() && r.EquityTradeKind == equityTradeKind {
+			return r, true
+		}
+	}
+
+	return StatutoryRates{}, false
+}
+
+// BrokerageRates returns the rates of the broker on the instrument on the segment. Brokers mostly charge
+// the same on all the segments, so the rates of the equity segment are used if the segment has none.
+// `equityTradeKind` is ignored for other instruments.
+func (s *Schedule) BrokerageRates(brokerName broker.Name, instrument types.Instrument, segment types.Segment, equityTradeKind string) (BrokerageRates, bool) {
+	if instrument != types.InstrumentEquity {
+		equityTradeKind = ""
+	}
+
+	var equitySegmentRates *BrokerageRates
+
+	for i, r := range s.Rates.Brokerage {
+		if r.Broker != brokerName || r.Instrument != instrument || r.EquityTradeKind != equityTradeKind {
+			continue
+		}
+
+		if r.Segment.OrDefault() == segment.OrDefault() {
+			return r, true
+		}
+
+		if r.Segment.OrDefault() == types.SegmentEquity {
+			equitySegmentRates = &s.Rates.Brokerage[i]
+		}
+	}
+
+	if equitySegmentRates != nil {
+		return *equitySegmentRates, true
+	}
+
+	return BrokerageRates{}, false
+}
+
+// USRates returns the US regulatory and clearing fees of the Schedule,
+// or of the default Schedule if it was added without them.
+func (s *Schedule) USRates() USRates {
+	if s.Rates.US != nil {
+		return *s.Rates.US
+	}
+
+	if us := defaultSchedule.Rates.US; us != nil {
+		return *us
+	}
+
+	return USRates{}
+}
+
+var (
+	ErrInvalidEffectiveFrom = errors.New("Charge schedule effective date is required")
+	ErrNoRates              = errors.New("Charge schedule must have statutory rates")
+)
+
+// New parses a Schedule from JSON, as in default_schedule.json.
+func New(data []byte) (*Schedule, error) {
+	var s Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse charge schedule: %w", err)
+	}
+
+	if s.EffectiveFrom.IsZero() {
+		return nil, ErrInvalidEffectiveFrom
+	}
+
+	if len(s.Rates.Statutory) == 0 {
+		return nil, ErrNoRates
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	s.ID = id
+	s.CreatedAt = time.Now().UTC()
+	s.EffectiveFrom = time.Date(s.EffectiveFrom.Year(), s.EffectiveFrom.Month(), s.EffectiveFrom.Day(), 0, 0, 0, 0, time.UTC)
+
+	return &s, nil
+}
+
+// The rates in effect since SEBI's true-to-label circular of 1 October 2024.
+// Trades before that are charged with them too, as we don't have older rates.
+//
+//go:embed default_schedule.json
+var defaultScheduleJSON []byte
+
+// defaultSchedule is the Schedule that ships with Arthveda. It is parsed once, as the charges of every trade are calculated with it or on top of it.
+var defaultSchedule = func() *Schedule {
+	s, err := New(defaultScheduleJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default charge schedule: %s", err))
+	}
+	return s
+}()
+
+// Schedules are all the Schedules, sorted by effective date and version.
+type Schedules []*Schedule
+
+// NewSchedules returns the default Schedule along with `schedules`, as stored in the database.
+//
+// A stored Schedule only needs the rates that changed. Each Schedule is merged onto the one before it,
+// and the earliest onto the default one, so the rates it leaves out stay as they were.
+// A stored Schedule replaces the default one if they have the same effective date and version.
+func NewSchedules(schedules []*Schedule) Schedules {
+	all := append(Schedules{defaultSchedule}, schedules...)
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].EffectiveFrom.Equal(all[j].EffectiveFrom) {
+			return all[i].Version < all[j].Version
+		}
+		return all[i].EffectiveFrom.Before(all[j].EffectiveFrom)
+	})
+
+	merged := make(Schedules, len(all))
+	previous := defaultSchedule
+	for i, s := range all {
+		merged[i] = mergeSchedule(previous, s)
+		previous = merged[i]
+	}
+
+	return merged
+}
+
+// mergeSchedule returns a copy of `s` with the rates of `previous` that `s` doesn't have.
+func mergeSchedule(previous, s *Schedule) *Schedule {
+	merged := *s
+	merged.Rates = Rates{US: s.Rates.US}
+
+	type statutoryKey struct {
+		instrument      types.Instrument
+		segment         types.Segment
+		equityTradeKind string
+	}
+
+	statutory := map[statutoryKey]bool{}
+	for _, r := range s.Rates.Statutory {
+		statutory[statutoryKey{r.Instrument, r.Segment.OrDefault(), r.EquityTradeKind}] = true
+	}
+
+	for _, r := range previous.Rates.Statutory {
+		if !statutory[statutoryKey{r.Instrument, r.Segment.OrDefault(), r.EquityTradeKind}] {
+			merged.Rates.Statutory = append(merged.Rates.Statutory, r)
+		}
+	}
+	merged.Rates.Statutory = append(merged.Rates.Statutory, s.Rates.Statutory...)
+
+	type brokerageKey struct {
+		broker          broker.Name
+		instrument      types.Instrument
+		segment         types.Segment
+		equityTradeKind string
+	}
+
+	brokerage := map[brokerageKey]bool{}
+	for _, r := range s.Rates.Brokerage {
+		brokerage[brokerageKey{r.Broker, r.Instrument, r.Segment.OrDefault(), r.EquityTradeKind}] = true
+	}
+
+	for _, r := range previous.Rates.Brokerage {
+		if !brokerage[brokerageKey{r.Broker, r.Instrument, r.Segment.OrDefault(), r.EquityTradeKind}] {
+			merged.Rates.Brokerage = append(merged.Rates.Brokerage, r)
+		}
+	}
+	merged.Rates.Brokerage = append(merged.Rates.Brokerage, s.Rates.Brokerage...)
+
+	if merged.Rates.US == nil {
+		merged.Rates.US = previous.Rates.US
+	}
+
+	return &merged
+}
+
+// exchangeLocation is the timezone the effective dates are in. It is loaded once
+// because On is called for every trade whose charges are calculated.
+var exchangeLocation = func() *time.Location {
+	loc, err := time.LoadLocation(string(common.AsiaKolkataTZ))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// On returns the Schedule that applies to a trade at `t`. That is the latest version of the Schedule
+// with the latest effective date on or before the trade's date. Trades before all the Schedules get the earliest one.
+func (s Schedules) On(t time.Time) *Schedule {
+	if len(s) == 0 {
+		return defaultSchedule
+	}
+
+	local := t.In(exchangeLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	applicable := s[0]
+	for _, schedule := range s {
+		if schedule.EffectiveFrom.After(day) {
+			break
+		}
+		applicable = schedule
+	}
+
+	return applicable
+}
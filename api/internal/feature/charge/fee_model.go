@@ -0,0 +1,101 @@
+package charge
+
+import (
+	"errors"
+)
+
+// FeeModelKind is the exchange or venue whose fees the charges of a broker account's trades are calculated with.
+type FeeModelKind string
+
+const (
+	FeeModelKindIndia  FeeModelKind = "india"  // Indian exchanges, with the rates of the Schedule.
+	FeeModelKindUS     FeeModelKind = "us"     // US exchanges, with the US regulatory fees of the Schedule.
+	FeeModelKindCrypto FeeModelKind = "crypto" // Crypto exchanges, with the maker and taker fees of the FeeModel.
+)
+
+func (k FeeModelKind) IsValid() bool {
+	switch k {
+	case FeeModelKindIndia, FeeModelKindUS, FeeModelKindCrypto:
+		return true
+	default:
+		return false
+	}
+}
+
+// Liquidity is whether an order added liquidity to the order book (maker) or took it (taker).
+type Liquidity string
+
+const (
+	LiquidityMaker Liquidity = "maker"
+	LiquidityTaker Liquidity = "taker"
+)
+
+// FeeModel is the fee model the user selected on a broker account.
+// The brokerage, or commission, is still calculated with the account's BrokeragePlan.
+type FeeModel struct {
+	Kind FeeModelKind `json:"kind"`
+
+	// Only for FeeModelKindCrypto. The venue's fees as percentages of the trade value.
+	MakerPercent float64 `json:"maker_percent,omitempty"`
+	TakerPercent float64 `json:"taker_percent,omitempty"`
+
+	// Only for FeeModelKindCrypto. Brokers don't tell us whether a trade was a maker or a taker,
+	// so all the trades of the account are charged as this. Taker if empty.
+	Liquidity Liquidity `json:"liquidity,omitempty"`
+}
+
+var (
+	ErrInvalidFeeModelKind  = errors.New("Fee model kind must be one of india, us or crypto")
+	ErrInvalidLiquidity     = errors.New("Liquidity must be maker or taker")
+	ErrNegativeFeeModelRate = errors.New("Fee model cannot have negative fees")
+)
+
+func (m *FeeModel) Validate() error {
+	if !m.Kind.IsValid() {
+		return ErrInvalidFeeModelKind
+	}
+
+	if m.Liquidity != "" && m.Liquidity != LiquidityMaker && m.Liquidity != LiquidityTaker {
+		return ErrInvalidLiquidity
+	}
+
+	if m.MakerPercent < 0 || m.TakerPercent < 0 {
+		return ErrNegativeFeeModelRate
+	}
+
+	return nil
+}
+
+// KindOrDefault returns the kind of the FeeModel, FeeModelKindIndia if there is none.
+func (m *FeeModel) KindOrDefault() FeeModelKind {
+	if m == nil || m.Kind == "" {
+		return FeeModelKindIndia
+	}
+	return m.Kind
+}
+
+// FeePercent returns the maker or taker fee, as the account's trades are charged.
+func (m *FeeModel) FeePercent() float64 {
+	if m.Liquidity == LiquidityMaker {
+		return m.MakerPercent
+	}
+	return m.TakerPercent
+}
+
+// USRates are the regulatory and clearing fees on US exchanges.
+type USRates struct {
+	// The SEC fee, in dollars per million dollars of the value of sell trades.
+	SECFeePerMillionOnSell float64 `json:"sec_fee_per_million_on_sell"`
+
+	// The FINRA Trading Activity Fee on the sell trades of stocks per share, capped per trade.
+	FINRATAFPerShareOnSell float64 `json:"finra_taf_per_share_on_sell"`
+	FINRATAFMaxPerTrade    float64 `json:"finra_taf_max_per_trade"`
+
+	// The fees of options per contract. The FINRA TAF is only on sell trades.
+	FINRATAFPerContractOnSell       float64 `json:"finra_taf_per_contract_on_sell"`
+	OptionsRegulatoryFeePerContract float64 `json:"options_regulatory_fee_per_contract"`
+	OCCClearingFeePerContract       float64 `json:"occ_clearing_fee_per_contract"`
+
+	// The shares in an option contract. The quantity of option trades is in shares.
+	OptionContractMultiplier float64 `json:"option_contract_multiplier"`
+}
@@ -0,0 +1,74 @@
+package charge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	// List returns all the stored Schedules, sorted by effective date and version.
+	List(ctx context.Context) ([]*Schedule, error)
+}
+
+type Writer interface {
+	// Create stores the Schedule with the next version for its effective date, and sets it on the Schedule.
+	Create(ctx context.Context, schedule *Schedule) error
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+//
+// PostgreSQL implementation
+//
+
+type chargeScheduleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *chargeScheduleRepository {
+	return &chargeScheduleRepository{db}
+}
+
+func (r *chargeScheduleRepository) List(ctx context.Context) ([]*Schedule, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created_at, effective_from, version, note, rates
+		FROM charge_schedule
+		ORDER BY effective_from ASC, version ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	defer rows.Close()
+
+	schedules := []*Schedule{}
+	for rows.Next() {
+		var s Schedule
+		err := rows.Scan(&s.ID, &s.CreatedAt, &s.EffectiveFrom, &s.Version, &s.Note, &s.Rates)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		schedules = append(schedules, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (r *chargeScheduleRepository) Create(ctx context.Context, s *Schedule) error {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO charge_schedule (id, created_at, effective_from, version, note, rates)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(version), 0) + 1 FROM charge_schedule WHERE effective_from = $3), $4, $5)
+		RETURNING version
+	`, s.ID, s.CreatedAt, s.EffectiveFrom, s.Note, s.Rates)
+
+	return row.Scan(&s.Version)
+}
@@ -0,0 +1,549 @@
+// Package chargereconciliation compares the charges that Arthveda estimated for the trades of a day
+// with the actual charges in the broker's contract note, and replaces the estimates with the actuals.
+package chargereconciliation
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/trade"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Reconciliation represents the `charge_reconciliation` table in the database.
+// It is the comparison of the actual and estimated charges of a segment on a day for a UserBrokerAccount.
+type Reconciliation struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	UserBrokerAccountID uuid.UUID     `json:"user_broker_account_id" db:"user_broker_account_id"`
+	Date                time.Time     `json:"date" db:"date"` // The date, in IST, of the contract note. At midnight UTC.
+	Segment             types.Segment `json:"segment" db:"segment"`
+
+	Actual       trade.ChargesBreakdown `json:"actual" db:"actual"`
+	ActualAmount decimal.Decimal        `json:"actual_amount" db:"actual_amount"`
+
+	// The estimates of the trades that have a breakdown. EstimatedAmount includes the other trades too.
+	Estimated       trade.ChargesBreakdown `json:"estimated" db:"estimated"`
+	EstimatedAmount decimal.Decimal        `json:"estimated_amount" db:"estimated_amount"`
+
+	// ActualAmount - EstimatedAmount.
+	DifferenceAmount decimal.Decimal `json:"difference_amount" db:"difference_amount"`
+	IsDiscrepancy    bool            `json:"is_discrepancy" db:"is_discrepancy"`
+	TradesCount      int             `json:"trades_count" db:"trades_count"`
+
+	// When the actual charges replaced the estimates of the trades. Nil if they haven't.
+	AppliedAt *time.Time `json:"applied_at" db:"applied_at"`
+}
+
+// SegmentCharges are the actual charges of a segment, as parsed from a contract note.
+type SegmentCharges struct {
+	Segment   types.Segment
+	Breakdown trade.ChargesBreakdown
+	// The total charges. The sum of the Breakdown unless the contract note has a total of its own.
+	Amount decimal.Decimal
+}
+
+// A difference within the tolerance is from rounding, not a discrepancy.
+var (
+	discrepancyToleranceAmount  = decimal.NewFromInt(1)
+	discrepancyTolerancePercent = decimal.NewFromInt(1)
+)
+
+// New reconciles the actual charges of a segment on `date` with the charges of the segment's trades on that date.
+func New(userID, userBrokerAccountID uuid.UUID, date time.Time, actual SegmentCharges, trades []*trade.Trade) (*Reconciliation, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reconciliation{
+		ID:                  id,
+		CreatedBy:           userID,
+		CreatedAt:           time.Now().UTC(),
+		UserBrokerAccountID: userBrokerAccountID,
+		Date:                time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+		Segment:             actual.Segment,
+		Actual:              actual.Breakdown,
+		ActualAmount:        actual.Amount,
+	}
+
+	r.Reconcile(trades)
+
+	return r, nil
+}
+
+// Reconcile compares the actual charges with the estimated charges of the trades.
+func (r *Reconciliation) Reconcile(trades []*trade.Trade) {
+	r.Estimated = trade.ChargesBreakdown{}
+	r.EstimatedAmount = decimal.Zero
+
+	for _, t := range trades {
+		r.EstimatedAmount = r.EstimatedAmount.Add(t.ChargesAmount)
+		if t.ChargesBreakdown != nil {
+			r.Estimated = r.Estimated.Add(*t.ChargesBreakdown)
+		}
+	}
+
+	r.TradesCount = len(trades)
+	r.DifferenceAmount = r.ActualAmount.Sub(r.EstimatedAmount)
+
+	tolerance := decimal.Max(discrepancyToleranceAmount, r.ActualAmount.Mul(discrepancyTolerancePercent).Div(decimal.NewFromInt(100)))
+	r.IsDiscrepancy = r.DifferenceAmount.Abs().GreaterThan(tolerance)
+}
+
+// Apply replaces the charges of the trades with the actual charges. Each component is shared by the trades
+// in proportion to their estimate of it, or to their value if there is none. The trades must be the ones
+// the Reconciliation was reconciled with.
+func (r *Reconciliation) Apply(trades []*trade.Trade) {
+	if len(trades) == 0 {
+		return
+	}
+
+	// Without a breakdown in the contract note, we can only share the total.
+	if r.Actual.Total().IsZero() {
+		shares := share(trades, r.ActualAmount, func(t *trade.Trade) decimal.Decimal { return t.ChargesAmount })
+		for i, t := range trades {
+			t.ChargesAmount = shares[i]
+			t.ChargesBreakdown = nil
+		}
+	} else {
+		component := func(get func(b trade.ChargesBreakdown) decimal.Decimal) []decimal.Decimal {
+			return share(trades, get(r.Actual), func(t *trade.Trade) decimal.Decimal {
+				if t.ChargesBreakdown == nil {
+					return decimal.Zero
+				}
+				return get(*t.ChargesBreakdown)
+			})
+		}
+
+		brokerage := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.Brokerage })
+		stt := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.STT })
+		exchangeTransaction := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.ExchangeTransaction })
+		stamp := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.Stamp })
+		sebi := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.SEBI })
+		dp := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.DP })
+		ipf := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.NSEInvestorProtectionFund })
+		gst := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.GST })
+		regulatory := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.Regulatory })
+		clearing := component(func(b trade.ChargesBreakdown) decimal.Decimal { return b.Clearing })
+
+		for i, t := range trades {
+			t.ChargesBreakdown = &trade.ChargesBreakdown{
+				Brokerage:                 brokerage[i],
+				STT:                       stt[i],
+				ExchangeTransaction:       exchangeTransaction[i],
+				Stamp:                     stamp[i],
+				SEBI:                      sebi[i],
+				DP:                        dp[i],
+				NSEInvestorProtectionFund: ipf[i],
+				GST:                       gst[i],
+				Regulatory:                regulatory[i],
+				Clearing:                  clearing[i],
+			}
+		}
+
+		// Round the charges of the trades so that they add up to the actual amount.
+		remaining := r.ActualAmount
+		for i, t := range trades {
+			if i == len(trades)-1 {
+				t.ChargesAmount = remaining
+				break
+			}
+
+			t.ChargesAmount = t.ChargesBreakdown.Total().Round(2)
+			remaining = remaining.Sub(t.ChargesAmount)
+		}
+	}
+
+	// The estimates are kept as they were, to show how far off they were.
+	now := time.Now().UTC()
+	r.AppliedAt = &now
+}
+
+// share shares the amount by the trades in proportion to their weights, or to their value if the weights are all zero.
+// The shares of all but the last trade are rounded, the last one gets what remains.
+func share(trades []*trade.Trade, amount decimal.Decimal, weight func(t *trade.Trade) decimal.Decimal) []decimal.Decimal {
+	weights := make([]decimal.Decimal, len(trades))
+	total := decimal.Zero
+
+	for i, t := range trades {
+		weights[i] = weight(t)
+		total = total.Add(weights[i])
+	}
+
+	if !total.IsPositive() {
+		total = decimal.Zero
+		for i, t := range trades {
+			weights[i] = t.Quantity.Mul(t.Price)
+			total = total.Add(weights[i])
+		}
+	}
+
+	shares := make([]decimal.Decimal, len(trades))
+	remaining := amount
+
+	for i := range trades {
+		if i == len(trades)-1 {
+			shares[i] = remaining
+			break
+		}
+
+		if total.IsPositive() {
+			shares[i] = amount.Mul(weights[i]).Div(total).Round(4)
+		}
+
+		remaining = remaining.Sub(shares[i])
+	}
+
+	return shares
+}
+
+var (
+	ErrNoHeader  = errors.New("Charges summary must have a header row with a Segment column")
+	ErrNoCharges = errors.New("Charges summary has no charges")
+)
+
+// The contract note segments by their normalized names. Our equity segment includes F&O.
+var segmentByName = map[string]types.Segment{
+	"equity":            types.SegmentEquity,
+	"eq":                types.SegmentEquity,
+	"nseeq":             types.SegmentEquity,
+	"bseeq":             types.SegmentEquity,
+	"cash":              types.SegmentEquity,
+	"capitalmarket":     types.SegmentEquity,
+	"cm":                types.SegmentEquity,
+	"fo":                types.SegmentEquity,
+	"nsefo":             types.SegmentEquity,
+	"bsefo":             types.SegmentEquity,
+	"derivatives":       types.SegmentEquity,
+	"futuresoptions":    types.SegmentEquity,
+	"futuresandoptions": types.SegmentEquity,
+	"commodity":         types.SegmentCommodity,
+	"com":               types.SegmentCommodity,
+	"mcx":               types.SegmentCommodity,
+	"mcxfo":             types.SegmentCommodity,
+	"currency":          types.SegmentCurrency,
+	"cds":               types.SegmentCurrency,
+	"cd":                types.SegmentCurrency,
+	"nsecds":            types.SegmentCurrency,
+}
+
+type column string
+
+const (
+	columnSegment             column = "segment"
+	columnBrokerage           column = "brokerage"
+	columnSTT                 column = "stt"
+	columnExchangeTransaction column = "exchange_transaction"
+	columnStamp               column = "stamp"
+	columnSEBI                column = "sebi"
+	columnDP                  column = "dp"
+	columnIPF                 column = "ipf"
+	columnGST                 column = "gst"
+	columnTotal               column = "total"
+)
+
+// The columns of a charges summary by their normalized headers. Contract notes split GST
+// into CGST and SGST, or IGST, so all of them are added up.
+var columnByHeader = map[string]column{
+	"segment":                       columnSegment,
+	"exchangesegment":               columnSegment,
+	"brokerage":                     columnBrokerage,
+	"taxablevalueofsupplybrokerage": columnBrokerage,
+	"stt":                           columnSTT,
+	"ctt":                           columnSTT,
+	"sttctt":                        columnSTT,
+	"securitiestransactiontax":      columnSTT,
+	"commoditiestransactiontax":     columnSTT,
+	"exchangetransactioncharges":    columnExchangeTransaction,
+	"exchangetransactioncharge":     columnExchangeTransaction,
+	"transactioncharges":            columnExchangeTransaction,
+	"exchangecharges":               columnExchangeTransaction,
+	"stamp":                         columnStamp,
+	"stampduty":                     columnStamp,
+	"sebi":                          columnSEBI,
+	"sebifees":                      columnSEBI,
+	"sebiturnoverfees":              columnSEBI,
+	"dp":                            columnDP,
+	"dpcharges":                     columnDP,
+	"ipf":                           columnIPF,
+	"ipft":                          columnIPF,
+	"investorprotectionfund":        columnIPF,
+	"nseinvestorprotectionfund":     columnIPF,
+	"gst":                           columnGST,
+	"igst":                          columnGST,
+	"cgst":                          columnGST,
+	"sgst":                          columnGST,
+	"total":                         columnTotal,
+	"totalcharges":                  columnTotal,
+	"netcharges":                    columnTotal,
+}
+
+// ParseChargesSummary parses the actual charges per segment from the rows of a CSV, Excel or PDF charges summary
+// of a contract note. The rows before the header are skipped, and the header must have a Segment column.
+// The charges of the rows of a segment are added up, like the equity and F&O rows of the equity segment.
+//
+// A summary without a Segment column, like the one of a PDF contract note, is read with the segments as its
+// columns and a row for each charge instead.
+func ParseChargesSummary(rows [][]string) ([]SegmentCharges, error) {
+	charges, err := parseChargesSummary(rows)
+	if errors.Is(err, ErrNoHeader) {
+		if transposed := transposeChargesSummary(rows); transposed != nil {
+			return parseChargesSummary(transposed)
+		}
+	}
+
+	return charges, err
+}
+
+func parseChargesSummary(rows [][]string) ([]SegmentCharges, error) {
+	headerRowIdx := -1
+	columns := map[int]column{}
+
+	for i, row := range rows {
+		for j, cell := range row {
+			if c, ok := columnByHeader[normalize(cell)]; ok {
+				columns[j] = c
+			}
+		}
+
+		for _, c := range columns {
+			if c == columnSegment {
+				headerRowIdx = i
+			}
+		}
+
+		if headerRowIdx != -1 {
+			break
+		}
+
+		columns = map[int]column{}
+	}
+
+	if headerRowIdx == -1 {
+		return nil, ErrNoHeader
+	}
+
+	chargesBySegment := map[types.Segment]*SegmentCharges{}
+	hasTotalBySegment := map[types.Segment]bool{}
+	segments := []types.Segment{}
+
+	for i := headerRowIdx + 1; i < len(rows); i++ {
+		row := rows[i]
+
+		var segmentName string
+		for j, c := range columns {
+			if c == columnSegment && j < len(row) {
+				segmentName = strings.TrimSpace(row[j])
+			}
+		}
+
+		// Skip the empty and the total rows.
+		if segmentName == "" || strings.HasPrefix(normalize(segmentName), "total") {
+			continue
+		}
+
+		segment, ok := segmentByName[normalize(segmentName)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown segment %q in row %d", segmentName, i+1)
+		}
+
+		charges, ok := chargesBySegment[segment]
+		if !ok {
+			charges = &SegmentCharges{Segment: segment}
+			chargesBySegment[segment] = charges
+			segments = append(segments, segment)
+		}
+
+		for j, c := range columns {
+			if c == columnSegment || j >= len(row) {
+				continue
+			}
+
+			amount, err := parseAmount(row[j])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid amount %q in row %d: %w", row[j], i+1, err)
+			}
+
+			b := &charges.Breakdown
+
+			switch c {
+			case columnBrokerage:
+				b.Brokerage = b.Brokerage.Add(amount)
+			case columnSTT:
+				b.STT = b.STT.Add(amount)
+			case columnExchangeTransaction:
+				b.ExchangeTransaction = b.ExchangeTransaction.Add(amount)
+			case columnStamp:
+				b.Stamp = b.Stamp.Add(amount)
+			case columnSEBI:
+				b.SEBI = b.SEBI.Add(amount)
+			case columnDP:
+				b.DP = b.DP.Add(amount)
+			case columnIPF:
+				b.NSEInvestorProtectionFund = b.NSEInvestorProtectionFund.Add(amount)
+			case columnGST:
+				b.GST = b.GST.Add(amount)
+			case columnTotal:
+				charges.Amount = charges.Amount.Add(amount)
+				hasTotalBySegment[segment] = true
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, ErrNoCharges
+	}
+
+	result := make([]SegmentCharges, 0, len(segments))
+	for _, segment := range segments {
+		charges := chargesBySegment[segment]
+		if !hasTotalBySegment[segment] {
+			charges.Amount = charges.Breakdown.Total()
+		}
+		result = append(result, *charges)
+	}
+
+	return result, nil
+}
+
+// transposeChargesSummary returns the rows of a summary that has the segments as its columns, like
+//
+//	                                     Equity   Futures and Options   NET TOTAL
+//	Taxable value of Supply (Brokerage)  -20.00   -40.00                -60.00
+//	CGST (@9% of Brok, SEBI, Trans ...)  -1.80    -3.60                 -5.40
+//
+// with a row for each segment and a column for each charge, or nil if it doesn't have a row of segments.
+// A charge is known by the header its label starts with. The values are aligned to the segments from the
+// right, as a PDF has no text for the empty cells before them.
+func transposeChargesSummary(rows [][]string) [][]string {
+	for i, row := range rows {
+		first := -1
+		for j, cell := range row {
+			if _, ok := segmentByName[normalize(cell)]; ok {
+				first = j
+				break
+			}
+		}
+
+		if first == -1 {
+			continue
+		}
+
+		// The cells after the segments can only be their total.
+		valueColumns := row[first:]
+		isHeader := true
+		for _, cell := range valueColumns {
+			_, isSegment := segmentByName[normalize(cell)]
+			if !isSegment && !strings.Contains(normalize(cell), "total") {
+				isHeader = false
+				break
+			}
+		}
+
+		if !isHeader {
+			continue
+		}
+
+		header := []string{string(columnSegment)}
+		values := [][]string{}
+
+		for _, labelRow := range rows[i+1:] {
+			if len(labelRow) < 2 {
+				continue
+			}
+
+			key := chargeHeader(labelRow[0])
+			if key == "" {
+				continue
+			}
+
+			cells := labelRow[1:]
+			if len(cells) > len(valueColumns) {
+				cells = cells[len(cells)-len(valueColumns):]
+			}
+
+			aligned := make([]string, len(valueColumns))
+			copy(aligned[len(valueColumns)-len(cells):], cells)
+
+			header = append(header, key)
+			values = append(values, aligned)
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		result := [][]string{header}
+		for j, name := range valueColumns {
+			if _, ok := segmentByName[normalize(name)]; !ok {
+				continue
+			}
+
+			segmentRow := []string{name}
+			for _, v := range values {
+				segmentRow = append(segmentRow, v[j])
+			}
+			result = append(result, segmentRow)
+		}
+
+		return result
+	}
+
+	return nil
+}
+
+// chargeHeader returns the longest header of a charge that the label starts with, like "cgst"
+// for "CGST (@9% of Brok, SEBI, Trans & Clearing Charges)", or "" if there is none.
+func chargeHeader(label string) string {
+	label = normalize(label)
+
+	var result string
+	for header, c := range columnByHeader {
+		if c == columnSegment || c == columnTotal {
+			continue
+		}
+
+		if strings.HasPrefix(label, header) && len(header) > len(result) {
+			result = header
+		}
+	}
+
+	return result
+}
+
+// normalize lowercases the text and removes everything but letters and digits,
+// so that "STT/CTT" and "stt ctt" are the same.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// parseAmount parses an amount like "₹1,234.50". Contract notes show charges as debits,
+// sometimes negative or in parentheses, so the amount is always positive.
+func parseAmount(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer(",", "", "₹", "", "Rs.", "", "(", "", ")", "", " ", "").Replace(strings.TrimSpace(s))
+	if s == "" || s == "-" {
+		return decimal.Zero, nil
+	}
+
+	amount, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return amount.Abs(), nil
+}
@@ -0,0 +1,129 @@
+package chargereconciliation
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/trade"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestParseChargesSummary(t *testing.T) {
+	rows := [][]string{
+		{"Contract Note", "", "", "", "", "", "", ""},
+		{"Segment", "Brokerage", "STT/CTT", "Exchange Transaction Charges", "Stamp Duty", "SEBI Turnover Fees", "CGST", "SGST"},
+		{"NSE EQ", "20", "100", "3.07", "15", "0.10", "1.67", "1.67"},
+		{"NSE FO", "40.00", "(62.50)", "₹1,200.00", "", "-", "0", "0"},
+		{"MCX", "20", "10", "2.10", "0", "0.10", "2.00", "2.00"},
+		{"", "", "", "", "", "", "", ""},
+		{"Total", "80", "172.5", "1205.17", "15", "0.2", "3.67", "3.67"},
+	}
+
+	charges, err := ParseChargesSummary(rows)
+	if err != nil {
+		t.Fatalf("ParseChargesSummary: %s", err)
+	}
+
+	if len(charges) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(charges))
+	}
+
+	equity := charges[0]
+	if equity.Segment != types.SegmentEquity {
+		t.Errorf("expected the equity segment first, got %s", equity.Segment)
+	}
+
+	// The equity and F&O rows are added up.
+	if !equity.Breakdown.STT.Equal(d("162.5")) || !equity.Breakdown.ExchangeTransaction.Equal(d("1203.07")) || !equity.Breakdown.GST.Equal(d("3.34")) {
+		t.Errorf("unexpected equity breakdown %+v", equity.Breakdown)
+	}
+
+	if !equity.Amount.Equal(d("1444.01")) {
+		t.Errorf("expected equity charges 1444.01, got %s", equity.Amount)
+	}
+
+	if charges[1].Segment != types.SegmentCommodity || !charges[1].Amount.Equal(d("36.2")) {
+		t.Errorf("expected commodity charges 36.2, got %s %s", charges[1].Segment, charges[1].Amount)
+	}
+
+	if _, err := ParseChargesSummary([][]string{{"Brokerage"}, {"20"}}); err != ErrNoHeader {
+		t.Errorf("expected ErrNoHeader, got %v", err)
+	}
+}
+
+func TestParseChargesSummary_SegmentColumns(t *testing.T) {
+	// The rows of the text of a PDF contract note, where the empty cells have no text.
+	rows := [][]string{
+		{"Contract Note No: 12345", "Trade Date: 10/03/2025"},
+		{"Equity", "Futures and Options", "NET TOTAL"},
+		{"Pay in/Pay out obligation", "-10,000.00", "2,500.00", "-7,500.00"},
+		{"Taxable value of Supply (Brokerage)", "-20.00", "-40.00", "-60.00"},
+		{"Exchange transaction charges", "-3.07", "-12.00", "-15.07"},
+		{"CGST (@9% of Brok, SEBI, Trans & Clearing Charges)", "-2.08", "-4.68", "-6.76"},
+		{"SGST (@9% of Brok, SEBI, Trans & Clearing Charges)", "-2.08", "-4.68", "-6.76"},
+		{"Securities transaction tax", "-10.00", "-6.25", "-16.25"},
+		{"Stamp duty", "-15.00", "-15.00"},
+		{"Net amount receivable/(payable by client)", "-10,052.23", "2,432.39", "-7,619.84"},
+	}
+
+	charges, err := ParseChargesSummary(rows)
+	if err != nil {
+		t.Fatalf("ParseChargesSummary: %s", err)
+	}
+
+	if len(charges) != 1 || charges[0].Segment != types.SegmentEquity {
+		t.Fatalf("expected the equity segment only, got %+v", charges)
+	}
+
+	// The equity and F&O columns are added up, and the stamp duty that has no F&O value is still counted.
+	b := charges[0].Breakdown
+	if !b.Brokerage.Equal(d("60")) || !b.GST.Equal(d("13.52")) || !b.STT.Equal(d("16.25")) || !b.Stamp.Equal(d("15")) {
+		t.Errorf("unexpected breakdown %+v", b)
+	}
+
+	if !charges[0].Amount.Equal(d("119.84")) {
+		t.Errorf("expected charges 119.84, got %s", charges[0].Amount)
+	}
+}
+
+func TestReconciliationApply(t *testing.T) {
+	trades := []*trade.Trade{
+		{Quantity: d("10"), Price: d("1000"), ChargesAmount: d("30"), ChargesBreakdown: &trade.ChargesBreakdown{Brokerage: d("20"), STT: d("10")}},
+		{Quantity: d("10"), Price: d("3000"), ChargesAmount: d("50"), ChargesBreakdown: &trade.ChargesBreakdown{Brokerage: d("20"), STT: d("30")}},
+	}
+
+	actual := SegmentCharges{
+		Segment:   types.SegmentEquity,
+		Breakdown: trade.ChargesBreakdown{Brokerage: d("30"), STT: d("40"), GST: d("5.4")},
+		Amount:    d("75.4"),
+	}
+
+	r, err := New(uuid.New(), uuid.New(), time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), actual, trades)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	if !r.DifferenceAmount.Equal(d("-4.6")) || !r.IsDiscrepancy {
+		t.Errorf("expected a discrepancy of -4.6, got %s (%t)", r.DifferenceAmount, r.IsDiscrepancy)
+	}
+
+	r.Apply(trades)
+
+	// The brokerage and STT are shared like the estimates, the GST by trade value as it wasn't estimated.
+	first := trades[0].ChargesBreakdown
+	if !first.Brokerage.Equal(d("15")) || !first.STT.Equal(d("10")) || !first.GST.Equal(d("1.35")) {
+		t.Errorf("unexpected breakdown of the first trade %+v", *first)
+	}
+
+	if !trades[0].ChargesAmount.Equal(d("26.35")) || !trades[1].ChargesAmount.Equal(d("49.05")) {
+		t.Errorf("expected charges 26.35 and 49.05, got %s and %s", trades[0].ChargesAmount, trades[1].ChargesAmount)
+	}
+
+	if r.AppliedAt == nil {
+		t.Error("expected the reconciliation to be applied")
+	}
+}
@@ -0,0 +1,159 @@
+package chargereconciliation
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/dbx"
+	"arthveda/internal/repository"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*Reconciliation, error)
+	// Search returns the Reconciliations of the user that match the filter, latest date first.
+	Search(ctx context.Context, userID uuid.UUID, filter SearchFilter) ([]*Reconciliation, error)
+}
+
+type Writer interface {
+	// Upsert creates the Reconciliation, or replaces the one of the same broker account, date and segment.
+	Upsert(ctx context.Context, r *Reconciliation) (*Reconciliation, error)
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+type SearchFilter struct {
+	UserBrokerAccountID *uuid.UUID              `json:"user_broker_account_id"`
+	IsDiscrepancy       *bool                   `json:"is_discrepancy"`
+	Date                *common.DateRangeFilter `json:"date"`
+}
+
+//
+// PostgreSQL implementation
+//
+
+type reconciliationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *reconciliationRepository {
+	return &reconciliationRepository{db}
+}
+
+const reconciliationColumns = `id, created_by, created_at, updated_at, user_broker_account_id, date, segment, actual, actual_amount,
+	estimated, estimated_amount, difference_amount, is_discrepancy, trades_count, applied_at`
+
+func scanReconciliations(rows pgx.Rows) ([]*Reconciliation, error) {
+	defer rows.Close()
+
+	reconciliations := []*Reconciliation{}
+	for rows.Next() {
+		var r Reconciliation
+		err := rows.Scan(
+			&r.ID, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt, &r.UserBrokerAccountID, &r.Date, &r.Segment, &r.Actual, &r.ActualAmount,
+			&r.Estimated, &r.EstimatedAmount, &r.DifferenceAmount, &r.IsDiscrepancy, &r.TradesCount, &r.AppliedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		reconciliations = append(reconciliations, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return reconciliations, nil
+}
+
+func (r *reconciliationRepository) GetByID(ctx context.Context, userID, id uuid.UUID) (*Reconciliation, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `SELECT `+reconciliationColumns+` FROM charge_reconciliation WHERE id = $1 AND created_by = $2`, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	reconciliations, err := scanReconciliations(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reconciliations) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return reconciliations[0], nil
+}
+
+func (r *reconciliationRepository) Search(ctx context.Context, userID uuid.UUID, filter SearchFilter) ([]*Reconciliation, error) {
+	where := []string{"created_by = @created_by"}
+	args := pgx.NamedArgs{"created_by": userID}
+
+	if filter.UserBrokerAccountID != nil {
+		where = append(where, "user_broker_account_id = @user_broker_account_id")
+		args["user_broker_account_id"] = *filter.UserBrokerAccountID
+	}
+
+	if filter.IsDiscrepancy != nil {
+		where = append(where, "is_discrepancy = @is_discrepancy")
+		args["is_discrepancy"] = *filter.IsDiscrepancy
+	}
+
+	if filter.Date != nil {
+		if filter.Date.From != nil {
+			where = append(where, "date >= @date_from")
+			args["date_from"] = *filter.Date.From
+		}
+		if filter.Date.To != nil {
+			where = append(where, "date <= @date_to")
+			args["date_to"] = *filter.Date.To
+		}
+	}
+
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		SELECT `+reconciliationColumns+`
+		FROM charge_reconciliation
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY date DESC, segment ASC
+	`, args)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanReconciliations(rows)
+}
+
+func (r *reconciliationRepository) Upsert(ctx context.Context, rec *Reconciliation) (*Reconciliation, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		INSERT INTO charge_reconciliation (`+reconciliationColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (user_broker_account_id, date, segment) DO UPDATE
+		SET updated_at = now(), actual = EXCLUDED.actual, actual_amount = EXCLUDED.actual_amount,
+			estimated = EXCLUDED.estimated, estimated_amount = EXCLUDED.estimated_amount,
+			difference_amount = EXCLUDED.difference_amount, is_discrepancy = EXCLUDED.is_discrepancy,
+			trades_count = EXCLUDED.trades_count, applied_at = EXCLUDED.applied_at
+		RETURNING `+reconciliationColumns,
+		rec.ID, rec.CreatedBy, rec.CreatedAt, rec.UpdatedAt, rec.UserBrokerAccountID, rec.Date, rec.Segment, rec.Actual, rec.ActualAmount,
+		rec.Estimated, rec.EstimatedAmount, rec.DifferenceAmount, rec.IsDiscrepancy, rec.TradesCount, rec.AppliedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("upsert: %w", err)
+	}
+
+	reconciliations, err := scanReconciliations(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reconciliations) == 0 {
+		return nil, fmt.Errorf("upsert: no row returned")
+	}
+
+	return reconciliations[0], nil
+}
@@ -0,0 +1,173 @@
+// Package corporateaction holds the splits and bonus issues of stocks, which change the quantity
+// and price of the shares held across their ex-date.
+package corporateaction
+
+import (
+	"arthveda/internal/common"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type Kind string
+
+const (
+	KindSplit Kind = "split"
+	KindBonus Kind = "bonus"
+)
+
+func (k Kind) IsValid() bool {
+	return k == KindSplit || k == KindBonus
+}
+
+// CorporateAction is a split or bonus issue of a stock.
+//
+// The ratio is NewShares for every HeldShares:
+//   - A split of one share into five is 5:1, so 10 shares held before the ex-date are 50 shares after it.
+//   - A 1:2 bonus is one bonus share for every two held, so 10 shares held before the ex-date are 15 shares after it.
+type CorporateAction struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	Symbol string `json:"symbol" db:"symbol"`
+	Kind   Kind   `json:"kind" db:"kind"`
+
+	// The first day the stock trades without the entitlement, at midnight UTC.
+	ExDate time.Time `json:"ex_date" db:"ex_date"`
+
+	NewShares  decimal.Decimal `json:"new_shares" db:"new_shares"`
+	HeldShares decimal.Decimal `json:"held_shares" db:"held_shares"`
+}
+
+var (
+	ErrInvalidKind  = errors.New("Corporate action kind must be split or bonus")
+	ErrInvalidRatio = errors.New("Corporate action ratio must be positive")
+)
+
+func New(symbol string, kind Kind, exDate time.Time, newShares, heldShares decimal.Decimal) (*CorporateAction, error) {
+	if !kind.IsValid() {
+		return nil, ErrInvalidKind
+	}
+
+	if !newShares.IsPositive() || !heldShares.IsPositive() {
+		return nil, ErrInvalidRatio
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CorporateAction{
+		ID:         id,
+		CreatedAt:  time.Now().UTC(),
+		Symbol:     strings.ToUpper(strings.TrimSpace(symbol)),
+		Kind:       kind,
+		ExDate:     time.Date(exDate.Year(), exDate.Month(), exDate.Day(), 0, 0, 0, 0, time.UTC),
+		NewShares:  newShares,
+		HeldShares: heldShares,
+	}, nil
+}
+
+// Multiplier is what a quantity held before the ex-date is multiplied by, and a price divided by,
+// to be comparable with quantities and prices after it.
+func (a *CorporateAction) Multiplier() decimal.Decimal {
+	if !a.HeldShares.IsPositive() {
+		return decimal.NewFromInt(1)
+	}
+
+	if a.Kind == KindBonus {
+		return a.NewShares.Add(a.HeldShares).Div(a.HeldShares)
+	}
+
+	return a.NewShares.Div(a.HeldShares)
+}
+
+// exchangeLocation is the timezone the ex-dates are in. It is loaded once because
+// AppliesTo is called for every trade of every Position with corporate actions.
+var exchangeLocation = func() *time.Location {
+	loc, err := time.LoadLocation(string(common.AsiaKolkataTZ))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// AppliesTo returns whether a trade at `t` was before the ex-date and needs to be adjusted.
+func (a *CorporateAction) AppliesTo(t time.Time) bool {
+	exDateStart := time.Date(a.ExDate.Year(), a.ExDate.Month(), a.ExDate.Day(), 0, 0, 0, 0, exchangeLocation)
+	return t.Before(exDateStart)
+}
+
+// BySymbol groups the CorporateActions by their symbol, each group sorted by ex-date.
+func BySymbol(actions []*CorporateAction) map[string][]*CorporateAction {
+	result := map[string][]*CorporateAction{}
+	for _, a := range actions {
+		result[a.Symbol] = append(result[a.Symbol], a)
+	}
+	return result
+}
+
+// ParseRows parses the rows of a CSV file with a header row that has "symbol", "kind", "ex_date"
+// (YYYY-MM-DD), "new_shares" and "held_shares" columns.
+func ParseRows(rows [][]string) ([]*CorporateAction, error) {
+	if len(rows) < 1 {
+		return nil, errors.New("missing header row")
+	}
+
+	columns := map[string]int{}
+	for i, h := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	for _, c := range []string{"symbol", "kind", "ex_date", "new_shares", "held_shares"} {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("missing column %q", c)
+		}
+	}
+
+	actions := []*CorporateAction{}
+	for i, row := range rows[1:] {
+		line := i + 2
+
+		get := func(c string) string {
+			if columns[c] >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[columns[c]])
+		}
+
+		if get("symbol") == "" {
+			continue
+		}
+
+		exDate, err := time.Parse(time.DateOnly, get("ex_date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ex_date: %w", line, err)
+		}
+
+		newShares, err := decimal.NewFromString(get("new_shares"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid new_shares: %w", line, err)
+		}
+
+		heldShares, err := decimal.NewFromString(get("held_shares"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid held_shares: %w", line, err)
+		}
+
+		action, err := New(get("symbol"), Kind(strings.ToLower(get("kind"))), exDate, newShares, heldShares)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
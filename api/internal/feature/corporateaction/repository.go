@@ -0,0 +1,104 @@
+package corporateaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	// ListBySymbols returns the CorporateActions of the symbols, sorted by ex-date.
+	ListBySymbols(ctx context.Context, symbols []string) ([]*CorporateAction, error)
+}
+
+type Writer interface {
+	// Upsert creates the CorporateActions or updates the ratio of the ones that already exist
+	// for the same symbol, kind and ex-date.
+	Upsert(ctx context.Context, actions []*CorporateAction) error
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+//
+// PostgreSQL implementation
+//
+
+type corporateActionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *corporateActionRepository {
+	return &corporateActionRepository{db}
+}
+
+func (r *corporateActionRepository) ListBySymbols(ctx context.Context, symbols []string) ([]*CorporateAction, error) {
+	actions := []*CorporateAction{}
+	if len(symbols) == 0 {
+		return actions, nil
+	}
+
+	upper := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		upper = append(upper, strings.ToUpper(s))
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created_at, updated_at, symbol, kind, ex_date, new_shares, held_shares
+		FROM corporate_action
+		WHERE symbol = ANY($1)
+		ORDER BY ex_date ASC
+	`, upper)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a CorporateAction
+		err := rows.Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt, &a.Symbol, &a.Kind, &a.ExDate, &a.NewShares, &a.HeldShares)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		actions = append(actions, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return actions, nil
+}
+
+func (r *corporateActionRepository) Upsert(ctx context.Context, actions []*CorporateAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, a := range actions {
+		batch.Queue(`
+			INSERT INTO corporate_action (id, created_at, symbol, kind, ex_date, new_shares, held_shares)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (symbol, kind, ex_date) DO UPDATE
+			SET new_shares = EXCLUDED.new_shares, held_shares = EXCLUDED.held_shares, updated_at = now()
+		`, a.ID, a.CreatedAt, a.Symbol, a.Kind, a.ExDate, a.NewShares, a.HeldShares)
+	}
+
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for range actions {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("upsert: %w", err)
+		}
+	}
+
+	return nil
+}
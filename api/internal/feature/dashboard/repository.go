@@ -1,10 +1,22 @@
 package dashboard
 
 import (
+	"arthveda/internal/dbx"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 )
 
-type Reader interface{}
+type Reader interface {
+	// Return the R-multiple stats of the user's positions with a risk amount that are closed by `to`
+	// and have a trade from `from`, if not nil, to `to`.
+	GetRMultipleStats(ctx context.Context, userID uuid.UUID, from *time.Time, to time.Time) (*RMultipleStats, error)
+}
 
 type Writer interface{}
 
@@ -20,3 +32,58 @@ type dashboardRepository struct {
 func NewRepository(db *pgxpool.Pool) *dashboardRepository {
 	return &dashboardRepository{db}
 }
+
+// RMultipleStats is the R of the positions, summed from the realised stats stored with their trades.
+// A position wins or loses by its net R, which leaves out its cash flows.
+type RMultipleStats struct {
+	PositionsCount  int
+	GrossRFactor    decimal.Decimal
+	NetRFactor      decimal.Decimal
+	AvgGrossRFactor decimal.Decimal
+	AvgRFactor      decimal.Decimal
+	AvgWinRFactor   decimal.Decimal
+	AvgLossRFactor  decimal.Decimal
+}
+
+func (r *dashboardRepository) GetRMultipleStats(ctx context.Context, userID uuid.UUID, from *time.Time, to time.Time) (*RMultipleStats, error) {
+	const sql = `
+		WITH position_r AS (
+			SELECT SUM(t.gross_r_factor) AS gross_r_factor, SUM(t.net_r_factor) AS net_r_factor
+			FROM position p
+			JOIN trade t ON t.position_id = p.id
+			WHERE p.created_by = @created_by
+				AND p.risk_amount > 0
+				AND p.closed_at IS NOT NULL
+				AND p.closed_at <= @to
+				AND EXISTS (
+					SELECT 1 FROM trade rt
+					WHERE rt.position_id = p.id
+						AND rt.time <= @to
+						AND (@from::TIMESTAMPTZ IS NULL OR rt.time >= @from)
+				)
+			GROUP BY p.id
+		)
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(gross_r_factor), 0),
+			COALESCE(SUM(net_r_factor), 0),
+			COALESCE(AVG(gross_r_factor), 0),
+			COALESCE(AVG(net_r_factor), 0),
+			COALESCE(AVG(net_r_factor) FILTER (WHERE net_r_factor > 0), 0),
+			COALESCE(AVG(net_r_factor) FILTER (WHERE net_r_factor < 0), 0)
+		FROM position_r
+	`
+
+	args := pgx.NamedArgs{"created_by": userID, "from": from, "to": to}
+
+	var stats RMultipleStats
+	err := dbx.Conn(ctx, r.db).QueryRow(ctx, sql, args).Scan(
+		&stats.PositionsCount, &stats.GrossRFactor, &stats.NetRFactor,
+		&stats.AvgGrossRFactor, &stats.AvgRFactor, &stats.AvgWinRFactor, &stats.AvgLossRFactor,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return &stats, nil
+}
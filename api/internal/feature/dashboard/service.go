@@ -3,7 +3,9 @@ package dashboard
 
 import (
 	"arthveda/internal/common"
+	"arthveda/internal/domain/price"
 	"arthveda/internal/domain/subscription"
+	"arthveda/internal/feature/cashflow"
 	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/trade"
 	"arthveda/internal/logger"
@@ -19,13 +21,17 @@ type Service struct {
 	dashboardRepository ReadWriter
 	positionRepository  position.ReadWriter
 	tradeRepository     trade.ReadWriter
+	priceFeed           price.Feed
+	cashFlowRepository  cashflow.Reader
 }
 
-func NewService(dashboardRepository ReadWriter, positionRepository position.ReadWriter, tradeRepository trade.ReadWriter) *Service {
+func NewService(dashboardRepository ReadWriter, positionRepository position.ReadWriter, tradeRepository trade.ReadWriter, priceFeed price.Feed, cashFlowRepository cashflow.Reader) *Service {
 	return &Service{
 		dashboardRepository,
 		positionRepository,
 		tradeRepository,
+		priceFeed,
+		cashFlowRepository,
 	}
 }
 
@@ -39,10 +45,16 @@ type GetDashboardResult struct {
 	CumulativePnLBuckets []position.PnlBucket `json:"cumulative_pnl_buckets"`
 	PnLBuckets           []position.PnlBucket `json:"pnl_buckets"`
 	NoOfPositionsHidden  int                  `json:"no_of_positions_hidden"`
+
+	// The Positions that are open right now, irrespective of the date range.
+	OpenExposure position.OpenExposure `json:"open_exposure"`
+
+	// The charges paid on the trades in the date range, by their components.
+	ChargesSummary position.ChargesSummary  `json:"charges_summary"`
+	ChargesBuckets []position.ChargesBucket `json:"charges_buckets"`
 }
 
 func (s *Service) Get(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, payload GetDashboardPayload) (*GetDashboardResult, service.Error, error) {
-	l := logger.Get()
 	now := time.Now().UTC()
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	from := time.Time{}
@@ -132,17 +144,41 @@ func (s *Service) Get(ctx context.Context, userID uuid.UUID, tz *time.Location,
 
 	positionsFiltered := position.FilterPositionsWithRealisingTradesUpTo(positions, rangeEnd, tz)
 
-	for _, pos := range positionsFiltered {
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			l.Errorw("failed to compute smart trades for position", "position_id", pos.ID, "error", err)
-			continue
-		}
+	cashFlows, err := s.cashFlowRepository.Search(ctx, userID, cashflow.SearchFilter{
+		Time: &common.DateRangeFilter{From: &rangeStart, To: &rangeEnd},
+	})
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("cash flow repository search: %w", err)
+	}
+
+	cashFlowPositions, err := position.FindCashFlowPositions(ctx, s.positionRepository, userID, cashFlows)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("find cash flow positions: %w", err)
 	}
 
 	generalStats := position.GetGeneralStats(positionsFiltered)
-	pnlBuckets := position.GetPnLBuckets(positionsFiltered, bucketPeriod, rangeStart, rangeEnd, tz)
-	cumulativePnLBuckets := position.GetCumulativePnLBuckets(positionsFiltered, bucketPeriod, rangeStart, rangeEnd, tz)
+	generalStats.AddCashFlows(cashFlows, cashFlowPositions)
+
+	// The R metrics are summed from the realised stats of the trades in SQL.
+	rStats, err := s.dashboardRepository.GetRMultipleStats(ctx, userID, tradeTimeRange.From, rangeEnd)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("dashboard repository get r multiple stats: %w", err)
+	}
+
+	generalStats.GrossRFactor = rStats.GrossRFactor.StringFixed(2)
+	generalStats.NetRFactor = rStats.NetRFactor.StringFixed(2)
+	generalStats.AvgGrossRFactor = rStats.AvgGrossRFactor.StringFixed(2)
+	generalStats.AvgRFactor = rStats.AvgRFactor.StringFixed(2)
+	generalStats.AvgWinRFactor = rStats.AvgWinRFactor.StringFixed(2)
+	generalStats.AvgLossRFactor = rStats.AvgLossRFactor.StringFixed(2)
+	pnlBuckets := position.GetPnLBuckets(positionsFiltered, cashFlows, cashFlowPositions, bucketPeriod, rangeStart, rangeEnd, tz)
+	cumulativePnLBuckets := position.GetCumulativePnLBuckets(positionsFiltered, cashFlows, cashFlowPositions, bucketPeriod, rangeStart, rangeEnd, tz)
+	chargesBuckets := position.GetChargesBuckets(positions, bucketPeriod, rangeStart, rangeEnd, tz)
+
+	openExposure, err := s.getOpenExposure(ctx, userID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get open exposure: %w", err)
+	}
 
 	result := &GetDashboardResult{
 		PositionsCount:       len(positionsFiltered),
@@ -150,7 +186,37 @@ func (s *Service) Get(ctx context.Context, userID uuid.UUID, tz *time.Location,
 		CumulativePnLBuckets: cumulativePnLBuckets,
 		PnLBuckets:           pnlBuckets,
 		NoOfPositionsHidden:  noOfPositionsHidden,
+		OpenExposure:         openExposure,
+		ChargesSummary:       position.SumChargesBuckets(chargesBuckets),
+		ChargesBuckets:       chargesBuckets,
 	}
 
 	return result, service.ErrNone, nil
 }
+
+func (s *Service) getOpenExposure(ctx context.Context, userID uuid.UUID) (position.OpenExposure, error) {
+	status := position.StatusOpen
+
+	openPositions, _, err := s.positionRepository.Search(ctx, position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy: &userID,
+			Status:    &status,
+		},
+	}, false, false)
+	if err != nil {
+		return position.OpenExposure{}, fmt.Errorf("search open positions: %w", err)
+	}
+
+	symbols := position.OpenSymbols(openPositions)
+	if len(symbols) > 0 {
+		// The quotes are best effort, the exposure is still useful without them.
+		quotes, err := s.priceFeed.GetQuotes(ctx, symbols)
+		if err != nil {
+			logger.FromCtx(ctx).Warnw("Failed to get quotes from the price feed", "error", err.Error())
+		} else {
+			position.MarkToMarket(openPositions, quotes)
+		}
+	}
+
+	return position.GetOpenExposure(openPositions), nil
+}
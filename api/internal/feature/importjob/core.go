@@ -0,0 +1,85 @@
+// Package importjob runs file imports of positions in the background, so that a large tradebook
+// isn't imported under the timeout of the request that uploaded it.
+package importjob
+
+import (
+	"arthveda/internal/feature/position"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+func (s Status) IsFinished() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Job represents the `import_job` table in the database.
+type Job struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	Status Status `json:"status" db:"status"`
+	// The percentage, from 0 to 100, of the positions of the file that have been imported.
+	Progress   int        `json:"progress" db:"progress"`
+	StartedAt  *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time `json:"finished_at" db:"finished_at"`
+
+	// What the job imports. It is kept so that the job can be run again if the API restarts while it runs,
+	// and removed once the job has finished, as a tradebook can be large.
+	Input *Input `json:"-" db:"input"`
+
+	// What the job imported. It is removed a while after the job has finished, see finishedJobRetention.
+	Result *position.ImportResult `json:"result" db:"result"`
+	Error  *string                `json:"error" db:"error"`
+}
+
+// Sheet is the rows of a sheet of a spreadsheet, or of a CSV file.
+type Sheet struct {
+	Name string     `json:"name"`
+	Rows [][]string `json:"rows"`
+}
+
+type Input struct {
+	// The payload of the import of each sheet, without its Rows.
+	Payload position.FileImportPayload `json:"payload"`
+	Sheets  []Sheet                    `json:"sheets"`
+}
+
+func newJob(userID uuid.UUID, input *Input) (*Job, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generate new UUID: %w", err)
+	}
+
+	input.Payload.Rows = nil
+
+	return &Job{
+		ID:        id,
+		CreatedBy: userID,
+		CreatedAt: time.Now().UTC(),
+		Status:    StatusPending,
+		Input:     input,
+	}, nil
+}
+
+// isAbandoned returns whether the job is running, but hasn't been updated for heartbeatTimeout, as the API
+// that ran it stopped. A job that is running is updated every heartbeatInterval.
+func (j *Job) isAbandoned(now time.Time) bool {
+	if j.Status != StatusRunning {
+		return false
+	}
+
+	return j.UpdatedAt == nil || j.UpdatedAt.Before(now.Add(-heartbeatTimeout))
+}
@@ -0,0 +1,192 @@
+package importjob
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	GetByID(ctx context.Context, userID, id uuid.UUID) (*Job, error)
+	// ListUnfinished returns the jobs of all the users that are pending or running, oldest first.
+	ListUnfinished(ctx context.Context) ([]*Job, error)
+}
+
+type Writer interface {
+	Create(ctx context.Context, job *Job) error
+	// Claim marks the job as running and returns it, if it hasn't been updated since `updatedAt` and it is
+	// pending, or running but last updated before `abandonedBefore`. It returns repository.ErrNotFound if
+	// another run has claimed it since, or is still running it.
+	Claim(ctx context.Context, id uuid.UUID, updatedAt *time.Time, abandonedBefore time.Time) (*Job, error)
+	Update(ctx context.Context, job *Job) error
+	// UpdateProgress updates only the progress of the job, as it is updated often.
+	UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error
+	// Heartbeat updates only the updated_at of the job, so that it isn't taken for abandoned while it runs.
+	Heartbeat(ctx context.Context, id uuid.UUID) error
+	// PruneFinished removes the input and result of the jobs that finished before `before`.
+	PruneFinished(ctx context.Context, before time.Time) error
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+//
+// PostgreSQL implementation
+//
+
+type importJobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *importJobRepository {
+	return &importJobRepository{db}
+}
+
+const jobColumns = `id, created_by, created_at, updated_at, status, progress, started_at, finished_at, input, result, error`
+
+func scanJobs(rows pgx.Rows) ([]*Job, error) {
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		var j Job
+		err := rows.Scan(
+			&j.ID, &j.CreatedBy, &j.CreatedAt, &j.UpdatedAt, &j.Status, &j.Progress, &j.StartedAt, &j.FinishedAt,
+			&j.Input, &j.Result, &j.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		jobs = append(jobs, &j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func (r *importJobRepository) GetByID(ctx context.Context, userID, id uuid.UUID) (*Job, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `SELECT `+jobColumns+` FROM import_job WHERE id = $1 AND created_by = $2`, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (r *importJobRepository) ListUnfinished(ctx context.Context) ([]*Job, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM import_job
+		WHERE status IN ($1, $2)
+		ORDER BY created_at ASC
+	`, StatusPending, StatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return scanJobs(rows)
+}
+
+func (r *importJobRepository) Create(ctx context.Context, j *Job) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO import_job (`+jobColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		j.ID, j.CreatedBy, j.CreatedAt, j.UpdatedAt, j.Status, j.Progress, j.StartedAt, j.FinishedAt,
+		j.Input, j.Result, j.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	return nil
+}
+
+func (r *importJobRepository) Claim(ctx context.Context, id uuid.UUID, updatedAt *time.Time, abandonedBefore time.Time) (*Job, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		UPDATE import_job
+		SET status = $3, progress = 0, started_at = now(), updated_at = now()
+		WHERE id = $1 AND updated_at IS NOT DISTINCT FROM $2
+			AND (status = $4 OR (status = $3 AND updated_at < $5))
+		RETURNING `+jobColumns+`
+	`, id, updatedAt, StatusRunning, StatusPending, abandonedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (r *importJobRepository) Update(ctx context.Context, j *Job) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		UPDATE import_job
+		SET updated_at = now(), status = $2, progress = $3, started_at = $4, finished_at = $5, result = $6, error = $7,
+			input = CASE WHEN $2 IN ('succeeded', 'failed') THEN NULL ELSE input END
+		WHERE id = $1
+	`, j.ID, j.Status, j.Progress, j.StartedAt, j.FinishedAt, j.Result, j.Error)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+func (r *importJobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `UPDATE import_job SET updated_at = now(), progress = $2 WHERE id = $1`, id, progress)
+	if err != nil {
+		return fmt.Errorf("update progress: %w", err)
+	}
+
+	return nil
+}
+
+func (r *importJobRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `UPDATE import_job SET updated_at = now() WHERE id = $1 AND status = $2`, id, StatusRunning)
+	if err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+func (r *importJobRepository) PruneFinished(ctx context.Context, before time.Time) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		UPDATE import_job
+		SET input = NULL, result = NULL
+		WHERE status IN ($2, $3) AND finished_at < $1 AND (input IS NOT NULL OR result IS NOT NULL)
+	`, before, StatusSucceeded, StatusFailed)
+	if err != nil {
+		return fmt.Errorf("prune finished: %w", err)
+	}
+
+	return nil
+}
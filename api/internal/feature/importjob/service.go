@@ -0,0 +1,251 @@
+package importjob
+
+import (
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/strategy"
+	"arthveda/internal/logger"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The jobs that can run at the same time. Each one holds the rows of its file in memory.
+const maxRunningJobs = 2
+
+// How long the result of a finished job is kept for the user to see what it imported.
+const finishedJobRetention = 7 * 24 * time.Hour
+
+// A running job is updated every heartbeatInterval. One that hasn't been updated for heartbeatTimeout
+// was abandoned by an API that stopped, and is run again by the next one to resume the jobs.
+const (
+	heartbeatInterval = 30 * time.Second
+	heartbeatTimeout  = 3 * heartbeatInterval
+)
+
+type Service struct {
+	importJobRepository ReadWriter
+	positionService     *position.Service
+	strategyService     *strategy.Service
+
+	slots chan struct{}
+}
+
+func NewService(importJobRepository ReadWriter, positionService *position.Service, strategyService *strategy.Service) *Service {
+	return &Service{
+		importJobRepository: importJobRepository,
+		positionService:     positionService,
+		strategyService:     strategyService,
+		slots:               make(chan struct{}, maxRunningJobs),
+	}
+}
+
+var errNoSheets = errors.New("File has no rows to import")
+
+// Create saves the job and starts running it in the background.
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, input *Input) (*Job, service.Error, error) {
+	if len(input.Sheets) == 0 {
+		return nil, service.ErrBadRequest, errNoSheets
+	}
+
+	job, err := newJob(userID, input)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	if err := s.importJobRepository.Create(ctx, job); err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("create: %w", err)
+	}
+
+	if err := s.importJobRepository.PruneFinished(ctx, time.Now().Add(-finishedJobRetention)); err != nil {
+		logger.FromCtx(ctx).Warnw("failed to prune finished import jobs", "error", err)
+	}
+
+	go s.run(logger.WithCtx(context.Background(), logger.FromCtx(ctx)), job)
+
+	return job, service.ErrNone, nil
+}
+
+func (s *Service) Get(ctx context.Context, userID, id uuid.UUID) (*Job, service.Error, error) {
+	job, err := s.importJobRepository.GetByID(ctx, userID, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Import job not found")
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("get: %w", err)
+	}
+
+	return job, service.ErrNone, nil
+}
+
+// Resume runs again the jobs that are pending, or that were running when an API stopped. A job is run from
+// the start of its file. The positions it had imported before are found to be duplicates,
+// so they are not imported twice. Each job is claimed before it runs, so that a job is run once
+// even if more than one instance of the API resumes it. A job another instance is running is left to it.
+func (s *Service) Resume(ctx context.Context) error {
+	if err := s.importJobRepository.PruneFinished(ctx, time.Now().Add(-finishedJobRetention)); err != nil {
+		return fmt.Errorf("prune finished: %w", err)
+	}
+
+	jobs, err := s.importJobRepository.ListUnfinished(ctx)
+	if err != nil {
+		return fmt.Errorf("list unfinished: %w", err)
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		if job.Status == StatusRunning && !job.isAbandoned(now) {
+			logger.FromCtx(ctx).Infow("import job is running elsewhere, not resuming it", "job_id", job.ID)
+			continue
+		}
+
+		logger.FromCtx(ctx).Infow("resuming import job", "job_id", job.ID, "status", job.Status)
+		go s.run(ctx, job)
+	}
+
+	return nil
+}
+
+func (s *Service) run(ctx context.Context, job *Job) {
+	l := logger.FromCtx(ctx).With("job_id", job.ID)
+
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	// An adapter that panics on a malformed row must not take the API down with it.
+	defer func() {
+		if r := recover(); r != nil {
+			s.fail(ctx, job, service.ErrInternalServerError, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	claimed, err := s.importJobRepository.Claim(ctx, job.ID, job.UpdatedAt, time.Now().Add(-heartbeatTimeout))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			l.Infow("import job was claimed by another run, skipping it")
+		} else {
+			l.Errorw("failed to claim import job", "error", err)
+		}
+		return
+	}
+
+	job.Status = claimed.Status
+	job.Progress = claimed.Progress
+	job.StartedAt = claimed.StartedAt
+	job.UpdatedAt = claimed.UpdatedAt
+
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go s.heartbeat(ctx, job.ID, stopHeartbeat)
+
+	var result *position.ImportResult
+	sheetsCount := len(job.Input.Sheets)
+
+	for sheetIdx, sheet := range job.Input.Sheets {
+		payload := job.Input.Payload
+		payload.Rows = sheet.Rows
+		payload.Sheet = sheet.Name
+		payload.OnProgress = func(done, total int) {
+			progress := 100 * sheetIdx / sheetsCount
+			if total > 0 {
+				progress += 100 * done / total / sheetsCount
+			}
+
+			if progress <= job.Progress {
+				return
+			}
+
+			job.Progress = progress
+			if err := s.importJobRepository.UpdateProgress(ctx, job.ID, progress); err != nil {
+				l.Warnw("failed to update import job progress", "error", err)
+			}
+		}
+
+		sheetResult, errKind, err := s.positionService.FileImport(ctx, job.CreatedBy, payload)
+		if err != nil {
+			s.fail(ctx, job, errKind, err)
+			return
+		}
+
+		if result == nil {
+			result = sheetResult
+		} else {
+			result.Merge(sheetResult)
+		}
+	}
+
+	if job.Input.Payload.Confirm && !job.Input.Payload.DryRun {
+		s.autoDetectStrategies(ctx, job.CreatedBy, result)
+	}
+
+	finishedAt := time.Now().UTC()
+	job.Status = StatusSucceeded
+	job.Progress = 100
+	job.FinishedAt = &finishedAt
+	job.Result = result
+
+	if err := s.importJobRepository.Update(ctx, job); err != nil {
+		l.Errorw("failed to mark import job as succeeded", "error", err)
+	}
+}
+
+// heartbeat updates the job every heartbeatInterval until `stop` is closed, so that it isn't claimed
+// by another run while this one is still running it.
+func (s *Service) heartbeat(ctx context.Context, id uuid.UUID, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.importJobRepository.Heartbeat(ctx, id); err != nil {
+				logger.FromCtx(ctx).Warnw("failed to update import job heartbeat", "job_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// fail marks the job as failed. The errors that aren't the user's are logged, and not shown to them.
+func (s *Service) fail(ctx context.Context, job *Job, errKind service.Error, err error) {
+	l := logger.FromCtx(ctx).With("job_id", job.ID)
+
+	message := err.Error()
+	if errKind == service.ErrInternalServerError {
+		l.Errorw("import job failed", "error", err)
+		message = "Something went wrong while importing the file"
+	}
+
+	now := time.Now().UTC()
+	job.Status = StatusFailed
+	job.FinishedAt = &now
+	job.Error = &message
+
+	if err := s.importJobRepository.Update(ctx, job); err != nil {
+		l.Errorw("failed to mark import job as failed", "error", err)
+	}
+}
+
+func (s *Service) autoDetectStrategies(ctx context.Context, userID uuid.UUID, result *position.ImportResult) {
+	if result == nil {
+		return
+	}
+
+	imported := []*position.Position{}
+	for _, p := range result.Positions {
+		// Duplicates were either skipped or were already in Arthveda.
+		if !p.IsDuplicate {
+			imported = append(imported, p)
+		}
+	}
+
+	_, _, err := s.strategyService.AutoDetect(ctx, userID, imported)
+	if err != nil {
+		logger.FromCtx(ctx).Errorw("failed to auto detect strategies", "error", err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+package importjob
+
+import (
+	"arthveda/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestJobIsAbandoned(t *testing.T) {
+	now := time.Now()
+	fresh := now.Add(-heartbeatInterval)
+	stale := now.Add(-heartbeatTimeout - time.Second)
+
+	tests := []struct {
+		name string
+		job  Job
+		want bool
+	}{
+		{"pending", Job{Status: StatusPending, UpdatedAt: &stale}, false},
+		{"running with a heartbeat", Job{Status: StatusRunning, UpdatedAt: &fresh}, false},
+		{"running without a heartbeat", Job{Status: StatusRunning, UpdatedAt: &stale}, true},
+		{"failed", Job{Status: StatusFailed, UpdatedAt: &stale}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.job.isAbandoned(now); got != tt.want {
+				t.Errorf("isAbandoned = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRepository has the unfinished jobs, and sends the ID of each job a run tries to claim. The claims fail,
+// as if another run had claimed the jobs, so that nothing else of the repository is used.
+type fakeRepository struct {
+	ReadWriter
+	jobs    []*Job
+	claimed chan uuid.UUID
+}
+
+func (r *fakeRepository) ListUnfinished(ctx context.Context) ([]*Job, error) {
+	return r.jobs, nil
+}
+
+func (r *fakeRepository) PruneFinished(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func (r *fakeRepository) Claim(ctx context.Context, id uuid.UUID, updatedAt *time.Time, abandonedBefore time.Time) (*Job, error) {
+	r.claimed <- id
+	return nil, repository.ErrNotFound
+}
+
+func TestResume(t *testing.T) {
+	fresh := time.Now().Add(-heartbeatInterval)
+	stale := time.Now().Add(-heartbeatTimeout - time.Second)
+
+	pending := &Job{ID: uuid.New(), Status: StatusPending}
+	abandoned := &Job{ID: uuid.New(), Status: StatusRunning, UpdatedAt: &stale}
+	running := &Job{ID: uuid.New(), Status: StatusRunning, UpdatedAt: &fresh}
+
+	repo := &fakeRepository{jobs: []*Job{pending, abandoned, running}, claimed: make(chan uuid.UUID, 3)}
+	s := NewService(repo, nil, nil)
+
+	if err := s.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume: %s", err)
+	}
+
+	claimed := map[uuid.UUID]bool{}
+	for range 2 {
+		select {
+		case id := <-repo.claimed:
+			claimed[id] = true
+		case <-time.After(time.Second):
+			t.Fatalf("expected the pending and the abandoned job to be claimed, got %d claims", len(claimed))
+		}
+	}
+
+	if !claimed[pending.ID] || !claimed[abandoned.ID] {
+		t.Errorf("expected the pending and the abandoned job to be claimed")
+	}
+
+	select {
+	case id := <-repo.claimed:
+		if id == running.ID {
+			t.Errorf("expected the job running elsewhere not to be claimed")
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -62,7 +62,7 @@ func (s *Service) Get(ctx context.Context, userID uuid.UUID, tz *time.Location,
 
 	baselineResult := position.GetGeneralStats(allPositions)
 
-	timeframes, svcErr, err := s.report.GetTimeframes(ctx, userID, tz, enforcer)
+	timeframes, svcErr, err := s.report.GetTimeframes(ctx, userID, tz, enforcer, nil)
 	if err != nil {
 		return nil, svcErr, err
 	}
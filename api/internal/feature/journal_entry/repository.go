@@ -1,6 +1,7 @@
 package journal_entry
 
 import (
+	"arthveda/internal/dbx"
 	"arthveda/internal/repository"
 	"context"
 	"fmt"
@@ -55,7 +56,7 @@ func (r *journalEntryRepository) UpsertForPosition(ctx context.Context, userID,
 		SET updated_at = EXCLUDED.updated_at
 		RETURNING id, user_id, scope, position_id, created_at, updated_at
 	`
-	err = r.db.QueryRow(
+	err = dbx.Conn(ctx, r.db).QueryRow(
 		ctx, query, journalEntry.ID, journalEntry.UserID, journalEntry.Scope,
 		journalEntry.PositionID, journalEntry.CreatedAt, journalEntry.UpdatedAt,
 	).Scan(
@@ -77,7 +78,7 @@ func (r *journalEntryRepository) GetForPosition(ctx context.Context, userID, pos
 	`
 	journalEntry := JournalEntry{}
 
-	err := r.db.QueryRow(ctx, query, userID, positionID, JournalEntryScopePosition).Scan(
+	err := dbx.Conn(ctx, r.db).QueryRow(ctx, query, userID, positionID, JournalEntryScopePosition).Scan(
 		&journalEntry.ID, &journalEntry.UserID, &journalEntry.Scope, &journalEntry.PositionID,
 		&journalEntry.CreatedAt, &journalEntry.UpdatedAt,
 	)
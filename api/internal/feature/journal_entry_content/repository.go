@@ -1,6 +1,7 @@
 package journal_entry_content
 
 import (
+	"arthveda/internal/dbx"
 	"arthveda/internal/repository"
 	"context"
 	"encoding/json"
@@ -35,7 +36,7 @@ func NewRepository(db *pgxpool.Pool) *journalEntryContentRepository {
 func (r *journalEntryContentRepository) Upsert(ctx context.Context, entryID uuid.UUID, content json.RawMessage) (*JournalEntryContent, error) {
 	journalEntryContent := JournalEntryContent{}
 
-	err := r.db.QueryRow(
+	err := dbx.Conn(ctx, r.db).QueryRow(
 		ctx,
 		`INSERT INTO journal_entry_content (journal_entry_id, content) VALUES ($1, $2)
 		ON CONFLICT (journal_entry_id) DO UPDATE SET content = EXCLUDED.content
@@ -54,7 +55,7 @@ func (r *journalEntryContentRepository) Upsert(ctx context.Context, entryID uuid
 func (r *journalEntryContentRepository) GetByJournalEntryID(ctx context.Context, journalEntryID uuid.UUID) (*JournalEntryContent, error) {
 	journalEntryContent := JournalEntryContent{}
 
-	err := r.db.QueryRow(
+	err := dbx.Conn(ctx, r.db).QueryRow(
 		ctx,
 		`SELECT journal_entry_id, content FROM journal_entry_content WHERE journal_entry_id = $1`,
 		journalEntryID,
@@ -0,0 +1,127 @@
+package position
+
+import (
+	"arthveda/internal/domain/broker_integration"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errImportFileNoMatch = errors.New("File doesn't match the tradebook of any supported broker. Map its columns to import it.")
+
+// BrokerMatch is a broker whose tradebook an import file matches, and how well.
+type BrokerMatch struct {
+	broker_integration.FileAdapterMatch
+	Broker *broker.Broker `json:"broker"`
+}
+
+type BrokerDetectionResult struct {
+	// The brokers whose tradebook the file matches, the best match first.
+	Matches []BrokerMatch `json:"matches"`
+
+	// The broker to select, and the Instruments of the file's trades, if the file clearly matches
+	// one broker's tradebook. Otherwise the user picks one of the Matches.
+	Broker      *broker.Broker     `json:"broker"`
+	Instruments []types.Instrument `json:"instruments"`
+}
+
+// DetectBroker returns the brokers whose tradebook the rows match.
+func (s *Service) DetectBroker(ctx context.Context, rows [][]string) (*BrokerDetectionResult, service.Error, error) {
+	matches := broker_integration.DetectFileAdapters(rows)
+	if len(matches) == 0 {
+		return nil, service.ErrBadRequest, errImportFileNoMatch
+	}
+
+	result := &BrokerDetectionResult{
+		Matches:     []BrokerMatch{},
+		Instruments: []types.Instrument{},
+	}
+
+	// The matches of the brokers that have been added, to select from.
+	addedMatches := []broker_integration.FileAdapterMatch{}
+
+	for _, match := range matches {
+		b, err := s.BrokerRepository.GetByName(ctx, match.BrokerName)
+		if err != nil {
+			// A broker we have a FileAdapter of, but haven't added yet.
+			if err == repository.ErrNotFound {
+				continue
+			}
+			return nil, service.ErrInternalServerError, fmt.Errorf("get broker by name: %w", err)
+		}
+
+		result.Matches = append(result.Matches, BrokerMatch{FileAdapterMatch: match, Broker: b})
+		addedMatches = append(addedMatches, match)
+	}
+
+	if len(result.Matches) == 0 {
+		return nil, service.ErrBadRequest, errImportFileNoMatch
+	}
+
+	if selected, ok := broker_integration.SelectFileAdapterMatch(addedMatches); ok {
+		for _, match := range result.Matches {
+			if match.BrokerName == selected.BrokerName {
+				result.Broker = match.Broker
+				result.Instruments = selected.Instruments
+			}
+		}
+	}
+
+	return result, service.ErrNone, nil
+}
+
+// detectBroker returns the broker whose tradebook the rows clearly match,
+// for a file import that didn't say which broker the file is of.
+func (s *Service) detectBroker(ctx context.Context, rows [][]string) (*broker.Broker, service.Error, error) {
+	result, errKind, err := s.DetectBroker(ctx, rows)
+	if err != nil {
+		return nil, errKind, err
+	}
+
+	if result.Broker == nil {
+		names := []string{}
+		for _, match := range result.Matches {
+			names = append(names, string(match.BrokerName))
+		}
+		return nil, service.ErrBadRequest, fmt.Errorf("File could be a tradebook of %s. Pick the broker to import it.", strings.Join(names, " or "))
+	}
+
+	return result.Broker, service.ErrNone, nil
+}
+
+// unparsableFileError returns the error for a file the broker's FileAdapter fails to parse, `err` unless
+// the file matches the tradebook of another broker that has been added, which the error then suggests.
+func (s *Service) unparsableFileError(ctx context.Context, rows [][]string, b *broker.Broker, fileMapping *broker_integration.FileMapping, err error) (service.Error, error) {
+	// The columns the user mapped are the only format the file can be in.
+	if fileMapping != nil {
+		return service.ErrBadRequest, err
+	}
+
+	matches := broker_integration.DetectFileAdapters(rows)
+
+	for _, match := range matches {
+		if match.BrokerName == b.Name {
+			return service.ErrBadRequest, err
+		}
+	}
+
+	for _, match := range matches {
+		other, getErr := s.BrokerRepository.GetByName(ctx, match.BrokerName)
+		if getErr != nil {
+			// A broker we have a FileAdapter of, but haven't added yet.
+			if getErr == repository.ErrNotFound {
+				continue
+			}
+			return service.ErrInternalServerError, fmt.Errorf("get broker by name: %w", getErr)
+		}
+
+		return service.ErrBadRequest, fmt.Errorf("File looks like a %s tradebook, not a %s one. Pick %s as the broker to import it.", other.Name, b.Name, other.Name)
+	}
+
+	return service.ErrBadRequest, err
+}
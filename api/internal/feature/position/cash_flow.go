@@ -0,0 +1,195 @@
+package position
+
+import (
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/logger"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateCashFlow records a dividend, interest or other cash flow against a Position or a UserBrokerAccount.
+// The Position, if any, is recomputed so that the cash flow is included in its income.
+func (s *Service) CreateCashFlow(ctx context.Context, userID uuid.UUID, payload cashflow.CreatePayload) (*cashflow.CashFlow, service.Error, error) {
+	var position *Position
+
+	if payload.PositionID != nil {
+		var err error
+		position, err = s.positionRepository.GetByID(ctx, userID, *payload.PositionID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				return nil, service.ErrNotFound, fmt.Errorf("Position not found with ID: %s", *payload.PositionID)
+			}
+			return nil, service.ErrInternalServerError, fmt.Errorf("failed to get position by ID: %w", err)
+		}
+
+		// A CashFlow of a Position always belongs to the Position's broker account.
+		payload.UserBrokerAccountID = position.UserBrokerAccountID
+	} else if payload.UserBrokerAccountID != nil {
+		account, err := s.userBrokerAccountRepository.GetByID(ctx, *payload.UserBrokerAccountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				return nil, service.ErrNotFound, fmt.Errorf("Broker account not found with ID: %s", *payload.UserBrokerAccountID)
+			}
+			return nil, service.ErrInternalServerError, fmt.Errorf("failed to get broker account by ID: %w", err)
+		}
+
+		if account.UserID != userID {
+			return nil, service.ErrNotFound, fmt.Errorf("Broker account not found with ID: %s", *payload.UserBrokerAccountID)
+		}
+	}
+
+	cashFlow, err := cashflow.New(userID, payload)
+	if err != nil {
+		return nil, service.ErrBadRequest, err
+	}
+
+	err = s.cashFlowRepository.Create(ctx, cashFlow)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("cash flow repository create: %w", err)
+	}
+
+	if position != nil {
+		position.CashFlows = append(position.CashFlows, cashFlow)
+		if err := s.recomputeCashFlows(ctx, position); err != nil {
+			return nil, service.ErrInternalServerError, err
+		}
+	}
+
+	return cashFlow, service.ErrNone, nil
+}
+
+func (s *Service) UpdateCashFlow(ctx context.Context, userID, cashFlowID uuid.UUID, payload cashflow.UpdatePayload) (*cashflow.CashFlow, service.Error, error) {
+	cashFlow, err := s.cashFlowRepository.GetByID(ctx, userID, cashFlowID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Cash flow not found with ID: %s", cashFlowID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get cash flow by ID: %w", err)
+	}
+
+	err = cashFlow.Update(payload)
+	if err != nil {
+		return nil, service.ErrBadRequest, err
+	}
+
+	err = s.cashFlowRepository.Update(ctx, cashFlow)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("cash flow repository update: %w", err)
+	}
+
+	svcErr, err := s.recomputePositionOfCashFlow(ctx, userID, cashFlow)
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	return cashFlow, service.ErrNone, nil
+}
+
+func (s *Service) DeleteCashFlow(ctx context.Context, userID, cashFlowID uuid.UUID) (service.Error, error) {
+	cashFlow, err := s.cashFlowRepository.GetByID(ctx, userID, cashFlowID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return service.ErrNotFound, fmt.Errorf("Cash flow not found with ID: %s", cashFlowID)
+		}
+		return service.ErrInternalServerError, fmt.Errorf("failed to get cash flow by ID: %w", err)
+	}
+
+	err = s.cashFlowRepository.Delete(ctx, cashFlow.ID)
+	if err != nil {
+		return service.ErrInternalServerError, fmt.Errorf("cash flow repository delete: %w", err)
+	}
+
+	return s.recomputePositionOfCashFlow(ctx, userID, cashFlow)
+}
+
+func (s *Service) SearchCashFlows(ctx context.Context, userID uuid.UUID, filter cashflow.SearchFilter) ([]*cashflow.CashFlow, service.Error, error) {
+	cashFlows, err := s.cashFlowRepository.Search(ctx, userID, filter)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("cash flow repository search: %w", err)
+	}
+
+	return cashFlows, service.ErrNone, nil
+}
+
+// recomputePositionOfCashFlow recomputes the Position the CashFlow belongs to, if any, with its current cash flows.
+func (s *Service) recomputePositionOfCashFlow(ctx context.Context, userID uuid.UUID, cashFlow *cashflow.CashFlow) (service.Error, error) {
+	if cashFlow.PositionID == nil {
+		return service.ErrNone, nil
+	}
+
+	position, err := s.positionRepository.GetByID(ctx, userID, *cashFlow.PositionID)
+	if err != nil {
+		// The Position may have been deleted along with its cash flows in the meantime.
+		if errors.Is(err, repository.ErrNotFound) {
+			return service.ErrNone, nil
+		}
+		return service.ErrInternalServerError, fmt.Errorf("failed to get position by ID: %w", err)
+	}
+
+	if err := s.recomputeCashFlows(ctx, position); err != nil {
+		return service.ErrInternalServerError, err
+	}
+
+	return service.ErrNone, nil
+}
+
+// recomputeCashFlows recomputes and saves the Position after its cash flows have changed.
+func (s *Service) recomputeCashFlows(ctx context.Context, position *Position) error {
+	if err := s.splitReversedPosition(ctx, position); err != nil {
+		return err
+	}
+
+	computeResult, err := Compute(position.ComputePayload())
+	if err != nil {
+		logger.FromCtx(ctx).Errorw("failed to recompute position with its cash flows", "error", err, "position_id", position.ID)
+		return fmt.Errorf("compute position: %w", err)
+	}
+
+	now := time.Now().UTC()
+	position.UpdatedAt = &now
+
+	ApplyComputeResultToPosition(position, computeResult)
+
+	err = s.positionRepository.Update(ctx, position)
+	if err != nil {
+		return fmt.Errorf("position repository update: %w", err)
+	}
+
+	return nil
+}
+
+// FindCashFlowPositions returns the Positions of the CashFlows by their ID. They are found on their own
+// instead of among the Positions being reported on, which leave out a stock that is held across the range
+// without a trade in it but is still paid a dividend.
+func FindCashFlowPositions(ctx context.Context, positionRepository Reader, userID uuid.UUID, cashFlows []*cashflow.CashFlow) (map[uuid.UUID]*Position, error) {
+	ids := []uuid.UUID{}
+	for _, c := range cashFlows {
+		if c.PositionID != nil {
+			ids = append(ids, *c.PositionID)
+		}
+	}
+
+	result := make(map[uuid.UUID]*Position, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	positions, _, err := positionRepository.Search(ctx, SearchPayload{
+		Filters: SearchFilter{CreatedBy: &userID, IDs: ids},
+	}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("position repository search: %w", err)
+	}
+
+	for _, p := range positions {
+		result[p.ID] = p
+	}
+
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"arthveda/internal/common"
 	"arthveda/internal/domain/types"
 	"arthveda/internal/feature/broker"
+	"arthveda/internal/feature/charge"
 	"arthveda/internal/feature/trade"
 	"arthveda/internal/logger"
 	"fmt"
@@ -15,22 +16,61 @@ import (
 
 // TODO: We need to also handle if the Future/Options trades are Equity based on Commodity based.
 
-func CalculateAndApplyChargesToTrades(trades []*trade.Trade, instrument types.Instrument, brokerName broker.Name) (charges []decimal.Decimal, userError bool, err error) {
-	charges = make([]decimal.Decimal, len(trades))
+// brokersWithoutDefaultBrokerage are the brokers whose brokerage depends on the plan the user is on, so the
+// Schedules have none of it. Like other brokers, their trades are only charged with the user's BrokeragePlan.
+var brokersWithoutDefaultBrokerage = map[broker.Name]bool{
+	broker.BrokerNameHDFCSecurities: true,
+	broker.BrokerNameICICIDirect:    true,
+	broker.BrokerNameMotilalOswal:   true,
+	broker.BrokerNameOther:          true,
+}
+
+// errNoDefaultBrokerage is returned for an import with the charges calculated, of a broker without
+// default brokerage, if the user hasn't set their plan.
+func errNoDefaultBrokerage(brokerName broker.Name) error {
+	return fmt.Errorf("Brokerage of %s depends on your plan. Set your brokerage plan on the broker account, or enter the charges manually, to import the trades.", brokerName)
+}
+
+// needsBrokeragePlan returns whether the charges of the broker's trades can only be calculated with the user's BrokeragePlan.
+func needsBrokeragePlan(brokerName broker.Name, plan *charge.BrokeragePlan, feeModel *charge.FeeModel) bool {
+	if _, ok := feeModelChargesByKind[feeModel.KindOrDefault()]; ok {
+		return false
+	}
 
+	// The user picks another broker knowing we don't have its rates.
 	if brokerName == broker.BrokerNameOther {
+		return false
+	}
+
+	return brokersWithoutDefaultBrokerage[brokerName] && plan == nil
+}
+
+// CalculateAndApplyChargesToTrades calculates the charges of each trade with the rates of the Schedule
+// that applies on the trade's date, and sets them on the trades.
+// The brokerage is calculated with the user's BrokeragePlan instead of the broker's default plan if it is set.
+// Trades on US or crypto exchanges are charged with the fees of the user's FeeModel instead, see feeModelChargesByKind.
+func CalculateAndApplyChargesToTrades(trades []*trade.Trade, instrument types.Instrument, segment types.Segment, brokerName broker.Name, plan *charge.BrokeragePlan, feeModel *charge.FeeModel, schedules charge.Schedules) (charges []decimal.Decimal, userError bool, err error) {
+	if calculate, ok := feeModelChargesByKind[feeModel.KindOrDefault()]; ok {
+		return calculate(trades, instrument, segment, plan, feeModel, schedules), false, nil
+	}
+
+	charges = make([]decimal.Decimal, len(trades))
+
+	// We don't know the brokerage of these brokers without a plan from the user.
+	if brokersWithoutDefaultBrokerage[brokerName] && plan == nil {
 		return charges, false, nil
 	}
 
 	for i, trade := range trades {
 		tradeValue := trade.Quantity.Mul(trade.Price)
+		schedule := schedules.On(trade.Time)
 
 		if instrument == types.InstrumentEquity {
 			intraday := EquityTradeIntraday
 			delivery := EquityTradeDelivery
 
-			equityIntradayChargesConfig := getComputeTradeChargesConfig(brokerName, instrument, &intraday)
-			equityDeliveryChargesConfig := getComputeTradeChargesConfig(brokerName, instrument, &delivery)
+			equityIntradayChargesConfig := getComputeTradeChargesConfig(schedule, brokerName, plan, instrument, segment, &intraday)
+			equityDeliveryChargesConfig := getComputeTradeChargesConfig(schedule, brokerName, plan, instrument, segment, &delivery)
 
 			chargeContextByTradeID, userError, err := computeEquityTradeChargesContext(trades)
 			if err != nil {
@@ -42,10 +82,12 @@ func CalculateAndApplyChargesToTrades(trades []*trade.Trade, instrument types.In
 				// If we don't have a charges context for the trade, we skip it.
 				charges[i] = decimal.Zero
 				trades[i].ChargesAmount = decimal.Zero
+				trades[i].ChargesBreakdown = &chargesBreakdown{}
 				continue
 			}
 
 			finalCharges := decimal.Zero
+			finalBreakdown := chargesBreakdown{}
 
 			// For equity trades, we will need to take care of intraday and delivery.
 			for _, split := range chargeContext.ChargesSplits {
@@ -72,24 +114,28 @@ func CalculateAndApplyChargesToTrades(trades []*trade.Trade, instrument types.In
 
 				// Calculate the total charges for the trade based on the split.
 				tradeValue := split.Quantity.Mul(trade.Price)
-				charges := getTotalChargesForTrade(tradeValue, trade.Kind, config)
+				charges, breakdown := getTotalChargesForTrade(tradeValue, split.Quantity, trade.Quantity, trade.Kind, config)
 				finalCharges = finalCharges.Add(charges)
+				finalBreakdown = finalBreakdown.Add(breakdown)
 			}
 
 			charges[i] = finalCharges
 
 			// Apply the charges to the trade.
 			trades[i].ChargesAmount = finalCharges
+			trades[i].ChargesBreakdown = &finalBreakdown
 		} else if instrument == types.InstrumentFuture {
-			config := getComputeTradeChargesConfig(brokerName, instrument, nil)
-			tradeCharges := getTotalChargesForTrade(tradeValue, trade.Kind, config)
+			config := getComputeTradeChargesConfig(schedule, brokerName, plan, instrument, segment, nil)
+			tradeCharges, breakdown := getTotalChargesForTrade(tradeValue, trade.Quantity, trade.Quantity, trade.Kind, config)
 			charges[i] = tradeCharges
 			trades[i].ChargesAmount = tradeCharges
+			trades[i].ChargesBreakdown = &breakdown
 		} else if instrument == types.InstrumentOption {
-			config := getComputeTradeChargesConfig(brokerName, instrument, nil)
-			tradeCharges := getTotalChargesForTrade(tradeValue, trade.Kind, config)
+			config := getComputeTradeChargesConfig(schedule, brokerName, plan, instrument, segment, nil)
+			tradeCharges, breakdown := getTotalChargesForTrade(tradeValue, trade.Quantity, trade.Quantity, trade.Kind, config)
 			charges[i] = tradeCharges
 			trades[i].ChargesAmount = tradeCharges
+			trades[i].ChargesBreakdown = &breakdown
 		} else {
 			logger.Get().Errorw("CalculateAndApplyChargesToTrades: unknown instrument for trade charges", "instrument", instrument)
 			return charges, false, nil
@@ -111,23 +157,16 @@ func calculateTotalChargesAmountFromTrades(trades []*trade.Trade) decimal.Decima
 	return totalCharges
 }
 
-// getTotalChargesForTrade computes the total charges for a trade based on the trade value and the configuration.
-// tradeValue is the value of the trade (quantity * price).
-func getTotalChargesForTrade(tradeValue decimal.Decimal, tradeKind types.TradeKind, config computeChargesConfig) decimal.Decimal {
-	// Calcualte brokerage
-	// Formula : tradeValue * brokeragePercent -> apply min/max
-	brokerageCharges := tradeValue.Mul(decimal.NewFromFloat(config.brokerage.percent / 100))
+type chargesBreakdown = trade.ChargesBreakdown
 
-	// Apply min/max brokerage charges
-	if brokerageCharges.GreaterThan(decimal.NewFromFloat(config.brokerage.max)) {
-		brokerageCharges = decimal.NewFromFloat(config.brokerage.max)
-	}
-
-	if brokerageCharges.LessThan(decimal.NewFromFloat(config.brokerage.min)) {
-		brokerageCharges = decimal.NewFromFloat(config.brokerage.min)
-	}
+// getTotalChargesForTrade computes the total charges for a trade based on the trade value and the configuration,
+// along with their breakdown. tradeValue is the value of the trade (quantity * price). quantity is the quantity charged,
+// which is less than orderQuantity, the trade's quantity, when an equity trade is split into intraday and delivery.
+func getTotalChargesForTrade(tradeValue, quantity, orderQuantity decimal.Decimal, tradeKind types.TradeKind, config computeChargesConfig) (decimal.Decimal, chargesBreakdown) {
+	// Calcualte brokerage
+	brokerageCharges := getBrokerageCharges(tradeValue, quantity, orderQuantity, config.brokerage)
 
-	// Calculate STT
+	// Calculate STT, or CTT for commodities
 	var sttCharges decimal.Decimal
 
 	switch tradeKind {
@@ -139,7 +178,12 @@ func getTotalChargesForTrade(tradeValue decimal.Decimal, tradeKind types.TradeKi
 
 	// Calculate exchange transaction charges
 	// TODO: We need to pass exchange as a parameter to this function.
-	exchangeTxnCharges := tradeValue.Mul(decimal.NewFromFloat(config.exchangeTransactionCharges.percentForNSE / 100))
+	exchangeTxnPercent := config.exchangeTransactionCharges.percentForNSE
+	if config.exchange == common.ExchangeMCX {
+		exchangeTxnPercent = config.exchangeTransactionCharges.percentForMCX
+	}
+
+	exchangeTxnCharges := tradeValue.Mul(decimal.NewFromFloat(exchangeTxnPercent / 100))
 
 	// Calculate stamp charges
 	var stampCharges decimal.Decimal
@@ -193,14 +237,56 @@ func getTotalChargesForTrade(tradeValue decimal.Decimal, tradeKind types.TradeKi
 		"total_charges", totalCharges,
 	)
 
-	return totalCharges.Truncate(2)
+	breakdown := chargesBreakdown{
+		Brokerage:                 brokerageCharges,
+		STT:                       sttCharges,
+		ExchangeTransaction:       exchangeTxnCharges,
+		Stamp:                     stampCharges,
+		SEBI:                      sebiCharges,
+		DP:                        dpCharges,
+		NSEInvestorProtectionFund: nseInvestorProtectionFundCharges,
+		GST:                       gstCharges,
+	}
+
+	return totalCharges.Truncate(2), breakdown
+}
+
+// getBrokerageCharges computes the brokerage of a trade, see getTotalChargesForTrade for the parameters.
+func getBrokerageCharges(tradeValue, quantity, orderQuantity decimal.Decimal, config brokerageConfig) decimal.Decimal {
+	// Formula : tradeValue * brokeragePercent -> apply min/max
+	brokerageCharges := tradeValue.Mul(decimal.NewFromFloat(config.percent / 100))
+
+	// The flat brokerage of an order is shared by its splits.
+	if config.perOrder > 0 && orderQuantity.IsPositive() {
+		brokerageCharges = brokerageCharges.Add(decimal.NewFromFloat(config.perOrder).Mul(quantity).Div(orderQuantity))
+	}
+
+	if config.perLot > 0 {
+		lots := quantity
+		if config.lotSize > 0 {
+			lots = quantity.Div(decimal.NewFromFloat(config.lotSize))
+		}
+
+		brokerageCharges = brokerageCharges.Add(decimal.NewFromFloat(config.perLot).Mul(lots))
+	}
+
+	// Apply min/max brokerage charges
+	if !config.uncapped && brokerageCharges.GreaterThan(decimal.NewFromFloat(config.max)) {
+		brokerageCharges = decimal.NewFromFloat(config.max)
+	}
+
+	if brokerageCharges.LessThan(decimal.NewFromFloat(config.min)) {
+		brokerageCharges = decimal.NewFromFloat(config.min)
+	}
+
+	return brokerageCharges
 }
 
 type equityTradeKind = string
 
 const (
-	EquityTradeIntraday equityTradeKind = "intraday"
-	EquityTradeDelivery equityTradeKind = "delivery"
+	EquityTradeIntraday equityTradeKind = charge.EquityTradeIntraday
+	EquityTradeDelivery equityTradeKind = charge.EquityTradeDelivery
 )
 
 type equityTradeChargeSplit struct {
@@ -429,6 +515,12 @@ type brokerageConfig struct {
 	percent float64
 	max     float64
 	min     float64
+
+	// Only set from a user's BrokeragePlan.
+	uncapped bool    // Whether max doesn't apply.
+	perOrder float64 // A flat brokerage per order.
+	perLot   float64 // The brokerage per lot of lotSize quantity.
+	lotSize  float64
 }
 
 type sttConfig struct {
@@ -439,6 +531,7 @@ type sttConfig struct {
 type exchangeTransactionChargesConfig struct {
 	percentForNSE float64
 	percentForBSE float64
+	percentForMCX float64
 }
 
 type dpChargesConfig struct {
@@ -449,6 +542,8 @@ type dpChargesConfig struct {
 
 // TODO: Instead of using float64 for percentages, we should use decimal.Decimal for better precision?
 type computeChargesConfig struct {
+	// The exchange the trade is charged for. NSE, unless it is a commodity trade on MCX.
+	exchange                            common.Exchange
 	brokerage                           brokerageConfig
 	stt                                 sttConfig
 	exchangeTransactionCharges          exchangeTransactionChargesConfig
@@ -461,262 +556,93 @@ type computeChargesConfig struct {
 
 // TODO: We need to pass exchange as a parameter to this function
 // so that we can compute the charges based on the exchange.
-func getComputeTradeChargesConfig(brokerName broker.Name, instrument types.Instrument, etk *equityTradeKind) computeChargesConfig {
-	const (
-		gstPercent         = 18
-		sebiChargesPercent = 0.0001
-	)
+func getComputeTradeChargesConfig(schedule *charge.Schedule, brokerName broker.Name, plan *charge.BrokeragePlan, instrument types.Instrument, segment types.Segment, etk *equityTradeKind) computeChargesConfig {
+	var kind string
+	if etk != nil {
+		kind = *etk
+	}
 
 	config := computeChargesConfig{
-		brokerage:                           getBrokerageConfig(brokerName, instrument, etk),
-		stt:                                 getSttConfig(instrument, etk),
-		exchangeTransactionCharges:          getExchangeTransactionChargesConfig(instrument),
-		stampChargesPercent:                 getStampChargesPercent(instrument, etk),
-		sebiChargesPercent:                  sebiChargesPercent,
-		dpCharges:                           getDpChargesAmount(brokerName, etk),
-		nseInvestorProtectionFundPercentage: getNSEInvestorProtectionFundPercentage(instrument),
-		gstPercent:                          gstPercent,
+		exchange: common.ExchangeNSE,
 	}
 
-	return config
-}
-
-func getBrokerageConfig(brokerName broker.Name, instrument types.Instrument, etk *equityTradeKind) brokerageConfig {
-	config := brokerageConfig{}
-
-	switch instrument {
-	case types.InstrumentEquity:
-		switch brokerName {
-		case broker.BrokerNameAngelOne:
-			if etk != nil && *etk == EquityTradeIntraday {
-				config.percent = 0.03
-				config.max = 20
-			} else {
-				config.percent = 0.1
-				config.max = 20
-				config.min = 2
-			}
-		case broker.BrokerNameGroww:
-			config.percent = 0.1
-			config.max = 20
-			config.min = 5
-		case broker.BrokerNameINDmoney:
-			config.percent = 0.1
-			config.max = 20
-			config.min = 2
-		case broker.BrokerNameKotakSecurities:
-			if etk != nil && *etk == EquityTradeIntraday {
-				config.percent = 0.05
-				config.max = 10
-			} else {
-				config.percent = 0.2
-			}
-		case broker.BrokerNameUpstox:
-			if etk != nil && *etk == EquityTradeIntraday {
-				config.percent = 0.1
-				config.max = 20
-				config.min = 0
-			} else {
-				// Flat 20 for delivery trades.
-				config.min = 20
-			}
-		case broker.BrokerNameZerodha:
-			// No brokerage for Zerodha on delivery trades.
-			if etk != nil && *etk == EquityTradeIntraday {
-				config.percent = 0.03
-				config.max = 20
-				config.min = 0
-			}
-		default:
-			logger.Get().Errorw("getBrokerageChargesConfig: unknown broker for equity trade charges config", "broker", brokerName)
-		}
+	if segment == types.SegmentCommodity {
+		config.exchange = common.ExchangeMCX
+	}
 
-	case types.InstrumentFuture:
-		switch brokerName {
-		case broker.BrokerNameAngelOne:
-			config.min = 20
-		case broker.BrokerNameGroww:
-			config.min = 20
-		case broker.BrokerNameINDmoney:
-			config.min = 20
-		case broker.BrokerNameKotakSecurities:
-			config.min = 10
-		case broker.BrokerNameUpstox:
-			config.percent = 0.05
-			config.max = 20
-		case broker.BrokerNameZerodha:
-			config.percent = 0.03
-			config.max = 20
+	statutory, ok := schedule.StatutoryRates(instrument, segment, kind)
+	if ok {
+		config.stt = sttConfig{
+			percentOnBuy:  statutory.STTPercentOnBuy,
+			percentOnSell: statutory.STTPercentOnSell,
 		}
-
-	case types.InstrumentOption:
-		switch brokerName {
-		case broker.BrokerNameAngelOne:
-			config.min = 20
-		case broker.BrokerNameINDmoney:
-			config.min = 20
-		case broker.BrokerNameGroww:
-			config.min = 20
-		case broker.BrokerNameKotakSecurities:
-			config.min = 10
-		case broker.BrokerNameUpstox:
-			config.min = 20
-		case broker.BrokerNameZerodha:
-			config.min = 20
+		config.exchangeTransactionCharges = exchangeTransactionChargesConfig{
+			percentForNSE: statutory.ExchangeTransactionPercentForNSE,
+			percentForBSE: statutory.ExchangeTransactionPercentForBSE,
+			percentForMCX: statutory.ExchangeTransactionPercentForMCX,
 		}
+		config.stampChargesPercent = statutory.StampPercentOnBuy
+		config.sebiChargesPercent = statutory.SEBIPercent
+		config.nseInvestorProtectionFundPercentage = statutory.NSEInvestorProtectionFundPercent
+		config.gstPercent = statutory.GSTPercent
+	} else {
+		logger.Get().Errorw("getComputeTradeChargesConfig: no statutory rates for instrument", "instrument", instrument, "segment", segment, "equity_trade_kind", kind, "effective_from", schedule.EffectiveFrom)
 	}
 
-	// For other instruments, we can define different configs if needed.
-	// Right now, we are not handling other instruments.
-
-	return config
-}
-
-func getSttConfig(instrument types.Instrument, etk *equityTradeKind) sttConfig {
-	const (
-		sttPercentOnSellEquityIntraday = 0.025
-		sttPercentOnBuyEquityDelivery  = 0.1
-		sttPercentOnSellEquityDelivery = 0.1
-
-		sttPercentOnSellFuture = 0.02
-		sttPercentOnSellOption = 0.1
-	)
-
-	stt := sttConfig{}
-
-	switch instrument {
-	case types.InstrumentEquity:
-		if etk != nil {
-			switch *etk {
-			case EquityTradeIntraday:
-				stt.percentOnSell = sttPercentOnSellEquityIntraday
-			case EquityTradeDelivery:
-				stt.percentOnBuy = sttPercentOnBuyEquityDelivery
-				stt.percentOnSell = sttPercentOnSellEquityDelivery
-			}
+	var planRule *charge.BrokeragePlanRule
+	if plan != nil {
+		if r, ok := plan.Rule(instrument, segment, kind); ok {
+			planRule = &r
+			config.brokerage = getBrokerageConfigFromPlanRule(r)
 		}
-	case types.InstrumentFuture:
-		stt.percentOnSell = sttPercentOnSellFuture
-	case types.InstrumentOption:
-		stt.percentOnSell = sttPercentOnSellOption
 	}
 
-	return stt
-}
-
-func getExchangeTransactionChargesConfig(instrument types.Instrument) exchangeTransactionChargesConfig {
-	const (
-		txnEquityChargesPercentForNSE = 0.00297
-		txnEquityChargesPercentForBSE = 0.00375
-
-		txnFutureChargesPercentForNSE = 0.00173
-		txnFutureChargesPercentForBSE = 0.0
-
-		txnOptionChargesPercentForNSE = 0.03503
-		txnOptionChargesPercentForBSE = 0.0325
-	)
-
-	exchangeTransactionCharges := exchangeTransactionChargesConfig{}
-
-	switch instrument {
-	case types.InstrumentEquity:
-		exchangeTransactionCharges.percentForNSE = txnEquityChargesPercentForNSE
-		exchangeTransactionCharges.percentForBSE = txnEquityChargesPercentForBSE
-	case types.InstrumentFuture:
-		exchangeTransactionCharges.percentForNSE = txnFutureChargesPercentForNSE
-		exchangeTransactionCharges.percentForBSE = txnFutureChargesPercentForBSE
-	case types.InstrumentOption:
-		exchangeTransactionCharges.percentForNSE = txnOptionChargesPercentForNSE
-		exchangeTransactionCharges.percentForBSE = txnOptionChargesPercentForBSE
-	default:
-		logger.Get().Errorw("getExchangeTransactionChargesConfig: unknown instrument for exchange transaction charges config", "instrument", instrument)
+	// We have no rates for these brokers, the user's plan is all there is.
+	if brokersWithoutDefaultBrokerage[brokerName] {
+		return config
 	}
 
-	return exchangeTransactionCharges
-}
-
-func getStampChargesPercent(instrument types.Instrument, etk *equityTradeKind) float64 {
-	const (
-		stampEquityDeliveryChargesPercentOnBuy = 0.015
-		stampEquityIntradayChargesPercentOnBuy = 0.003
-
-		stampFutureChargesPercentOnBuy = 0.002
-		stampOptionChargesPercentOnBuy = 0.003
-	)
-
-	var stampChargesPercent float64
+	brokerage, ok := schedule.BrokerageRates(brokerName, instrument, segment, kind)
+	if ok {
+		if planRule == nil {
+			config.brokerage = brokerageConfig{
+				percent: brokerage.Brokerage.Percent,
+				min:     brokerage.Brokerage.Min,
+				max:     brokerage.Brokerage.Max,
+			}
+		}
 
-	switch instrument {
-	case types.InstrumentEquity:
-		if etk != nil {
-			switch *etk {
-			case EquityTradeIntraday:
-				stampChargesPercent = stampEquityIntradayChargesPercentOnBuy
-			case EquityTradeDelivery:
-				stampChargesPercent = stampEquityDeliveryChargesPercentOnBuy
+		// DP charges are applicable only for equity delivery trades.
+		if kind == EquityTradeDelivery {
+			config.dpCharges = dpChargesConfig{
+				percent: brokerage.DPCharges.Percent,
+				min:     brokerage.DPCharges.Min,
+				max:     brokerage.DPCharges.Max,
 			}
 		}
-	case types.InstrumentFuture:
-		stampChargesPercent = stampFutureChargesPercentOnBuy
-	case types.InstrumentOption:
-		stampChargesPercent = stampOptionChargesPercentOnBuy
-	default:
-		logger.Get().Errorw("getStampChargesPercent: unknown instrument for stamp charges", "instrument", instrument)
+	} else {
+		logger.Get().Errorw("getComputeTradeChargesConfig: no brokerage rates for broker", "broker", brokerName, "instrument", instrument, "segment", segment, "equity_trade_kind", kind, "effective_from", schedule.EffectiveFrom)
 	}
 
-	return stampChargesPercent
+	return config
 }
 
-func getDpChargesAmount(b broker.Name, etk *equityTradeKind) dpChargesConfig {
-	config := dpChargesConfig{}
-
-	// DP charges are applicable only for Equity trades.
-	if etk == nil {
-		return config
-	}
-
-	// DP charges are not applicable for intraday trades.
-	if *etk == EquityTradeIntraday {
-		return config
+func getBrokerageConfigFromPlanRule(rule charge.BrokeragePlanRule) brokerageConfig {
+	config := brokerageConfig{
+		min:      rule.Min,
+		max:      rule.Max,
+		uncapped: rule.Max == 0,
 	}
 
-	switch b {
-	case broker.BrokerNameAngelOne:
-		config.min = 20.0
-	case broker.BrokerNameGroww:
-		config.min = 16.5
-	case broker.BrokerNameINDmoney:
-		config.min = 18.5
-	case broker.BrokerNameKotakSecurities:
-		config.percent = 0.04
-		config.min = 20
-	case broker.BrokerNameZerodha:
-		config.min = 15.34
-	case broker.BrokerNameUpstox:
-		config.min = 20.0
-	default:
-		logger.Get().Errorw("getDpChargesAmount: unknown broker for dp charges", "broker", b)
+	switch rule.Kind {
+	case charge.BrokeragePlanRuleKindPerOrder:
+		config.perOrder = rule.Amount
+	case charge.BrokeragePlanRuleKindPercent:
+		config.percent = rule.Percent
+	case charge.BrokeragePlanRuleKindPerLot:
+		config.perLot = rule.Amount
+		config.lotSize = rule.LotSize
 	}
 
 	return config
 }
-
-func getNSEInvestorProtectionFundPercentage(instrument types.Instrument) float64 {
-	var (
-		equityNSEInvestorProtectionFundPercentage = 0.0001
-		futureNSEInvestorProtectionFundPercentage = 0.0001
-		optionNSEInvestorProtectionFundPercentage = 0.0005
-	)
-
-	switch instrument {
-	case types.InstrumentEquity:
-		return equityNSEInvestorProtectionFundPercentage
-	case types.InstrumentFuture:
-		return futureNSEInvestorProtectionFundPercentage
-	case types.InstrumentOption:
-		return optionNSEInvestorProtectionFundPercentage
-	default:
-		logger.Get().Errorw("getNSEInvestorProtectionFundPercentage: unknown instrument for NSE Investor Protection Fund percentage", "instrument", instrument)
-		return 0
-	}
-}
@@ -0,0 +1,246 @@
+package position
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/dbx"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/chargereconciliation"
+	"arthveda/internal/feature/trade"
+	"arthveda/internal/logger"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReconcileChargesPayload struct {
+	UserBrokerAccountID uuid.UUID
+
+	// The date of the contract note.
+	Date time.Time
+
+	// The rows of the charges summary of the contract note, see chargereconciliation.ParseChargesSummary.
+	Rows [][]string
+
+	// Whether to replace the estimated charges of the day's trades with the actual ones.
+	Apply bool
+}
+
+var errContractNoteDateRequired = errors.New("Contract note date is required")
+
+// ReconcileCharges compares the actual charges of each segment in a contract note with the charges of the
+// trades of the broker account on that day, and optionally replaces the estimates with the actuals.
+// Uploading the contract note of a day again replaces its Reconciliations.
+func (s *Service) ReconcileCharges(ctx context.Context, userID uuid.UUID, payload ReconcileChargesPayload) ([]*chargereconciliation.Reconciliation, service.Error, error) {
+	if payload.Date.IsZero() {
+		return nil, service.ErrBadRequest, errContractNoteDateRequired
+	}
+
+	account, err := s.userBrokerAccountRepository.GetByID(ctx, payload.UserBrokerAccountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Broker account not found with ID: %s", payload.UserBrokerAccountID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get broker account by ID: %w", err)
+	}
+
+	if account.UserID != userID {
+		return nil, service.ErrNotFound, fmt.Errorf("Broker account not found with ID: %s", payload.UserBrokerAccountID)
+	}
+
+	segmentCharges, err := chargereconciliation.ParseChargesSummary(payload.Rows)
+	if err != nil {
+		return nil, service.ErrBadRequest, err
+	}
+
+	positions, tradesBySegment, err := s.getTradesOfDay(ctx, userID, account.ID, payload.Date)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	reconciliations := make([]*chargereconciliation.Reconciliation, 0, len(segmentCharges))
+
+	// The charges of the trades, the Positions and the Reconciliations of all the segments are saved together.
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		for _, charges := range segmentCharges {
+			trades := tradesBySegment[charges.Segment]
+
+			reconciliation, err := chargereconciliation.New(userID, account.ID, payload.Date, charges, trades)
+			if err != nil {
+				return fmt.Errorf("new charge reconciliation: %w", err)
+			}
+
+			if payload.Apply {
+				if err := s.applyChargeReconciliation(ctx, reconciliation, positions, trades); err != nil {
+					return err
+				}
+			}
+
+			reconciliation, err = s.chargeReconciliationRepository.Upsert(ctx, reconciliation)
+			if err != nil {
+				return fmt.Errorf("charge reconciliation repository upsert: %w", err)
+			}
+
+			reconciliations = append(reconciliations, reconciliation)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	return reconciliations, service.ErrNone, nil
+}
+
+// ApplyChargeReconciliation replaces the estimated charges of the trades of a Reconciliation with the actual ones.
+func (s *Service) ApplyChargeReconciliation(ctx context.Context, userID, reconciliationID uuid.UUID) (*chargereconciliation.Reconciliation, service.Error, error) {
+	reconciliation, err := s.chargeReconciliationRepository.GetByID(ctx, userID, reconciliationID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Charge reconciliation not found with ID: %s", reconciliationID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get charge reconciliation by ID: %w", err)
+	}
+
+	if reconciliation.AppliedAt != nil {
+		return nil, service.ErrBadRequest, fmt.Errorf("The actual charges have already been applied")
+	}
+
+	positions, tradesBySegment, err := s.getTradesOfDay(ctx, userID, reconciliation.UserBrokerAccountID, reconciliation.Date)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	trades := tradesBySegment[reconciliation.Segment]
+
+	// The trades of the day may have changed since the contract note was uploaded.
+	reconciliation.Reconcile(trades)
+
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		if err := s.applyChargeReconciliation(ctx, reconciliation, positions, trades); err != nil {
+			return err
+		}
+
+		reconciliation, err = s.chargeReconciliationRepository.Upsert(ctx, reconciliation)
+		if err != nil {
+			return fmt.Errorf("charge reconciliation repository upsert: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	return reconciliation, service.ErrNone, nil
+}
+
+// SearchChargeReconciliations returns the user's Reconciliations, e.g. the discrepancies of a broker account.
+func (s *Service) SearchChargeReconciliations(ctx context.Context, userID uuid.UUID, filter chargereconciliation.SearchFilter) ([]*chargereconciliation.Reconciliation, service.Error, error) {
+	reconciliations, err := s.chargeReconciliationRepository.Search(ctx, userID, filter)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("charge reconciliation repository search: %w", err)
+	}
+
+	return reconciliations, service.ErrNone, nil
+}
+
+// getTradesOfDay returns the Positions of the broker account with trades on the date, in IST, and those trades by segment.
+// The trades are the Positions' own, so changing them changes the Positions.
+func (s *Service) getTradesOfDay(ctx context.Context, userID, userBrokerAccountID uuid.UUID, date time.Time) ([]*Position, map[types.Segment][]*trade.Trade, error) {
+	tz, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
+	loc, _ := time.LoadLocation(string(tz))
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+
+	positions, _, err := s.positionRepository.Search(ctx, SearchPayload{
+		Filters: SearchFilter{
+			CreatedBy:           &userID,
+			UserBrokerAccountID: &userBrokerAccountID,
+			TradeTime:           &common.DateRangeFilter{From: &start, To: &end},
+		},
+	}, true, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("position repository search: %w", err)
+	}
+
+	tradesBySegment := map[types.Segment][]*trade.Trade{}
+	for _, pos := range positions {
+		for _, t := range pos.Trades {
+			if !t.Time.Before(start) && t.Time.Before(end) {
+				tradesBySegment[pos.Segment.OrDefault()] = append(tradesBySegment[pos.Segment.OrDefault()], t)
+			}
+		}
+	}
+
+	return positions, tradesBySegment, nil
+}
+
+// applyChargeReconciliation replaces the charges of the trades with the actual charges
+// and recomputes the Positions of the trades. It must be called in a transaction, so that
+// a Position that fails to recompute doesn't leave its trades with the new charges.
+func (s *Service) applyChargeReconciliation(ctx context.Context, reconciliation *chargereconciliation.Reconciliation, positions []*Position, trades []*trade.Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	reconciliation.Apply(trades)
+
+	now := time.Now().UTC()
+	isTradeApplied := map[uuid.UUID]bool{}
+	for _, t := range trades {
+		t.UpdatedAt = &now
+		isTradeApplied[t.ID] = true
+	}
+
+	if err := s.tradeRepository.UpdateCharges(ctx, trades); err != nil {
+		return fmt.Errorf("trade repository update charges: %w", err)
+	}
+
+	for _, pos := range positions {
+		for _, t := range pos.Trades {
+			if isTradeApplied[t.ID] {
+				if err := s.recomputeCharges(ctx, pos); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// recomputeCharges recomputes and saves the Position and the realised stats of its trades after their charges have changed.
+func (s *Service) recomputeCharges(ctx context.Context, position *Position) error {
+	if err := s.splitReversedPosition(ctx, position); err != nil {
+		return err
+	}
+
+	computeResult, err := Compute(position.ComputePayload())
+	if err != nil {
+		logger.FromCtx(ctx).Errorw("failed to recompute position with its new charges", "error", err, "position_id", position.ID)
+		return fmt.Errorf("compute position: %w", err)
+	}
+
+	now := time.Now().UTC()
+	position.UpdatedAt = &now
+
+	ApplyComputeResultToPosition(position, computeResult)
+
+	if err := s.positionRepository.Update(ctx, position); err != nil {
+		return fmt.Errorf("position repository update: %w", err)
+	}
+
+	if err := s.tradeRepository.UpdateRealisedStats(ctx, position.Trades); err != nil {
+		return fmt.Errorf("trade repository update realised stats: %w", err)
+	}
+
+	return nil
+}
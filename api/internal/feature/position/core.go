@@ -5,6 +5,8 @@ import (
 	"arthveda/internal/domain/subscription"
 	"arthveda/internal/domain/symbol"
 	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/corporateaction"
 	"arthveda/internal/feature/currency"
 
 	"arthveda/internal/feature/tag"
@@ -14,6 +16,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,11 +38,20 @@ type Position struct {
 
 	Symbol             string                `json:"symbol" db:"symbol"`
 	Instrument         types.Instrument      `json:"instrument" db:"instrument"`
+	Segment            types.Segment         `json:"segment" db:"segment"`
 	RiskAmount         decimal.Decimal       `json:"risk_amount" db:"risk_amount"`
 	TotalChargesAmount decimal.Decimal       `json:"total_charges_amount" db:"total_charges_amount"`
 	CurrencyCode       currency.CurrencyCode `json:"currency_code" db:"currency_code"`
 	EnableAutoCharges  bool                  `json:"enable_auto_charges" db:"enable_auto_charges"`
 
+	// The lot-matching method chosen for this Position only.
+	// If `nil`, the user's default lot-matching method is used.
+	LotMatchingMethod *LotMatchingMethod `json:"lot_matching_method" db:"lot_matching_method"`
+
+	// The planned entry, stop-loss and target prices of this Position, if any.
+	// If the planned entry and stop-loss prices are set, the RiskAmount is derived from them when it isn't given.
+	Plan
+
 	//
 	// Data computed by Arthveda based on data provided by user mentioned above & related trade(s).
 	// So if the data provideed by the user changes, the data below must be recomputed and saved.
@@ -51,6 +63,7 @@ type Position struct {
 	ClosedAt                    *time.Time      `json:"closed_at" db:"closed_at"`
 	GrossPnLAmount              decimal.Decimal `json:"gross_pnl_amount" db:"gross_pnl_amount"`
 	NetPnLAmount                decimal.Decimal `json:"net_pnl_amount" db:"net_pnl_amount"`
+	IncomeAmount                decimal.Decimal `json:"income_amount" db:"income_amount"` // The total of the CashFlows, apart from the NetPnLAmount of the trades.
 	RFactor                     decimal.Decimal `json:"r_factor" db:"r_factor"`
 	GrossRFactor                decimal.Decimal `json:"gross_r_factor" db:"gross_r_factor"`
 	NetReturnPercentage         decimal.Decimal `json:"net_return_percentage" db:"net_return_percentage"`
@@ -58,12 +71,42 @@ type Position struct {
 	OpenQuantity                decimal.Decimal `json:"open_quantity" db:"open_quantity"`
 	OpenAveragePriceAmount      decimal.Decimal `json:"open_average_price_amount" db:"open_average_price_amount"`
 
+	// The future or option contract of this Position, decomposed from its symbol. See symbol.ParseContract.
+	// They are `nil` for equities and for symbols that we don't recognise. Strike and OptionRight are `nil` for futures.
+	Underlying  *string             `json:"underlying" db:"underlying"`
+	Expiry      *time.Time          `json:"expiry" db:"expiry"`
+	Strike      *decimal.Decimal    `json:"strike" db:"strike"`
+	OptionRight *symbol.OptionRight `json:"option_right" db:"option_right"`
+
+	// The planned reward to risk of this Position. `nil` if the Position has no planned entry, stop-loss and target prices.
+	PlannedRFactor *decimal.Decimal `json:"planned_r_factor" db:"planned_r_factor"`
+	// How far the average exit price landed from the target price, in R. It is positive if the exit was beyond
+	// the target and negative if it fell short. `nil` if the Position has no target price or no exit yet.
+	ExitVsPlanRFactor *decimal.Decimal `json:"exit_vs_plan_r_factor" db:"exit_vs_plan_r_factor"`
+
+	// Whether the Position was intraday, BTST, swing or delivery, by how long it was held.
+	ProductType ProductType `json:"product_type" db:"product_type"`
+
+	// The maximum adverse and favourable excursion of this Position, computed from the candles of its symbol.
+	// They are `nil` until computed and are reset when the trades change. See Service.ComputeExcursion.
+	MAEAmount *decimal.Decimal `json:"mae_amount" db:"mae_amount"`
+	MFEAmount *decimal.Decimal `json:"mfe_amount" db:"mfe_amount"`
+	// How much of the MFE was realised by the exit. `nil` if the Position is open or never went in favour.
+	ExitEfficiencyPercentage *decimal.Decimal `json:"exit_efficiency_percentage" db:"exit_efficiency_percentage"`
+
 	// If this Position is imported, then the following fields are used to track the source of the import.
 	// Deprecated: Use UserBrokerAccountID instead.
 	BrokerID *uuid.UUID `json:"broker_id" db:"broker_id"` // The ID of the Broker from which this Position is imported.
 
 	UserBrokerAccountID *uuid.UUID `json:"user_broker_account_id" db:"user_broker_account_id"` // The ID of the UserBrokerAccount to which this Position belongs.
 
+	// The ID of the ImportBatch that created this Position, or last replaced it, if any.
+	ImportBatchID *uuid.UUID `json:"import_batch_id" db:"import_batch_id"`
+
+	// The ID of the Strategy of which this Position is a leg, if any.
+	// It is only set by the strategy package, so it isn't written when a Position is created or updated.
+	StrategyID *uuid.UUID `json:"strategy_id" db:"strategy_id"`
+
 	FxRate                 decimal.Decimal  `json:"fx_rate" db:"fx_rate"`
 	FxSource               fxSource         `json:"fx_source" db:"fx_source"`
 	GrossPnLAmountAway     *decimal.Decimal `json:"gross_pnl_amount_away" db:"gross_pnl_amount_away"`
@@ -87,9 +130,26 @@ type Position struct {
 	// All the trade(s) that are RELATED to this Position.
 	Trades []*trade.Trade `json:"trades"`
 
+	// The dividends and other cash credited or debited against this Position, attached with the trades.
+	CashFlows []*cashflow.CashFlow `json:"cash_flows"`
+
 	// Whether this Position is a duplicate of another Position or not.
 	// This flag is used when we are importing positions from Brokers.
 	IsDuplicate bool `json:"is_duplicate"`
+
+	// The lot-matching method that was used to compute this Position.
+	// It is the Position's LotMatchingMethod if set, otherwise the user's default.
+	EffectiveLotMatchingMethod LotMatchingMethod `json:"effective_lot_matching_method"`
+
+	// The mark-to-market of the open quantity of this Position. The last price is in the currency of the symbol,
+	// like OpenAveragePriceAmount, while the unrealised PnL is in the home currency.
+	// They are `nil` if the Position is closed or the price feed has no quote for its symbol. See MarkToMarket.
+	LastPriceAmount     *decimal.Decimal `json:"last_price_amount"`
+	UnrealisedPnLAmount *decimal.Decimal `json:"unrealised_pnl_amount"`
+
+	// The splits and bonus issues of the symbol of an equity Position, attached with the trades.
+	// They are applied to the trades when the Position is computed. See ComputePayload.CorporateActions.
+	CorporateActions []*corporateaction.CorporateAction `json:"-"`
 }
 
 // UserBrokerAccountSearchValue contains only the essential fields needed for Position display
@@ -116,6 +176,78 @@ const (
 	StatusOpen      Status = "open"
 )
 
+// LotMatchingMethod decides which open lot(s) a scale-out trade is matched against
+// to compute the realised PnL of that trade.
+type LotMatchingMethod string
+
+const (
+	LotMatchingMethodFIFO            LotMatchingMethod = "fifo"             // First in, first out.
+	LotMatchingMethodLIFO            LotMatchingMethod = "lifo"             // Last in, first out.
+	LotMatchingMethodWeightedAverage LotMatchingMethod = "weighted_average" // All open lots are pooled at their weighted average price.
+)
+
+func (m LotMatchingMethod) IsValid() bool {
+	switch m {
+	case LotMatchingMethodFIFO, LotMatchingMethodLIFO, LotMatchingMethodWeightedAverage:
+		return true
+	}
+
+	return false
+}
+
+// OrDefault returns FIFO if the method is empty or unknown.
+func (m LotMatchingMethod) OrDefault() LotMatchingMethod {
+	if !m.IsValid() {
+		return LotMatchingMethodFIFO
+	}
+
+	return m
+}
+
+// Plan is the planned entry, stop-loss and target prices of a Position.
+type Plan struct {
+	PlannedEntryPrice *decimal.Decimal `json:"planned_entry_price" db:"planned_entry_price"`
+	StopLossPrice     *decimal.Decimal `json:"stop_loss_price" db:"stop_loss_price"`
+	TargetPrice       *decimal.Decimal `json:"target_price" db:"target_price"`
+}
+
+// riskPerUnit returns the planned risk of a single unit, or false if the
+// planned entry or stop-loss price is missing.
+func (p Plan) riskPerUnit() (decimal.Decimal, bool) {
+	if p.PlannedEntryPrice == nil || p.StopLossPrice == nil {
+		return decimal.Zero, false
+	}
+
+	return p.PlannedEntryPrice.Sub(*p.StopLossPrice).Abs(), true
+}
+
+// validate makes sure that the stop-loss and target prices are on the correct side
+// of the planned entry price for the direction of the Position.
+func (p Plan) validate(direction Direction) error {
+	if p.PlannedEntryPrice == nil {
+		if p.StopLossPrice != nil || p.TargetPrice != nil {
+			return ErrPlannedEntryPriceRequired
+		}
+		return nil
+	}
+
+	if !p.PlannedEntryPrice.IsPositive() {
+		return ErrInvalidPlannedEntryPrice
+	}
+
+	sign := directionSignDecimal(direction)
+
+	if p.StopLossPrice != nil && !p.PlannedEntryPrice.Sub(*p.StopLossPrice).Mul(sign).IsPositive() {
+		return ErrInvalidStopLossPrice
+	}
+
+	if p.TargetPrice != nil && !p.TargetPrice.Sub(*p.PlannedEntryPrice).Mul(sign).IsPositive() {
+		return ErrInvalidTargetPrice
+	}
+
+	return nil
+}
+
 type fxSource string
 
 const (
@@ -131,6 +263,11 @@ func new(userID uuid.UUID, payload CreatePayload) (position *Position, userErr b
 		return nil, false, err
 	}
 
+	// The Position's own lot-matching method takes precedence over the user's default.
+	if payload.LotMatchingMethod != nil {
+		payload.ComputePayload.LotMatchingMethod = *payload.LotMatchingMethod
+	}
+
 	computeResult, err := Compute(payload.ComputePayload)
 	if err != nil {
 		return nil, true, err
@@ -147,11 +284,15 @@ func new(userID uuid.UUID, payload CreatePayload) (position *Position, userErr b
 		CreatedAt:           now,
 		Symbol:              symbol.Sanitize(payload.Symbol, payload.Instrument),
 		Instrument:          payload.Instrument,
+		Segment:             payload.Segment.OrDefault(),
 		CurrencyCode:        payload.CurrencyCode,
 		EnableAutoCharges:   payload.EnableAutoCharges,
 		RiskAmount:          payload.RiskAmount,
+		LotMatchingMethod:   payload.LotMatchingMethod,
+		Plan:                payload.Plan,
 		UserBrokerAccountID: payload.UserBrokerAccountID,
 		Trades:              trades,
+		CorporateActions:    payload.CorporateActions,
 	}
 
 	ApplyComputeResultToPosition(position, computeResult)
@@ -196,6 +337,14 @@ func (originalPosition *Position) update(payload UpdatePayload) (position Positi
 		UpdatedAt: &now,
 	}
 
+	// The Position's own lot-matching method takes precedence over the user's default.
+	if payload.LotMatchingMethod != nil {
+		payload.ComputePayload.LotMatchingMethod = *payload.LotMatchingMethod
+	}
+
+	// The cash flows are managed separately, so they stay as they are.
+	payload.ComputePayload.CashFlows = originalPosition.CashFlows
+
 	computeResult, err := Compute(payload.ComputePayload)
 	if err != nil {
 		return updatedPosition, true, err
@@ -217,11 +366,16 @@ func (originalPosition *Position) update(payload UpdatePayload) (position Positi
 
 	updatedPosition.Symbol = payload.Symbol
 	updatedPosition.Instrument = payload.Instrument
+	updatedPosition.Segment = payload.Segment.OrDefault()
 	updatedPosition.CurrencyCode = payload.CurrencyCode
 	updatedPosition.EnableAutoCharges = payload.EnableAutoCharges
-	updatedPosition.RiskAmount = payload.RiskAmount
+	updatedPosition.RiskAmount = computeResult.RiskAmount
+	updatedPosition.Plan = payload.Plan
+	updatedPosition.LotMatchingMethod = payload.LotMatchingMethod
 	updatedPosition.BrokerID = payload.BrokerID
 	updatedPosition.UserBrokerAccountID = payload.UserBrokerAccountID
+	updatedPosition.CorporateActions = payload.CorporateActions
+	updatedPosition.CashFlows = originalPosition.CashFlows
 
 	// TODO: Use ApplyComputeResultToPosition here.
 	updatedPosition.TotalChargesAmount = computeResult.TotalChargesAmount
@@ -231,6 +385,7 @@ func (originalPosition *Position) update(payload UpdatePayload) (position Positi
 	updatedPosition.ClosedAt = computeResult.ClosedAt
 	updatedPosition.GrossPnLAmount = computeResult.GrossPnLAmount
 	updatedPosition.NetPnLAmount = computeResult.NetPnLAmount
+	updatedPosition.IncomeAmount = computeResult.IncomeAmount
 	updatedPosition.RFactor = computeResult.RFactor
 	updatedPosition.GrossRFactor = computeResult.GrossRFactor
 	updatedPosition.NetReturnPercentage = computeResult.NetReturnPercentage
@@ -240,7 +395,17 @@ func (originalPosition *Position) update(payload UpdatePayload) (position Positi
 	updatedPosition.GrossPnLAmountAway = &computeResult.GrossPnLAmountAway
 	updatedPosition.TotalChargesAmountAway = &computeResult.TotalChargesAmountAway
 	updatedPosition.NetPnLAmountAway = &computeResult.NetPnLAmountAway
+	updatedPosition.EffectiveLotMatchingMethod = computeResult.LotMatchingMethod
+	updatedPosition.PlannedRFactor = computeResult.PlannedRFactor
+	updatedPosition.ExitVsPlanRFactor = computeResult.ExitVsPlanRFactor
+	updatedPosition.ProductType = computeResult.ProductType
+
+	ApplyContractToPosition(&updatedPosition)
+
+	// The trades may have changed, so the excursion must be computed again.
+	resetExcursion(&updatedPosition)
 
+	applyRealisedStatsToTrades(trades, computeResult.trades)
 	updatedPosition.Trades = trades
 
 	if payload.FxRate != nil {
@@ -251,34 +416,103 @@ func (originalPosition *Position) update(payload UpdatePayload) (position Positi
 	return updatedPosition, false, nil
 }
 
+// ApplyContractToPosition decomposes the symbol of a future or option Position into its contract fields.
+// The Position must be computed first because its opening time resolves the expiry of some symbols.
+func ApplyContractToPosition(position *Position) {
+	position.Underlying = nil
+	position.Expiry = nil
+	position.Strike = nil
+	position.OptionRight = nil
+
+	if position.Instrument != types.InstrumentFuture && position.Instrument != types.InstrumentOption {
+		return
+	}
+
+	contract, ok := symbol.ParseContract(position.Symbol, position.OpenedAt)
+	if !ok {
+		return
+	}
+
+	position.Underlying = &contract.Underlying
+	position.Expiry = contract.Expiry
+	position.Strike = contract.Strike
+	position.OptionRight = contract.Right
+}
+
+// Contract returns the contract of this Position, or false if it isn't a future or option that we recognise.
+// The symbol is parsed if the contract isn't stored on the Position yet.
+func (p *Position) Contract() (symbol.Contract, bool) {
+	if p.Underlying == nil {
+		if p.Instrument != types.InstrumentFuture && p.Instrument != types.InstrumentOption {
+			return symbol.Contract{}, false
+		}
+		return symbol.ParseContract(p.Symbol, p.OpenedAt)
+	}
+
+	return symbol.Contract{
+		Underlying: *p.Underlying,
+		Expiry:     p.Expiry,
+		Strike:     p.Strike,
+		Right:      p.OptionRight,
+	}, true
+}
+
 type computeResult struct {
-	Direction                   Direction       `json:"direction"`
-	Status                      Status          `json:"status"`
-	OpenedAt                    time.Time       `json:"opened_at"`
-	ClosedAt                    *time.Time      `json:"closed_at"` // `nil` if the Status is StatusOpen meaning the position is open.
-	GrossPnLAmount              decimal.Decimal `json:"gross_pnl_amount"`
-	NetPnLAmount                decimal.Decimal `json:"net_pnl_amount"`
-	TotalChargesAmount          decimal.Decimal `json:"total_charges_amount"`
-	RFactor                     decimal.Decimal `json:"r_factor"`
-	GrossRFactor                decimal.Decimal `json:"gross_r_factor"`
-	NetReturnPercentage         decimal.Decimal `json:"net_return_percentage"`
-	ChargesAsPercentageOfNetPnL decimal.Decimal `json:"charges_as_percentage_of_net_pnl"`
-	OpenQuantity                decimal.Decimal `json:"open_quantity"`
-	OpenAveragePriceAmount      decimal.Decimal `json:"open_average_price_amount"`
-	GrossPnLAmountAway          decimal.Decimal `json:"gross_pnl_amount_away"`
-	NetPnLAmountAway            decimal.Decimal `json:"net_pnl_amount_away"`
-	TotalChargesAmountAway      decimal.Decimal `json:"total_charges_amount_away"`
+	Direction                   Direction         `json:"direction"`
+	Status                      Status            `json:"status"`
+	OpenedAt                    time.Time         `json:"opened_at"`
+	ClosedAt                    *time.Time        `json:"closed_at"` // `nil` if the Status is StatusOpen meaning the position is open.
+	GrossPnLAmount              decimal.Decimal   `json:"gross_pnl_amount"`
+	NetPnLAmount                decimal.Decimal   `json:"net_pnl_amount"`
+	IncomeAmount                decimal.Decimal   `json:"income_amount"`
+	TotalChargesAmount          decimal.Decimal   `json:"total_charges_amount"`
+	RFactor                     decimal.Decimal   `json:"r_factor"`
+	GrossRFactor                decimal.Decimal   `json:"gross_r_factor"`
+	NetReturnPercentage         decimal.Decimal   `json:"net_return_percentage"`
+	ChargesAsPercentageOfNetPnL decimal.Decimal   `json:"charges_as_percentage_of_net_pnl"`
+	OpenQuantity                decimal.Decimal   `json:"open_quantity"`
+	OpenAveragePriceAmount      decimal.Decimal   `json:"open_average_price_amount"`
+	GrossPnLAmountAway          decimal.Decimal   `json:"gross_pnl_amount_away"`
+	NetPnLAmountAway            decimal.Decimal   `json:"net_pnl_amount_away"`
+	TotalChargesAmountAway      decimal.Decimal   `json:"total_charges_amount_away"`
+	LotMatchingMethod           LotMatchingMethod `json:"lot_matching_method"`
+	RiskAmount                  decimal.Decimal   `json:"risk_amount"`
+	PlannedRFactor              *decimal.Decimal  `json:"planned_r_factor"`
+	ExitVsPlanRFactor           *decimal.Decimal  `json:"exit_vs_plan_r_factor"`
+	ProductType                 ProductType       `json:"product_type"`
+
+	// trades are the trades of the Position with their realised stats computed.
+	trades []*trade.Trade
 }
 
 var ErrInvalidTradeData = errors.New("Invalid trade data provided")
 
+var ErrInvalidSegment = errors.New("Segment must be one of equity, commodity or currency")
+
+var ErrTradeReversesPosition = errors.New("A trade takes the position through zero. Close this position with that trade and add the remaining quantity as a new position")
+
+var (
+	ErrPlannedEntryPriceRequired = errors.New("Planned entry price is required to set a stop-loss or target price")
+	ErrInvalidPlannedEntryPrice  = errors.New("Planned entry price must be greater than zero")
+	ErrInvalidStopLossPrice      = errors.New("Stop-loss price must be below the planned entry price for a long position and above it for a short position")
+	ErrInvalidTargetPrice        = errors.New("Target price must be above the planned entry price for a long position and below it for a short position")
+
+	ErrRiskAmountConflictsWithPlan = errors.New("Risk amount doesn't match the planned entry and stop-loss prices. Clear it to derive it from them")
+)
+
 func Compute(payload ComputePayload) (computeResult, error) {
 	l := logger.Get()
 
 	result := computeResult{
-		OpenedAt:  time.Now().UTC(),
-		Status:    StatusOpen,
-		Direction: DirectionLong,
+		OpenedAt:          time.Now().UTC(),
+		Status:            StatusOpen,
+		Direction:         DirectionLong,
+		LotMatchingMethod: payload.LotMatchingMethod.OrDefault(),
+		RiskAmount:        payload.RiskAmount,
+	}
+
+	if !payload.Segment.OrDefault().IsValid() {
+		return result, ErrInvalidSegment
 	}
 
 	if len(payload.Trades) == 0 {
@@ -287,10 +521,6 @@ func Compute(payload ComputePayload) (computeResult, error) {
 
 	// If there is only one trade, we know the trade is the opening trade.
 
-	// TODO: Calculate and store the realised PnL on every trade in the position.
-	// This will make the analytics easier and faster and we can show the realised
-	// PnL on every trade in the position when viewing the position.
-
 	// FIXME: Change thix functions API to accept a "position.Position"?
 	var trades []*trade.Trade
 
@@ -304,18 +534,50 @@ func Compute(payload ComputePayload) (computeResult, error) {
 		trades = append(trades, newTrade)
 	}
 
+	// Trades before a split or bonus are restated in the shares after it, so that they can be matched
+	// with the trades after it. This only changes the trades that are computed, not the ones stored.
+	applyCorporateActions(trades, payload.CorporateActions)
+
 	direction, err := computeDirection(trades)
 	if err != nil {
 		l.Errorw("computeDirection", "error", err, "trades", trades)
 		return result, ErrInvalidTradeData
 	}
 
-	computeTradesResult, err := ComputeSmartTrades(trades, direction, payload.RiskAmount)
+	// A single Position can only be in one direction. So a trade that reverses the Position
+	// must be split into two Positions by the caller. See SplitTradeOnReversal.
+	if idx := findReversalTradeIdx(trades, direction); idx != -1 {
+		l.Debugw("findReversalTradeIdx", "trade_idx", idx, "trades", trades)
+		return result, ErrTradeReversesPosition
+	}
+
+	if err := payload.Plan.validate(direction); err != nil {
+		return result, err
+	}
+
+	// With a planned entry and stop-loss price, the risk is what the opening trade would lose at the stop-loss.
+	// The quantity is the one traded, as the plan's prices are, not the one restated after a split or bonus.
+	riskAmount := payload.RiskAmount
+	if riskPerUnit, ok := payload.Plan.riskPerUnit(); ok {
+		plannedRiskAmount := riskPerUnit.Mul(payload.Trades[0].Quantity)
+
+		if riskAmount.IsZero() {
+			riskAmount = plannedRiskAmount
+		} else if !riskAmount.Round(2).Equal(plannedRiskAmount.Round(2)) {
+			return result, ErrRiskAmountConflictsWithPlan
+		}
+	}
+
+	result.RiskAmount = riskAmount
+
+	computeTradesResult, err := ComputeSmartTrades(trades, direction, riskAmount, payload.LotMatchingMethod)
 	if err != nil {
 		l.Debugw("ComputeSmartTrades", "error", err, "trades", trades)
 		return result, ErrInvalidTradeData
 	}
 
+	result.trades = trades
+
 	var closedAt *time.Time = nil
 	grossPnL := decimal.Zero
 	netOpenQty := decimal.Zero
@@ -344,6 +606,10 @@ func Compute(payload ComputePayload) (computeResult, error) {
 	var netPnL, rFactor, grossRFactor, chargesAsPercentageOfNetPnL decimal.Decimal
 
 	totalCharges := calculateTotalChargesAmountFromTrades(trades)
+
+	// Dividends and other cash flows are reported apart from the PnL of the trades, which alone decides
+	// whether the Position was a win or a loss.
+	income := cashflow.Sum(payload.CashFlows, time.Time{})
 	netPnL = grossPnL.Sub(totalCharges)
 
 	result.GrossPnLAmount = grossPnL
@@ -356,9 +622,9 @@ func Compute(payload ComputePayload) (computeResult, error) {
 
 	result.NetReturnPercentage = netReturnPercentage
 
-	if !grossPnL.IsZero() && payload.RiskAmount.IsPositive() {
-		rFactor = netPnL.Div(payload.RiskAmount)
-		grossRFactor = grossPnL.Div(payload.RiskAmount)
+	if !grossPnL.IsZero() && riskAmount.IsPositive() {
+		rFactor = netPnL.Div(riskAmount)
+		grossRFactor = grossPnL.Div(riskAmount)
 	}
 
 	var status Status
@@ -387,6 +653,7 @@ func Compute(payload ComputePayload) (computeResult, error) {
 		result.GrossPnLAmount = grossPnL.Mul(*payload.FxRate)
 		result.TotalChargesAmount = totalCharges.Mul(*payload.FxRate)
 		result.NetPnLAmount = netPnL.Mul(*payload.FxRate)
+		result.IncomeAmount = income.Mul(*payload.FxRate)
 
 		result.GrossPnLAmountAway = grossPnL
 		result.TotalChargesAmountAway = totalCharges
@@ -395,6 +662,7 @@ func Compute(payload ComputePayload) (computeResult, error) {
 		result.GrossPnLAmount = grossPnL
 		result.NetPnLAmount = netPnL
 		result.TotalChargesAmount = totalCharges
+		result.IncomeAmount = income
 	}
 
 	result.Direction = direction
@@ -405,6 +673,8 @@ func Compute(payload ComputePayload) (computeResult, error) {
 	result.GrossRFactor = grossRFactor
 	result.NetReturnPercentage = netReturnPercentage
 	result.ChargesAsPercentageOfNetPnL = chargesAsPercentageOfNetPnL
+	result.PlannedRFactor, result.ExitVsPlanRFactor = computePlanRFactors(payload.Plan, direction, trades)
+	result.ProductType = getProductType(payload.Instrument, result.OpenedAt, closedAt)
 
 	if netOpenQty.IsPositive() {
 		result.OpenQuantity = netOpenQty
@@ -414,6 +684,25 @@ func Compute(payload ComputePayload) (computeResult, error) {
 	return result, nil
 }
 
+// ComputePayload returns the payload to recompute the stored Position with, after its trades, cash flows
+// or charges, or the corporate actions of its symbol, have changed.
+func (p *Position) ComputePayload() ComputePayload {
+	return ComputePayload{
+		Trades:              ConvertTradesToCreatePayload(p.Trades),
+		RiskAmount:          p.RiskAmount,
+		FxRate:              &p.FxRate,
+		LotMatchingMethod:   p.EffectiveLotMatchingMethod,
+		Plan:                p.Plan,
+		CorporateActions:    p.CorporateActions,
+		CashFlows:           p.CashFlows,
+		Instrument:          p.Instrument,
+		Segment:             p.Segment,
+		EnableAutoCharges:   p.EnableAutoCharges,
+		BrokerID:            p.BrokerID,
+		UserBrokerAccountID: p.UserBrokerAccountID,
+	}
+}
+
 func ConvertTradesToCreatePayload(trades []*trade.Trade) []trade.CreatePayload {
 	createPayloads := make([]trade.CreatePayload, len(trades))
 	for i, t := range trades {
@@ -425,6 +714,8 @@ func ConvertTradesToCreatePayload(trades []*trade.Trade) []trade.CreatePayload {
 			ChargesAmount: t.ChargesAmount,
 			PositionID:    t.PositionID,
 			BrokerTradeID: t.BrokerTradeID,
+
+			ChargesBreakdown: t.ChargesBreakdown,
 		}
 	}
 	return createPayloads
@@ -458,9 +749,20 @@ func GetRealisedStatsUptoATradeByTradeID(positions []*Position) map[uuid.UUID]Re
 	return realisedStats
 }
 
+// ApplyComputeResultToPosition applies the result of computing a Position's trades to it before it is saved.
+// The trades may have changed, so the excursion must be computed again.
 func ApplyComputeResultToPosition(
 	position *Position,
 	computeResult computeResult,
+) {
+	applyComputeResult(position, computeResult)
+	resetExcursion(position)
+}
+
+// applyComputeResult applies the result of computing a Position's trades to it, keeping its excursion.
+func applyComputeResult(
+	position *Position,
+	computeResult computeResult,
 ) {
 	if position.FxRate.Equal(decimal.NewFromInt(0)) {
 		position.FxRate = decimal.NewFromInt(1)
@@ -479,6 +781,7 @@ func ApplyComputeResultToPosition(
 	position.ClosedAt = computeResult.ClosedAt
 	position.GrossPnLAmount = computeResult.GrossPnLAmount
 	position.NetPnLAmount = computeResult.NetPnLAmount
+	position.IncomeAmount = computeResult.IncomeAmount
 	position.TotalChargesAmount = computeResult.TotalChargesAmount
 	position.RFactor = computeResult.RFactor
 	position.GrossRFactor = computeResult.GrossRFactor
@@ -486,6 +789,33 @@ func ApplyComputeResultToPosition(
 	position.ChargesAsPercentageOfNetPnL = computeResult.ChargesAsPercentageOfNetPnL
 	position.OpenQuantity = computeResult.OpenQuantity
 	position.OpenAveragePriceAmount = computeResult.OpenAveragePriceAmount
+	position.EffectiveLotMatchingMethod = computeResult.LotMatchingMethod
+	position.RiskAmount = computeResult.RiskAmount
+	position.PlannedRFactor = computeResult.PlannedRFactor
+	position.ExitVsPlanRFactor = computeResult.ExitVsPlanRFactor
+	position.ProductType = computeResult.ProductType
+
+	ApplyContractToPosition(position)
+	applyRealisedStatsToTrades(position.Trades, computeResult.trades)
+}
+
+// applyRealisedStatsToTrades copies the realised stats of the computed trades onto the Position's trades.
+// The computed trades are in the same order as the trades they were computed from.
+func applyRealisedStatsToTrades(trades []*trade.Trade, computedTrades []*trade.Trade) {
+	if len(trades) != len(computedTrades) {
+		return
+	}
+
+	for i, t := range trades {
+		computed := computedTrades[i]
+
+		t.RealisedGrossPnL = computed.RealisedGrossPnL
+		t.RealisedNetPnL = computed.RealisedNetPnL
+		t.GrossROI = computed.GrossROI
+		t.GrossRFactor = computed.GrossRFactor
+		t.NetRFactor = computed.NetRFactor
+		t.MatchedLots = computed.MatchedLots
+	}
 }
 
 func isScaleOut(
@@ -501,28 +831,44 @@ func isScaleOut(
 	return false
 }
 
-type fifoLot struct {
+// openLot is the quantity of a scale-in trade that is yet to be matched by a scale-out trade.
+type openLot struct {
 	Qty   decimal.Decimal
 	Price decimal.Decimal
+
+	// The charges of the scale-in trade per unit, realised along with the lot.
+	ChargesPerUnit decimal.Decimal
 }
 
 type ComputeSmartTradesResult struct {
 	openAvgPrice decimal.Decimal
 }
 
-func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount decimal.Decimal) (ComputeSmartTradesResult, error) {
+// ComputeSmartTrades computes the realised PnL, ROI, R and matched lots of every scale-out trade.
+// The open lot(s) that a scale-out trade is matched against depend on the LotMatchingMethod.
+func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount decimal.Decimal, method LotMatchingMethod) (ComputeSmartTradesResult, error) {
 	result := ComputeSmartTradesResult{}
 
 	if len(trades) == 0 {
 		return result, nil
 	}
 
-	var fifo []fifoLot
+	method = method.OrDefault()
+
+	var lots []openLot
 	netOpenQty := decimal.Zero
 
 	for i, t := range trades {
 		qtyLeft := t.Quantity
 
+		// Reset the realised stats, the trade may be carrying the ones that were stored with it.
+		t.RealisedGrossPnL = decimal.Zero
+		t.RealisedNetPnL = decimal.Zero
+		t.GrossROI = decimal.Zero
+		t.GrossRFactor = decimal.Zero
+		t.NetRFactor = decimal.Zero
+		t.MatchedLots = nil
+
 		if qtyLeft.LessThanOrEqual(decimal.Zero) {
 			return result, fmt.Errorf("invalid quantity at trade %d: must be positive", i)
 		}
@@ -530,8 +876,17 @@ func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount d
 		isScaleIn := (direction == DirectionLong && t.Kind == types.TradeKindBuy) || (direction == DirectionShort && t.Kind == types.TradeKindSell)
 
 		if isScaleIn {
-			// Add to FIFO
-			fifo = append(fifo, fifoLot{Qty: qtyLeft, Price: t.Price})
+			lot := openLot{Qty: qtyLeft, Price: t.Price, ChargesPerUnit: t.ChargesAmount.Div(qtyLeft)}
+
+			if method == LotMatchingMethodWeightedAverage && len(lots) > 0 {
+				// Pool the new lot with the existing one at the weighted average price.
+				totalQty := lots[0].Qty.Add(qtyLeft)
+				charges := lots[0].Qty.Mul(lots[0].ChargesPerUnit).Add(t.ChargesAmount)
+				lots[0] = openLot{Qty: totalQty, Price: computeAvgPrice([]openLot{lots[0], lot}), ChargesPerUnit: charges.Div(totalQty)}
+			} else {
+				lots = append(lots, lot)
+			}
+
 			netOpenQty = netOpenQty.Add(qtyLeft.Mul(directionSignDecimal(direction)))
 		} else {
 			// Scale-out
@@ -545,9 +900,17 @@ func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount d
 			matched := []trade.MatchedLot{}
 			realisedGrossPnL := decimal.Zero
 			costBasis := decimal.Zero
+			// The charges of the scale-in trades of the matched lots.
+			entryCharges := decimal.Zero
+
+			for qtyLeft.GreaterThan(decimal.Zero) && len(lots) > 0 {
+				// FIFO and weighted average match against the oldest lot, LIFO against the newest one.
+				lotIdx := 0
+				if method == LotMatchingMethodLIFO {
+					lotIdx = len(lots) - 1
+				}
 
-			for qtyLeft.GreaterThan(decimal.Zero) && len(fifo) > 0 {
-				lot := &fifo[0]
+				lot := &lots[lotIdx]
 				matchQty := decimal.Min(qtyLeft, lot.Qty)
 
 				var pnl decimal.Decimal
@@ -566,19 +929,22 @@ func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount d
 
 				realisedGrossPnL = realisedGrossPnL.Add(pnl)
 				costBasis = costBasis.Add(matchQty.Mul(lot.Price))
+				entryCharges = entryCharges.Add(matchQty.Mul(lot.ChargesPerUnit))
 
 				lot.Qty = lot.Qty.Sub(matchQty)
 				qtyLeft = qtyLeft.Sub(matchQty)
 
 				if lot.Qty.IsZero() {
-					fifo = fifo[1:]
+					lots = append(lots[:lotIdx], lots[lotIdx+1:]...)
 				}
 
 				t.MatchedLots = matched
 			}
 
 			t.RealisedGrossPnL = realisedGrossPnL
-			t.RealisedNetPnL = realisedGrossPnL.Sub(t.ChargesAmount)
+			// The charges of the scale-ins are realised with the lots they opened, so that the realised net PnL
+			// of the trades of a closed Position adds up to its net PnL.
+			t.RealisedNetPnL = realisedGrossPnL.Sub(t.ChargesAmount).Sub(entryCharges)
 
 			if !costBasis.IsZero() {
 				t.GrossROI = realisedGrossPnL.Div(costBasis).Mul(decimal.NewFromInt(100))
@@ -587,8 +953,7 @@ func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount d
 
 			if riskAmount.IsPositive() {
 				t.GrossRFactor = t.RealisedGrossPnL.Div(riskAmount)
-				// NOTE: We aren't updating the t.RFactor because we don't know the charges
-				// accumulated upto this point. This must be handled from the caller of this function.
+				t.NetRFactor = t.RealisedNetPnL.Div(riskAmount)
 			}
 
 			netOpenQty = netOpenQty.Sub(t.Quantity.Mul(directionSignDecimal(direction)))
@@ -598,13 +963,263 @@ func ComputeSmartTrades(trades []*trade.Trade, direction Direction, riskAmount d
 	if netOpenQty.IsPositive() {
 		// If we have any open quantity left, it means we have unclosed positions.
 		// We can compute the average price of the open positions.
-		openAvgPrice := computeAvgPrice(fifo)
+		openAvgPrice := computeAvgPrice(lots)
 		result.openAvgPrice = openAvgPrice
 	}
 
 	return result, nil
 }
 
+// findReversalTradeIdx returns the index of the first trade that takes the net open quantity
+// past zero into the opposite direction, or -1 if there is no such trade.
+func findReversalTradeIdx(trades []*trade.Trade, direction Direction) int {
+	netOpenQty := decimal.Zero
+
+	for i, t := range trades {
+		if isScaleOut(t, direction) {
+			netOpenQty = netOpenQty.Sub(t.Quantity)
+		} else {
+			netOpenQty = netOpenQty.Add(t.Quantity)
+		}
+
+		if netOpenQty.IsNegative() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// SplitTradeOnReversal splits a trade that takes a Position with `openQty` in `direction` through zero.
+// The closing trade closes the Position and the reversal trade, which is `nil` if the trade doesn't reverse
+// the Position, opens a new Position in the opposite direction at the same time and price.
+// The charges of the trade are split in proportion to the quantity.
+func SplitTradeOnReversal(t trade.CreatePayload, direction Direction, openQty decimal.Decimal) (closing trade.CreatePayload, reversal *trade.CreatePayload) {
+	isScaleOut := (direction == DirectionLong && t.Kind == types.TradeKindSell) || (direction == DirectionShort && t.Kind == types.TradeKindBuy)
+
+	if !isScaleOut || !openQty.IsPositive() || t.Quantity.LessThanOrEqual(openQty) {
+		return t, nil
+	}
+
+	closingCharges := t.ChargesAmount.Mul(openQty).Div(t.Quantity)
+
+	closing = t
+	closing.Quantity = openQty
+	closing.ChargesAmount = closingCharges
+
+	reversed := t
+	reversed.Quantity = t.Quantity.Sub(openQty)
+	reversed.ChargesAmount = t.ChargesAmount.Sub(closingCharges)
+
+	if t.ChargesBreakdown != nil {
+		closingBreakdown := t.ChargesBreakdown.Mul(openQty.Div(t.Quantity))
+		reversedBreakdown := t.ChargesBreakdown.Add(closingBreakdown.Mul(decimal.NewFromInt(-1)))
+		closing.ChargesBreakdown = &closingBreakdown
+		reversed.ChargesBreakdown = &reversedBreakdown
+	}
+
+	return closing, &reversed
+}
+
+// splitTradeOnPositionReversal splits the trade with SplitTradeOnReversal if it takes the Position through zero.
+// The open quantity of the Position is after the splits and bonus issues, so it's compared in the shares of the trade.
+func splitTradeOnPositionReversal(t trade.CreatePayload, pos *Position) (closing trade.CreatePayload, reversal *trade.CreatePayload) {
+	openQty := pos.OpenQuantity.Div(corporateActionsMultiplier(t.Time, pos.CorporateActions))
+	return SplitTradeOnReversal(t, pos.Direction, openQty)
+}
+
+// restoreReversingTrade gives the trade back the quantity and charges of its payload, which were cut to the part
+// that closes the Position. If the Position can't be computed with that part, the rest of the trade stays in it,
+// to be reported as invalid with it, rather than opening a Position that would be lost.
+func restoreReversingTrade(t *trade.Trade, payload trade.CreatePayload) {
+	t.Quantity = payload.Quantity
+	t.ChargesAmount = payload.ChargesAmount
+}
+
+// splitTradesOnReversals splits the trades of a Position into the trades of the Positions they make,
+// as an import does. A trade that takes the Position through zero is split with SplitTradeOnReversal:
+// the closing part stays in the Position, and the rest of it opens the next Position with the trades
+// after it. The quantities are compared after the splits and bonus issues, as Compute compares them.
+// It returns the trades as they are, as the only element, if no trade reverses the Position.
+func splitTradesOnReversals(trades []trade.CreatePayload, actions []*corporateaction.CorporateAction) [][]trade.CreatePayload {
+	splitTrades := [][]trade.CreatePayload{}
+	current := []trade.CreatePayload{}
+
+	var direction Direction
+	openQty := decimal.Zero
+
+	for _, t := range trades {
+		multiplier := corporateActionsMultiplier(t.Time, actions)
+		qty := t.Quantity.Mul(multiplier)
+
+		if len(current) == 0 {
+			direction = DirectionLong
+			if t.Kind == types.TradeKindSell {
+				direction = DirectionShort
+			}
+		}
+
+		isScaleOut := (direction == DirectionLong && t.Kind == types.TradeKindSell) || (direction == DirectionShort && t.Kind == types.TradeKindBuy)
+
+		if isScaleOut && qty.GreaterThan(openQty) {
+			if openQty.IsPositive() {
+				closing, reversal := SplitTradeOnReversal(t, direction, openQty.Div(multiplier))
+				current = append(current, closing)
+				t = *reversal
+
+				if t.BrokerTradeID != nil {
+					brokerTradeID := reversalBrokerTradeID(*t.BrokerTradeID)
+					t.BrokerTradeID = &brokerTradeID
+				}
+
+				qty = qty.Sub(openQty)
+			}
+
+			// The Position is closed, so the rest of the trade opens a new one in the opposite direction.
+			splitTrades = append(splitTrades, current)
+			current = []trade.CreatePayload{}
+
+			direction = DirectionLong
+			if t.Kind == types.TradeKindSell {
+				direction = DirectionShort
+			}
+
+			openQty = decimal.Zero
+			isScaleOut = false
+		}
+
+		if isScaleOut {
+			openQty = openQty.Sub(qty)
+		} else {
+			openQty = openQty.Add(qty)
+		}
+
+		current = append(current, t)
+	}
+
+	return append(splitTrades, current)
+}
+
+// reversedPositionPayload is the payload of a Position opened by the rest of a trade that reversed the
+// Position of `payload`, with the trades after it. The plan and the risk of the reversed Position don't
+// apply to it, and the journal and tags stay with the reversed Position.
+func reversedPositionPayload(payload CreatePayload, trades []trade.CreatePayload) CreatePayload {
+	payload.Trades = trades
+	payload.RiskAmount = decimal.Zero
+	payload.Plan = Plan{}
+	payload.CashFlows = nil
+	payload.JournalContent = nil
+	payload.ActiveUploadIDs = nil
+	payload.TagIDs = nil
+
+	return payload
+}
+
+// SplitReversedPosition cuts a stored Position whose trades take it through zero at the reversal, as an
+// import or a manual entry is cut. The Position keeps its trades up to the reversal, which are replaced
+// by new ones, and the rest of its trades open the returned Positions. The caller must save both.
+// It returns no Positions if the Position doesn't reverse.
+func SplitReversedPosition(pos *Position) ([]*Position, error) {
+	payload := CreatePayload{
+		ComputePayload:      pos.ComputePayload(),
+		Symbol:              pos.Symbol,
+		Instrument:          pos.Instrument,
+		CurrencyCode:        pos.CurrencyCode,
+		UserBrokerAccountID: pos.UserBrokerAccountID,
+		LotMatchingMethod:   pos.LotMatchingMethod,
+	}
+
+	splitTrades := splitTradesOnReversals(payload.Trades, payload.CorporateActions)
+	if len(splitTrades) == 1 {
+		return nil, nil
+	}
+
+	trades, err := createTradesFromCreatePayload(splitTrades[0], pos.ID)
+	if err != nil {
+		return nil, fmt.Errorf("create trades from create payload: %w", err)
+	}
+
+	for i, t := range trades {
+		t.ImportBatchID = pos.Trades[i].ImportBatchID
+	}
+
+	positions := []*Position{}
+
+	for _, reversedTrades := range splitTrades[1:] {
+		reversedPosition, _, err := new(pos.CreatedBy, reversedPositionPayload(payload, reversedTrades))
+		if err != nil {
+			return nil, fmt.Errorf("new: %w", err)
+		}
+
+		reversedPosition.BrokerID = pos.BrokerID
+		positions = append(positions, reversedPosition)
+	}
+
+	pos.Trades = trades
+
+	return positions, nil
+}
+
+// reversalBrokerTradeID is the broker trade ID of the trade that opens a new Position after
+// a reversal, so that it doesn't clash with the broker trade ID of the trade that closed the Position.
+func reversalBrokerTradeID(brokerTradeID string) string {
+	return brokerTradeID + reversalBrokerTradeIDSuffix
+}
+
+const reversalBrokerTradeIDSuffix = ":reversal"
+
+// importedBrokerTradePositionID returns the ID of the Position of the trade with the broker trade ID, if
+// the trade has been imported already. A trade that reversed a Position is imported as the trade that
+// closed it, with the broker trade ID, and the one that opened the next, with reversalBrokerTradeID.
+// Either of them having been imported means the trade has been.
+func importedBrokerTradePositionID(brokerTradeIDs map[string]uuid.UUID, brokerTradeID string) (uuid.UUID, bool) {
+	orderID := strings.TrimSuffix(brokerTradeID, reversalBrokerTradeIDSuffix)
+
+	for _, id := range []string{brokerTradeID, orderID, reversalBrokerTradeID(orderID)} {
+		if positionID, ok := brokerTradeIDs[id]; ok {
+			return positionID, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+func isBrokerTradeImported(brokerTradeIDs map[string]uuid.UUID, brokerTradeID string) bool {
+	_, ok := importedBrokerTradePositionID(brokerTradeIDs, brokerTradeID)
+	return ok
+}
+
+// computePlanRFactors computes the planned reward to risk and how far the average exit price
+// landed from the target price in R. Both are `nil` if the plan has no target price.
+func computePlanRFactors(plan Plan, direction Direction, trades []*trade.Trade) (plannedRFactor, exitVsPlanRFactor *decimal.Decimal) {
+	riskPerUnit, ok := plan.riskPerUnit()
+	if !ok || riskPerUnit.IsZero() || plan.TargetPrice == nil {
+		return nil, nil
+	}
+
+	sign := directionSignDecimal(direction)
+
+	planned := plan.TargetPrice.Sub(*plan.PlannedEntryPrice).Mul(sign).Div(riskPerUnit)
+	plannedRFactor = &planned
+
+	exitQty := decimal.Zero
+	exitValue := decimal.Zero
+	for _, t := range trades {
+		if isScaleOut(t, direction) {
+			exitQty = exitQty.Add(t.Quantity)
+			exitValue = exitValue.Add(t.Quantity.Mul(t.Price))
+		}
+	}
+
+	if exitQty.IsPositive() {
+		avgExitPrice := exitValue.Div(exitQty)
+		exitVsPlan := avgExitPrice.Sub(*plan.TargetPrice).Mul(sign).Div(riskPerUnit)
+		exitVsPlanRFactor = &exitVsPlan
+	}
+
+	return plannedRFactor, exitVsPlanRFactor
+}
+
 func directionSignDecimal(direction Direction) decimal.Decimal {
 	if direction == DirectionLong {
 		return decimal.NewFromInt(1)
@@ -627,11 +1242,11 @@ func computeDirection(trades []*trade.Trade) (Direction, error) {
 	return direction, nil
 }
 
-func computeAvgPrice(fifo []fifoLot) decimal.Decimal {
+func computeAvgPrice(lots []openLot) decimal.Decimal {
 	totalCost := decimal.Zero
 	totalQty := decimal.Zero
 
-	for _, lot := range fifo {
+	for _, lot := range lots {
 		totalCost = totalCost.Add(lot.Qty.Mul(lot.Price))
 		totalQty = totalQty.Add(lot.Qty)
 	}
@@ -714,6 +1329,15 @@ func FilterPositionsWithRealisingTradesUpTo(positions []*Position, end time.Time
 
 		positionCopy.Trades = trades
 
+		// Like the trades, only the cash flows up to the end count.
+		cashFlows := []*cashflow.CashFlow{}
+		for _, c := range p.CashFlows {
+			if !c.Time.In(loc).After(end) {
+				cashFlows = append(cashFlows, c)
+			}
+		}
+		positionCopy.CashFlows = cashFlows
+
 		if atLeastOneTradeWasScalingOut {
 			positionsWithTradesUptoEnd = append(positionsWithTradesUptoEnd, &positionCopy)
 		}
@@ -723,20 +1347,15 @@ func FilterPositionsWithRealisingTradesUpTo(positions []*Position, end time.Time
 	// to calculate the realised PnL and other stats.
 
 	for i, p := range positionsWithTradesUptoEnd {
-		payload := ComputePayload{
-			Trades:     ConvertTradesToCreatePayload(p.Trades),
-			RiskAmount: p.RiskAmount,
-			FxRate:     &p.FxRate,
-		}
-
-		computeResult, err := Compute(payload)
+		computeResult, err := Compute(p.ComputePayload())
 		if err != nil {
 			// If we fail silently and continue.
 			logger.Get().Errorw("failed to compute position", "error", err, "symbol", p.Symbol, "opened_at", p.OpenedAt)
 			continue
 		}
 
-		ApplyComputeResultToPosition(p, computeResult)
+		// The excursion of the whole Position is kept for the analytics.
+		applyComputeResult(p, computeResult)
 		positionsWithTradesUptoEnd[i] = p
 	}
 
@@ -750,12 +1369,16 @@ type PnlBucket struct {
 	GrossPnL     decimal.Decimal         `json:"gross_pnl"`
 	Charges      decimal.Decimal         `json:"charges"`
 	NetPnL       decimal.Decimal         `json:"net_pnl"`
+	Income       decimal.Decimal         `json:"income"` // Dividends and other cash flows, included in NetPnL.
 	GrossRFactor decimal.Decimal         `json:"gross_r_factor"`
 	Positions    map[uuid.UUID]*Position `json:"-"` // Positions that contributed to this bucket's PnL.
 }
 
-func GetPnLBuckets(positions []*Position, period common.BucketPeriod, start, end time.Time, loc *time.Location) []PnlBucket {
-	if len(positions) == 0 {
+// GetPnLBuckets buckets the realised PnL of the Positions by the time of their trades. The CashFlows are
+// added to the bucket they happened in. `cashFlowPositions` are the Positions of the CashFlows by their ID,
+// see FindCashFlowPositions.
+func GetPnLBuckets(positions []*Position, cashFlows []*cashflow.CashFlow, cashFlowPositions map[uuid.UUID]*Position, period common.BucketPeriod, start, end time.Time, loc *time.Location) []PnlBucket {
+	if len(positions) == 0 && len(cashFlows) == 0 {
 		return []PnlBucket{}
 	}
 
@@ -834,12 +1457,46 @@ func GetPnLBuckets(positions []*Position, period common.BucketPeriod, start, end
 		}
 	}
 
+	for _, c := range cashFlows {
+		amount, ok := cashFlowAmount(c, cashFlowPositions)
+		if !ok {
+			continue
+		}
+
+		for i := range results {
+			if !c.Time.Before(results[i].Start) && c.Time.Before(results[i].End) {
+				results[i].Income = results[i].Income.Add(amount)
+				results[i].NetPnL = results[i].NetPnL.Add(amount)
+				break
+			}
+		}
+	}
+
 	return results
 }
 
+// cashFlowAmount returns the amount of the CashFlow in the home currency. It returns false
+// if the CashFlow belongs to a Position that isn't in `cashFlowPositions`.
+func cashFlowAmount(c *cashflow.CashFlow, cashFlowPositions map[uuid.UUID]*Position) (decimal.Decimal, bool) {
+	if c.PositionID == nil {
+		return c.Amount, true
+	}
+
+	pos, ok := cashFlowPositions[*c.PositionID]
+	if !ok {
+		return decimal.Zero, false
+	}
+
+	if !pos.FxRate.IsPositive() {
+		return c.Amount, true
+	}
+
+	return c.Amount.Mul(pos.FxRate), true
+}
+
 // GetCumulativePnLBuckets calculates cumulative realized PnL using pnL buckets.
-func GetCumulativePnLBuckets(positions []*Position, period common.BucketPeriod, start, end time.Time, loc *time.Location) []PnlBucket {
-	pnlBuckets := GetPnLBuckets(positions, period, start, end, loc)
+func GetCumulativePnLBuckets(positions []*Position, cashFlows []*cashflow.CashFlow, cashFlowPositions map[uuid.UUID]*Position, period common.BucketPeriod, start, end time.Time, loc *time.Location) []PnlBucket {
+	pnlBuckets := GetPnLBuckets(positions, cashFlows, cashFlowPositions, period, start, end, loc)
 
 	// Convert bucket PnL and charges to cumulative values with rounding
 	for i := range pnlBuckets {
@@ -847,17 +1504,97 @@ func GetCumulativePnLBuckets(positions []*Position, period common.BucketPeriod,
 			pnlBuckets[i].NetPnL = pnlBuckets[i].NetPnL.Add(pnlBuckets[i-1].NetPnL)
 			pnlBuckets[i].GrossPnL = pnlBuckets[i].GrossPnL.Add(pnlBuckets[i-1].GrossPnL)
 			pnlBuckets[i].Charges = pnlBuckets[i].Charges.Add(pnlBuckets[i-1].Charges)
+			pnlBuckets[i].Income = pnlBuckets[i].Income.Add(pnlBuckets[i-1].Income)
 		}
 	}
 
 	return pnlBuckets
 }
 
+// ChargesSummary is the charges of trades by their components, in the home currency.
+type ChargesSummary struct {
+	trade.ChargesBreakdown
+
+	// The charges of the trades without a breakdown, like the ones with manual charges.
+	Unitemised decimal.Decimal `json:"unitemised"`
+	Total      decimal.Decimal `json:"total"`
+}
+
+func (s *ChargesSummary) addTrade(t *trade.Trade, fxRate decimal.Decimal) {
+	charges := t.ChargesAmount.Mul(fxRate)
+	s.Total = s.Total.Add(charges)
+
+	if t.ChargesBreakdown == nil {
+		s.Unitemised = s.Unitemised.Add(charges)
+		return
+	}
+
+	s.ChargesBreakdown = s.ChargesBreakdown.Add(t.ChargesBreakdown.Mul(fxRate))
+}
+
+type ChargesBucket struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	ChargesSummary
+}
+
+// SumChargesBuckets sums the charges of the buckets, e.g. to show what share of the charges is STT.
+func SumChargesBuckets(buckets []ChargesBucket) ChargesSummary {
+	var summary ChargesSummary
+
+	for _, b := range buckets {
+		summary.ChargesBreakdown = summary.ChargesBreakdown.Add(b.ChargesBreakdown)
+		summary.Unitemised = summary.Unitemised.Add(b.Unitemised)
+		summary.Total = summary.Total.Add(b.Total)
+	}
+
+	return summary
+}
+
+// GetChargesBuckets buckets the charges of the trades of the Positions by their components. Unlike
+// GetPnLBuckets, the charges of a trade are added to the bucket of the trade, when they were paid.
+func GetChargesBuckets(positions []*Position, period common.BucketPeriod, start, end time.Time, loc *time.Location) []ChargesBucket {
+	buckets := common.GenerateBuckets(period, start, end, loc)
+	results := make([]ChargesBucket, len(buckets))
+	for i, b := range buckets {
+		results[i] = ChargesBucket{
+			Label: b.Label(loc),
+			Start: b.Start,
+			End:   b.End,
+		}
+	}
+
+	for _, pos := range positions {
+		for _, t := range pos.Trades {
+			for i := range results {
+				if !t.Time.Before(results[i].Start) && t.Time.Before(results[i].End) {
+					results[i].addTrade(t, positionFxRate(pos))
+					break
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// positionFxRate returns the rate to convert the amounts of the Position to the home currency.
+func positionFxRate(pos *Position) decimal.Decimal {
+	if !pos.FxRate.IsPositive() {
+		return decimal.NewFromInt(1)
+	}
+
+	return pos.FxRate
+}
+
 type GeneralStats struct {
 	// --- Core ---
 	NetPnL   decimal.Decimal `json:"net_pnl"`
 	GrossPnL string          `json:"gross_pnl"`
 	Charges  string          `json:"charges"`
+	// Dividends and other cash flows. They are included in NetPnL.
+	Income decimal.Decimal `json:"income"`
 
 	// --- Performance ---
 	WinRate  float64 `json:"win_rate"`
@@ -885,6 +1622,19 @@ type GeneralStats struct {
 	AvgWinRFactor  string `json:"avg_win_r_factor"`
 	AvgLossRFactor string `json:"avg_loss_r_factor"`
 
+	// --- Plan ---
+	// Only the settled positions with a planned entry, stop-loss and target price are considered.
+	AvgPlannedRFactor      string `json:"avg_planned_r_factor"`
+	AvgExitVsPlanRFactor   string `json:"avg_exit_vs_plan_r_factor"`
+	PositionsWithPlanCount int    `json:"positions_with_plan_count"`
+
+	// --- Excursion ---
+	// Only the settled positions with a computed excursion are considered.
+	AvgMAE                      string `json:"avg_mae"`
+	AvgMFE                      string `json:"avg_mfe"`
+	AvgExitEfficiency           string `json:"avg_exit_efficiency"`
+	PositionsWithExcursionCount int    `json:"positions_with_excursion_count"`
+
 	// --- ROI ---
 	AvgWinROI  string `json:"avg_win_roi"`
 	AvgLossROI string `json:"avg_loss_roi"`
@@ -915,6 +1665,9 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 		avgWinRFactor, avgLossRFactor decimal.Decimal
 		avgWinROI, avgLossROI         decimal.Decimal
 
+		avgPlannedRFactor, avgExitVsPlanRFactor decimal.Decimal
+		avgMAE, avgMFE, avgExitEfficiency       decimal.Decimal
+
 		avgWin, avgLoss decimal.Decimal
 		maxWin, maxLoss decimal.Decimal
 
@@ -926,6 +1679,8 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 		openTradesCount, settledTradesCount                            int
 		winPositionsCount, lossPositionsCount, breakevenPositionsCount int
 		tradesWithRiskAmountCount                                      int
+		positionsWithPlanCount, positionsWithExitVsPlanCount           int
+		positionsWithExcursionCount, positionsWithExitEfficiencyCount  int
 
 		maxWinStreak, maxLossStreak int
 		currentWin, currentLoss     int
@@ -964,6 +1719,29 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 			}
 		}
 
+		// --- Plan-based metrics ---
+		if p.PlannedRFactor != nil {
+			positionsWithPlanCount++
+			avgPlannedRFactor = avgPlannedRFactor.Add(*p.PlannedRFactor)
+
+			if p.ExitVsPlanRFactor != nil {
+				positionsWithExitVsPlanCount++
+				avgExitVsPlanRFactor = avgExitVsPlanRFactor.Add(*p.ExitVsPlanRFactor)
+			}
+		}
+
+		// --- Excursion-based metrics ---
+		if p.MAEAmount != nil && p.MFEAmount != nil {
+			positionsWithExcursionCount++
+			avgMAE = avgMAE.Add(*p.MAEAmount)
+			avgMFE = avgMFE.Add(*p.MFEAmount)
+
+			if p.ExitEfficiencyPercentage != nil {
+				positionsWithExitEfficiencyCount++
+				avgExitEfficiency = avgExitEfficiency.Add(*p.ExitEfficiencyPercentage)
+			}
+		}
+
 		// --- PnL-based classification ---
 		if p.NetPnLAmount.GreaterThan(decimal.Zero) {
 			winPositionsCount++
@@ -1008,6 +1786,24 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 		avgGrossRFactor = avgGrossRFactor.Div(div)
 	}
 
+	if positionsWithPlanCount > 0 {
+		avgPlannedRFactor = avgPlannedRFactor.Div(decimal.NewFromInt(int64(positionsWithPlanCount)))
+	}
+
+	if positionsWithExitVsPlanCount > 0 {
+		avgExitVsPlanRFactor = avgExitVsPlanRFactor.Div(decimal.NewFromInt(int64(positionsWithExitVsPlanCount)))
+	}
+
+	if positionsWithExcursionCount > 0 {
+		div := decimal.NewFromInt(int64(positionsWithExcursionCount))
+		avgMAE = avgMAE.Div(div)
+		avgMFE = avgMFE.Div(div)
+	}
+
+	if positionsWithExitEfficiencyCount > 0 {
+		avgExitEfficiency = avgExitEfficiency.Div(decimal.NewFromInt(int64(positionsWithExitEfficiencyCount)))
+	}
+
 	if winPositionsCount > 0 {
 		div := decimal.NewFromInt(int64(winPositionsCount))
 		avgWin = avgWin.Div(div)
@@ -1066,6 +1862,15 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 		AvgWinROI:      avgWinROI.StringFixed(2),
 		AvgLossROI:     avgLossROI.StringFixed(2),
 
+		AvgPlannedRFactor:      avgPlannedRFactor.StringFixed(2),
+		AvgExitVsPlanRFactor:   avgExitVsPlanRFactor.StringFixed(2),
+		PositionsWithPlanCount: positionsWithPlanCount,
+
+		AvgMAE:                      avgMAE.StringFixed(2),
+		AvgMFE:                      avgMFE.StringFixed(2),
+		AvgExitEfficiency:           avgExitEfficiency.StringFixed(2),
+		PositionsWithExcursionCount: positionsWithExcursionCount,
+
 		WinStreak:  maxWinStreak,
 		LossStreak: maxLossStreak,
 
@@ -1083,6 +1888,20 @@ func GetGeneralStats(positions []*Position) GeneralStats {
 	}
 }
 
+// AddCashFlows adds the CashFlows to the income and net PnL. `cashFlowPositions` are the Positions
+// of the CashFlows by their ID, see FindCashFlowPositions.
+func (s *GeneralStats) AddCashFlows(cashFlows []*cashflow.CashFlow, cashFlowPositions map[uuid.UUID]*Position) {
+	for _, c := range cashFlows {
+		amount, ok := cashFlowAmount(c, cashFlowPositions)
+		if !ok {
+			continue
+		}
+
+		s.Income = s.Income.Add(amount)
+		s.NetPnL = s.NetPnL.Add(amount)
+	}
+}
+
 func GetDefaultSearchPayload(userID uuid.UUID, enforcer *subscription.PlanEnforcer, tz *time.Location) SearchPayload {
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	tradeTimeRange := &common.DateRangeFilter{}
@@ -0,0 +1,44 @@
+package position
+
+import (
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/trade"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// applyCorporateActions restates the trades before the ex-date of a split or bonus issue in the shares
+// after it. The quantity is multiplied and the price divided by the ratio, so the value of a trade and
+// its charges stay the same. The trades after all the ex-dates are left untouched.
+func applyCorporateActions(trades []*trade.Trade, actions []*corporateaction.CorporateAction) {
+	for _, a := range actions {
+		multiplier := a.Multiplier()
+		if multiplier.Equal(decimal.NewFromInt(1)) {
+			continue
+		}
+
+		for _, t := range trades {
+			if !a.AppliesTo(t.Time) {
+				continue
+			}
+
+			t.Quantity = t.Quantity.Mul(multiplier)
+			t.Price = t.Price.Div(multiplier)
+		}
+	}
+}
+
+// corporateActionsMultiplier returns what a share traded at `tradeTime` is in the shares after all the
+// splits and bonus issues, as applyCorporateActions restates it.
+func corporateActionsMultiplier(tradeTime time.Time, actions []*corporateaction.CorporateAction) decimal.Decimal {
+	multiplier := decimal.NewFromInt(1)
+
+	for _, a := range actions {
+		if a.AppliesTo(tradeTime) {
+			multiplier = multiplier.Mul(a.Multiplier())
+		}
+	}
+
+	return multiplier
+}
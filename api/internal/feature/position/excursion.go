@@ -0,0 +1,115 @@
+package position
+
+import (
+	"arthveda/internal/domain/price"
+	"arthveda/internal/feature/trade"
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrCandlesDontCoverPosition = errors.New("Candles must start at or before the first trade of the position")
+
+type excursionResult struct {
+	MAEAmount decimal.Decimal
+	MFEAmount decimal.Decimal
+
+	// `nil` if the Position is open or never went in favour.
+	ExitEfficiencyPercentage *decimal.Decimal
+}
+
+// computeExcursion computes the maximum adverse excursion (MAE) and the maximum favourable excursion (MFE)
+// of a Position from the candles of its symbol. The excursion at any point is the PnL realised so far plus the
+// PnL of the open quantity at the candle's worst (MAE) or best (MFE) price. The open quantity is valued at its
+// weighted average price.
+//
+// We don't know when a trade was made within its candle, so the candle's prices are considered both with
+// the quantity that was open before and after the trades that fall within the candle.
+func computeExcursion(trades []*trade.Trade, direction Direction, candles []price.Candle) (excursionResult, error) {
+	result := excursionResult{}
+
+	if len(trades) == 0 || len(candles) == 0 || candles[0].Time.After(trades[0].Time) {
+		return result, ErrCandlesDontCoverPosition
+	}
+
+	sign := directionSignDecimal(direction)
+
+	openQty := decimal.Zero
+	avgPrice := decimal.Zero
+	realised := decimal.Zero
+	tradeIdx := 0
+
+	evaluate := func(c price.Candle) {
+		// The Position hasn't been opened yet.
+		if tradeIdx == 0 {
+			return
+		}
+
+		adversePrice, favourablePrice := c.Low, c.High
+		if direction == DirectionShort {
+			adversePrice, favourablePrice = c.High, c.Low
+		}
+
+		adverse := realised.Add(adversePrice.Sub(avgPrice).Mul(openQty).Mul(sign))
+		favourable := realised.Add(favourablePrice.Sub(avgPrice).Mul(openQty).Mul(sign))
+
+		result.MAEAmount = decimal.Min(result.MAEAmount, adverse)
+		result.MFEAmount = decimal.Max(result.MFEAmount, favourable)
+	}
+
+	for i, c := range candles {
+		evaluate(c)
+
+		// Apply the trades that were made before the next candle started.
+		for tradeIdx < len(trades) && (i == len(candles)-1 || trades[tradeIdx].Time.Before(candles[i+1].Time)) {
+			t := trades[tradeIdx]
+
+			if isScaleOut(t, direction) {
+				realised = realised.Add(t.Price.Sub(avgPrice).Mul(t.Quantity).Mul(sign))
+				openQty = openQty.Sub(t.Quantity)
+			} else {
+				avgPrice = avgPrice.Mul(openQty).Add(t.Price.Mul(t.Quantity)).Div(openQty.Add(t.Quantity))
+				openQty = openQty.Add(t.Quantity)
+			}
+
+			tradeIdx++
+		}
+
+		evaluate(c)
+
+		// The Position is closed, the candles after this one don't matter.
+		if tradeIdx == len(trades) && openQty.IsZero() {
+			break
+		}
+	}
+
+	if openQty.IsZero() && result.MFEAmount.IsPositive() {
+		efficiency := realised.Div(result.MFEAmount).Mul(decimal.NewFromInt(100))
+		result.ExitEfficiencyPercentage = &efficiency
+	}
+
+	return result, nil
+}
+
+// applyExcursionToPosition sets the excursion on the Position in the home currency.
+func applyExcursionToPosition(position *Position, excursion excursionResult) {
+	fx := position.FxRate
+	if !fx.IsPositive() {
+		fx = decimal.NewFromInt(1)
+	}
+
+	mae := excursion.MAEAmount.Mul(fx)
+	mfe := excursion.MFEAmount.Mul(fx)
+
+	position.MAEAmount = &mae
+	position.MFEAmount = &mfe
+	position.ExitEfficiencyPercentage = excursion.ExitEfficiencyPercentage
+}
+
+// resetExcursion clears the excursion of a Position whose trades may have changed,
+// so that it is computed again from the candles.
+func resetExcursion(position *Position) {
+	position.MAEAmount = nil
+	position.MFEAmount = nil
+	position.ExitEfficiencyPercentage = nil
+}
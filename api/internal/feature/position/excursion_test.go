@@ -0,0 +1,55 @@
+package position
+
+import (
+	"arthveda/internal/domain/price"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/trade"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestComputeExcursion(t *testing.T) {
+	d := decimal.RequireFromString
+	at := func(hour, min int) time.Time { return time.Date(2024, 1, 1, hour, min, 0, 0, time.UTC) }
+
+	candle := func(hour, min int, open, high, low, close string) price.Candle {
+		return price.Candle{Time: at(hour, min), Open: d(open), High: d(high), Low: d(low), Close: d(close)}
+	}
+
+	trades := []*trade.Trade{
+		{Time: at(9, 20), Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("100")},
+		{Time: at(9, 50), Kind: types.TradeKindSell, Quantity: d("10"), Price: d("104")},
+	}
+
+	candles := []price.Candle{
+		candle(9, 15, "100", "101", "99", "100"),
+		candle(9, 30, "100", "102", "96", "101"),
+		candle(9, 45, "101", "108", "100", "104"),
+		candle(10, 0, "104", "120", "80", "110"), // After the exit, ignored.
+	}
+
+	res, err := computeExcursion(trades, DirectionLong, candles)
+	if err != nil {
+		t.Fatalf("computeExcursion: %s", err)
+	}
+
+	if !res.MAEAmount.Equal(d("-40")) {
+		t.Errorf("expected MAE -40, got %s", res.MAEAmount)
+	}
+
+	if !res.MFEAmount.Equal(d("80")) {
+		t.Errorf("expected MFE 80, got %s", res.MFEAmount)
+	}
+
+	// Realised 40 of the 80 it went in favour.
+	if res.ExitEfficiencyPercentage == nil || !res.ExitEfficiencyPercentage.Equal(d("50")) {
+		t.Errorf("expected exit efficiency 50, got %v", res.ExitEfficiencyPercentage)
+	}
+
+	_, err = computeExcursion(trades, DirectionLong, candles[1:])
+	if err != ErrCandlesDontCoverPosition {
+		t.Errorf("expected %v, got %v", ErrCandlesDontCoverPosition, err)
+	}
+}
@@ -0,0 +1,107 @@
+package position
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/charge"
+	"arthveda/internal/feature/trade"
+
+	"github.com/shopspring/decimal"
+)
+
+// feeModelCharges calculates the charges of trades under a FeeModel, sets them on the trades and returns them.
+type feeModelCharges func(trades []*trade.Trade, instrument types.Instrument, segment types.Segment, plan *charge.BrokeragePlan, feeModel *charge.FeeModel, schedules charge.Schedules) []decimal.Decimal
+
+// feeModelChargesByKind are the fee models other than charge.FeeModelKindIndia,
+// which is calculated with the Indian rates of the Schedules.
+var feeModelChargesByKind = map[charge.FeeModelKind]feeModelCharges{
+	charge.FeeModelKindUS:     calculateUSCharges,
+	charge.FeeModelKindCrypto: calculateCryptoCharges,
+}
+
+var oneMillion = decimal.NewFromInt(1_000_000)
+
+// calculateUSCharges charges the commission of the user's BrokeragePlan, the SEC fee and FINRA TAF on sells,
+// and the per-contract regulatory and clearing fees of options. The regulatory fees are rounded up
+// to the cent per trade, as the brokers pass them on. Futures are only charged the commission.
+// The total is truncated to the cent, like the charges of every fee model.
+func calculateUSCharges(trades []*trade.Trade, instrument types.Instrument, segment types.Segment, plan *charge.BrokeragePlan, _ *charge.FeeModel, schedules charge.Schedules) []decimal.Decimal {
+	charges := make([]decimal.Decimal, len(trades))
+
+	for i, t := range trades {
+		tradeValue := t.Quantity.Mul(t.Price)
+		rates := schedules.On(t.Time).USRates()
+		isSell := t.Kind == types.TradeKindSell
+
+		breakdown := chargesBreakdown{
+			Brokerage: getPlanBrokerageCharges(tradeValue, t.Quantity, plan, instrument, segment),
+		}
+
+		var secFee, taf, optionsRegulatoryFee decimal.Decimal
+
+		switch instrument {
+		case types.InstrumentEquity:
+			if isSell {
+				secFee = tradeValue.Mul(decimal.NewFromFloat(rates.SECFeePerMillionOnSell)).Div(oneMillion)
+				taf = decimal.Min(t.Quantity.Mul(decimal.NewFromFloat(rates.FINRATAFPerShareOnSell)), decimal.NewFromFloat(rates.FINRATAFMaxPerTrade))
+			}
+		case types.InstrumentOption:
+			contracts := t.Quantity
+			if rates.OptionContractMultiplier > 0 {
+				contracts = t.Quantity.Div(decimal.NewFromFloat(rates.OptionContractMultiplier))
+			}
+
+			if isSell {
+				secFee = tradeValue.Mul(decimal.NewFromFloat(rates.SECFeePerMillionOnSell)).Div(oneMillion)
+				taf = contracts.Mul(decimal.NewFromFloat(rates.FINRATAFPerContractOnSell))
+			}
+
+			optionsRegulatoryFee = contracts.Mul(decimal.NewFromFloat(rates.OptionsRegulatoryFeePerContract))
+			breakdown.Clearing = contracts.Mul(decimal.NewFromFloat(rates.OCCClearingFeePerContract)).RoundCeil(2)
+		}
+
+		breakdown.Regulatory = secFee.RoundCeil(2).Add(taf.RoundCeil(2)).Add(optionsRegulatoryFee.RoundCeil(2))
+
+		charges[i] = breakdown.Total().Truncate(2)
+		trades[i].ChargesAmount = charges[i]
+		trades[i].ChargesBreakdown = &breakdown
+	}
+
+	return charges
+}
+
+// calculateCryptoCharges charges the maker or taker fee of the FeeModel on the value of each trade, as the
+// exchange transaction charges, and the brokerage of the user's BrokeragePlan if a broker is in between.
+func calculateCryptoCharges(trades []*trade.Trade, instrument types.Instrument, segment types.Segment, plan *charge.BrokeragePlan, feeModel *charge.FeeModel, _ charge.Schedules) []decimal.Decimal {
+	charges := make([]decimal.Decimal, len(trades))
+	feePercent := decimal.NewFromFloat(feeModel.FeePercent()).Div(decimal.NewFromInt(100))
+
+	for i, t := range trades {
+		tradeValue := t.Quantity.Mul(t.Price)
+
+		breakdown := chargesBreakdown{
+			Brokerage:           getPlanBrokerageCharges(tradeValue, t.Quantity, plan, instrument, segment),
+			ExchangeTransaction: tradeValue.Mul(feePercent),
+		}
+
+		charges[i] = breakdown.Total().Truncate(2)
+		trades[i].ChargesAmount = charges[i]
+		trades[i].ChargesBreakdown = &breakdown
+	}
+
+	return charges
+}
+
+// getPlanBrokerageCharges returns the brokerage of a trade with the rule of the user's BrokeragePlan that matches it,
+// zero if there is none as we don't have the commissions of brokers outside India.
+func getPlanBrokerageCharges(tradeValue, quantity decimal.Decimal, plan *charge.BrokeragePlan, instrument types.Instrument, segment types.Segment) decimal.Decimal {
+	if plan == nil {
+		return decimal.Zero
+	}
+
+	rule, ok := plan.Rule(instrument, segment, "")
+	if !ok {
+		return decimal.Zero
+	}
+
+	return getBrokerageCharges(tradeValue, quantity, quantity, getBrokerageConfigFromPlanRule(rule))
+}
@@ -0,0 +1,334 @@
+package position
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/feature/tag"
+	"arthveda/internal/logger"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ImportBatchSource string
+
+const (
+	ImportBatchSourceFile ImportBatchSource = "file"
+	ImportBatchSourceSync ImportBatchSource = "sync"
+)
+
+// ImportBatch represents the `import_batch` table in the database.
+// It is a confirmed import of a file, or a sync, and what it created and replaced, so that it can be rolled back.
+type ImportBatch struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	Source              ImportBatchSource `json:"source" db:"source"`
+	UserBrokerAccountID uuid.UUID         `json:"user_broker_account_id" db:"user_broker_account_id"`
+	BrokerID            uuid.UUID         `json:"broker_id" db:"broker_id"`
+
+	// Only for ImportBatchSourceFile. The name of the file, and of its sheet if it is a spreadsheet.
+	FileName *string `json:"file_name" db:"file_name"`
+	Sheet    *string `json:"sheet" db:"sheet"`
+
+	PositionsCreatedCount  int `json:"positions_created_count" db:"positions_created_count"`
+	PositionsReplacedCount int `json:"positions_replaced_count" db:"positions_replaced_count"`
+	TradesCount            int `json:"trades_count" db:"trades_count"`
+
+	// The positions, with their trades, cash flows, journals and tags, as they were before the batch replaced them.
+	// They are restored when the batch is rolled back.
+	ReplacedPositions []*Position `json:"-" db:"replaced_positions"`
+
+	RolledBackAt *time.Time `json:"rolled_back_at" db:"rolled_back_at"`
+}
+
+func newImportBatch(payload ImportPayload, source ImportBatchSource) (*ImportBatch, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("generate new UUID: %w", err)
+	}
+
+	batch := &ImportBatch{
+		ID:                  id,
+		CreatedBy:           payload.UserID,
+		CreatedAt:           time.Now().UTC(),
+		Source:              source,
+		UserBrokerAccountID: payload.UserBrokerAccountID,
+		BrokerID:            payload.Broker.ID,
+		ReplacedPositions:   []*Position{},
+	}
+
+	if payload.FileName != "" {
+		batch.FileName = &payload.FileName
+	}
+
+	if payload.Sheet != "" {
+		batch.Sheet = &payload.Sheet
+	}
+
+	return batch, nil
+}
+
+// startImportBatch saves a new ImportBatch for the import's positions to be linked to.
+func (s *Service) startImportBatch(ctx context.Context, payload ImportPayload, source ImportBatchSource) (*ImportBatch, error) {
+	batch, err := newImportBatch(payload, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.positionRepository.CreateImportBatch(ctx, batch); err != nil {
+		return nil, fmt.Errorf("create import batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// withImportTx runs fn in a transaction if the import saves its positions. A dry run saves nothing,
+// so it doesn't hold a transaction open while its positions are computed.
+func (s *Service) withImportTx(ctx context.Context, confirm bool, fn func(ctx context.Context) error) error {
+	if !confirm {
+		return fn(ctx)
+	}
+
+	return dbx.WithTx(ctx, s.db, fn)
+}
+
+// saveImportedPosition creates a position of the batch, and its trades. If replacedPositionID isn't nil,
+// that position is kept in the batch and deleted first. Its trades keep the batch that added them,
+// and the other trades are linked to this batch. It must run in the transaction that finishes the batch.
+func (s *Service) saveImportedPosition(ctx context.Context, batch *ImportBatch, pos *Position, replacedPositionID *uuid.UUID) (service.Error, error) {
+	isExistingTrade := map[uuid.UUID]bool{}
+
+	if replacedPositionID != nil {
+		replaced, err := s.positionRepository.GetByID(ctx, batch.CreatedBy, *replacedPositionID)
+		if err != nil {
+			return service.ErrInternalServerError, fmt.Errorf("failed to get replaced position: %w", err)
+		}
+
+		replaced.Trades, err = s.tradeRepository.FindByPositionID(ctx, replaced.ID)
+		if err != nil {
+			return service.ErrInternalServerError, fmt.Errorf("failed to get trades of replaced position: %w", err)
+		}
+
+		for _, t := range replaced.Trades {
+			isExistingTrade[t.ID] = true
+		}
+
+		// The cash flows, journal and tags of the position are deleted with it, so they're kept to be restored too.
+		replaced.CashFlows, err = s.cashFlowRepository.ListByPositionIDs(ctx, []uuid.UUID{replaced.ID})
+		if err != nil {
+			return service.ErrInternalServerError, fmt.Errorf("failed to get cash flows of replaced position: %w", err)
+		}
+
+		journalContent, err := s.journalEntryService.GetJournalContentForPosition(ctx, batch.CreatedBy, replaced.ID)
+		if err != nil {
+			return service.ErrInternalServerError, fmt.Errorf("failed to get journal of replaced position: %w", err)
+		}
+		if journalContent != nil {
+			replaced.JournalContent = *journalContent
+		}
+
+		replaced.Tags, err = s.tagRepository.GetTagsByPositionID(ctx, replaced.ID)
+		if err != nil {
+			return service.ErrInternalServerError, fmt.Errorf("failed to get tags of replaced position: %w", err)
+		}
+
+		svcErr, err := s.Delete(ctx, batch.CreatedBy, replaced.ID)
+		if err != nil {
+			return svcErr, fmt.Errorf("failed to delete existing position: %w", err)
+		}
+
+		batch.ReplacedPositions = append(batch.ReplacedPositions, replaced)
+		batch.PositionsReplacedCount++
+	} else {
+		batch.PositionsCreatedCount++
+	}
+
+	pos.ImportBatchID = &batch.ID
+	for _, t := range pos.Trades {
+		if !isExistingTrade[t.ID] {
+			t.ImportBatchID = &batch.ID
+			batch.TradesCount++
+		}
+	}
+
+	err := s.positionRepository.Create(ctx, pos)
+	if err != nil {
+		return service.ErrInternalServerError, err
+	}
+
+	_, err = s.tradeRepository.CreateForPosition(ctx, pos.Trades)
+	if err != nil {
+		return service.ErrInternalServerError, err
+	}
+
+	return service.ErrNone, nil
+}
+
+// finishImportBatch saves the counts and the replaced positions of the batch.
+func (s *Service) finishImportBatch(ctx context.Context, batch *ImportBatch) error {
+	now := time.Now().UTC()
+	batch.UpdatedAt = &now
+
+	if err := s.positionRepository.UpdateImportBatch(ctx, batch); err != nil {
+		return fmt.Errorf("update import batch: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) ListImportBatches(ctx context.Context, userID uuid.UUID, userBrokerAccountID *uuid.UUID) ([]*ImportBatch, service.Error, error) {
+	batches, err := s.positionRepository.ListImportBatches(ctx, userID, userBrokerAccountID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("list import batches: %w", err)
+	}
+
+	return batches, service.ErrNone, nil
+}
+
+var (
+	errImportBatchRolledBack = errors.New("Import has been rolled back already")
+	errImportBatchReplaced   = errors.New("A later import replaced positions of this import. Roll it back first.")
+)
+
+// laterReplacingBatch returns the batch of the broker account, that isn't rolled back, which replaced
+// positions of the batch, or nil if no later batch touched them.
+func laterReplacingBatch(batch *ImportBatch, batches []*ImportBatch) *ImportBatch {
+	for _, b := range batches {
+		if b.ID == batch.ID || b.RolledBackAt != nil {
+			continue
+		}
+
+		for _, pos := range b.ReplacedPositions {
+			if pos.ImportBatchID != nil && *pos.ImportBatchID == batch.ID {
+				return b
+			}
+		}
+	}
+
+	return nil
+}
+
+// RollbackImportBatch deletes the positions the batch created and restores the ones it replaced, with their
+// trades, cash flows, journals and tags. A batch can't be rolled back while a later one that replaced its
+// positions isn't rolled back, as that one has the trades of the batch in its positions.
+func (s *Service) RollbackImportBatch(ctx context.Context, userID, batchID uuid.UUID) (*ImportBatch, service.Error, error) {
+	l := logger.FromCtx(ctx)
+
+	batch, err := s.positionRepository.GetImportBatch(ctx, userID, batchID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Import not found")
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("get import batch: %w", err)
+	}
+
+	if batch.RolledBackAt != nil {
+		return nil, service.ErrConflict, errImportBatchRolledBack
+	}
+
+	batches, err := s.positionRepository.ListImportBatches(ctx, userID, &batch.UserBrokerAccountID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("list import batches: %w", err)
+	}
+
+	if laterReplacingBatch(batch, batches) != nil {
+		return nil, service.ErrConflict, errImportBatchReplaced
+	}
+
+	positions, _, err := s.positionRepository.Search(ctx, SearchPayload{
+		Filters: SearchFilter{CreatedBy: &userID, ImportBatchID: &batch.ID},
+	}, false, false)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("search positions of import batch: %w", err)
+	}
+
+	svcErr := service.ErrInternalServerError
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		for _, pos := range positions {
+			deleteSvcErr, err := s.Delete(ctx, userID, pos.ID)
+			if err != nil {
+				svcErr = deleteSvcErr
+				return fmt.Errorf("delete position of import batch: %w", err)
+			}
+		}
+
+		for _, pos := range batch.ReplacedPositions {
+			if err := s.restoreReplacedPosition(ctx, userID, pos); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+		batch.RolledBackAt = &now
+		batch.UpdatedAt = &now
+
+		if err := s.positionRepository.UpdateImportBatch(ctx, batch); err != nil {
+			return fmt.Errorf("update import batch: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	l.Infow("rolled back import batch", "import_batch_id", batch.ID, "deleted", len(positions), "restored", len(batch.ReplacedPositions))
+
+	return batch, service.ErrNone, nil
+}
+
+// restoreReplacedPosition creates a position a batch replaced again, with its trades, cash flows, journal and tags.
+// The tags deleted since aren't attached.
+func (s *Service) restoreReplacedPosition(ctx context.Context, userID uuid.UUID, pos *Position) error {
+	if err := s.positionRepository.Create(ctx, pos); err != nil {
+		return fmt.Errorf("restore replaced position: %w", err)
+	}
+
+	if _, err := s.tradeRepository.CreateForPosition(ctx, pos.Trades); err != nil {
+		return fmt.Errorf("restore trades of replaced position: %w", err)
+	}
+
+	for _, cf := range pos.CashFlows {
+		if err := s.cashFlowRepository.Create(ctx, cf); err != nil {
+			return fmt.Errorf("restore cash flow of replaced position: %w", err)
+		}
+	}
+
+	if len(pos.JournalContent) > 0 {
+		if _, err := s.journalEntryService.UpsertForPosition(ctx, userID, pos.ID, pos.JournalContent); err != nil {
+			return fmt.Errorf("restore journal of replaced position: %w", err)
+		}
+	}
+
+	if len(pos.Tags) == 0 {
+		return nil
+	}
+
+	tagIDs := make([]uuid.UUID, len(pos.Tags))
+	for i, t := range pos.Tags {
+		tagIDs[i] = t.ID
+	}
+
+	tags, err := s.tagRepository.GetTagsByIDs(ctx, tagIDs)
+	if err != nil {
+		return fmt.Errorf("get tags of replaced position: %w", err)
+	}
+
+	tagIDs = tagIDs[:0]
+	for _, t := range tags {
+		tagIDs = append(tagIDs, t.ID)
+	}
+
+	if _, err := s.tagService.AttachTagToPosition(ctx, tag.AttachTagToPositionPayload{PositionID: pos.ID, TagIDs: tagIDs}); err != nil {
+		return fmt.Errorf("restore tags of replaced position: %w", err)
+	}
+
+	return nil
+}
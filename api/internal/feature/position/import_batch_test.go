@@ -0,0 +1,64 @@
+package position
+
+import (
+	"arthveda/internal/feature/broker"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewImportBatch(t *testing.T) {
+	payload := ImportPayload{
+		UserID:              uuid.New(),
+		UserBrokerAccountID: uuid.New(),
+		Broker:              &broker.Broker{ID: uuid.New()},
+		FileName:            "tradebook.xlsx",
+		Sheet:               "Equity",
+	}
+
+	batch, err := newImportBatch(payload, ImportBatchSourceFile)
+	if err != nil {
+		t.Fatalf("newImportBatch: %v", err)
+	}
+
+	if batch.CreatedBy != payload.UserID || batch.UserBrokerAccountID != payload.UserBrokerAccountID || batch.BrokerID != payload.Broker.ID {
+		t.Errorf("batch isn't of the payload's user and broker account: %+v", batch)
+	}
+
+	if batch.FileName == nil || *batch.FileName != "tradebook.xlsx" || batch.Sheet == nil || *batch.Sheet != "Equity" {
+		t.Errorf("expected file name and sheet, got %v and %v", batch.FileName, batch.Sheet)
+	}
+
+	if batch.ReplacedPositions == nil {
+		t.Errorf("expected replaced positions to be empty, not nil, to be saved as a JSON array")
+	}
+
+	payload.FileName, payload.Sheet = "", ""
+	batch, err = newImportBatch(payload, ImportBatchSourceSync)
+	if err != nil {
+		t.Fatalf("newImportBatch: %v", err)
+	}
+
+	if batch.FileName != nil || batch.Sheet != nil {
+		t.Errorf("expected no file name and sheet for a sync, got %v and %v", batch.FileName, batch.Sheet)
+	}
+}
+
+func TestLaterReplacingBatch(t *testing.T) {
+	batch := &ImportBatch{ID: uuid.New()}
+	other := uuid.New()
+	rolledBackAt := time.Now()
+
+	later := &ImportBatch{ID: uuid.New(), ReplacedPositions: []*Position{{ImportBatchID: &other}, {ImportBatchID: &batch.ID}}}
+	rolledBack := &ImportBatch{ID: uuid.New(), ReplacedPositions: []*Position{{ImportBatchID: &batch.ID}}, RolledBackAt: &rolledBackAt}
+	untouched := &ImportBatch{ID: uuid.New(), ReplacedPositions: []*Position{{ImportBatchID: &other}, {}}}
+
+	if got := laterReplacingBatch(batch, []*ImportBatch{untouched, rolledBack, batch}); got != nil {
+		t.Errorf("expected no batch to have replaced positions of the batch, got %s", got.ID)
+	}
+
+	if got := laterReplacingBatch(batch, []*ImportBatch{untouched, later, rolledBack, batch}); got != later {
+		t.Errorf("expected the later batch that replaced positions of the batch, got %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package position
+
+import (
+	"arthveda/internal/domain/types"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ImportRowIssue is a row of an import file that no trade was imported from, and why.
+type ImportRowIssue struct {
+	Sheet string `json:"sheet,omitempty"`
+
+	// The 1-based number of the row, as spreadsheet apps show it.
+	RowNumber int      `json:"row_number"`
+	Row       []string `json:"row"`
+	Reason    string   `json:"reason"`
+}
+
+// ImportMergedOrder is an order that was executed as many trades, which are imported as one trade
+// at their average price.
+type ImportMergedOrder struct {
+	Sheet        string          `json:"sheet,omitempty"`
+	OrderID      string          `json:"order_id"`
+	Symbol       string          `json:"symbol"`
+	TradeKind    types.TradeKind `json:"trade_kind"`
+	RowNumbers   []int           `json:"row_numbers"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	AveragePrice decimal.Decimal `json:"average_price"`
+}
+
+// ImportPositionAction is what an import does, or would do if confirmed, with a position.
+type ImportPositionAction string
+
+const (
+	ImportPositionActionCreate      ImportPositionAction = "create"      // A new position.
+	ImportPositionActionUpdate      ImportPositionAction = "update"      // New trades of a position open in Arthveda, with Force.
+	ImportPositionActionReplace     ImportPositionAction = "replace"     // A duplicate of a position in Arthveda that replaces it, with Force.
+	ImportPositionActionDuplicate   ImportPositionAction = "duplicate"   // A duplicate of, or new trades of, a position in Arthveda. Skipped.
+	ImportPositionActionInvalid     ImportPositionAction = "invalid"     // Its trades don't make a valid position. Skipped.
+	ImportPositionActionUnsupported ImportPositionAction = "unsupported" // The broker's imports don't support its Instrument. Skipped.
+)
+
+// ImportPositionDiff is a position of an import, and what the import does with it.
+type ImportPositionDiff struct {
+	PositionID  uuid.UUID            `json:"position_id"`
+	Symbol      string               `json:"symbol"`
+	Instrument  types.Instrument     `json:"instrument"`
+	Action      ImportPositionAction `json:"action"`
+	OpenedAt    time.Time            `json:"opened_at"`
+	TradesCount int                  `json:"trades_count"`
+}
+
+func newImportPositionDiff(pos *Position, action ImportPositionAction) ImportPositionDiff {
+	return ImportPositionDiff{
+		PositionID:  pos.ID,
+		Symbol:      pos.Symbol,
+		Instrument:  pos.Instrument,
+		Action:      action,
+		OpenedAt:    pos.OpenedAt,
+		TradesCount: len(pos.Trades),
+	}
+}
+
+// importRow is a parsed row of an import file with its 1-based row number.
+type importRow struct {
+	number int
+	trade  *types.ImportableTrade
+}
+
+// getMergedOrders returns the orders of which many rows were merged into one trade, in the order of their first row.
+func getMergedOrders(rows []importRow, sheet string) []ImportMergedOrder {
+	var orderIDs []string
+	rowsByOrderID := map[string][]importRow{}
+
+	for _, row := range rows {
+		if _, ok := rowsByOrderID[row.trade.OrderID]; !ok {
+			orderIDs = append(orderIDs, row.trade.OrderID)
+		}
+		rowsByOrderID[row.trade.OrderID] = append(rowsByOrderID[row.trade.OrderID], row)
+	}
+
+	mergedOrders := []ImportMergedOrder{}
+	for _, orderID := range orderIDs {
+		orderRows := rowsByOrderID[orderID]
+		if len(orderRows) < 2 {
+			continue
+		}
+
+		order := ImportMergedOrder{
+			Sheet:     sheet,
+			OrderID:   orderID,
+			Symbol:    orderRows[0].trade.Symbol,
+			TradeKind: orderRows[0].trade.TradeKind,
+		}
+
+		totalPrice := decimal.Zero
+		for _, row := range orderRows {
+			order.RowNumbers = append(order.RowNumbers, row.number)
+			order.Quantity = order.Quantity.Add(row.trade.Quantity)
+			totalPrice = totalPrice.Add(row.trade.Price.Mul(row.trade.Quantity))
+		}
+
+		if order.Quantity.IsPositive() {
+			order.AveragePrice = totalPrice.Div(order.Quantity)
+		}
+
+		mergedOrders = append(mergedOrders, order)
+	}
+
+	return mergedOrders
+}
+
+// sortImportPositionDiffs sorts the diffs by the time the positions were opened, the latest first,
+// as the positions of an ImportResult are.
+func sortImportPositionDiffs(diffs []ImportPositionDiff) {
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].OpenedAt.After(diffs[j].OpenedAt)
+	})
+}
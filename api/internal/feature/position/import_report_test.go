@@ -0,0 +1,39 @@
+package position
+
+import (
+	"arthveda/internal/domain/types"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGetMergedOrders(t *testing.T) {
+	row := func(number int, orderID string, qty, price string) importRow {
+		return importRow{number: number, trade: &types.ImportableTrade{
+			Symbol:    "INFY",
+			TradeKind: types.TradeKindBuy,
+			OrderID:   orderID,
+			Quantity:  decimal.RequireFromString(qty),
+			Price:     decimal.RequireFromString(price),
+		}}
+	}
+
+	orders := getMergedOrders([]importRow{
+		row(2, "A", "10", "100"),
+		row(3, "B", "5", "200"),
+		row(4, "A", "30", "104"),
+	}, "Equity")
+
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 merged order, got %d", len(orders))
+	}
+
+	order := orders[0]
+	if order.OrderID != "A" || order.Sheet != "Equity" || len(order.RowNumbers) != 2 || order.RowNumbers[0] != 2 || order.RowNumbers[1] != 4 {
+		t.Errorf("unexpected merged order %+v", order)
+	}
+
+	if !order.Quantity.Equal(decimal.NewFromInt(40)) || !order.AveragePrice.Equal(decimal.NewFromInt(103)) {
+		t.Errorf("expected 40 at 103, got %s at %s", order.Quantity, order.AveragePrice)
+	}
+}
@@ -0,0 +1,163 @@
+package position_test
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"arthveda/internal/feature/charge"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/trade"
+	"arthveda/internal/feature/userbrokeraccount"
+	"arthveda/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The fakes implement only what a dry run of Import reads. The embedded interfaces are nil,
+// so anything else panics.
+
+// fakePositionRepository has the open positions of the broker account.
+type fakePositionRepository struct {
+	position.ReadWriter
+	positions []*position.Position
+}
+
+func (r fakePositionRepository) Search(ctx context.Context, payload position.SearchPayload, attachTrades, attachTags bool) ([]*position.Position, int, error) {
+	return r.positions, len(r.positions), nil
+}
+
+type fakeTradeRepository struct{ trade.ReadWriter }
+
+func (fakeTradeRepository) GetAllBrokerTradeIDs(ctx context.Context, userID, brokerID *uuid.UUID) (map[string]uuid.UUID, error) {
+	return map[string]uuid.UUID{}, nil
+}
+
+type fakeUserBrokerAccountRepository struct{ userbrokeraccount.ReadWriter }
+
+func (fakeUserBrokerAccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*userbrokeraccount.UserBrokerAccount, error) {
+	return nil, repository.ErrNotFound
+}
+
+type fakeCorporateActionRepository []*corporateaction.CorporateAction
+
+func (r fakeCorporateActionRepository) ListBySymbols(ctx context.Context, symbols []string) ([]*corporateaction.CorporateAction, error) {
+	return r, nil
+}
+
+type fakeChargeScheduleRepository struct{}
+
+func (fakeChargeScheduleRepository) List(ctx context.Context) ([]*charge.Schedule, error) {
+	return nil, nil
+}
+
+type fakeUserProfileService struct{}
+
+func (fakeUserProfileService) GetLotMatchingMethod(ctx context.Context, userID uuid.UUID) (position.LotMatchingMethod, error) {
+	return position.LotMatchingMethodFIFO, nil
+}
+
+func newImportService(openPositions []*position.Position, actions []*corporateaction.CorporateAction) *position.Service {
+	return position.NewService(nil, nil, fakePositionRepository{positions: openPositions}, fakeTradeRepository{}, fakeUserBrokerAccountRepository{},
+		nil, nil, nil, nil, nil, nil, fakeCorporateActionRepository(actions), nil, fakeChargeScheduleRepository{}, nil,
+		fakeUserProfileService{})
+}
+
+func TestImport_ReversalBeforeSplit(t *testing.T) {
+	split, err := corporateaction.New("INFY", corporateaction.KindSplit, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), d("2"), d("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both trades are before the 2:1 split, so the position is long 20 restated shares when the sell of 15
+	// comes, which is 10 of the shares of the sell, and the sell reverses it.
+	trades := []*types.ImportableTrade{
+		{Symbol: "INFY", Instrument: types.InstrumentEquity, TradeKind: types.TradeKindBuy, Quantity: d("10"), Price: d("100"), Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), OrderID: "1"},
+		{Symbol: "INFY", Instrument: types.InstrumentEquity, TradeKind: types.TradeKindSell, Quantity: d("15"), Price: d("110"), Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), OrderID: "2"},
+	}
+
+	s := newImportService(nil, []*corporateaction.CorporateAction{split})
+
+	result, _, err := s.Import(context.Background(), trades, position.ImportPayload{
+		UserID:                   uuid.New(),
+		UserBrokerAccountID:      uuid.New(),
+		Broker:                   &broker.Broker{ID: uuid.New(), Name: broker.BrokerNameZerodha},
+		ChargesCalculationMethod: position.ChargesCalculationMethodManual,
+	})
+	if err != nil {
+		t.Fatalf("Import: %s", err)
+	}
+
+	if len(result.InvalidPositions) != 0 {
+		t.Fatalf("expected no invalid positions, got %d", len(result.InvalidPositions))
+	}
+
+	if len(result.Positions) != 2 {
+		t.Fatalf("expected the sell to close the position and open another, got %d positions", len(result.Positions))
+	}
+
+	// The positions are sorted by the time they were opened, latest first.
+	reversed, closed := result.Positions[0], result.Positions[1]
+
+	if closed.Status == position.StatusOpen || len(closed.Trades) != 2 || !closed.Trades[1].Quantity.Equal(d("10")) {
+		t.Errorf("expected the position to be closed by 10 shares of the sell, got %s with %d trades", closed.Status, len(closed.Trades))
+	}
+
+	if reversed.Direction != position.DirectionShort || len(reversed.Trades) != 1 || !reversed.Trades[0].Quantity.Equal(d("5")) {
+		t.Errorf("expected a short position with the 5 shares left of the sell, got %s with %d trades", reversed.Direction, len(reversed.Trades))
+	}
+
+	if !reversed.OpenQuantity.Equal(d("10")) {
+		t.Errorf("expected the short position to have 10 shares open after the split, got %s", reversed.OpenQuantity)
+	}
+}
+
+func TestImport_ReversalOfInvalidPosition(t *testing.T) {
+	// The stop-loss without a planned entry price makes the position fail to compute.
+	stopLoss := d("90")
+	open := &position.Position{
+		ID:                         uuid.New(),
+		Symbol:                     "INFY",
+		Instrument:                 types.InstrumentEquity,
+		Status:                     position.StatusOpen,
+		Direction:                  position.DirectionLong,
+		OpenedAt:                   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		OpenQuantity:               d("10"),
+		EffectiveLotMatchingMethod: position.LotMatchingMethodFIFO,
+		Plan:                       position.Plan{StopLossPrice: &stopLoss},
+		Trades: []*trade.Trade{
+			{Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("100"), Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	trades := []*types.ImportableTrade{
+		{Symbol: "INFY", Instrument: types.InstrumentEquity, TradeKind: types.TradeKindSell, Quantity: d("15"), Price: d("110"), Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), OrderID: "2"},
+	}
+
+	s := newImportService([]*position.Position{open}, nil)
+
+	result, _, err := s.Import(context.Background(), trades, position.ImportPayload{
+		UserID:                   uuid.New(),
+		UserBrokerAccountID:      uuid.New(),
+		Broker:                   &broker.Broker{ID: uuid.New(), Name: broker.BrokerNameZerodha},
+		ChargesCalculationMethod: position.ChargesCalculationMethodManual,
+	})
+	if err != nil {
+		t.Fatalf("Import: %s", err)
+	}
+
+	if len(result.Positions) != 0 {
+		t.Errorf("expected no position to be opened with the rest of the sell, got %d", len(result.Positions))
+	}
+
+	if len(result.InvalidPositions) != 1 || result.InvalidPositions[0].ID != open.ID {
+		t.Fatalf("expected the open position to be invalid, got %d invalid positions", len(result.InvalidPositions))
+	}
+
+	invalid := result.InvalidPositions[0]
+	if len(invalid.Trades) != 2 || !invalid.Trades[1].Quantity.Equal(d("15")) {
+		t.Errorf("expected the invalid position to have all of the sell, got %d trades", len(invalid.Trades))
+	}
+}
@@ -0,0 +1,109 @@
+package position
+
+import (
+	"arthveda/internal/domain/price"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// OpenSymbols returns the distinct symbols of the open Positions.
+func OpenSymbols(positions []*Position) []string {
+	seen := map[string]bool{}
+	symbols := []string{}
+
+	for _, p := range positions {
+		if p.Status != StatusOpen || seen[p.Symbol] {
+			continue
+		}
+		seen[p.Symbol] = true
+		symbols = append(symbols, p.Symbol)
+	}
+
+	return symbols
+}
+
+// MarkToMarket values the open quantity of the open Positions at the quotes of their symbols.
+// The realised PnL of a Position is left untouched, the unrealised PnL is set separately.
+func MarkToMarket(positions []*Position, quotes map[string]price.Quote) {
+	for _, p := range positions {
+		p.LastPriceAmount = nil
+		p.UnrealisedPnLAmount = nil
+
+		if p.Status != StatusOpen || p.OpenQuantity.IsZero() {
+			continue
+		}
+
+		q, ok := quotes[p.Symbol]
+		if !ok {
+			q, ok = quotes[strings.ToUpper(p.Symbol)]
+		}
+		if !ok {
+			continue
+		}
+
+		fx := p.FxRate
+		if !fx.IsPositive() {
+			fx = decimal.NewFromInt(1)
+		}
+
+		lastPrice := q.Price
+		unrealised := lastPrice.Sub(p.OpenAveragePriceAmount).Mul(p.OpenQuantity).Mul(directionSignDecimal(p.Direction)).Mul(fx)
+
+		p.LastPriceAmount = &lastPrice
+		p.UnrealisedPnLAmount = &unrealised
+	}
+}
+
+type OpenExposure struct {
+	OpenPositionsCount int `json:"open_positions_count"`
+	// The open Positions that have a quote. Only these are included in the market value and unrealised PnL.
+	MarkedPositionsCount int `json:"marked_positions_count"`
+
+	// The cost of the open quantity of all the open Positions.
+	CostAmount decimal.Decimal `json:"cost_amount"`
+	// The value of the open quantity of the marked Positions at their last price.
+	MarketValueAmount   decimal.Decimal `json:"market_value_amount"`
+	UnrealisedPnLAmount decimal.Decimal `json:"unrealised_pnl_amount"`
+
+	LongCostAmount  decimal.Decimal `json:"long_cost_amount"`
+	ShortCostAmount decimal.Decimal `json:"short_cost_amount"`
+}
+
+// GetOpenExposure summarises the open Positions. The Positions must be marked to market first.
+// All the amounts are in the home currency.
+func GetOpenExposure(positions []*Position) OpenExposure {
+	exposure := OpenExposure{}
+
+	for _, p := range positions {
+		if p.Status != StatusOpen {
+			continue
+		}
+
+		exposure.OpenPositionsCount++
+
+		fx := p.FxRate
+		if !fx.IsPositive() {
+			fx = decimal.NewFromInt(1)
+		}
+
+		cost := p.OpenAveragePriceAmount.Mul(p.OpenQuantity).Mul(fx)
+		exposure.CostAmount = exposure.CostAmount.Add(cost)
+
+		if p.Direction == DirectionShort {
+			exposure.ShortCostAmount = exposure.ShortCostAmount.Add(cost)
+		} else {
+			exposure.LongCostAmount = exposure.LongCostAmount.Add(cost)
+		}
+
+		if p.LastPriceAmount == nil || p.UnrealisedPnLAmount == nil {
+			continue
+		}
+
+		exposure.MarkedPositionsCount++
+		exposure.MarketValueAmount = exposure.MarketValueAmount.Add(p.LastPriceAmount.Mul(p.OpenQuantity).Mul(fx))
+		exposure.UnrealisedPnLAmount = exposure.UnrealisedPnLAmount.Add(*p.UnrealisedPnLAmount)
+	}
+
+	return exposure
+}
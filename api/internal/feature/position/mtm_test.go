@@ -0,0 +1,57 @@
+package position
+
+import (
+	"arthveda/internal/domain/price"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMarkToMarket(t *testing.T) {
+	d := decimal.RequireFromString
+
+	long := &Position{Symbol: "ABC", Direction: DirectionLong, Status: StatusOpen, OpenQuantity: d("10"), OpenAveragePriceAmount: d("100"), FxRate: d("1")}
+	short := &Position{Symbol: "XYZ", Direction: DirectionShort, Status: StatusOpen, OpenQuantity: d("5"), OpenAveragePriceAmount: d("50"), FxRate: d("2")}
+	unquoted := &Position{Symbol: "NOQUOTE", Direction: DirectionLong, Status: StatusOpen, OpenQuantity: d("1"), OpenAveragePriceAmount: d("10")}
+	closed := &Position{Symbol: "ABC", Direction: DirectionLong, Status: StatusWin}
+
+	positions := []*Position{long, short, unquoted, closed}
+
+	quotes := map[string]price.Quote{
+		"ABC": {Price: d("110")},
+		"XYZ": {Price: d("55")},
+	}
+
+	MarkToMarket(positions, quotes)
+
+	if long.UnrealisedPnLAmount == nil || !long.UnrealisedPnLAmount.Equal(d("100")) {
+		t.Errorf("expected long unrealised PnL 100, got %v", long.UnrealisedPnLAmount)
+	}
+
+	// Short loses when the price goes up, and the PnL is converted with the fx rate.
+	if short.UnrealisedPnLAmount == nil || !short.UnrealisedPnLAmount.Equal(d("-50")) {
+		t.Errorf("expected short unrealised PnL -50, got %v", short.UnrealisedPnLAmount)
+	}
+
+	if unquoted.UnrealisedPnLAmount != nil || closed.UnrealisedPnLAmount != nil {
+		t.Errorf("expected unquoted and closed positions to not be marked")
+	}
+
+	exposure := GetOpenExposure(positions)
+
+	if exposure.OpenPositionsCount != 3 || exposure.MarkedPositionsCount != 2 {
+		t.Errorf("expected 3 open and 2 marked positions, got %d and %d", exposure.OpenPositionsCount, exposure.MarkedPositionsCount)
+	}
+
+	if !exposure.CostAmount.Equal(d("1510")) {
+		t.Errorf("expected cost 1510, got %s", exposure.CostAmount)
+	}
+
+	if !exposure.MarketValueAmount.Equal(d("1650")) {
+		t.Errorf("expected market value 1650, got %s", exposure.MarketValueAmount)
+	}
+
+	if !exposure.UnrealisedPnLAmount.Equal(d("50")) {
+		t.Errorf("expected unrealised PnL 50, got %s", exposure.UnrealisedPnLAmount)
+	}
+}
@@ -2,12 +2,17 @@ package position_test
 
 import (
 	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/broker"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/charge"
+	"arthveda/internal/feature/corporateaction"
 	"arthveda/internal/feature/position"
 	"arthveda/internal/feature/trade"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
@@ -66,3 +71,763 @@ func TestComputeSmartTrades_LongPosition(t *testing.T) {
 		t.Errorf("expected NetReturnPercentage 16.14, got %s", res.NetReturnPercentage.StringFixed(2))
 	}
 }
+
+func TestComputeSmartTrades_LotMatchingMethods(t *testing.T) {
+	newTrades := func() []*trade.Trade {
+		return []*trade.Trade{
+			{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100")},
+			{Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("50"), Price: d("110")},
+			{Time: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("80"), Price: d("120")},
+			{Time: time.Date(2024, 1, 4, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("70"), Price: d("115")},
+			{Time: time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("90"), Price: d("125")},
+			{Time: time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("50"), Price: d("130")},
+		}
+	}
+
+	tests := []struct {
+		method              position.LotMatchingMethod
+		expectedRealisedPnL []string // Realised gross PnL of the 3 sell trades.
+	}{
+		{position.LotMatchingMethodFIFO, []string{"1600.00", "1450.00", "750.00"}},
+		{position.LotMatchingMethodLIFO, []string{"1100.00", "1200.00", "1500.00"}},
+		{position.LotMatchingMethodWeightedAverage, []string{"1333.33", "1425.00", "1041.67"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.method), func(t *testing.T) {
+			trades := newTrades()
+
+			_, err := position.ComputeSmartTrades(trades, position.DirectionLong, decimal.Zero, tc.method)
+			if err != nil {
+				t.Fatalf("position.ComputeSmartTrades: %s", err)
+			}
+
+			sells := []*trade.Trade{trades[2], trades[4], trades[5]}
+			total := decimal.Zero
+
+			for i, sell := range sells {
+				total = total.Add(sell.RealisedGrossPnL)
+
+				if !sell.RealisedGrossPnL.Round(2).Equal(d(tc.expectedRealisedPnL[i])) {
+					t.Errorf("sell %d: expected realised PnL %s, got %s", i, tc.expectedRealisedPnL[i], sell.RealisedGrossPnL.StringFixed(2))
+				}
+			}
+
+			// The total PnL of a closed position doesn't depend on the lot-matching method.
+			if !total.Round(2).Equal(d("3800.00")) {
+				t.Errorf("expected total realised PnL 3800.00, got %s", total.StringFixed(2))
+			}
+		})
+	}
+}
+
+func TestCompute_OpenAveragePriceByLotMatchingMethod(t *testing.T) {
+	trades := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100")},
+		{Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("50"), Price: d("110")},
+		{Time: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("80"), Price: d("120")},
+	}
+
+	tests := []struct {
+		method               position.LotMatchingMethod
+		expectedOpenAvgPrice string
+		expectedGrossPnL     string
+	}{
+		{position.LotMatchingMethodFIFO, "107.14", "1600.00"},
+		{position.LotMatchingMethodLIFO, "100.00", "1100.00"},
+		{position.LotMatchingMethodWeightedAverage, "103.33", "1333.33"},
+		{"", "107.14", "1600.00"}, // Defaults to FIFO.
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.method), func(t *testing.T) {
+			res, err := position.Compute(position.ComputePayload{Trades: trades, LotMatchingMethod: tc.method})
+			if err != nil {
+				t.Fatalf("position.Compute: %s", err)
+			}
+
+			if res.Status != position.StatusOpen {
+				t.Errorf("expected status %s, got %s", position.StatusOpen, res.Status)
+			}
+
+			if !res.OpenQuantity.Equal(d("70")) {
+				t.Errorf("expected open quantity 70, got %s", res.OpenQuantity.String())
+			}
+
+			if !res.OpenAveragePriceAmount.Round(2).Equal(d(tc.expectedOpenAvgPrice)) {
+				t.Errorf("expected open average price %s, got %s", tc.expectedOpenAvgPrice, res.OpenAveragePriceAmount.StringFixed(2))
+			}
+
+			if !res.GrossPnLAmount.Round(2).Equal(d(tc.expectedGrossPnL)) {
+				t.Errorf("expected gross PnL %s, got %s", tc.expectedGrossPnL, res.GrossPnLAmount.StringFixed(2))
+			}
+		})
+	}
+}
+
+func TestCompute_TradeReversesPosition(t *testing.T) {
+	trades := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100")},
+		{Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("150"), Price: d("110")},
+	}
+
+	_, err := position.Compute(position.ComputePayload{Trades: trades})
+	if err != position.ErrTradeReversesPosition {
+		t.Fatalf("expected %v, got %v", position.ErrTradeReversesPosition, err)
+	}
+}
+
+func TestSplitTradeOnReversal(t *testing.T) {
+	sell := trade.CreatePayload{
+		Time:          time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		Kind:          types.TradeKindSell,
+		Quantity:      d("150"),
+		Price:         d("110"),
+		ChargesAmount: d("30"),
+	}
+
+	closing, reversal := position.SplitTradeOnReversal(sell, position.DirectionLong, d("100"))
+	if reversal == nil {
+		t.Fatalf("expected the trade to reverse the position")
+	}
+
+	if !closing.Quantity.Equal(d("100")) || !closing.ChargesAmount.Equal(d("20")) {
+		t.Errorf("expected closing trade of 100 with charges 20, got %s with charges %s", closing.Quantity, closing.ChargesAmount)
+	}
+
+	if !reversal.Quantity.Equal(d("50")) || !reversal.ChargesAmount.Equal(d("10")) {
+		t.Errorf("expected reversal trade of 50 with charges 10, got %s with charges %s", reversal.Quantity, reversal.ChargesAmount)
+	}
+
+	if reversal.Kind != sell.Kind || !reversal.Price.Equal(sell.Price) || !reversal.Time.Equal(sell.Time) {
+		t.Errorf("expected reversal trade to keep the kind, price and time of the trade")
+	}
+
+	closed, err := position.Compute(position.ComputePayload{Trades: []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100")},
+		closing,
+	}})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	if closed.Status == position.StatusOpen || !closed.GrossPnLAmount.Equal(d("1000")) {
+		t.Errorf("expected closed position with gross PnL 1000, got status %s and gross PnL %s", closed.Status, closed.GrossPnLAmount)
+	}
+
+	reversed, err := position.Compute(position.ComputePayload{Trades: []trade.CreatePayload{*reversal}})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	if reversed.Direction != position.DirectionShort || !reversed.OpenQuantity.Equal(d("50")) {
+		t.Errorf("expected short position with open quantity 50, got %s with %s", reversed.Direction, reversed.OpenQuantity)
+	}
+
+	// A trade that doesn't take the position through zero is left alone.
+	closing, reversal = position.SplitTradeOnReversal(sell, position.DirectionLong, d("150"))
+	if reversal != nil || !closing.Quantity.Equal(d("150")) {
+		t.Errorf("expected no reversal for a trade that closes the position exactly")
+	}
+}
+
+func TestSplitReversedPosition(t *testing.T) {
+	split, err := corporateaction.New("INFY", corporateaction.KindSplit, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), d("5"), d("1"))
+	if err != nil {
+		t.Fatalf("corporateaction.New: %s", err)
+	}
+
+	orderID := "1001"
+	pos := &position.Position{
+		ID:               uuid.New(),
+		CreatedBy:        uuid.New(),
+		Symbol:           "INFY",
+		Instrument:       types.InstrumentEquity,
+		FxRate:           d("1"),
+		CorporateActions: []*corporateaction.CorporateAction{split},
+	}
+
+	for _, payload := range []trade.CreatePayload{
+		// 10 shares before the split are 50 after it, so selling 80 goes 30 short.
+		{Time: time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("1000")},
+		{Time: time.Date(2024, 4, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("80"), Price: d("210"), ChargesAmount: d("16"), BrokerTradeID: &orderID},
+		{Time: time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("200")},
+	} {
+		payload.PositionID = pos.ID
+		tr, err := trade.New(payload)
+		if err != nil {
+			t.Fatalf("trade.New: %s", err)
+		}
+		pos.Trades = append(pos.Trades, tr)
+	}
+
+	if _, err := position.Compute(pos.ComputePayload()); err != position.ErrTradeReversesPosition {
+		t.Fatalf("expected the position to reverse, got %v", err)
+	}
+
+	reversedPositions, err := position.SplitReversedPosition(pos)
+	if err != nil {
+		t.Fatalf("position.SplitReversedPosition: %s", err)
+	}
+
+	if len(reversedPositions) != 1 {
+		t.Fatalf("expected 1 reversed position, got %d", len(reversedPositions))
+	}
+
+	if len(pos.Trades) != 2 || !pos.Trades[1].Quantity.Equal(d("50")) || !pos.Trades[1].ChargesAmount.Equal(d("10")) {
+		t.Fatalf("expected the position to keep the buy and a sell of 50 with charges 10, got %d trades", len(pos.Trades))
+	}
+
+	closed, err := position.Compute(pos.ComputePayload())
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	if closed.Status == position.StatusOpen || !closed.GrossPnLAmount.Equal(d("500")) {
+		t.Errorf("expected closed position with gross PnL 500, got status %s and gross PnL %s", closed.Status, closed.GrossPnLAmount)
+	}
+
+	reversed := reversedPositions[0]
+	if reversed.Direction != position.DirectionShort || !reversed.OpenQuantity.Equal(d("20")) || len(reversed.Trades) != 2 {
+		t.Errorf("expected short position with open quantity 20 and 2 trades, got %s with %s and %d trades", reversed.Direction, reversed.OpenQuantity, len(reversed.Trades))
+	}
+
+	if id := reversed.Trades[0].BrokerTradeID; id == nil || *id != orderID+":reversal" {
+		t.Errorf("expected the reversal trade to have broker trade ID %s:reversal, got %v", orderID, id)
+	}
+
+	// A position that doesn't reverse is left alone.
+	reversedPositions, err = position.SplitReversedPosition(pos)
+	if err != nil || len(reversedPositions) != 0 {
+		t.Errorf("expected no reversed positions, got %d and error %v", len(reversedPositions), err)
+	}
+}
+
+func TestApplyComputeResultToPosition_RealisedStatsOfTrades(t *testing.T) {
+	payloads := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100"), ChargesAmount: d("40")},
+		{Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("100"), Price: d("110"), ChargesAmount: d("60")},
+	}
+
+	pos := &position.Position{}
+	for _, p := range payloads {
+		tr, err := trade.New(p)
+		if err != nil {
+			t.Fatalf("trade.New: %s", err)
+		}
+		pos.Trades = append(pos.Trades, tr)
+	}
+
+	res, err := position.Compute(position.ComputePayload{Trades: payloads, RiskAmount: d("500")})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	mae, mfe := d("-500"), d("1500")
+	pos.MAEAmount, pos.MFEAmount = &mae, &mfe
+
+	position.ApplyComputeResultToPosition(pos, res)
+
+	if pos.MAEAmount != nil || pos.MFEAmount != nil {
+		t.Errorf("expected the excursion to be cleared, got MAE %v and MFE %v", pos.MAEAmount, pos.MFEAmount)
+	}
+
+	buy, sell := pos.Trades[0], pos.Trades[1]
+
+	if !buy.RealisedGrossPnL.IsZero() || len(buy.MatchedLots) != 0 {
+		t.Errorf("expected no realised stats on the scale-in trade, got gross PnL %s and %d matched lots", buy.RealisedGrossPnL, len(buy.MatchedLots))
+	}
+
+	expected := map[string][2]decimal.Decimal{
+		"realised gross PnL": {d("1000"), sell.RealisedGrossPnL},
+		// The charges of the buy are realised with the sell, so it adds up to the net PnL of the position.
+		"realised net PnL": {res.NetPnLAmount, sell.RealisedNetPnL},
+		"gross ROI":        {d("10"), sell.GrossROI},
+		"gross R":          {d("2"), sell.GrossRFactor},
+		"net R":            {d("1.8"), sell.NetRFactor},
+	}
+
+	for name, v := range expected {
+		if !v[0].Equal(v[1]) {
+			t.Errorf("expected %s %s, got %s", name, v[0], v[1])
+		}
+	}
+
+	if len(sell.MatchedLots) != 1 || !sell.MatchedLots[0].Qty.Equal(d("100")) {
+		t.Errorf("expected one matched lot of 100, got %+v", sell.MatchedLots)
+	}
+}
+
+func TestCompute_Plan(t *testing.T) {
+	entry, stopLoss, target := d("100"), d("95"), d("115")
+
+	trades := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("101")},
+		{Time: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("50"), Price: d("110")},
+		{Time: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("50"), Price: d("112")},
+	}
+
+	plan := position.Plan{PlannedEntryPrice: &entry, StopLossPrice: &stopLoss, TargetPrice: &target}
+
+	// A risk amount that doesn't match the plan isn't overwritten.
+	_, err := position.Compute(position.ComputePayload{Trades: trades, RiskAmount: d("1000"), Plan: plan})
+	if err != position.ErrRiskAmountConflictsWithPlan {
+		t.Errorf("expected ErrRiskAmountConflictsWithPlan, got %v", err)
+	}
+
+	res, err := position.Compute(position.ComputePayload{Trades: trades, Plan: plan})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	// 5 per unit for the 100 units of the opening trade.
+	if !res.RiskAmount.Equal(d("500")) {
+		t.Errorf("expected risk amount 500, got %s", res.RiskAmount)
+	}
+
+	if !res.RFactor.Equal(d("2")) {
+		t.Errorf("expected R factor 2, got %s", res.RFactor)
+	}
+
+	if res.PlannedRFactor == nil || !res.PlannedRFactor.Equal(d("3")) {
+		t.Errorf("expected planned R factor 3, got %v", res.PlannedRFactor)
+	}
+
+	// The average exit of 111 fell 4 short of the target, which is 0.8R.
+	if res.ExitVsPlanRFactor == nil || !res.ExitVsPlanRFactor.Equal(d("-0.8")) {
+		t.Errorf("expected exit vs plan R factor -0.8, got %v", res.ExitVsPlanRFactor)
+	}
+}
+
+func TestCompute_InvalidPlan(t *testing.T) {
+	entry, aboveEntry, belowEntry := d("100"), d("105"), d("95")
+
+	trades := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("100"), Price: d("100")},
+	}
+
+	tests := []struct {
+		name        string
+		plan        position.Plan
+		expectedErr error
+	}{
+		{"stop-loss without entry", position.Plan{StopLossPrice: &belowEntry}, position.ErrPlannedEntryPriceRequired},
+		{"stop-loss above entry", position.Plan{PlannedEntryPrice: &entry, StopLossPrice: &aboveEntry}, position.ErrInvalidStopLossPrice},
+		{"target below entry", position.Plan{PlannedEntryPrice: &entry, TargetPrice: &belowEntry}, position.ErrInvalidTargetPrice},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := position.Compute(position.ComputePayload{Trades: trades, Plan: tc.plan})
+			if err != tc.expectedErr {
+				t.Errorf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCompute_CorporateActions(t *testing.T) {
+	split, err := corporateaction.New("INFY", corporateaction.KindSplit, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), d("5"), d("1"))
+	if err != nil {
+		t.Fatalf("corporateaction.New: %s", err)
+	}
+
+	bonus, err := corporateaction.New("INFY", corporateaction.KindBonus, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), d("1"), d("1"))
+	if err != nil {
+		t.Fatalf("corporateaction.New: %s", err)
+	}
+
+	trades := []trade.CreatePayload{
+		// 10 shares before the split are 50 after it and 100 after the bonus, at 1000 / 5 / 2 = 100.
+		{Time: time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("1000")},
+		// 50 shares between the split and the bonus are 100 after it, at 210 / 2 = 105.
+		{Time: time.Date(2024, 4, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("50"), Price: d("210")},
+		{Time: time.Date(2024, 7, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("150"), Price: d("120")},
+	}
+
+	res, err := position.Compute(position.ComputePayload{
+		Trades:           trades,
+		CorporateActions: []*corporateaction.CorporateAction{split, bonus},
+	})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	if res.Status != position.StatusOpen || !res.OpenQuantity.Equal(d("50")) {
+		t.Errorf("expected an open position with 50 shares, got %s with %s", res.Status, res.OpenQuantity)
+	}
+
+	// FIFO: 100 @ 100 and 50 of the 100 @ 105 are sold at 120.
+	if !res.GrossPnLAmount.Equal(d("2750")) {
+		t.Errorf("expected gross PnL 2750, got %s", res.GrossPnLAmount)
+	}
+
+	if !res.OpenAveragePriceAmount.Equal(d("105")) {
+		t.Errorf("expected open average price 105, got %s", res.OpenAveragePriceAmount)
+	}
+
+	// The capital used is 10 * 1000 + 25 * 210 = 15250, the same as before the adjustments.
+	expectedReturn := d("2750").Div(d("15250")).Mul(d("100"))
+	if !res.NetReturnPercentage.Round(4).Equal(expectedReturn.Round(4)) {
+		t.Errorf("expected net return %s%%, got %s%%", expectedReturn.Round(4), res.NetReturnPercentage.Round(4))
+	}
+
+	// The payload trades are left as they were executed.
+	if !trades[0].Quantity.Equal(d("10")) || !trades[0].Price.Equal(d("1000")) {
+		t.Errorf("expected the payload trades to be unchanged, got %s @ %s", trades[0].Quantity, trades[0].Price)
+	}
+}
+
+func TestCompute_CashFlows(t *testing.T) {
+	trades := []trade.CreatePayload{
+		{Time: time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("100")},
+		{Time: time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), Kind: types.TradeKindSell, Quantity: d("10"), Price: d("98")},
+	}
+
+	cashFlows := []*cashflow.CashFlow{
+		{Kind: cashflow.KindDividend, Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Amount: d("25")},
+		{Kind: cashflow.KindOther, Time: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), Amount: d("-5")},
+	}
+
+	res, err := position.Compute(position.ComputePayload{
+		Trades:    trades,
+		CashFlows: cashFlows,
+	})
+	if err != nil {
+		t.Fatalf("position.Compute: %s", err)
+	}
+
+	if !res.GrossPnLAmount.Equal(d("-20")) {
+		t.Errorf("expected gross PnL -20, got %s", res.GrossPnLAmount)
+	}
+
+	if !res.IncomeAmount.Equal(d("20")) {
+		t.Errorf("expected income 20, got %s", res.IncomeAmount)
+	}
+
+	// The income makes up for the loss of the trades, but it is reported apart from it.
+	if !res.NetPnLAmount.Equal(d("-20")) {
+		t.Errorf("expected net PnL -20, got %s", res.NetPnLAmount)
+	}
+
+	if res.Status != position.StatusLoss {
+		t.Errorf("expected status %s, got %s", position.StatusLoss, res.Status)
+	}
+}
+
+func TestCalculateAndApplyChargesToTrades_Schedules(t *testing.T) {
+	// A schedule from 2025 that doubles the STT on the sale of options.
+	schedule, err := charge.New([]byte(`{
+		"effective_from": "2025-01-01T00:00:00Z",
+		"rates": {
+			"statutory": [{"instrument": "option", "stt_percent_on_sell": 0.2, "gst_percent": 18}],
+			"brokerage": [{"broker": "Zerodha", "instrument": "option", "brokerage": {"min": 20}}]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("charge.New: %s", err)
+	}
+
+	schedules := charge.NewSchedules([]*charge.Schedule{schedule})
+
+	newTrades := func() []*trade.Trade {
+		return []*trade.Trade{
+			// 31 Dec 2024 in IST, before the schedule.
+			{Kind: types.TradeKindSell, Time: time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC), Quantity: d("100"), Price: d("100")},
+			// 1 Jan 2025 in IST, though 31 Dec 2024 in UTC.
+			{Kind: types.TradeKindSell, Time: time.Date(2024, 12, 31, 19, 0, 0, 0, time.UTC), Quantity: d("100"), Price: d("100")},
+		}
+	}
+
+	defaultCharges, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, broker.BrokerNameZerodha, nil, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	charges, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, broker.BrokerNameZerodha, nil, nil, schedules)
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	if !charges[0].Equal(defaultCharges[0]) {
+		t.Errorf("expected the trade before the schedule to have the default charges %s, got %s", defaultCharges[0], charges[0])
+	}
+
+	// 20 brokerage + 0.2% STT on 10000 + 18% GST on the brokerage.
+	if !charges[1].Equal(d("43.6")) {
+		t.Errorf("expected the trade on the schedule's date to have charges 43.6, got %s", charges[1])
+	}
+
+	// The schedule leaves out the futures, so they keep the default rates after it.
+	defaultCharges, _, err = position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentFuture, types.SegmentEquity, broker.BrokerNameZerodha, nil, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	charges, _, err = position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentFuture, types.SegmentEquity, broker.BrokerNameZerodha, nil, nil, schedules)
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	if !charges[1].IsPositive() || !charges[1].Equal(defaultCharges[1]) {
+		t.Errorf("expected the future after the schedule to have the default charges %s, got %s", defaultCharges[1], charges[1])
+	}
+}
+
+func TestNewTrade_ChargesBreakdown(t *testing.T) {
+	breakdown := &trade.ChargesBreakdown{Brokerage: d("20"), STT: d("10.004"), GST: d("3.6")}
+
+	tr, err := trade.New(trade.CreatePayload{Kind: types.TradeKindSell, Quantity: d("100"), Price: d("100"), ChargesAmount: d("33.6"), ChargesBreakdown: breakdown})
+	if err != nil {
+		t.Fatalf("trade.New: %s", err)
+	}
+
+	if tr.ChargesBreakdown == nil {
+		t.Error("expected the breakdown that adds up to the charges within rounding to be kept")
+	}
+
+	// Only the charges amount was edited.
+	for _, amount := range []string{"40", "33.55"} {
+		tr, err = trade.New(trade.CreatePayload{Kind: types.TradeKindSell, Quantity: d("100"), Price: d("100"), ChargesAmount: d(amount), ChargesBreakdown: breakdown})
+		if err != nil {
+			t.Fatalf("trade.New: %s", err)
+		}
+
+		if tr.ChargesBreakdown != nil {
+			t.Errorf("expected the breakdown that doesn't add up to charges of %s to be cleared, got %+v", amount, tr.ChargesBreakdown)
+		}
+	}
+}
+
+func TestCalculateAndApplyChargesToTrades_CommoditySegment(t *testing.T) {
+	trades := []*trade.Trade{
+		{Kind: types.TradeKindSell, Time: time.Date(2025, 3, 10, 5, 0, 0, 0, time.UTC), Quantity: d("100"), Price: d("1000")},
+	}
+
+	charges, _, err := position.CalculateAndApplyChargesToTrades(trades, types.InstrumentFuture, types.SegmentCommodity, broker.BrokerNameZerodha, nil, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	// 20 brokerage + 0.01% CTT + 0.0021% MCX transaction charges + 0.0001% SEBI charges
+	// + 18% GST on the brokerage, MCX and SEBI charges, on 100000.
+	if !charges[0].Equal(d("36.19")) {
+		t.Errorf("expected charges 36.19, got %s", charges[0])
+	}
+
+	breakdown := trades[0].ChargesBreakdown
+	if breakdown == nil {
+		t.Fatal("expected the trade to have a charges breakdown")
+	}
+
+	if !breakdown.Brokerage.Equal(d("20")) || !breakdown.STT.Equal(d("10")) || !breakdown.ExchangeTransaction.Equal(d("2.1")) || !breakdown.GST.Equal(d("3.996")) {
+		t.Errorf("unexpected charges breakdown %+v", *breakdown)
+	}
+
+	if !breakdown.Total().Truncate(2).Equal(charges[0]) {
+		t.Errorf("expected the breakdown to add up to %s, got %s", charges[0], breakdown.Total())
+	}
+}
+
+func TestCalculateAndApplyChargesToTrades_BrokeragePlan(t *testing.T) {
+	newTrades := func() []*trade.Trade {
+		tradeTime := time.Date(2025, 3, 10, 5, 0, 0, 0, time.UTC)
+		return []*trade.Trade{
+			{Kind: types.TradeKindBuy, Time: tradeTime, Quantity: d("50"), Price: d("2000")},
+			{Kind: types.TradeKindSell, Time: tradeTime.Add(time.Hour), Quantity: d("50"), Price: d("2000")},
+		}
+	}
+
+	charges, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentFuture, types.SegmentEquity, broker.BrokerNameOther, nil, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	if !charges[0].IsZero() || !charges[1].IsZero() {
+		t.Errorf("expected no charges without a brokerage plan, got %s and %s", charges[0], charges[1])
+	}
+
+	plan := &charge.BrokeragePlan{Rules: []charge.BrokeragePlanRule{
+		{Instrument: types.InstrumentOption, Kind: charge.BrokeragePlanRuleKindPerLot, Amount: 20, LotSize: 75},
+		{Kind: charge.BrokeragePlanRuleKindPerOrder, Amount: 15},
+	}}
+
+	charges, _, err = position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentFuture, types.SegmentEquity, broker.BrokerNameOther, plan, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	// 15 brokerage per order, with the statutory charges of the default schedule on 100000.
+	if !charges[0].Equal(d("21.95")) {
+		t.Errorf("expected buy charges 21.95, got %s", charges[0])
+	}
+
+	if !charges[1].Equal(d("39.95")) {
+		t.Errorf("expected sell charges 39.95, got %s", charges[1])
+	}
+}
+
+func TestCalculateAndApplyChargesToTrades_DefaultBrokerage(t *testing.T) {
+	newTrades := func() []*trade.Trade {
+		return []*trade.Trade{
+			{Kind: types.TradeKindSell, Time: time.Date(2025, 3, 10, 5, 0, 0, 0, time.UTC), Quantity: d("100"), Price: d("100")},
+		}
+	}
+
+	// Dhan and 5paisa charge a flat 20 an order on options, like Zerodha.
+	zerodha, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, broker.BrokerNameZerodha, nil, nil, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	for _, brokerName := range []broker.Name{broker.BrokerNameDhan, broker.BrokerName5paisa} {
+		charges, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, brokerName, nil, nil, charge.NewSchedules(nil))
+		if err != nil {
+			t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+		}
+
+		if !charges[0].Equal(zerodha[0]) {
+			t.Errorf("expected %s charges %s, got %s", brokerName, zerodha[0], charges[0])
+		}
+	}
+
+	// The brokerage of ICICI Direct depends on the plan, so it's charged like another broker.
+	plan := &charge.BrokeragePlan{Rules: []charge.BrokeragePlanRule{{Kind: charge.BrokeragePlanRuleKindPerOrder, Amount: 20}}}
+
+	for _, p := range []*charge.BrokeragePlan{nil, plan} {
+		other, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, broker.BrokerNameOther, p, nil, charge.NewSchedules(nil))
+		if err != nil {
+			t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+		}
+
+		charges, _, err := position.CalculateAndApplyChargesToTrades(newTrades(), types.InstrumentOption, types.SegmentEquity, broker.BrokerNameICICIDirect, p, nil, charge.NewSchedules(nil))
+		if err != nil {
+			t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+		}
+
+		if !charges[0].Equal(other[0]) {
+			t.Errorf("expected ICICI Direct charges %s, got %s", other[0], charges[0])
+		}
+	}
+}
+
+func TestCalculateAndApplyChargesToTrades_FeeModels(t *testing.T) {
+	tradeTime := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	us := &charge.FeeModel{Kind: charge.FeeModelKindUS}
+
+	stocks := []*trade.Trade{
+		{Kind: types.TradeKindBuy, Time: tradeTime, Quantity: d("1000"), Price: d("100")},
+		{Kind: types.TradeKindSell, Time: tradeTime.Add(time.Hour), Quantity: d("1000"), Price: d("100")},
+	}
+
+	charges, _, err := position.CalculateAndApplyChargesToTrades(stocks, types.InstrumentEquity, types.SegmentEquity, broker.BrokerNameOther, nil, us, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	// 2.78 SEC fee and 0.166 FINRA TAF, rounded up to 0.17, on the sell only.
+	if !charges[0].IsZero() || !charges[1].Equal(d("2.95")) {
+		t.Errorf("expected US stock charges 0 and 2.95, got %s and %s", charges[0], charges[1])
+	}
+
+	plan := &charge.BrokeragePlan{Rules: []charge.BrokeragePlanRule{
+		{Instrument: types.InstrumentOption, Kind: charge.BrokeragePlanRuleKindPerLot, Amount: 0.65, LotSize: 100},
+	}}
+
+	options := []*trade.Trade{
+		{Kind: types.TradeKindSell, Time: tradeTime, Quantity: d("500"), Price: d("2")},
+	}
+
+	charges, _, err = position.CalculateAndApplyChargesToTrades(options, types.InstrumentOption, types.SegmentEquity, broker.BrokerNameOther, plan, us, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	// 5 contracts: 3.25 commission, 0.03 SEC fee + 0.02 TAF + 0.12 ORF, and 0.13 OCC clearing.
+	breakdown := options[0].ChargesBreakdown
+	if !charges[0].Equal(d("3.55")) || !breakdown.Regulatory.Equal(d("0.17")) || !breakdown.Clearing.Equal(d("0.13")) {
+		t.Errorf("expected US option charges 3.55, got %s %+v", charges[0], *breakdown)
+	}
+
+	perShare := &charge.BrokeragePlan{Rules: []charge.BrokeragePlanRule{
+		{Instrument: types.InstrumentEquity, Kind: charge.BrokeragePlanRuleKindPerLot, Amount: 0.0035, LotSize: 1},
+	}}
+
+	buy := []*trade.Trade{{Kind: types.TradeKindBuy, Time: tradeTime, Quantity: d("333"), Price: d("10")}}
+
+	charges, _, err = position.CalculateAndApplyChargesToTrades(buy, types.InstrumentEquity, types.SegmentEquity, broker.BrokerNameOther, perShare, us, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	// A commission of 1.1655 is truncated to the cent, like the charges of the other fee models.
+	if !charges[0].Equal(d("1.16")) {
+		t.Errorf("expected US commission 1.16, got %s", charges[0])
+	}
+
+	crypto := &charge.FeeModel{Kind: charge.FeeModelKindCrypto, MakerPercent: 0.02, TakerPercent: 0.1}
+	newCryptoTrades := func() []*trade.Trade {
+		return []*trade.Trade{{Kind: types.TradeKindBuy, Time: tradeTime, Quantity: d("0.5"), Price: d("60000")}}
+	}
+
+	charges, _, err = position.CalculateAndApplyChargesToTrades(newCryptoTrades(), types.InstrumentCrypto, "", broker.BrokerNameOther, nil, crypto, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	if !charges[0].Equal(d("30")) {
+		t.Errorf("expected crypto taker fee 30, got %s", charges[0])
+	}
+
+	crypto.Liquidity = charge.LiquidityMaker
+	charges, _, err = position.CalculateAndApplyChargesToTrades(newCryptoTrades(), types.InstrumentCrypto, "", broker.BrokerNameOther, nil, crypto, charge.NewSchedules(nil))
+	if err != nil {
+		t.Fatalf("CalculateAndApplyChargesToTrades: %s", err)
+	}
+
+	if !charges[0].Equal(d("6")) {
+		t.Errorf("expected crypto maker fee 6, got %s", charges[0])
+	}
+}
+
+func TestCompute_ProductType(t *testing.T) {
+	// 5 January 2024 is a Friday.
+	opened := time.Date(2024, 1, 5, 4, 0, 0, 0, time.UTC)
+	sameDay, nextTradingDay, twoWeeks, twoMonths := opened.Add(5*time.Hour), opened.AddDate(0, 0, 3), opened.AddDate(0, 0, 14), opened.AddDate(0, 2, 0)
+
+	tests := []struct {
+		name       string
+		instrument types.Instrument
+		closedAt   *time.Time
+		expected   position.ProductType
+	}{
+		{"closed the same day", types.InstrumentEquity, &sameDay, position.ProductTypeIntraday},
+		{"closed on the next trading day", types.InstrumentEquity, &nextTradingDay, position.ProductTypeBTST},
+		{"closed in two weeks", types.InstrumentEquity, &twoWeeks, position.ProductTypeSwing},
+		{"closed in two months", types.InstrumentEquity, &twoMonths, position.ProductTypeDelivery},
+		{"open", types.InstrumentEquity, nil, position.ProductTypeOpen},
+		{"future closed the same day", types.InstrumentFuture, &sameDay, position.ProductTypeIntraday},
+		{"future closed on the next trading day", types.InstrumentFuture, &nextTradingDay, position.ProductTypeCarryForward},
+		{"option closed in two weeks", types.InstrumentOption, &twoWeeks, position.ProductTypeCarryForward},
+		{"open option", types.InstrumentOption, nil, position.ProductTypeOpen},
+	}
+
+	for _, tt := range tests {
+		trades := []trade.CreatePayload{
+			{Time: opened, Kind: types.TradeKindBuy, Quantity: d("10"), Price: d("100")},
+		}
+
+		if tt.closedAt != nil {
+			trades = append(trades, trade.CreatePayload{Time: *tt.closedAt, Kind: types.TradeKindSell, Quantity: d("10"), Price: d("110")})
+		}
+
+		res, err := position.Compute(position.ComputePayload{Trades: trades, RiskAmount: d("100"), Instrument: tt.instrument})
+		if err != nil {
+			t.Fatalf("%s: position.Compute: %s", tt.name, err)
+		}
+
+		if res.ProductType != tt.expected {
+			t.Errorf("%s: expected product type %s, got %s", tt.name, tt.expected, res.ProductType)
+		}
+	}
+}
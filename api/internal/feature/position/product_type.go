@@ -0,0 +1,87 @@
+package position
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/domain/types"
+	"errors"
+	"time"
+)
+
+// ProductType is how long a Position was held, from when it opened until it closed.
+type ProductType string
+
+const (
+	ProductTypeOpen         ProductType = "open"          // Still open, as we don't know yet how long it will be held.
+	ProductTypeIntraday     ProductType = "intraday"      // Closed on the day it opened.
+	ProductTypeBTST         ProductType = "btst"          // Equity closed on the next trading day, also called STBT for shorts.
+	ProductTypeSwing        ProductType = "swing"         // Equity closed within swingMaxDays.
+	ProductTypeDelivery     ProductType = "delivery"      // Equity held longer.
+	ProductTypeCarryForward ProductType = "carry_forward" // A future, option or crypto held overnight, like NRML.
+)
+
+func (p ProductType) IsValid() bool {
+	switch p {
+	case ProductTypeOpen, ProductTypeIntraday, ProductTypeBTST, ProductTypeSwing, ProductTypeDelivery, ProductTypeCarryForward:
+		return true
+	}
+
+	return false
+}
+
+var errInvalidProductType = errors.New("Product type must be one of open, intraday, btst, swing, delivery or carry_forward")
+
+// swingMaxDays is the most calendar days a swing Position is held for.
+const swingMaxDays = 30
+
+// productTypeLocation is where the dates of a Position are taken for its ProductType, UTC if the
+// NSE time zone can't be loaded.
+var productTypeLocation = func() *time.Location {
+	tz, _ := common.GetTimeZoneForExchange(common.ExchangeNSE)
+	loc, err := time.LoadLocation(string(tz))
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}()
+
+// getProductType returns the ProductType of a Position of the instrument opened at `openedAt` and closed
+// at `closedAt`, by their dates in IST like the intraday and delivery charges. Only equity is split into
+// BTST, swing and delivery, the other instruments are intraday or carried forward. Open Positions are
+// ProductTypeOpen until they are closed.
+func getProductType(instrument types.Instrument, openedAt time.Time, closedAt *time.Time) ProductType {
+	if closedAt == nil {
+		return ProductTypeOpen
+	}
+
+	opened := openedAt.In(productTypeLocation)
+	closed := closedAt.In(productTypeLocation)
+
+	openedDay := time.Date(opened.Year(), opened.Month(), opened.Day(), 0, 0, 0, 0, time.UTC)
+	closedDay := time.Date(closed.Year(), closed.Month(), closed.Day(), 0, 0, 0, 0, time.UTC)
+
+	if !closedDay.After(openedDay) {
+		return ProductTypeIntraday
+	}
+
+	// A Position computed without its instrument is taken to be equity.
+	if instrument != types.InstrumentEquity && instrument != "" {
+		return ProductTypeCarryForward
+	}
+
+	// The next trading day of a Friday is the Monday. We don't know the exchange holidays.
+	nextTradingDay := openedDay.AddDate(0, 0, 1)
+	for nextTradingDay.Weekday() == time.Saturday || nextTradingDay.Weekday() == time.Sunday {
+		nextTradingDay = nextTradingDay.AddDate(0, 0, 1)
+	}
+
+	if !closedDay.After(nextTradingDay) {
+		return ProductTypeBTST
+	}
+
+	if !closedDay.After(openedDay.AddDate(0, 0, swingMaxDays)) {
+		return ProductTypeSwing
+	}
+
+	return ProductTypeDelivery
+}
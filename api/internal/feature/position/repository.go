@@ -5,10 +5,13 @@ import (
 	"arthveda/internal/dbx"
 	"arthveda/internal/domain/symbol"
 	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/corporateaction"
 	"arthveda/internal/feature/tag"
 	"arthveda/internal/feature/trade"
 	"arthveda/internal/repository"
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -27,12 +30,17 @@ type Reader interface {
 	TotalPositions(ctx context.Context, userID uuid.UUID) (int, error)
 	// Return the number of currencies used by a user in the logged positions.
 	DistinctCurrenciesUsed(ctx context.Context, userID uuid.UUID) (int, error)
+	GetImportBatch(ctx context.Context, createdBy, importBatchID uuid.UUID) (*ImportBatch, error)
+	// Return the user's import batches, of a broker account if not nil, the latest first.
+	ListImportBatches(ctx context.Context, createdBy uuid.UUID, userBrokerAccountID *uuid.UUID) ([]*ImportBatch, error)
 }
 
 type Writer interface {
 	Create(ctx context.Context, position *Position) error
 	Update(ctx context.Context, position *Position) error
 	Delete(ctx context.Context, positionID uuid.UUID) error
+	CreateImportBatch(ctx context.Context, batch *ImportBatch) error
+	UpdateImportBatch(ctx context.Context, batch *ImportBatch) error
 }
 
 type ReadWriter interface {
@@ -45,13 +53,18 @@ type ReadWriter interface {
 //
 
 type positionRepository struct {
-	db              *pgxpool.Pool
-	tradeRepository trade.ReadWriter
-	tagRepository   tag.ReadWriter
+	db                        *pgxpool.Pool
+	tradeRepository           trade.ReadWriter
+	tagRepository             tag.ReadWriter
+	corporateActionRepository corporateaction.Reader
+	cashFlowRepository        cashflow.Reader
 }
 
-func NewRepository(db *pgxpool.Pool, tradeRepository trade.ReadWriter, tagRepository tag.ReadWriter) *positionRepository {
-	return &positionRepository{db, tradeRepository, tagRepository}
+func NewRepository(
+	db *pgxpool.Pool, tradeRepository trade.ReadWriter, tagRepository tag.ReadWriter,
+	corporateActionRepository corporateaction.Reader, cashFlowRepository cashflow.Reader,
+) *positionRepository {
+	return &positionRepository{db, tradeRepository, tagRepository, corporateActionRepository, cashFlowRepository}
 }
 
 const (
@@ -61,6 +74,7 @@ const (
 	searchFieldOpened              common.SearchField = "opened"
 	searchFieldSymbol              common.SearchField = "symbol"
 	searchFieldInstrument          common.SearchField = "instrument"
+	searchFieldSegment             common.SearchField = "segment"
 	searchFieldDirection           common.SearchField = "direction"
 	searchFieldStatus              common.SearchField = "status"
 	searchFieldRFactor             common.SearchField = "r_factor"
@@ -70,6 +84,16 @@ const (
 	searchFieldChargesPercentage   common.SearchField = "charges_percentage"
 	searchFieldUserBrokerAccountID common.SearchField = "broker_account_id"
 	searchFieldTotalCharges        common.SearchField = "total_charges_amount"
+	searchFieldPlannedRFactor      common.SearchField = "planned_r_factor"
+	searchFieldExitVsPlanRFactor   common.SearchField = "exit_vs_plan_r_factor"
+	searchFieldMAE                 common.SearchField = "mae"
+	searchFieldMFE                 common.SearchField = "mfe"
+	searchFieldExitEfficiency      common.SearchField = "exit_efficiency"
+	searchFieldStrategyIDs         common.SearchField = "strategy_ids"
+	searchFieldUnderlying          common.SearchField = "underlying"
+	searchFieldExpiry              common.SearchField = "expiry"
+	searchFieldProductType         common.SearchField = "product_type"
+	searchFieldImportBatchID       common.SearchField = "import_batch_id"
 
 	// We can use this field to search for positions based on their trade time.
 	// Meaning if we pass April 1 to April 30, it will return all positions
@@ -98,6 +122,7 @@ type SearchFilter struct {
 	Opened                      *common.DateRangeFilter `json:"opened"`
 	Symbol                      *string                 `json:"symbol"`
 	Instrument                  *types.Instrument       `json:"instrument"`
+	Segment                     *types.Segment          `json:"segment"`
 	Direction                   *Direction              `json:"direction"`
 	Status                      *Status                 `json:"status"`
 	RFactor                     *string                 `json:"r_factor"`
@@ -111,6 +136,27 @@ type SearchFilter struct {
 	ChargesPercentage           *string                 `json:"charges_percentage"`
 	ChargesPercentageOperator   *dbx.Operator           `json:"charges_percentage_operator"`
 	UserBrokerAccountID         *uuid.UUID              `json:"user_broker_account_id"`
+	PlannedRFactor              *string                 `json:"planned_r_factor"`
+	PlannedRFactorOperator      *dbx.Operator           `json:"planned_r_factor_operator"`
+	ExitVsPlanRFactor           *string                 `json:"exit_vs_plan_r_factor"`
+	ExitVsPlanRFactorOperator   *dbx.Operator           `json:"exit_vs_plan_r_factor_operator"`
+	MAE                         *string                 `json:"mae"`
+	MAEOperator                 *dbx.Operator           `json:"mae_operator"`
+	MFE                         *string                 `json:"mfe"`
+	MFEOperator                 *dbx.Operator           `json:"mfe_operator"`
+	ExitEfficiency              *string                 `json:"exit_efficiency"`
+	ExitEfficiencyOperator      *dbx.Operator           `json:"exit_efficiency_operator"`
+	ProductType                 *ProductType            `json:"product_type"`
+	ImportBatchID               *uuid.UUID              `json:"import_batch_id"`
+
+	// Positions that are legs of any of these Strategies.
+	StrategyIDs []uuid.UUID `json:"strategy_ids"`
+	// Positions that are or aren't legs of a Strategy.
+	InStrategy *bool `json:"in_strategy"`
+
+	// Futures and options of this underlying, like "NIFTY".
+	Underlying *string                 `json:"underlying"`
+	Expiry     *common.DateRangeFilter `json:"expiry"`
 
 	TradeTime *common.DateRangeFilter `json:"trade_time"`
 	TagIDs    []uuid.UUID             `json:"tag_ids"`
@@ -128,6 +174,13 @@ var allowedSortFields = []common.SearchField{
 	searchFieldNetReturnPercentage,
 	searchFieldChargesPercentage,
 	searchFieldTotalCharges,
+	searchFieldPlannedRFactor,
+	searchFieldExitVsPlanRFactor,
+	searchFieldMAE,
+	searchFieldMFE,
+	searchFieldExitEfficiency,
+	searchFieldExpiry,
+	searchFieldProductType,
 	searchFieldTradeTime,
 }
 
@@ -138,6 +191,7 @@ var searchFieldsSQLColumn = map[common.SearchField]string{
 	searchFieldOpened:              "p.opened_at",
 	searchFieldSymbol:              "p.symbol",
 	searchFieldInstrument:          "p.instrument",
+	searchFieldSegment:             "p.segment",
 	searchFieldDirection:           "p.direction",
 	searchFieldStatus:              "p.status",
 	searchFieldRFactor:             "p.r_factor",
@@ -147,36 +201,53 @@ var searchFieldsSQLColumn = map[common.SearchField]string{
 	searchFieldChargesPercentage:   "p.charges_as_percentage_of_net_pnl",
 	searchFieldUserBrokerAccountID: "p.user_broker_account_id",
 	searchFieldTotalCharges:        "p.total_charges_amount",
+	searchFieldPlannedRFactor:      "p.planned_r_factor",
+	searchFieldExitVsPlanRFactor:   "p.exit_vs_plan_r_factor",
+	searchFieldMAE:                 "p.mae_amount",
+	searchFieldMFE:                 "p.mfe_amount",
+	searchFieldExitEfficiency:      "p.exit_efficiency_percentage",
+	searchFieldStrategyIDs:         "p.strategy_id",
+	searchFieldUnderlying:          "p.underlying",
+	searchFieldExpiry:              "p.expiry",
+	searchFieldProductType:         "p.product_type",
+	searchFieldImportBatchID:       "p.import_batch_id",
 	searchFieldTradeTime:           "t.time", // This is used when we want to filter positions based on their trades' time.
 }
 
 func (r *positionRepository) Create(ctx context.Context, position *Position) error {
 	const sql = `
         INSERT INTO position (
-            id, created_by, created_at, updated_at, symbol, instrument, 
+            id, created_by, created_at, updated_at, symbol, instrument, segment,
             risk_amount, total_charges_amount, direction, status, opened_at, closed_at,
-            gross_pnl_amount, net_pnl_amount, r_factor, gross_r_factor, net_return_percentage,
+            gross_pnl_amount, net_pnl_amount, income_amount, r_factor, gross_r_factor, net_return_percentage,
             charges_as_percentage_of_net_pnl, open_quantity, open_average_price_amount,
             broker_id, user_broker_account_id, currency_code, enable_auto_charges, fx_rate, fx_source, 
-			gross_pnl_amount_away, net_pnl_amount_away, total_charges_amount_away
+			gross_pnl_amount_away, net_pnl_amount_away, total_charges_amount_away, lot_matching_method,
+			planned_entry_price, stop_loss_price, target_price, planned_r_factor, exit_vs_plan_r_factor,
+			mae_amount, mfe_amount, exit_efficiency_percentage,
+			underlying, expiry, strike, option_right, product_type, import_batch_id
         )
         VALUES (
-            @id, @created_by, @created_at, @updated_at, @symbol, @instrument,
+            @id, @created_by, @created_at, @updated_at, @symbol, @instrument, @segment,
             @risk_amount, @total_charges_amount, @direction, @status, @opened_at, @closed_at,
-            @gross_pnl_amount, @net_pnl_amount, @r_factor, @gross_r_factor, @net_return_percentage,
+            @gross_pnl_amount, @net_pnl_amount, @income_amount, @r_factor, @gross_r_factor, @net_return_percentage,
             @charges_as_percentage_of_net_pnl, @open_quantity, @open_average_price_amount,
             @broker_id, @user_broker_account_id, @currency_code, @enable_auto_charges, @fx_rate, @fx_source,
-			@gross_pnl_amount_away, @net_pnl_amount_away, @total_charges_amount_away
+			@gross_pnl_amount_away, @net_pnl_amount_away, @total_charges_amount_away, @lot_matching_method,
+			@planned_entry_price, @stop_loss_price, @target_price, @planned_r_factor, @exit_vs_plan_r_factor,
+			@mae_amount, @mfe_amount, @exit_efficiency_percentage,
+			@underlying, @expiry, @strike, @option_right, @product_type, @import_batch_id
         )
     `
 
-	_, err := r.db.Exec(ctx, sql, pgx.NamedArgs{
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, pgx.NamedArgs{
 		"id":                               position.ID,
 		"created_by":                       position.CreatedBy,
 		"created_at":                       position.CreatedAt,
 		"updated_at":                       position.UpdatedAt,
 		"symbol":                           symbol.Sanitize(position.Symbol, position.Instrument),
 		"instrument":                       position.Instrument,
+		"segment":                          position.Segment,
 		"risk_amount":                      position.RiskAmount,
 		"total_charges_amount":             position.TotalChargesAmount,
 		"direction":                        position.Direction,
@@ -185,6 +256,7 @@ func (r *positionRepository) Create(ctx context.Context, position *Position) err
 		"closed_at":                        position.ClosedAt,
 		"gross_pnl_amount":                 position.GrossPnLAmount,
 		"net_pnl_amount":                   position.NetPnLAmount,
+		"income_amount":                    position.IncomeAmount,
 		"r_factor":                         position.RFactor,
 		"gross_r_factor":                   position.GrossRFactor,
 		"net_return_percentage":            position.NetReturnPercentage,
@@ -200,6 +272,21 @@ func (r *positionRepository) Create(ctx context.Context, position *Position) err
 		"gross_pnl_amount_away":            position.GrossPnLAmountAway,
 		"net_pnl_amount_away":              position.NetPnLAmountAway,
 		"total_charges_amount_away":        position.TotalChargesAmountAway,
+		"lot_matching_method":              position.LotMatchingMethod,
+		"planned_entry_price":              position.PlannedEntryPrice,
+		"stop_loss_price":                  position.StopLossPrice,
+		"target_price":                     position.TargetPrice,
+		"planned_r_factor":                 position.PlannedRFactor,
+		"exit_vs_plan_r_factor":            position.ExitVsPlanRFactor,
+		"mae_amount":                       position.MAEAmount,
+		"mfe_amount":                       position.MFEAmount,
+		"exit_efficiency_percentage":       position.ExitEfficiencyPercentage,
+		"underlying":                       position.Underlying,
+		"expiry":                           position.Expiry,
+		"strike":                           position.Strike,
+		"option_right":                     position.OptionRight,
+		"product_type":                     position.ProductType,
+		"import_batch_id":                  position.ImportBatchID,
 	})
 
 	if err != nil {
@@ -218,6 +305,7 @@ func (r *positionRepository) Update(ctx context.Context, position *Position) err
             updated_at = @updated_at,
             symbol = @symbol,
             instrument = @instrument,
+            segment = @segment,
             risk_amount = @risk_amount,
             total_charges_amount = @total_charges_amount,
             direction = @direction,
@@ -226,6 +314,7 @@ func (r *positionRepository) Update(ctx context.Context, position *Position) err
             closed_at = @closed_at,
             gross_pnl_amount = @gross_pnl_amount,
             net_pnl_amount = @net_pnl_amount,
+            income_amount = @income_amount,
             r_factor = @r_factor,
 			gross_r_factor = @gross_r_factor,
             net_return_percentage = @net_return_percentage,
@@ -240,17 +329,32 @@ func (r *positionRepository) Update(ctx context.Context, position *Position) err
 			fx_source = @fx_source,
 			gross_pnl_amount_away = @gross_pnl_amount_away,
 			net_pnl_amount_away = @net_pnl_amount_away,
-			total_charges_amount_away = @total_charges_amount_away
+			total_charges_amount_away = @total_charges_amount_away,
+			lot_matching_method = @lot_matching_method,
+			planned_entry_price = @planned_entry_price,
+			stop_loss_price = @stop_loss_price,
+			target_price = @target_price,
+			planned_r_factor = @planned_r_factor,
+			exit_vs_plan_r_factor = @exit_vs_plan_r_factor,
+			mae_amount = @mae_amount,
+			mfe_amount = @mfe_amount,
+			exit_efficiency_percentage = @exit_efficiency_percentage,
+			underlying = @underlying,
+			expiry = @expiry,
+			strike = @strike,
+			option_right = @option_right,
+			product_type = @product_type
         WHERE id = @id
     `
 
-	_, err := r.db.Exec(ctx, sql, pgx.NamedArgs{
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, pgx.NamedArgs{
 		"id":                               position.ID,
 		"created_by":                       position.CreatedBy,
 		"created_at":                       position.CreatedAt,
 		"updated_at":                       position.UpdatedAt,
 		"symbol":                           symbol.Sanitize(position.Symbol, position.Instrument),
 		"instrument":                       position.Instrument,
+		"segment":                          position.Segment,
 		"risk_amount":                      position.RiskAmount,
 		"total_charges_amount":             position.TotalChargesAmount,
 		"direction":                        position.Direction,
@@ -259,6 +363,7 @@ func (r *positionRepository) Update(ctx context.Context, position *Position) err
 		"closed_at":                        position.ClosedAt,
 		"gross_pnl_amount":                 position.GrossPnLAmount,
 		"net_pnl_amount":                   position.NetPnLAmount,
+		"income_amount":                    position.IncomeAmount,
 		"r_factor":                         position.RFactor,
 		"gross_r_factor":                   position.GrossRFactor,
 		"net_return_percentage":            position.NetReturnPercentage,
@@ -274,6 +379,20 @@ func (r *positionRepository) Update(ctx context.Context, position *Position) err
 		"gross_pnl_amount_away":            position.GrossPnLAmountAway,
 		"net_pnl_amount_away":              position.NetPnLAmountAway,
 		"total_charges_amount_away":        position.TotalChargesAmountAway,
+		"lot_matching_method":              position.LotMatchingMethod,
+		"planned_entry_price":              position.PlannedEntryPrice,
+		"stop_loss_price":                  position.StopLossPrice,
+		"target_price":                     position.TargetPrice,
+		"planned_r_factor":                 position.PlannedRFactor,
+		"exit_vs_plan_r_factor":            position.ExitVsPlanRFactor,
+		"mae_amount":                       position.MAEAmount,
+		"mfe_amount":                       position.MFEAmount,
+		"exit_efficiency_percentage":       position.ExitEfficiencyPercentage,
+		"underlying":                       position.Underlying,
+		"expiry":                           position.Expiry,
+		"strike":                           position.Strike,
+		"option_right":                     position.OptionRight,
+		"product_type":                     position.ProductType,
 	})
 
 	if err != nil {
@@ -289,7 +408,7 @@ func (r *positionRepository) Delete(ctx context.Context, positionID uuid.UUID) e
         WHERE id = @id
     `
 
-	_, err := r.db.Exec(ctx, sql, pgx.NamedArgs{"id": positionID})
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, pgx.NamedArgs{"id": positionID})
 	if err != nil {
 		return fmt.Errorf("sql exec: %w", err)
 	}
@@ -327,17 +446,22 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 	baseSQL := `
 		SELECT
 			p.id, p.created_by, p.created_at, p.updated_at,
-			p.symbol, p.instrument, p.risk_amount, p.total_charges_amount,
+			p.symbol, p.instrument, p.segment, p.risk_amount, p.total_charges_amount,
 			p.direction, p.status, p.opened_at, p.closed_at,
-			p.gross_pnl_amount, p.net_pnl_amount, p.r_factor, p.gross_r_factor, p.net_return_percentage,
+			p.gross_pnl_amount, p.net_pnl_amount, p.income_amount, p.r_factor, p.gross_r_factor, p.net_return_percentage,
 			p.charges_as_percentage_of_net_pnl, p.open_quantity,
 			p.open_average_price_amount, p.broker_id, p.user_broker_account_id,
 			p.currency_code, p.enable_auto_charges, p.fx_rate, p.fx_source, p.gross_pnl_amount_away,
 			p.net_pnl_amount_away, p.total_charges_amount_away,
+			p.lot_matching_method, COALESCE(p.lot_matching_method, up.lot_matching_method, 'fifo'),
+			p.planned_entry_price, p.stop_loss_price, p.target_price, p.planned_r_factor, p.exit_vs_plan_r_factor,
+			p.mae_amount, p.mfe_amount, p.exit_efficiency_percentage, p.strategy_id,
+			p.underlying, p.expiry, p.strike, p.option_right, p.product_type, p.import_batch_id,
 			uba.id, uba.broker_id, uba.name,
 			b.name
 		FROM
 			position p
+		LEFT JOIN user_profile up ON up.user_id = p.created_by
 		LEFT JOIN user_broker_account uba ON uba.id = p.user_broker_account_id
 		LEFT JOIN broker b on b.id = uba.broker_id
 	`
@@ -404,6 +528,18 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldInstrument], "=", *p.Filters.Instrument)
 	}
 
+	if p.Filters.Segment != nil && *p.Filters.Segment != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldSegment], "=", *p.Filters.Segment)
+	}
+
+	if p.Filters.ProductType != nil && *p.Filters.ProductType != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldProductType], "=", *p.Filters.ProductType)
+	}
+
+	if p.Filters.ImportBatchID != nil {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldImportBatchID], "=", *p.Filters.ImportBatchID)
+	}
+
 	if p.Filters.Direction != nil && *p.Filters.Direction != "" {
 		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldDirection], "=", *p.Filters.Direction)
 	}
@@ -432,10 +568,59 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldChargesPercentage], *p.Filters.ChargesPercentageOperator, *p.Filters.ChargesPercentage)
 	}
 
+	if p.Filters.PlannedRFactor != nil && *p.Filters.PlannedRFactor != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldPlannedRFactor], *p.Filters.PlannedRFactorOperator, *p.Filters.PlannedRFactor)
+	}
+
+	if p.Filters.ExitVsPlanRFactor != nil && *p.Filters.ExitVsPlanRFactor != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldExitVsPlanRFactor], *p.Filters.ExitVsPlanRFactorOperator, *p.Filters.ExitVsPlanRFactor)
+	}
+
+	if p.Filters.MAE != nil && *p.Filters.MAE != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldMAE], *p.Filters.MAEOperator, *p.Filters.MAE)
+	}
+
+	if p.Filters.MFE != nil && *p.Filters.MFE != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldMFE], *p.Filters.MFEOperator, *p.Filters.MFE)
+	}
+
+	if p.Filters.ExitEfficiency != nil && *p.Filters.ExitEfficiency != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldExitEfficiency], *p.Filters.ExitEfficiencyOperator, *p.Filters.ExitEfficiency)
+	}
+
 	if p.Filters.UserBrokerAccountID != nil {
 		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldUserBrokerAccountID], "=", p.Filters.UserBrokerAccountID)
 	}
 
+	if len(p.Filters.StrategyIDs) > 0 {
+		var ids []any
+		for _, id := range p.Filters.StrategyIDs {
+			ids = append(ids, id)
+		}
+		b.AddArrayFilter(searchFieldsSQLColumn[searchFieldStrategyIDs], ids)
+	}
+
+	if p.Filters.InStrategy != nil {
+		if *p.Filters.InStrategy {
+			b.AppendWhere(searchFieldsSQLColumn[searchFieldStrategyIDs] + " IS NOT NULL")
+		} else {
+			b.AppendWhere(searchFieldsSQLColumn[searchFieldStrategyIDs] + " IS NULL")
+		}
+	}
+
+	if p.Filters.Underlying != nil && *p.Filters.Underlying != "" {
+		b.AddCompareFilter(searchFieldsSQLColumn[searchFieldUnderlying], "=", strings.ToUpper(*p.Filters.Underlying))
+	}
+
+	if p.Filters.Expiry != nil {
+		if p.Filters.Expiry.From != nil {
+			b.AddCompareFilter(searchFieldsSQLColumn[searchFieldExpiry], ">=", p.Filters.Expiry.From)
+		}
+		if p.Filters.Expiry.To != nil {
+			b.AddCompareFilter(searchFieldsSQLColumn[searchFieldExpiry], "<=", p.Filters.Expiry.To)
+		}
+	}
+
 	if p.Sort.Field == "" {
 		p.Sort.Field = searchFieldOpened
 	}
@@ -449,7 +634,7 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 
 	sql, args := b.Build()
 
-	rows, err := r.db.Query(ctx, sql, args...)
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("query: %w", err)
 	}
@@ -469,13 +654,17 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 
 		err := rows.Scan(
 			&pos.ID, &pos.CreatedBy, &pos.CreatedAt, &pos.UpdatedAt,
-			&pos.Symbol, &pos.Instrument, &pos.RiskAmount, &pos.TotalChargesAmount,
+			&pos.Symbol, &pos.Instrument, &pos.Segment, &pos.RiskAmount, &pos.TotalChargesAmount,
 			&pos.Direction, &pos.Status, &pos.OpenedAt, &pos.ClosedAt,
-			&pos.GrossPnLAmount, &pos.NetPnLAmount, &pos.RFactor, &pos.GrossRFactor, &pos.NetReturnPercentage,
+			&pos.GrossPnLAmount, &pos.NetPnLAmount, &pos.IncomeAmount, &pos.RFactor, &pos.GrossRFactor, &pos.NetReturnPercentage,
 			&pos.ChargesAsPercentageOfNetPnL, &pos.OpenQuantity,
 			&pos.OpenAveragePriceAmount, &pos.BrokerID, &pos.UserBrokerAccountID,
 			&pos.CurrencyCode, &pos.EnableAutoCharges, &pos.FxRate, &pos.FxSource, &pos.GrossPnLAmountAway,
 			&pos.NetPnLAmountAway, &pos.TotalChargesAmountAway,
+			&pos.LotMatchingMethod, &pos.EffectiveLotMatchingMethod,
+			&pos.PlannedEntryPrice, &pos.StopLossPrice, &pos.TargetPrice, &pos.PlannedRFactor, &pos.ExitVsPlanRFactor,
+			&pos.MAEAmount, &pos.MFEAmount, &pos.ExitEfficiencyPercentage, &pos.StrategyID,
+			&pos.Underlying, &pos.Expiry, &pos.Strike, &pos.OptionRight, &pos.ProductType, &pos.ImportBatchID,
 			&ubaID, &ubaBrokerID, &ubaName,
 			&ubaBrokerName,
 		)
@@ -522,6 +711,39 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 				})
 			}
 		}
+
+		// Like the trades, the cash flows are needed to compute the positions.
+		cashFlows, err := r.cashFlowRepository.ListByPositionIDs(ctx, positionIDs)
+		if err != nil {
+			return nil, 0, fmt.Errorf("fetch cash flows: %w", err)
+		}
+		for _, c := range cashFlows {
+			if pos, ok := positionMap[*c.PositionID]; ok {
+				pos.CashFlows = append(pos.CashFlows, c)
+			}
+		}
+
+		// The corporate actions are needed to compute equity positions from their trades.
+		equitySymbols := []string{}
+		for _, pos := range positions {
+			if pos.Instrument == types.InstrumentEquity {
+				equitySymbols = append(equitySymbols, pos.Symbol)
+			}
+		}
+
+		if len(equitySymbols) > 0 {
+			actions, err := r.corporateActionRepository.ListBySymbols(ctx, equitySymbols)
+			if err != nil {
+				return nil, 0, fmt.Errorf("fetch corporate actions: %w", err)
+			}
+
+			actionsBySymbol := corporateaction.BySymbol(actions)
+			for _, pos := range positions {
+				if pos.Instrument == types.InstrumentEquity {
+					pos.CorporateActions = actionsBySymbol[pos.Symbol]
+				}
+			}
+		}
 	}
 
 	// Attach tags if requested
@@ -540,7 +762,7 @@ func (r *positionRepository) findPositions(ctx context.Context, p SearchPayload,
 	// Use b.Count() directly for the count query.
 	countSQL, countArgs := b.Count()
 	var total int
-	err = r.db.QueryRow(ctx, countSQL, countArgs...).Scan(&total)
+	err = dbx.Conn(ctx, r.db).QueryRow(ctx, countSQL, countArgs...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -571,7 +793,7 @@ func (r *positionRepository) SearchSymbols(ctx context.Context, userID uuid.UUID
 	b.AddSorting("UPPER(position.symbol)", common.SortOrderASC)
 
 	sql, args := b.Build()
-	rows, err := r.db.Query(ctx, sql, args...)
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, sql, args...)
 	if err != nil {
 		return symbols, fmt.Errorf("query: %w", err)
 	}
@@ -599,7 +821,7 @@ func (r *positionRepository) NoOfPositionsOlderThanTwelveMonths(ctx context.Cont
 	`
 
 	var count int
-	err := r.db.QueryRow(ctx, sql, userID, twelveMonthsAgo).Scan(&count)
+	err := dbx.Conn(ctx, r.db).QueryRow(ctx, sql, userID, twelveMonthsAgo).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("query: %w", err)
 	}
@@ -615,7 +837,7 @@ func (r *positionRepository) TotalPositions(ctx context.Context, userID uuid.UUI
 	`
 
 	var count int
-	err := r.db.QueryRow(ctx, sql, userID).Scan(&count)
+	err := dbx.Conn(ctx, r.db).QueryRow(ctx, sql, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("query: %w", err)
 	}
@@ -632,10 +854,141 @@ func (r *positionRepository) DistinctCurrenciesUsed(ctx context.Context, userID
 	`
 
 	var count int
-	err := r.db.QueryRow(ctx, sql, userID).Scan(&count)
+	err := dbx.Conn(ctx, r.db).QueryRow(ctx, sql, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("query: %w", err)
 	}
 
 	return count, nil
 }
+
+func (r *positionRepository) CreateImportBatch(ctx context.Context, batch *ImportBatch) error {
+	const sql = `
+        INSERT INTO import_batch (
+            id, created_by, created_at, updated_at, source, user_broker_account_id, broker_id,
+            file_name, sheet, positions_created_count, positions_replaced_count, trades_count,
+            replaced_positions, rolled_back_at
+        )
+        VALUES (
+            @id, @created_by, @created_at, @updated_at, @source, @user_broker_account_id, @broker_id,
+            @file_name, @sheet, @positions_created_count, @positions_replaced_count, @trades_count,
+            @replaced_positions, @rolled_back_at
+        )
+    `
+
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, pgx.NamedArgs{
+		"id":                       batch.ID,
+		"created_by":               batch.CreatedBy,
+		"created_at":               batch.CreatedAt,
+		"updated_at":               batch.UpdatedAt,
+		"source":                   batch.Source,
+		"user_broker_account_id":   batch.UserBrokerAccountID,
+		"broker_id":                batch.BrokerID,
+		"file_name":                batch.FileName,
+		"sheet":                    batch.Sheet,
+		"positions_created_count":  batch.PositionsCreatedCount,
+		"positions_replaced_count": batch.PositionsReplacedCount,
+		"trades_count":             batch.TradesCount,
+		"replaced_positions":       batch.ReplacedPositions,
+		"rolled_back_at":           batch.RolledBackAt,
+	})
+	if err != nil {
+		return fmt.Errorf("sql exec: %w", err)
+	}
+
+	return nil
+}
+
+func (r *positionRepository) UpdateImportBatch(ctx context.Context, batch *ImportBatch) error {
+	const sql = `
+        UPDATE import_batch
+        SET
+            updated_at = @updated_at,
+            positions_created_count = @positions_created_count,
+            positions_replaced_count = @positions_replaced_count,
+            trades_count = @trades_count,
+            replaced_positions = @replaced_positions,
+            rolled_back_at = @rolled_back_at
+        WHERE id = @id
+    `
+
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, pgx.NamedArgs{
+		"id":                       batch.ID,
+		"updated_at":               batch.UpdatedAt,
+		"positions_created_count":  batch.PositionsCreatedCount,
+		"positions_replaced_count": batch.PositionsReplacedCount,
+		"trades_count":             batch.TradesCount,
+		"replaced_positions":       batch.ReplacedPositions,
+		"rolled_back_at":           batch.RolledBackAt,
+	})
+	if err != nil {
+		return fmt.Errorf("sql exec: %w", err)
+	}
+
+	return nil
+}
+
+const importBatchColumns = `
+    id, created_by, created_at, updated_at, source, user_broker_account_id, broker_id,
+    file_name, sheet, positions_created_count, positions_replaced_count, trades_count,
+    replaced_positions, rolled_back_at
+`
+
+func scanImportBatch(row pgx.Row) (*ImportBatch, error) {
+	var batch ImportBatch
+	err := row.Scan(
+		&batch.ID, &batch.CreatedBy, &batch.CreatedAt, &batch.UpdatedAt, &batch.Source,
+		&batch.UserBrokerAccountID, &batch.BrokerID, &batch.FileName, &batch.Sheet,
+		&batch.PositionsCreatedCount, &batch.PositionsReplacedCount, &batch.TradesCount,
+		&batch.ReplacedPositions, &batch.RolledBackAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+func (r *positionRepository) GetImportBatch(ctx context.Context, createdBy, importBatchID uuid.UUID) (*ImportBatch, error) {
+	sql := `SELECT ` + importBatchColumns + ` FROM import_batch WHERE id = @id AND created_by = @created_by`
+
+	batch, err := scanImportBatch(dbx.Conn(ctx, r.db).QueryRow(ctx, sql, pgx.NamedArgs{"id": importBatchID, "created_by": createdBy}))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("sql scan: %w", err)
+	}
+
+	return batch, nil
+}
+
+func (r *positionRepository) ListImportBatches(ctx context.Context, createdBy uuid.UUID, userBrokerAccountID *uuid.UUID) ([]*ImportBatch, error) {
+	sql := `
+        SELECT ` + importBatchColumns + ` FROM import_batch
+        WHERE created_by = @created_by
+          AND (@user_broker_account_id::UUID IS NULL OR user_broker_account_id = @user_broker_account_id)
+        ORDER BY created_at DESC
+    `
+
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, sql, pgx.NamedArgs{"created_by": createdBy, "user_broker_account_id": userBrokerAccountID})
+	if err != nil {
+		return nil, fmt.Errorf("sql query: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []*ImportBatch{}
+	for rows.Next() {
+		batch, err := scanImportBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sql scan: %w", err)
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return batches, nil
+}
@@ -2,13 +2,19 @@ package position
 
 import (
 	"arthveda/internal/common"
+	"arthveda/internal/dbx"
 	"arthveda/internal/domain/broker_integration"
 	"strings"
 
+	"arthveda/internal/domain/price"
 	"arthveda/internal/domain/subscription"
 	"arthveda/internal/domain/symbol"
 	"arthveda/internal/domain/types"
 	"arthveda/internal/feature/broker"
+	"arthveda/internal/feature/cashflow"
+	"arthveda/internal/feature/charge"
+	"arthveda/internal/feature/chargereconciliation"
+	"arthveda/internal/feature/corporateaction"
 	"arthveda/internal/feature/currency"
 	"arthveda/internal/feature/journal_entry"
 	"arthveda/internal/feature/tag"
@@ -26,27 +32,47 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 	"github.com/xuri/excelize/v2"
 )
 
 type Service struct {
-	BrokerRepository            broker.ReadWriter
-	positionRepository          ReadWriter
-	tradeRepository             trade.ReadWriter
-	userBrokerAccountRepository userbrokeraccount.Reader
-	journalEntryService         *journal_entry.Service
-	uploadRepository            upload.ReadWriter
-	tagService                  *tag.Service
-	tagRepository               tag.Reader
+	db                             *pgxpool.Pool
+	BrokerRepository               broker.ReadWriter
+	positionRepository             ReadWriter
+	tradeRepository                trade.ReadWriter
+	userBrokerAccountRepository    userbrokeraccount.ReadWriter
+	journalEntryService            *journal_entry.Service
+	uploadRepository               upload.ReadWriter
+	tagService                     *tag.Service
+	tagRepository                  tag.Reader
+	priceStore                     price.Store
+	priceFeed                      price.Feed
+	corporateActionRepository      corporateaction.Reader
+	cashFlowRepository             cashflow.ReadWriter
+	chargeScheduleRepository       charge.Reader
+	chargeReconciliationRepository chargereconciliation.ReadWriter
+	userProfileService             UserProfileService
 }
 
-func NewService(brokerRepository broker.ReadWriter, positionRepository ReadWriter,
-	tradeRepository trade.ReadWriter, userBrokerAccountRepository userbrokeraccount.Reader,
+// UserProfileService is what the Service needs of the userprofile service,
+// which can't be imported here as it imports this package.
+type UserProfileService interface {
+	// Return the user's default lot-matching method.
+	GetLotMatchingMethod(ctx context.Context, userID uuid.UUID) (LotMatchingMethod, error)
+}
+
+func NewService(db *pgxpool.Pool, brokerRepository broker.ReadWriter, positionRepository ReadWriter,
+	tradeRepository trade.ReadWriter, userBrokerAccountRepository userbrokeraccount.ReadWriter,
 	journalEntryService *journal_entry.Service, uploadRepository upload.ReadWriter,
-	tagService *tag.Service, tagRepository tag.Reader,
+	tagService *tag.Service, tagRepository tag.Reader, priceStore price.Store, priceFeed price.Feed,
+	corporateActionRepository corporateaction.Reader, cashFlowRepository cashflow.ReadWriter,
+	chargeScheduleRepository charge.Reader, chargeReconciliationRepository chargereconciliation.ReadWriter,
+	userProfileService UserProfileService,
 ) *Service {
 	return &Service{
+		db,
 		brokerRepository,
 		positionRepository,
 		tradeRepository,
@@ -55,6 +81,13 @@ func NewService(brokerRepository broker.ReadWriter, positionRepository ReadWrite
 		uploadRepository,
 		tagService,
 		tagRepository,
+		priceStore,
+		priceFeed,
+		corporateActionRepository,
+		cashFlowRepository,
+		chargeScheduleRepository,
+		chargeReconciliationRepository,
+		userProfileService,
 	}
 }
 
@@ -63,10 +96,27 @@ type ComputePayload struct {
 	RiskAmount decimal.Decimal       `json:"risk_amount"`
 	FxRate     *decimal.Decimal      `json:"fx_rate"`
 
+	// The lot-matching method to compute the trades with. FIFO is used if empty.
+	LotMatchingMethod LotMatchingMethod `json:"lot_matching_method"`
+
+	// The planned entry, stop-loss and target prices. If the planned entry and stop-loss
+	// prices are set, the RiskAmount is derived from them if it is zero, and must match them otherwise.
+	Plan
+
+	// The splits and bonus issues of the symbol. They aren't sent by the client, see Service.resolveCorporateActions.
+	CorporateActions []*corporateaction.CorporateAction `json:"-"`
+
+	// The dividends and other cash flows of the Position. They aren't sent by the client, see Position.CashFlows.
+	CashFlows []*cashflow.CashFlow `json:"-"`
+
 	// Data below is needed to calculate charges.
 	Instrument        types.Instrument `json:"instrument"`
+	Segment           types.Segment    `json:"segment"` // The equity segment if empty.
 	EnableAutoCharges bool             `json:"enable_auto_charges"`
 	BrokerID          *uuid.UUID       `json:"broker_id"`
+
+	// The brokerage plan of the UserBrokerAccount, if it has one, is used for the charges.
+	UserBrokerAccountID *uuid.UUID `json:"user_broker_account_id"`
 }
 
 type ComputeServiceResult struct {
@@ -74,17 +124,34 @@ type ComputeServiceResult struct {
 
 	// This order will match the order of trades in the ComputePayload.
 	TradeCharges []decimal.Decimal `json:"trade_charges"`
+	// The breakdowns of TradeCharges, in the same order.
+	TradeChargesBreakdowns []*trade.ChargesBreakdown `json:"trade_charges_breakdowns"`
+
+	// How many Positions the rest of a trade that reverses the Position opens, with the trades after it.
+	// The Position is computed up to the reversal, and the others are created along with it.
+	ReversedPositionsCount int `json:"reversed_positions_count"`
 }
 
-func (s *Service) Compute(ctx context.Context, payload ComputePayload) (ComputeServiceResult, service.Error, error) {
+func (s *Service) Compute(ctx context.Context, userID uuid.UUID, payload ComputePayload) (ComputeServiceResult, service.Error, error) {
 	result := ComputeServiceResult{}
-	computeResult, err := Compute(payload)
+
+	if payload.LotMatchingMethod != "" && !payload.LotMatchingMethod.IsValid() {
+		return result, service.ErrBadRequest, errInvalidLotMatchingMethod
+	}
+
+	splitTrades := splitTradesOnReversals(payload.Trades, payload.CorporateActions)
+
+	positionPayload := payload
+	positionPayload.Trades = splitTrades[0]
+
+	computeResult, err := Compute(positionPayload)
 
 	if err != nil {
 		return result, service.ErrBadRequest, err
 	}
 
 	result.computeResult = computeResult
+	result.ReversedPositionsCount = len(splitTrades) - 1
 
 	if payload.EnableAutoCharges {
 		if payload.BrokerID == nil {
@@ -104,7 +171,31 @@ func (s *Service) Compute(ctx context.Context, payload ComputePayload) (ComputeS
 			return result, service.ErrInternalServerError, fmt.Errorf("create trades from create payload: %w", err)
 		}
 
-		charges, userErr, err := CalculateAndApplyChargesToTrades(trades, payload.Instrument, broker.Name)
+		chargeSchedules, err := s.getChargeSchedules(ctx)
+		if err != nil {
+			return result, service.ErrInternalServerError, fmt.Errorf("get charge schedules: %w", err)
+		}
+
+		var brokeragePlan *charge.BrokeragePlan
+		var feeModel *charge.FeeModel
+		if payload.UserBrokerAccountID != nil {
+			account, err := s.userBrokerAccountRepository.GetByID(ctx, *payload.UserBrokerAccountID)
+			if err != nil {
+				if err == repository.ErrNotFound {
+					return result, service.ErrBadRequest, fmt.Errorf("Broker Account provided is invalid or does not exist")
+				}
+				return result, service.ErrInternalServerError, fmt.Errorf("failed to get user's broker account by ID: %w", err)
+			}
+
+			if account.UserID != userID {
+				return result, service.ErrBadRequest, fmt.Errorf("Broker Account provided is invalid or does not exist")
+			}
+
+			brokeragePlan = account.BrokeragePlan
+			feeModel = account.FeeModel
+		}
+
+		charges, userErr, err := CalculateAndApplyChargesToTrades(trades, payload.Instrument, payload.Segment, broker.Name, brokeragePlan, feeModel, chargeSchedules)
 		if err != nil {
 			if userErr {
 				return result, service.ErrBadRequest, err
@@ -121,6 +212,11 @@ func (s *Service) Compute(ctx context.Context, payload ComputePayload) (ComputeS
 
 		result.computeResult.TotalChargesAmount = totalCharges
 		result.TradeCharges = charges
+
+		result.TradeChargesBreakdowns = make([]*trade.ChargesBreakdown, len(trades))
+		for i, t := range trades {
+			result.TradeChargesBreakdowns[i] = t.ChargesBreakdown
+		}
 	}
 
 	return result, service.ErrNone, nil
@@ -138,14 +234,104 @@ type CreatePayload struct {
 	JournalContent      json.RawMessage       `json:"journal_content"`
 	ActiveUploadIDs     []uuid.UUID           `json:"active_upload_ids"`
 	TagIDs              []uuid.UUID           `json:"tag_ids"`
+
+	// The lot-matching method for this Position only. If `nil`, the user's default is used.
+	// NOTE: This shadows ComputePayload.LotMatchingMethod which is the method actually used to compute.
+	LotMatchingMethod *LotMatchingMethod `json:"lot_matching_method"`
+}
+
+var errInvalidLotMatchingMethod = errors.New("Lot matching method must be one of fifo, lifo or weighted_average")
+
+// resolveLotMatchingMethod validates the Position's own lot-matching method (if any)
+// and sets the user's default lot-matching method on the ComputePayload.
+func (s *Service) resolveLotMatchingMethod(ctx context.Context, userID uuid.UUID, payload *CreatePayload) (service.Error, error) {
+	if payload.LotMatchingMethod != nil && !payload.LotMatchingMethod.IsValid() {
+		return service.ErrBadRequest, errInvalidLotMatchingMethod
+	}
+
+	method, err := s.userProfileService.GetLotMatchingMethod(ctx, userID)
+	if err != nil {
+		return service.ErrInternalServerError, fmt.Errorf("get default lot matching method: %w", err)
+	}
+
+	payload.ComputePayload.LotMatchingMethod = method
+
+	return service.ErrNone, nil
+}
+
+// resolveCorporateActions sets the splits and bonus issues of the symbol of an equity Position on the ComputePayload.
+func (s *Service) resolveCorporateActions(ctx context.Context, payload *CreatePayload) (service.Error, error) {
+	payload.ComputePayload.CorporateActions = nil
+
+	if payload.Instrument != types.InstrumentEquity {
+		return service.ErrNone, nil
+	}
+
+	actions, err := s.corporateActionRepository.ListBySymbols(ctx, []string{symbol.Sanitize(payload.Symbol, payload.Instrument)})
+	if err != nil {
+		return service.ErrInternalServerError, fmt.Errorf("corporate action repository list by symbols: %w", err)
+	}
+
+	payload.ComputePayload.CorporateActions = actions
+
+	return service.ErrNone, nil
+}
+
+// newReversedPositions returns the Positions opened by the rest of a trade that reversed the Position
+// of the payload, with the trades after it. See splitTradesOnReversals.
+func newReversedPositions(userID uuid.UUID, payload CreatePayload, splitTrades [][]trade.CreatePayload) ([]*Position, service.Error, error) {
+	positions := []*Position{}
+
+	for _, trades := range splitTrades {
+		position, userErr, err := new(userID, reversedPositionPayload(payload, trades))
+		if err != nil {
+			if userErr {
+				return nil, service.ErrBadRequest, err
+			}
+			return nil, service.ErrInternalServerError, fmt.Errorf("new: %w", err)
+		}
+
+		positions = append(positions, position)
+	}
+
+	return positions, service.ErrNone, nil
 }
 
-// FIXME: use transaction.
+// createPositionsWithTrades creates the Positions and their trades.
+func createPositionsWithTrades(ctx context.Context, positionRepository Writer, tradeRepository trade.Writer, positions []*Position) error {
+	for _, position := range positions {
+		if err := positionRepository.Create(ctx, position); err != nil {
+			return fmt.Errorf("position repository create: %w", err)
+		}
+
+		if _, err := tradeRepository.CreateForPosition(ctx, position.Trades); err != nil {
+			return fmt.Errorf("trade repository create for position: %w", err)
+		}
+	}
+
+	return nil
+}
 
+// Create creates the Position. If a trade takes it through zero, the Position is closed with the part
+// of the trade that closes it, and the rest of the trade opens a new Position with the trades after it,
+// as an import does.
 func (s *Service) Create(ctx context.Context, userID uuid.UUID, payload CreatePayload) (*Position, service.Error, error) {
 	logger := logger.FromCtx(ctx)
 	var err error
 
+	svcErr, err := s.resolveLotMatchingMethod(ctx, userID, &payload)
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	svcErr, err = s.resolveCorporateActions(ctx, &payload)
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	splitTrades := splitTradesOnReversals(payload.Trades, payload.CorporateActions)
+	payload.Trades = splitTrades[0]
+
 	position, userErr, err := new(userID, payload)
 	if err != nil {
 		if userErr {
@@ -155,43 +341,44 @@ func (s *Service) Create(ctx context.Context, userID uuid.UUID, payload CreatePa
 		}
 	}
 
-	err = s.positionRepository.Create(ctx, position)
+	reversedPositions, svcErr, err := newReversedPositions(userID, payload, splitTrades[1:])
 	if err != nil {
-		return nil, service.ErrInternalServerError, err
+		return nil, svcErr, err
 	}
 
-	trades, err := s.tradeRepository.CreateForPosition(ctx, position.Trades)
-	if err != nil {
-		logger.Errorw("failed to create trades after creating a position, so deleting the position that was created", "error", err, "position_id", position.ID)
-		s.positionRepository.Delete(ctx, position.ID)
-		return nil, service.ErrInternalServerError, err
-	}
+	svcErr = service.ErrInternalServerError
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		err := createPositionsWithTrades(ctx, s.positionRepository, s.tradeRepository, append([]*Position{position}, reversedPositions...))
+		if err != nil {
+			return err
+		}
 
-	position.Trades = trades
+		journalEntry, err := s.journalEntryService.UpsertForPosition(ctx, userID, position.ID, payload.JournalContent)
+		if err != nil {
+			return fmt.Errorf("upsert journal entry for position: %w", err)
+		}
 
-	journalEntry, err := s.journalEntryService.UpsertForPosition(ctx, userID, position.ID, payload.JournalContent)
-	if err != nil {
-		logger.Errorw("failed to create journal entry after creating a position, so deleting the position and trades that were created", "error", err, "position_id", position.ID)
-		s.tradeRepository.DeleteByPositionID(ctx, position.ID)
-		s.positionRepository.Delete(ctx, position.ID)
-		return nil, service.ErrInternalServerError, err
-	}
+		position.JournalContent = payload.JournalContent
 
-	position.JournalContent = payload.JournalContent
+		err = s.syncUploads(ctx, userID, journalEntry.ID, payload.ActiveUploadIDs)
+		if err != nil {
+			logger.Errorw("failed to sync uploads after creating a position", "error", err, "position_id", position.ID)
+			// Not returning an error here, as the position was created successfully.
+		}
 
-	err = s.syncUploads(ctx, userID, journalEntry.ID, payload.ActiveUploadIDs)
-	if err != nil {
-		logger.Errorw("failed to sync uploads after creating a position", "error", err, "position_id", position.ID)
-		// Not returning an error here, as the position was created successfully.
-	}
+		tagSvcErr, err := s.tagService.AttachTagToPosition(ctx, tag.AttachTagToPositionPayload{
+			PositionID: position.ID,
+			TagIDs:     payload.TagIDs,
+		})
+		if err != nil {
+			svcErr = tagSvcErr
+			return err
+		}
 
-	svcErr, err := s.tagService.AttachTagToPosition(ctx, tag.AttachTagToPositionPayload{
-		PositionID: position.ID,
-		TagIDs:     payload.TagIDs,
+		return nil
 	})
-
 	if err != nil {
-		logger.Errorw("failed to attach tags to position after creating a position", "error", err, "position_id", position.ID)
+		logger.Errorw("failed to create position", "error", err, "position_id", position.ID)
 		return nil, svcErr, err
 	}
 
@@ -206,13 +393,15 @@ type SearchResult struct {
 }
 
 func (s *Service) Search(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, payload SearchPayload) (*SearchResult, service.Error, error) {
-	l := logger.Get()
-
 	err := payload.Init(allowedSortFields)
 	if err != nil {
 		return nil, service.ErrInvalidInput, err
 	}
 
+	if payload.Filters.ProductType != nil && *payload.Filters.ProductType != "" && !payload.Filters.ProductType.IsValid() {
+		return nil, service.ErrBadRequest, errInvalidProductType
+	}
+
 	twelveMonthsAgo := time.Now().AddDate(-1, 0, 0)
 
 	// If the user is not a Pro user, we limit the time range to the last 12 months.
@@ -285,6 +474,8 @@ func (s *Service) Search(ctx context.Context, userID uuid.UUID, tz *time.Locatio
 		return nil, service.ErrInternalServerError, fmt.Errorf("position repository list: %w", err)
 	}
 
+	s.markToMarket(ctx, positions)
+
 	result := common.NewSearchResult(positions, payload.Pagination.GetMeta(totalItems))
 
 	// Remove pagination while keeping the filters.
@@ -295,17 +486,6 @@ func (s *Service) Search(ctx context.Context, userID uuid.UUID, tz *time.Locatio
 		return nil, service.ErrInternalServerError, fmt.Errorf("position repository list for without pagination: %w", err)
 	}
 
-	_, rangeEnd := GetRangeBasedOnTrades(positions)
-	positionsFiltered := FilterPositionsWithRealisingTradesUpTo(positions, rangeEnd, tz)
-
-	for _, pos := range positionsFiltered {
-		_, err := ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			l.Errorw("failed to compute smart trades for position", "position_id", pos.ID, "error", err)
-			continue
-		}
-	}
-
 	generalStats := GetGeneralStats(positionsAll)
 
 	return &SearchResult{
@@ -325,6 +505,7 @@ type FileImportPayload struct {
 	Rows [][]string
 
 	// Broker ID is the ID of the broker from which the positions are being imported.
+	// If nil, the broker is detected from the Rows, if they clearly match a broker's tradebook.
 	BrokerID uuid.UUID `form:"broker_id"`
 
 	// To which UserBrokerAccount the positions are being imported to.
@@ -350,8 +531,28 @@ type FileImportPayload struct {
 
 	// Force is a boolean flag to indicate whether the import should overwrite existing positions.
 	Force bool `json:"force"`
+
+	// The user's mapping of the file's columns, for a tradebook we have no adapter for.
+	// It is saved on the UserBrokerAccount when the import is confirmed, and used for its later imports.
+	FileMapping *broker_integration.FileMapping `json:"file_mapping"`
+
+	// DryRun never creates the positions, even if Confirm is true. It reports the rows that failed to parse,
+	// and the ones after an empty row, which a confirmed import skips the same way.
+	DryRun bool `json:"dry_run"`
+
+	// The name of the spreadsheet's sheet the Rows are of, if any. Used to report the rows, and saved on the ImportBatch.
+	Sheet string `json:"-"`
+
+	// The name of the uploaded file. Saved on the ImportBatch.
+	FileName string `json:"file_name"`
+
+	// Called as the positions are saved, if not nil.
+	OnProgress ImportProgressFunc `json:"-"`
 }
 
+// ImportProgressFunc is called as an import goes through its positions, with how many of them are done.
+type ImportProgressFunc func(done, total int)
+
 var errImportFileInvalid = errors.New("File seems invalid or unsupported")
 
 func (s *Service) FileImport(ctx context.Context, userID uuid.UUID, payload FileImportPayload) (*ImportResult, service.Error, error) {
@@ -363,6 +564,16 @@ func (s *Service) FileImport(ctx context.Context, userID uuid.UUID, payload File
 		return &ImportResult{}, service.ErrNone, nil
 	}
 
+	if payload.BrokerID == uuid.Nil && payload.FileMapping == nil {
+		detected, errKind, err := s.detectBroker(ctx, rows)
+		if err != nil {
+			return nil, errKind, err
+		}
+
+		l.Infow("detected broker of import file", "broker", detected.Name)
+		payload.BrokerID = detected.ID
+	}
+
 	broker, err := s.BrokerRepository.GetByID(ctx, payload.BrokerID)
 	if err != nil {
 		if err == repository.ErrNotFound {
@@ -381,44 +592,96 @@ func (s *Service) FileImport(ctx context.Context, userID uuid.UUID, payload File
 		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get user's broker account by ID: %w", err)
 	}
 
+	// The account's column mapping is used for the file, and saved on it, so it must be the user's own.
+	if uba.UserID != userID {
+		return nil, service.ErrNotFound, fmt.Errorf("Broker account not found with ID: %s", payload.UserBrokerAccountID)
+	}
+
 	if uba.BrokerID != broker.ID {
 		return nil, service.ErrBadRequest, fmt.Errorf("Broker Account provided does not belong to the Broker provided")
 	}
 
-	fileAdapter, err := broker_integration.GetFileAdapter(broker)
+	fileMapping := uba.FileMapping
+	if payload.FileMapping != nil {
+		if err := payload.FileMapping.Validate(); err != nil {
+			return nil, service.ErrBadRequest, err
+		}
+		fileMapping = payload.FileMapping
+	}
+
+	fileAdapter, err := broker_integration.GetFileAdapter(broker, fileMapping)
 	if err != nil {
+		if fileMapping == nil {
+			return nil, service.ErrBadRequest, fmt.Errorf("Map the columns of the file to import it from %s", broker.Name)
+		}
 		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get broker importer: %w", err)
 	}
 
 	metadata, err := fileAdapter.GetMetadata(rows)
 	if err != nil {
 		l.Infow("Failed to get metadata from importer", "error", err, "broker", broker)
-		return nil, service.ErrBadRequest, errImportFileInvalid
+		if errors.Is(err, broker_integration.ErrPnLStatement) {
+			return nil, service.ErrBadRequest, err
+		}
+		errKind, err := s.unparsableFileError(ctx, rows, broker, fileMapping, errImportFileInvalid)
+		return nil, errKind, err
 	}
 
 	headerRowIdx := metadata.HeaderRowIdx
 	importableTrades := []*types.ImportableTrade{}
+	parsedRows := []importRow{}
+	failedRows := []ImportRowIssue{}
+	ignoredRows := []ImportRowIssue{}
+	reachedEnd := false
+	var firstRowErr error
 
 	// Replace the map with a slice and populate it
 	for rowIdx, row := range rows[headerRowIdx+1:] {
-		l.Debugf("Processing row %d: %v\n", rowIdx+headerRowIdx+1, row)
+		rowNumber := headerRowIdx + rowIdx + 2
+		l.Debugf("Processing row %d: %v\n", rowNumber, row)
+
+		if reachedEnd {
+			ignoredRows = append(ignoredRows, ImportRowIssue{Sheet: payload.Sheet, RowNumber: rowNumber, Row: row, Reason: "After an empty row, which is taken as the end of the trades"})
+			continue
+		}
 
 		if len(row) == 0 {
 			l.Debugf("Found an empty row. We will stop processing further rows assuming we have reached the end.")
-			break
+			if !payload.DryRun {
+				break
+			}
+
+			// A dry run reports the rows after it, so that the user knows they weren't imported.
+			reachedEnd = true
+			continue
 		}
 
+		// A row that fails to parse is skipped, and reported, by a dry run and a confirmed import alike,
+		// so that the import does what its dry run showed.
 		importableTrade, err := fileAdapter.ParseRow(row, metadata)
 		if err != nil {
-			l.Errorw("failed to parse row", "error", err, "row_index", rowIdx+headerRowIdx+1, "row", row)
-			return nil, service.ErrBadRequest, fmt.Errorf("File seems invalid or unsupported")
+			l.Infow("failed to parse row", "error", err, "row_number", rowNumber, "row", row)
+			if firstRowErr == nil {
+				firstRowErr = fmt.Errorf("%w: row %d: %s", errImportFileInvalid, rowNumber, err.Error())
+			}
+
+			failedRows = append(failedRows, ImportRowIssue{Sheet: payload.Sheet, RowNumber: rowNumber, Row: row, Reason: err.Error()})
+			continue
 		}
 
 		if importableTrade.ShouldIgnore {
+			ignoredRows = append(ignoredRows, ImportRowIssue{Sheet: payload.Sheet, RowNumber: rowNumber, Row: row, Reason: "Not a trade"})
 			continue
 		}
 
 		importableTrades = append(importableTrades, importableTrade)
+		parsedRows = append(parsedRows, importRow{number: rowNumber, trade: importableTrade})
+	}
+
+	// No row parsed, so the file is likely of another broker, or mapped wrong.
+	if len(importableTrades) == 0 && firstRowErr != nil {
+		errKind, err := s.unparsableFileError(ctx, rows, broker, fileMapping, firstRowErr)
+		return nil, errKind, err
 	}
 
 	options := ImportPayload{
@@ -429,11 +692,34 @@ func (s *Service) FileImport(ctx context.Context, userID uuid.UUID, payload File
 		CurrencyCode:             payload.CurrencyCode,
 		ChargesCalculationMethod: payload.ChargesCalculationMethod,
 		ManualChargeAmount:       payload.ManualChargeAmount,
-		Confirm:                  payload.Confirm,
+		Confirm:                  payload.Confirm && !payload.DryRun,
 		Force:                    payload.Force,
+		FileMapping:              fileMapping,
+		FileName:                 payload.FileName,
+		Sheet:                    payload.Sheet,
+		OnProgress:               payload.OnProgress,
+	}
+
+	result, errKind, err := s.Import(ctx, importableTrades, options)
+	if err != nil {
+		return nil, errKind, err
+	}
+
+	result.FailedRows = failedRows
+	result.IgnoredRows = ignoredRows
+	result.MergedOrders = getMergedOrders(parsedRows, payload.Sheet)
+
+	if options.Confirm && payload.FileMapping != nil {
+		now := time.Now().UTC()
+		uba.UpdatedAt = &now
+		uba.FileMapping = payload.FileMapping
+
+		if _, err := s.userBrokerAccountRepository.Update(ctx, uba); err != nil {
+			return nil, service.ErrInternalServerError, fmt.Errorf("save file mapping: %w", err)
+		}
 	}
 
-	return s.Import(ctx, importableTrades, options)
+	return result, errKind, nil
 }
 
 type ImportPayload struct {
@@ -461,6 +747,32 @@ type ImportPayload struct {
 
 	// Force is a boolean flag to indicate whether the import should overwrite existing positions.
 	Force bool
+
+	// The user's mapping of the file's columns the trades were parsed with, if any.
+	FileMapping *broker_integration.FileMapping
+
+	// Only for file imports. The names of the file and its sheet, saved on the ImportBatch.
+	FileName string
+	Sheet    string
+
+	// Called as the positions are saved, if not nil.
+	OnProgress ImportProgressFunc
+}
+
+func (p *ImportPayload) reportProgress(done, total int) {
+	if p.OnProgress != nil {
+		p.OnProgress(done, total)
+	}
+}
+
+// isInstrumentSupported returns whether the positions of the Instrument can be imported.
+// A file the user mapped the columns of can have any Instrument.
+func (p *ImportPayload) isInstrumentSupported(instrument types.Instrument) bool {
+	if p.FileMapping != nil {
+		return true
+	}
+
+	return p.Broker.IsInstrumentSupportedForImport(instrument)
 }
 
 type ImportResult struct {
@@ -475,6 +787,17 @@ type ImportResult struct {
 	UnsupportedPositionsCount int         `json:"unsupported_positions_count"`
 	FromDate                  time.Time   `json:"from_date"`
 	ToDate                    time.Time   `json:"to_date"`
+
+	// What the import does, or would do if confirmed, with each position.
+	Diff []ImportPositionDiff `json:"diff"`
+
+	// Only for file imports. The rows that weren't imported, and the orders of many rows.
+	FailedRows   []ImportRowIssue    `json:"failed_rows"`
+	IgnoredRows  []ImportRowIssue    `json:"ignored_rows"`
+	MergedOrders []ImportMergedOrder `json:"merged_orders"`
+
+	// The ImportBatches the positions were saved in, if confirmed.
+	ImportBatchIDs []uuid.UUID `json:"import_batch_ids"`
 }
 
 func (r *ImportResult) Merge(other *ImportResult) {
@@ -486,6 +809,11 @@ func (r *ImportResult) Merge(other *ImportResult) {
 	r.Positions = append(r.Positions, other.Positions...)
 	r.InvalidPositions = append(r.InvalidPositions, other.InvalidPositions...)
 	r.UnsupportedPositions = append(r.UnsupportedPositions, other.UnsupportedPositions...)
+	r.Diff = append(r.Diff, other.Diff...)
+	r.FailedRows = append(r.FailedRows, other.FailedRows...)
+	r.IgnoredRows = append(r.IgnoredRows, other.IgnoredRows...)
+	r.MergedOrders = append(r.MergedOrders, other.MergedOrders...)
+	r.ImportBatchIDs = append(r.ImportBatchIDs, other.ImportBatchIDs...)
 
 	// Merge counters
 	r.PositionsCount += other.PositionsCount
@@ -503,6 +831,62 @@ func (r *ImportResult) Merge(other *ImportResult) {
 	if r.ToDate.IsZero() || (!other.ToDate.IsZero() && other.ToDate.After(r.ToDate)) {
 		r.ToDate = other.ToDate
 	}
+
+	sortImportPositionDiffs(r.Diff)
+}
+
+// getCorporateActionsBySymbol returns the splits and bonus issues of the equity symbols of the trades,
+// keyed by the upper-cased symbol.
+func (s *Service) getCorporateActionsBySymbol(ctx context.Context, importableTrades []*types.ImportableTrade) (map[string][]*corporateaction.CorporateAction, error) {
+	symbols := []string{}
+	seen := map[string]bool{}
+	for _, t := range importableTrades {
+		if t.Instrument != types.InstrumentEquity || seen[t.Symbol] {
+			continue
+		}
+		seen[t.Symbol] = true
+		symbols = append(symbols, t.Symbol)
+	}
+
+	actions, err := s.corporateActionRepository.ListBySymbols(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return corporateaction.BySymbol(actions), nil
+}
+
+// getSegmentBySymbol returns the Segment of each symbol's contracts. The symbols are sanitized already.
+func getSegmentBySymbol(importableTrades []*types.ImportableTrade) map[string]types.Segment {
+	segmentBySymbol := map[string]types.Segment{}
+	for _, t := range importableTrades {
+		segmentBySymbol[t.Symbol] = t.Segment.OrDefault()
+	}
+	return segmentBySymbol
+}
+
+// getBrokeragePlanAndFeeModel returns the user's own brokerage plan and fee model on the UserBrokerAccount,
+// nil for the ones it has none of.
+func (s *Service) getBrokeragePlanAndFeeModel(ctx context.Context, userBrokerAccountID uuid.UUID) (*charge.BrokeragePlan, *charge.FeeModel, error) {
+	account, err := s.userBrokerAccountRepository.GetByID(ctx, userBrokerAccountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("get user broker account: %w", err)
+	}
+
+	return account.BrokeragePlan, account.FeeModel, nil
+}
+
+// getChargeSchedules returns the charge Schedules, including the ones added after the deploy.
+func (s *Service) getChargeSchedules(ctx context.Context) (charge.Schedules, error) {
+	schedules, err := s.chargeScheduleRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return charge.NewSchedules(schedules), nil
 }
 
 // TOOD: If I'm Syncing my Zerodha account, due to `force` flag being true, a position that
@@ -524,6 +908,27 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 		isSymbolBeingImported[trade.Symbol] = true
 	}
 
+	corporateActionsBySymbol, err := s.getCorporateActionsBySymbol(ctx, importableTrades)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get corporate actions: %w", err)
+	}
+
+	chargeSchedules, err := s.getChargeSchedules(ctx)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get charge schedules: %w", err)
+	}
+
+	brokeragePlan, feeModel, err := s.getBrokeragePlanAndFeeModel(ctx, payload.UserBrokerAccountID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get brokerage plan and fee model: %w", err)
+	}
+
+	if payload.ChargesCalculationMethod == ChargesCalculationMethodAuto && needsBrokeragePlan(payload.Broker.Name, brokeragePlan, feeModel) {
+		return nil, service.ErrBadRequest, errNoDefaultBrokerage(payload.Broker.Name)
+	}
+
+	segmentBySymbol := getSegmentBySymbol(importableTrades)
+
 	// Map to store parsed rows by Order ID.
 	// This makes it easy to access the parsed row data by Order ID later.
 	parsedRowByOrderID := map[string]*types.ImportableTrade{}
@@ -648,9 +1053,75 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 		enableAutoCharges = true
 	}
 
+	// New positions are computed with the user's default lot-matching method.
+	defaultLotMatchingMethod, err := s.userProfileService.GetLotMatchingMethod(ctx, payload.UserID)
+	if err != nil {
+		l.Errorw("failed to get default lot matching method", "error", err, "user_id", payload.UserID)
+		// Not returning error, FIFO will be used.
+	}
+
 	// Array to store all finalized positions
 	finalizedPositions := []*Position{}
 
+	// What the import does, or would do if confirmed, with each position.
+	diff := []ImportPositionDiff{}
+
+	// openNewPosition opens a new position in the file for the symbol with the trade as its first trade.
+	openNewPosition := func(symbol string, instrument types.Instrument, newTrade *trade.Trade, brokerTradeID string) error {
+		positionID, err := uuid.NewV7()
+		if err != nil {
+			return fmt.Errorf("failed to generate UUID for position: %w", err)
+		}
+
+		newTrade.PositionID = positionID
+		newTrade.BrokerTradeID = &brokerTradeID
+
+		trades := []*trade.Trade{
+			newTrade,
+		}
+
+		newPosition := &Position{
+			ID:                         positionID,
+			CreatedBy:                  payload.UserID,
+			CreatedAt:                  now,
+			Symbol:                     symbol,
+			Instrument:                 instrument,
+			Segment:                    segmentBySymbol[symbol],
+			CurrencyCode:               payload.CurrencyCode,
+			EnableAutoCharges:          enableAutoCharges,
+			RiskAmount:                 payload.RiskAmount,
+			Trades:                     trades,
+			BrokerID:                   &payload.Broker.ID,
+			UserBrokerAccountID:        &payload.UserBrokerAccountID,
+			CorporateActions:           corporateActionsBySymbol[strings.ToUpper(symbol)],
+			EffectiveLotMatchingMethod: defaultLotMatchingMethod,
+		}
+
+		// Initialize the position with the first trade
+		computeResult, err := Compute(newPosition.ComputePayload())
+		if err != nil {
+			l.Debugw("failed to compute position after creating a new position and marking it as invalid", "error", err, "position_id", positionID, "symbol", symbol)
+			invalidPositionsByPosID[positionID] = true
+			return nil
+		}
+
+		ApplyComputeResultToPosition(newPosition, computeResult)
+		openPositions[symbol] = newPosition
+		return nil
+	}
+
+	// openReversedPosition opens a new position in the opposite direction with the part
+	// of the trade that was left after it closed the position for the symbol.
+	openReversedPosition := func(symbol string, instrument types.Instrument, reversalPayload trade.CreatePayload, orderID string) error {
+		reversalTrade, err := trade.New(reversalPayload)
+		if err != nil {
+			return fmt.Errorf("failed to create trade from payload: %w", err)
+		}
+
+		l.Debugw("trade reversed the position, opening a new position with the rest of the trade", "order_id", orderID, "symbol", symbol)
+		return openNewPosition(symbol, instrument, reversalTrade, reversalBrokerTradeID(orderID))
+	}
+
 	// Process the sorted trades
 	for _, tradeWithOrderID := range tradesWithOrderIDs {
 		orderID := tradeWithOrderID.OrderID
@@ -661,6 +1132,7 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 		}
 
 		symbol := parsedRow.Symbol
+		instrument := parsedRow.Instrument
 
 		newTrade, err := trade.New(tradePayload)
 		if err != nil {
@@ -668,29 +1140,32 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 			return nil, service.ErrInternalServerError, fmt.Errorf("failed to create trade from payload: %w", err)
 		}
 
-		if existingOpenPosition, exists := existingOpenPositionsInArthvedaBySymbol[symbol]; exists {
+		if existingOpenPosition, exists := existingOpenPositionsInArthvedaBySymbol[symbol]; exists && existingOpenPosition.Status == StatusOpen {
 			// We should make sure that the trades being imported are after the position was OPENED AT.
 			if newTrade.Time.After(existingOpenPosition.OpenedAt) || newTrade.Time.Equal(existingOpenPosition.OpenedAt) {
-				if common.ExistsInSet(brokerTradeIDs, orderID) {
+				if isBrokerTradeImported(brokerTradeIDs, orderID) {
 					l.Debugw("skipping trade because it already exists in the open position in Arthveda", "order_id", orderID, "symbol", symbol)
 					continue
 				}
 
+				// If the trade reverses the position, only the part that closes it belongs to it.
+				closingPayload, reversalPayload := splitTradeOnPositionReversal(tradePayload, existingOpenPosition)
+				newTrade.Quantity = closingPayload.Quantity
+				newTrade.ChargesAmount = closingPayload.ChargesAmount
+
 				// If an open position exists in Arthveda for the symbol, we will use that
 				// to update the position with the new trade.
 				newTrade.PositionID = existingOpenPosition.ID
 				newTrade.BrokerTradeID = &orderID
 				existingOpenPosition.Trades = append(existingOpenPosition.Trades, newTrade)
 
-				computePayload := ComputePayload{
-					RiskAmount: existingOpenPosition.RiskAmount,
-					Trades:     ConvertTradesToCreatePayload(existingOpenPosition.Trades),
-				}
-
-				computeResult, err := Compute(computePayload)
+				computeResult, err := Compute(existingOpenPosition.ComputePayload())
 				if err != nil {
 					l.Debugw("failed to compute position that already exists in Arthveda and marking it as invalid", "error", err, "position_id", existingOpenPosition.ID, "symbol", existingOpenPosition.Symbol)
 					invalidPositionsByPosID[existingOpenPosition.ID] = true
+					// So that the position is reported as invalid, with all of the trade.
+					existingOpenPositionsInArthvedaWasUpdatedByPositionID[existingOpenPosition.ID] = true
+					restoreReversingTrade(newTrade, tradePayload)
 					continue
 				}
 
@@ -700,26 +1175,35 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 				existingOpenPositionsInArthvedaBySymbol[symbol] = existingOpenPosition
 				// Mark that this existing open position in Arthveda was updated with new trades.
 				existingOpenPositionsInArthvedaWasUpdatedByPositionID[existingOpenPosition.ID] = true
+
+				if reversalPayload != nil {
+					err = openReversedPosition(symbol, instrument, *reversalPayload, orderID)
+					if err != nil {
+						return nil, service.ErrInternalServerError, err
+					}
+				}
+
 				continue
 			}
 		}
 
 		// Check if there is an open position for the Symbol
 		if openPosition, exists := openPositions[symbol]; exists {
+			// If the trade reverses the position, only the part that closes it belongs to it.
+			closingPayload, reversalPayload := splitTradeOnPositionReversal(tradePayload, openPosition)
+			newTrade.Quantity = closingPayload.Quantity
+			newTrade.ChargesAmount = closingPayload.ChargesAmount
+
 			newTrade.PositionID = openPosition.ID
 			newTrade.BrokerTradeID = &orderID
 			openPosition.Trades = append(openPosition.Trades, newTrade)
 
 			// Use the compute function to update the position state
-			computePayload := ComputePayload{
-				RiskAmount: payload.RiskAmount,
-				Trades:     ConvertTradesToCreatePayload(openPosition.Trades),
-			}
-
-			computeResult, err := Compute(computePayload)
+			computeResult, err := Compute(openPosition.ComputePayload())
 			if err != nil {
 				l.Debugw("failed to compute position that already exists and marking it as invalid", "error", err, "position_id", openPosition.ID, "symbol", openPosition.Symbol)
 				invalidPositionsByPosID[openPosition.ID] = true
+				restoreReversingTrade(newTrade, tradePayload)
 				continue
 			}
 
@@ -732,56 +1216,19 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 				// Remove the finalized position from the openPositions map
 				delete(openPositions, symbol)
 			}
-		} else {
-			// If no open position exists, create a new one
-			parsedRow, exists := parsedRowByOrderID[orderID]
-			if !exists {
-				return nil, service.ErrInternalServerError, fmt.Errorf("ParsedRow not found for Order ID %s", orderID)
-			}
 
-			instrument := parsedRow.Instrument
-
-			// Initialize the position with the first trade
-			computePayload := ComputePayload{
-				RiskAmount: payload.RiskAmount,
-				Trades:     []trade.CreatePayload{tradePayload},
-			}
-
-			positionID, err := uuid.NewV7()
-			if err != nil {
-				return nil, service.ErrInternalServerError, fmt.Errorf("failed to generate UUID for position: %w", err)
+			if reversalPayload != nil {
+				err = openReversedPosition(symbol, instrument, *reversalPayload, orderID)
+				if err != nil {
+					return nil, service.ErrInternalServerError, err
+				}
 			}
-
-			computeResult, err := Compute(computePayload)
+		} else {
+			// If no open position exists, create a new one
+			err = openNewPosition(symbol, instrument, newTrade, orderID)
 			if err != nil {
-				l.Debugw("failed to compute position after creating a new position and marking it as invalid", "error", err, "position_id", positionID, "symbol", parsedRow.Symbol)
-				invalidPositionsByPosID[positionID] = true
-				continue
-			}
-
-			newTrade.PositionID = positionID
-			newTrade.BrokerTradeID = &orderID
-
-			trades := []*trade.Trade{
-				newTrade,
-			}
-
-			newPosition := &Position{
-				ID:                  positionID,
-				CreatedBy:           payload.UserID,
-				CreatedAt:           now,
-				Symbol:              symbol,
-				Instrument:          instrument,
-				CurrencyCode:        payload.CurrencyCode,
-				EnableAutoCharges:   enableAutoCharges,
-				RiskAmount:          payload.RiskAmount,
-				Trades:              trades,
-				BrokerID:            &payload.Broker.ID,
-				UserBrokerAccountID: &payload.UserBrokerAccountID,
+				return nil, service.ErrInternalServerError, err
 			}
-
-			ApplyComputeResultToPosition(newPosition, computeResult)
-			openPositions[symbol] = newPosition
 		}
 	}
 
@@ -790,6 +1237,7 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 		if invalid, exists := invalidPositionsByPosID[openPosition.ID]; exists && invalid {
 			l.Debugw("skipping invalid position", "position_id", openPosition.ID, "symbol", openPosition.Symbol)
 			invalidPositions = append(invalidPositions, openPosition)
+			diff = append(diff, newImportPositionDiff(openPosition, ImportPositionActionInvalid))
 			// Skip invalid positions
 			continue
 		}
@@ -803,6 +1251,7 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 			if invalid, exists := invalidPositionsByPosID[existingOpenPosition.ID]; exists && invalid {
 				l.Debugw("skipping invalid position that was updated with new trades", "position_id", existingOpenPosition.ID, "symbol", existingOpenPosition.Symbol)
 				invalidPositions = append(invalidPositions, existingOpenPosition)
+				diff = append(diff, newImportPositionDiff(existingOpenPosition, ImportPositionActionInvalid))
 				// Skip invalid positions
 				continue
 			}
@@ -818,10 +1267,11 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 	// If the instrument is not supported by the broker, we will mark the position as unsupported.
 	filteredFinalizedPositions := finalizedPositions[:0]
 	for _, finalizedPos := range finalizedPositions {
-		if !payload.Broker.IsInstrumentSupportedForImport(finalizedPos.Instrument) {
+		if !payload.isInstrumentSupported(finalizedPos.Instrument) {
 			l.Debugw("unsupported instrument found in finalized position", "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol, "instrument", finalizedPos.Instrument)
 			unsupportedPositionsCount++
 			unsupportedPositions = append(unsupportedPositions, finalizedPos)
+			diff = append(diff, newImportPositionDiff(finalizedPos, ImportPositionActionUnsupported))
 			// Do not add to filteredFinalizedPositions
 			continue
 		}
@@ -848,153 +1298,189 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 	positionsImported := 0
 	forcedPositionCount := 0
 
-	for positionIdx, finalizedPos := range finalizedPositions {
-		var isDuplicate bool
-		// If we find a duplicate trade, we need to get it's position ID.
-		var positionIDForTheDuplicateOrderID uuid.UUID
-
-		for _, trade := range finalizedPos.Trades {
-			orderID := trade.BrokerTradeID
+	// The batch of the positions being saved. Created with the first one, so that an import
+	// that saves nothing leaves no batch behind.
+	var batch *ImportBatch
 
-			if common.ExistsInSet(brokerTradeIDs, *orderID) {
-				isDuplicate = true
-				positionIDForTheDuplicateOrderID = brokerTradeIDs[*orderID]
+	// The positions are saved, and the batch finished, in one transaction so that a failed import
+	// doesn't leave a replaced position deleted without the batch that keeps it to restore.
+	svcErr := service.ErrInternalServerError
+	err = s.withImportTx(ctx, payload.Confirm, func(ctx context.Context) error {
+		for positionIdx, finalizedPos := range finalizedPositions {
+			payload.reportProgress(positionIdx, len(finalizedPositions))
 
-				// If we find a duplicate, we can break out of the loop early.
-				// Because if one trade in the position is a duplicate,
-				// the whole position is considered a duplicate.
-				break
-			}
-		}
+			var isDuplicate bool
+			// If we find a duplicate trade, we need to get it's position ID.
+			var positionIDForTheDuplicateOrderID uuid.UUID
 
-		var svcErr service.Error
+			for _, trade := range finalizedPos.Trades {
+				orderID := trade.BrokerTradeID
 
-		// When we import trades that belong to an existing open position in Arthveda.
-		isUpdatingPositionAlreadyInArthveda := false
+				if positionID, ok := importedBrokerTradePositionID(brokerTradeIDs, *orderID); ok {
+					isDuplicate = true
+					positionIDForTheDuplicateOrderID = positionID
 
-		isPositionAlreadyInArthvedaButHasUpdated, exists := existingOpenPositionsInArthvedaWasUpdatedByPositionID[finalizedPos.ID]
-		if exists && isPositionAlreadyInArthvedaButHasUpdated {
-			isUpdatingPositionAlreadyInArthveda = true
-		}
+					// If we find a duplicate, we can break out of the loop early.
+					// Because if one trade in the position is a duplicate,
+					// the whole position is considered a duplicate.
+					break
+				}
+			}
 
-		if isDuplicate && !isUpdatingPositionAlreadyInArthveda {
-			existingPosition, svcErr, err := s.Get(ctx, payload.UserID, positionIDForTheDuplicateOrderID)
-			if err != nil {
-				return nil, svcErr, fmt.Errorf("failed to fetch existing position: %w", err)
+			// When we import trades that belong to an existing open position in Arthveda.
+			isUpdatingPositionAlreadyInArthveda := false
+
+			isPositionAlreadyInArthvedaButHasUpdated, exists := existingOpenPositionsInArthvedaWasUpdatedByPositionID[finalizedPos.ID]
+			if exists && isPositionAlreadyInArthvedaButHasUpdated {
+				isUpdatingPositionAlreadyInArthveda = true
 			}
 
-			finalizedPos.RiskAmount = payload.RiskAmount
+			if isDuplicate && !isUpdatingPositionAlreadyInArthveda {
+				existingPosition, getSvcErr, err := s.Get(ctx, payload.UserID, positionIDForTheDuplicateOrderID)
+				if err != nil {
+					svcErr = getSvcErr
+					return fmt.Errorf("failed to fetch existing position: %w", err)
+				}
 
-			// We should copy some fields from the existing position to the new position.
-			// This helps us keep the risk amount of the existing position.
-			// Also the URL for the existing position will be the same as the new position.
+				finalizedPos.RiskAmount = payload.RiskAmount
 
-			// If the existing position has a risk amount, we will use that.
-			// If the payload has a risk amount, we will use that.
-			if existingPosition.RiskAmount.IsPositive() && payload.RiskAmount.IsZero() {
-				finalizedPos.RiskAmount = existingPosition.RiskAmount
-			}
+				// We should copy some fields from the existing position to the new position.
+				// This helps us keep the risk amount of the existing position.
+				// Also the URL for the existing position will be the same as the new position.
+
+				// If the existing position has a risk amount, we will use that.
+				// If the payload has a risk amount, we will use that.
+				if existingPosition.RiskAmount.IsPositive() && payload.RiskAmount.IsZero() {
+					finalizedPos.RiskAmount = existingPosition.RiskAmount
+				}
 
-			// So that the existing URL to view the position remains the same.
-			finalizedPos.ID = existingPosition.ID
-			finalizedPos.IsDuplicate = true
+				// Keep the lot-matching method of the existing position.
+				finalizedPos.LotMatchingMethod = existingPosition.LotMatchingMethod
+				finalizedPos.EffectiveLotMatchingMethod = existingPosition.EffectiveLotMatchingMethod
 
-			// Update the positionID for the trades in the position.
-			for _, trade := range finalizedPos.Trades {
-				trade.PositionID = existingPosition.ID
-			}
-		}
+				// So that the existing URL to view the position remains the same.
+				finalizedPos.ID = existingPosition.ID
+				finalizedPos.IsDuplicate = true
 
-		switch payload.ChargesCalculationMethod {
-		case ChargesCalculationMethodAuto:
-			_, userErr, err := CalculateAndApplyChargesToTrades(finalizedPos.Trades, finalizedPos.Instrument, payload.Broker.Name)
-			if err != nil {
-				if userErr {
-					return nil, service.ErrBadRequest, err
-				} else {
-					return nil, service.ErrInternalServerError, fmt.Errorf("CalculateAndApplyChargesToTrades: %w", err)
+				// Update the positionID for the trades in the position.
+				for _, trade := range finalizedPos.Trades {
+					trade.PositionID = existingPosition.ID
 				}
 			}
 
-		case ChargesCalculationMethodManual:
-			for _, trade := range finalizedPos.Trades {
-				trade.ChargesAmount = payload.ManualChargeAmount
+			switch payload.ChargesCalculationMethod {
+			case ChargesCalculationMethodAuto:
+				_, userErr, err := CalculateAndApplyChargesToTrades(finalizedPos.Trades, finalizedPos.Instrument, finalizedPos.Segment, payload.Broker.Name, brokeragePlan, feeModel, chargeSchedules)
+				if err != nil {
+					if userErr {
+						svcErr = service.ErrBadRequest
+						return err
+					} else {
+						svcErr = service.ErrInternalServerError
+						return fmt.Errorf("CalculateAndApplyChargesToTrades: %w", err)
+					}
+				}
+
+			case ChargesCalculationMethodManual:
+				for _, trade := range finalizedPos.Trades {
+					trade.ChargesAmount = payload.ManualChargeAmount
+					trade.ChargesBreakdown = nil
+				}
 			}
-		}
 
-		// Add the position's total charges amount.
-		// This is calculated from the trades in the position.
-		finalizedPos.TotalChargesAmount = calculateTotalChargesAmountFromTrades(finalizedPos.Trades)
+			// Add the position's total charges amount.
+			// This is calculated from the trades in the position.
+			finalizedPos.TotalChargesAmount = calculateTotalChargesAmountFromTrades(finalizedPos.Trades)
 
-		// As we have updated the trades with charges, we need to recompute the position.
-		computePayload := ComputePayload{
-			RiskAmount: finalizedPos.RiskAmount,
-			Trades:     ConvertTradesToCreatePayload(finalizedPos.Trades),
-		}
+			// As we have updated the trades with charges, we need to recompute the position.
+			computeResult, err := Compute(finalizedPos.ComputePayload())
+			if err != nil {
+				l.Debugw("failed to compute position after charges and marking it as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
+				diff = append(diff, newImportPositionDiff(finalizedPos, ImportPositionActionInvalid))
+				continue
+			}
 
-		computeResult, err := Compute(computePayload)
-		if err != nil {
-			l.Debugw("failed to compute position after charges and marking it as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
-			continue
-		}
+			ApplyComputeResultToPosition(finalizedPos, computeResult)
 
-		ApplyComputeResultToPosition(finalizedPos, computeResult)
+			if isDuplicate {
+				action := ImportPositionActionDuplicate
+				if payload.Force {
+					action = ImportPositionActionReplace
+					if isUpdatingPositionAlreadyInArthveda {
+						action = ImportPositionActionUpdate
+					}
+				}
+				diff = append(diff, newImportPositionDiff(finalizedPos, action))
+
+				// If the force & confirm flags are true, we will have to delete the existing position and create a new position.
+				if payload.Force && payload.Confirm {
+					l.Debugw("force importing a duplicate position, deleting existing position", "symbol", finalizedPos.Symbol, "opened_at", finalizedPos.OpenedAt)
+
+					if batch == nil {
+						batch, err = s.startImportBatch(ctx, payload, ImportBatchSourceFile)
+						if err != nil {
+							svcErr = service.ErrInternalServerError
+							return err
+						}
+					}
+
+					// Replace the existing position, keeping it in the batch to restore on rollback.
+					svcErr, err = s.saveImportedPosition(ctx, batch, finalizedPos, &positionIDForTheDuplicateOrderID)
+					if err != nil {
+						return err
+					}
+
+					positionsImported += 1
+					forcedPositionCount += 1
+					continue
+				}
 
-		if isDuplicate {
-			// If the force & confirm flags are true, we will have to delete the existing position and create a new position.
-			if payload.Force && payload.Confirm {
-				l.Debugw("force importing a duplicate position, deleting existing position", "symbol", finalizedPos.Symbol, "opened_at", finalizedPos.OpenedAt)
+				l.Debugw("skipping position because it is a duplicate", "symbol", finalizedPos.Symbol, "opened_at", finalizedPos.OpenedAt)
+				duplicatePositionsCount += 1
+				finalizedPositions[positionIdx].IsDuplicate = true
 
-				svcErr, err = s.Delete(ctx, payload.UserID, positionIDForTheDuplicateOrderID)
-				if err != nil {
-					return nil, svcErr, fmt.Errorf("failed to delete existing position: %w", err)
-				}
+				// We skip the position if it has any duplicate trades.
+				continue
+			}
 
-				// Create the position in the database
-				err = s.positionRepository.Create(ctx, finalizedPos)
-				if err != nil {
-					return nil, service.ErrInternalServerError, err
+			diff = append(diff, newImportPositionDiff(finalizedPos, ImportPositionActionCreate))
+
+			// If confirm is true, we will create the positions in the database.
+			if payload.Confirm {
+				if batch == nil {
+					batch, err = s.startImportBatch(ctx, payload, ImportBatchSourceFile)
+					if err != nil {
+						svcErr = service.ErrInternalServerError
+						return err
+					}
 				}
 
-				// Create the trades in the database
-				_, err = s.tradeRepository.CreateForPosition(ctx, finalizedPos.Trades)
+				svcErr, err = s.saveImportedPosition(ctx, batch, finalizedPos, nil)
 				if err != nil {
-					// Delete the position if trades creation fails.
-					s.positionRepository.Delete(ctx, finalizedPos.ID)
-					return nil, service.ErrInternalServerError, err
+					return err
 				}
 
 				positionsImported += 1
-				forcedPositionCount += 1
-				continue
 			}
-
-			l.Debugw("skipping position because it is a duplicate", "symbol", finalizedPos.Symbol, "opened_at", finalizedPos.OpenedAt)
-			duplicatePositionsCount += 1
-			finalizedPositions[positionIdx].IsDuplicate = true
-
-			// We skip the position if it has any duplicate trades.
-			continue
 		}
 
-		// If confirm is true, we will create the positions in the database.
-		if payload.Confirm {
-			// Create the position in the database
-			err := s.positionRepository.Create(ctx, finalizedPos)
-			if err != nil {
-				return nil, service.ErrInternalServerError, err
-			}
+		payload.reportProgress(len(finalizedPositions), len(finalizedPositions))
 
-			// Create the trades in the database
-			_, err = s.tradeRepository.CreateForPosition(ctx, finalizedPos.Trades)
-			if err != nil {
-				// Delete the position if trades creation fails.
-				s.positionRepository.Delete(ctx, finalizedPos.ID)
-				return nil, service.ErrInternalServerError, err
+		if batch != nil {
+			if err := s.finishImportBatch(ctx, batch); err != nil {
+				return err
 			}
-
-			positionsImported += 1
 		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	importBatchIDs := []uuid.UUID{}
+	if batch != nil {
+		importBatchIDs = append(importBatchIDs, batch.ID)
 	}
 
 	l.Debugf("Duplicate positions skipped: %d", duplicatePositionsCount)
@@ -1014,8 +1500,15 @@ func (s *Service) Import(ctx context.Context, importableTrades []*types.Importab
 		InvalidPositionsCount:     len(invalidPositionsByPosID),
 		ForcedPositionsCount:      forcedPositionCount,
 		UnsupportedPositionsCount: unsupportedPositionsCount,
+		Diff:                      diff,
+		FailedRows:                []ImportRowIssue{},
+		IgnoredRows:               []ImportRowIssue{},
+		MergedOrders:              []ImportMergedOrder{},
+		ImportBatchIDs:            importBatchIDs,
 	}
 
+	sortImportPositionDiffs(result.Diff)
+
 	return result, service.ErrNone, nil
 }
 
@@ -1027,6 +1520,27 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 		trade.Symbol = symbol.Sanitize(trade.Symbol, trade.Instrument)
 	}
 
+	corporateActionsBySymbol, err := s.getCorporateActionsBySymbol(ctx, importableTrades)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get corporate actions: %w", err)
+	}
+
+	chargeSchedules, err := s.getChargeSchedules(ctx)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get charge schedules: %w", err)
+	}
+
+	brokeragePlan, feeModel, err := s.getBrokeragePlanAndFeeModel(ctx, payload.UserBrokerAccountID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("get brokerage plan and fee model: %w", err)
+	}
+
+	if payload.ChargesCalculationMethod == ChargesCalculationMethodAuto && needsBrokeragePlan(payload.Broker.Name, brokeragePlan, feeModel) {
+		return nil, service.ErrBadRequest, errNoDefaultBrokerage(payload.Broker.Name)
+	}
+
+	segmentBySymbol := getSegmentBySymbol(importableTrades)
+
 	// Fetch all open positions for this user broker account.
 	open := StatusOpen
 	searchPayload := SearchPayload{
@@ -1066,7 +1580,7 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 		if t.OrderID == "" {
 			continue
 		}
-		if !common.ExistsInSet(brokerTradeIDs, t.OrderID) {
+		if !isBrokerTradeImported(brokerTradeIDs, t.OrderID) {
 			filteredTrades = append(filteredTrades, t)
 		}
 	}
@@ -1139,6 +1653,13 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 		enableAutoCharges = true
 	}
 
+	// New positions are computed with the user's default lot-matching method.
+	defaultLotMatchingMethod, err := s.userProfileService.GetLotMatchingMethod(ctx, payload.UserID)
+	if err != nil {
+		l.Errorw("failed to get default lot matching method", "error", err, "user_id", payload.UserID)
+		// Not returning error, FIFO will be used.
+	}
+
 	finalizedPositions := []*Position{}
 	invalidPositions := []*Position{}
 	unsupportedPositions := []*Position{}
@@ -1146,6 +1667,42 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 	positionsImported := 0
 	unsupportedPositionsCount := 0
 
+	// openNewPosition opens a new position for the symbol with the trade as its first trade.
+	openNewPosition := func(symbol string, instrument types.Instrument, tradePayload trade.CreatePayload, brokerTradeID string) {
+		createPayload := CreatePayload{
+			ComputePayload: ComputePayload{
+				Trades:            []trade.CreatePayload{tradePayload},
+				RiskAmount:        payload.RiskAmount,
+				EnableAutoCharges: enableAutoCharges,
+				LotMatchingMethod: defaultLotMatchingMethod,
+				CorporateActions:  corporateActionsBySymbol[strings.ToUpper(symbol)],
+				Segment:           segmentBySymbol[symbol],
+			},
+			Symbol:              symbol,
+			Instrument:          instrument,
+			CurrencyCode:        payload.CurrencyCode,
+			UserBrokerAccountID: &payload.UserBrokerAccountID,
+		}
+
+		newPos, userErr, err := new(payload.UserID, createPayload)
+		if err != nil {
+			l.Errorw("failed to create new position", "error", err)
+			if userErr {
+				invalidPositions = append(invalidPositions, newPos)
+			}
+			return
+		}
+
+		openPositionsUpdatedOrCreated[newPos.ID] = true
+
+		newPos.BrokerID = &payload.Broker.ID
+		if len(newPos.Trades) > 0 {
+			newPos.Trades[0].BrokerTradeID = &brokerTradeID
+		}
+
+		openPositions[symbol] = newPos
+	}
+
 	// For each trade, append to open position or create new one, similar to Import
 	for _, t := range tradesWithOrderIDs {
 		symbol := t.Symbol
@@ -1165,20 +1722,21 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 				openPos.IsDuplicate = true
 			}
 
+			// If the trade reverses the position, only the part that closes it belongs to it.
+			closingPayload, reversalPayload := splitTradeOnPositionReversal(tradePayload, openPos)
+			newTrade.Quantity = closingPayload.Quantity
+			newTrade.ChargesAmount = closingPayload.ChargesAmount
+
 			openPositionsUpdatedOrCreated[openPos.ID] = true
 			newTrade.PositionID = openPos.ID
 			newTrade.BrokerTradeID = &t.OrderID
 			openPos.Trades = append(openPos.Trades, newTrade)
 
 			// Compute and update position
-			computePayload := ComputePayload{
-				RiskAmount: openPos.RiskAmount,
-				Trades:     ConvertTradesToCreatePayload(openPos.Trades),
-			}
-
-			computeResult, err := Compute(computePayload)
+			computeResult, err := Compute(openPos.ComputePayload())
 			if err != nil {
 				l.Debugw("failed to compute position, marking as invalid", "error", err, "position_id", openPos.ID, "symbol", openPos.Symbol)
+				restoreReversingTrade(newTrade, tradePayload)
 				invalidPositions = append(invalidPositions, openPos)
 				delete(openPositions, symbol)
 				continue
@@ -1191,37 +1749,15 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 				finalizedPositions = append(finalizedPositions, openPos)
 				delete(openPositions, symbol)
 			}
-		} else {
-			// Create new position
-			createPayload := CreatePayload{
-				ComputePayload: ComputePayload{
-					Trades:            []trade.CreatePayload{tradePayload},
-					RiskAmount:        payload.RiskAmount,
-					EnableAutoCharges: enableAutoCharges,
-				},
-				Symbol:              t.Symbol,
-				Instrument:          t.Instrument,
-				CurrencyCode:        payload.CurrencyCode,
-				UserBrokerAccountID: &payload.UserBrokerAccountID,
-			}
 
-			newPos, userErr, err := new(payload.UserID, createPayload)
-			if err != nil {
-				l.Errorw("failed to create new position", "error", err)
-				if userErr {
-					invalidPositions = append(invalidPositions, newPos)
-				}
-				continue
+			// The rest of the trade opens a new position in the opposite direction.
+			if reversalPayload != nil {
+				l.Debugw("trade reversed the position, opening a new position with the rest of the trade", "order_id", t.OrderID, "symbol", symbol)
+				openNewPosition(symbol, t.Instrument, *reversalPayload, reversalBrokerTradeID(t.OrderID))
 			}
-
-			openPositionsUpdatedOrCreated[newPos.ID] = true
-
-			newPos.BrokerID = &payload.Broker.ID
-			if len(newPos.Trades) > 0 {
-				newPos.Trades[0].BrokerTradeID = &t.OrderID
-			}
-
-			openPositions[symbol] = newPos
+		} else {
+			// Create new position
+			openNewPosition(symbol, t.Instrument, tradePayload, t.OrderID)
 		}
 	}
 
@@ -1233,101 +1769,109 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 		}
 	}
 
-	for _, finalizedPos := range finalizedPositions {
-		computePayload := ComputePayload{
-			RiskAmount: finalizedPos.RiskAmount,
-			Trades:     ConvertTradesToCreatePayload(finalizedPos.Trades),
-		}
+	// The batch of the positions being saved. Created with the first one, so that a sync
+	// with no new trades leaves no batch behind.
+	var batch *ImportBatch
 
-		computeResult, err := Compute(computePayload)
-		if err != nil {
-			l.Debugw("failed to compute position, marking as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
-			invalidPositions = append(invalidPositions, finalizedPos)
-			continue
-		}
-
-		ApplyComputeResultToPosition(finalizedPos, computeResult)
-
-		switch payload.ChargesCalculationMethod {
-		case ChargesCalculationMethodAuto:
-			_, userErr, err := CalculateAndApplyChargesToTrades(finalizedPos.Trades, finalizedPos.Instrument, payload.Broker.Name)
+	// The positions are saved, and the batch finished, in one transaction so that a failed sync
+	// doesn't leave a replaced position deleted without the batch that keeps it to restore.
+	svcErr := service.ErrInternalServerError
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		for _, finalizedPos := range finalizedPositions {
+			computeResult, err := Compute(finalizedPos.ComputePayload())
 			if err != nil {
-				if userErr {
-					invalidPositions = append(invalidPositions, finalizedPos)
-					continue
-				} else {
-					l.Errorw("failed to auto-calculate charges", "error", err)
-					invalidPositions = append(invalidPositions, finalizedPos)
-					continue
-				}
-			}
-		case ChargesCalculationMethodManual:
-			for _, trade := range finalizedPos.Trades {
-				trade.ChargesAmount = payload.ManualChargeAmount
+				l.Debugw("failed to compute position, marking as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
+				invalidPositions = append(invalidPositions, finalizedPos)
+				continue
 			}
-		}
 
-		finalizedPos.TotalChargesAmount = calculateTotalChargesAmountFromTrades(finalizedPos.Trades)
+			ApplyComputeResultToPosition(finalizedPos, computeResult)
 
-		// Recompute after charges
-		computePayload = ComputePayload{
-			RiskAmount: finalizedPos.RiskAmount,
-			Trades:     ConvertTradesToCreatePayload(finalizedPos.Trades),
-		}
+			switch payload.ChargesCalculationMethod {
+			case ChargesCalculationMethodAuto:
+				_, userErr, err := CalculateAndApplyChargesToTrades(finalizedPos.Trades, finalizedPos.Instrument, finalizedPos.Segment, payload.Broker.Name, brokeragePlan, feeModel, chargeSchedules)
+				if err != nil {
+					if userErr {
+						invalidPositions = append(invalidPositions, finalizedPos)
+						continue
+					} else {
+						l.Errorw("failed to auto-calculate charges", "error", err)
+						invalidPositions = append(invalidPositions, finalizedPos)
+						continue
+					}
+				}
+			case ChargesCalculationMethodManual:
+				for _, trade := range finalizedPos.Trades {
+					trade.ChargesAmount = payload.ManualChargeAmount
+					trade.ChargesBreakdown = nil
+				}
+			}
 
-		computeResult, err = Compute(computePayload)
-		if err != nil {
-			l.Debugw("failed to compute position after charges, marking as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
-			invalidPositions = append(invalidPositions, finalizedPos)
-			continue
-		}
+			finalizedPos.TotalChargesAmount = calculateTotalChargesAmountFromTrades(finalizedPos.Trades)
 
-		ApplyComputeResultToPosition(finalizedPos, computeResult)
+			// Recompute after charges
+			computeResult, err = Compute(finalizedPos.ComputePayload())
+			if err != nil {
+				l.Debugw("failed to compute position after charges, marking as invalid", "error", err, "position_id", finalizedPos.ID, "symbol", finalizedPos.Symbol)
+				invalidPositions = append(invalidPositions, finalizedPos)
+				continue
+			}
 
-		if !payload.Broker.IsInstrumentSupportedForImport(finalizedPos.Instrument) {
-			unsupportedPositions = append(unsupportedPositions, finalizedPos)
-			unsupportedPositionsCount++
-			continue
-		}
+			ApplyComputeResultToPosition(finalizedPos, computeResult)
 
-		_, isExistingPos := existingOpenPositionsByID[finalizedPos.ID]
+			if !payload.isInstrumentSupported(finalizedPos.Instrument) {
+				unsupportedPositions = append(unsupportedPositions, finalizedPos)
+				unsupportedPositionsCount++
+				continue
+			}
 
-		if isExistingPos {
-			// Marking as duplicate so that frontend can show appropriate UI.
-			finalizedPos.IsDuplicate = true
+			_, isExistingPos := existingOpenPositionsByID[finalizedPos.ID]
 
-			svcErr, err := s.Delete(ctx, payload.UserID, finalizedPos.ID)
-			if err != nil {
-				return nil, svcErr, fmt.Errorf("failed to delete existing position: %w", err)
+			if batch == nil {
+				batch, err = s.startImportBatch(ctx, payload, ImportBatchSourceSync)
+				if err != nil {
+					svcErr = service.ErrInternalServerError
+					return err
+				}
 			}
 
-			err = s.positionRepository.Create(ctx, finalizedPos)
-			if err != nil {
-				return nil, service.ErrInternalServerError, err
-			}
+			if isExistingPos {
+				// Marking as duplicate so that frontend can show appropriate UI.
+				finalizedPos.IsDuplicate = true
 
-			_, err = s.tradeRepository.CreateForPosition(ctx, finalizedPos.Trades)
-			if err != nil {
-				// Delete the position if trades creation fails.
-				s.positionRepository.Delete(ctx, finalizedPos.ID)
-				return nil, service.ErrInternalServerError, err
-			}
-		} else {
-			err := s.positionRepository.Create(ctx, finalizedPos)
-			if err != nil {
-				l.Errorw("failed to create position", "error", err, "position_id", finalizedPos.ID)
-				return nil, service.ErrInternalServerError, err
+				// Replace the existing position, keeping it in the batch to restore on rollback.
+				saveSvcErr, err := s.saveImportedPosition(ctx, batch, finalizedPos, &finalizedPos.ID)
+				if err != nil {
+					svcErr = saveSvcErr
+					return err
+				}
+			} else {
+				saveSvcErr, err := s.saveImportedPosition(ctx, batch, finalizedPos, nil)
+				if err != nil {
+					l.Errorw("failed to create position", "error", err, "position_id", finalizedPos.ID)
+					svcErr = saveSvcErr
+					return err
+				}
 			}
 
-			_, err = s.tradeRepository.CreateForPosition(ctx, finalizedPos.Trades)
-			if err != nil {
-				l.Errorw("failed to create trades for new position", "error", err, "position_id", finalizedPos.ID)
-				s.positionRepository.Delete(ctx, finalizedPos.ID)
-				return nil, service.ErrInternalServerError, err
+			positionsImported++
+		}
+
+		if batch != nil {
+			if err := s.finishImportBatch(ctx, batch); err != nil {
+				return err
 			}
 		}
 
-		positionsImported++
+		return nil
+	})
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	importBatchIDs := []uuid.UUID{}
+	if batch != nil {
+		importBatchIDs = append(importBatchIDs, batch.ID)
 	}
 
 	sort.Slice(finalizedPositions, func(i, j int) bool {
@@ -1350,6 +1894,7 @@ func (s *Service) Sync(ctx context.Context, importableTrades []*types.Importable
 		InvalidPositionsCount:     len(invalidPositions),
 		ForcedPositionsCount:      0,
 		UnsupportedPositionsCount: unsupportedPositionsCount,
+		ImportBatchIDs:            importBatchIDs,
 	}
 
 	return result, service.ErrNone, nil
@@ -1393,17 +1938,36 @@ func (s *Service) Get(ctx context.Context, userID, positionID uuid.UUID) (*Posit
 
 	position.Tags = tags
 
+	s.markToMarket(ctx, []*Position{position})
+
 	return position, service.ErrNone, nil
 }
 
+// markToMarket marks the open Positions to market with the price feed.
+// The quotes are best effort, so a failing feed leaves the Positions unmarked instead of failing the request.
+func (s *Service) markToMarket(ctx context.Context, positions []*Position) {
+	symbols := OpenSymbols(positions)
+	if len(symbols) == 0 {
+		return
+	}
+
+	quotes, err := s.priceFeed.GetQuotes(ctx, symbols)
+	if err != nil {
+		logger.FromCtx(ctx).Warnw("Failed to get quotes from the price feed", "error", err.Error())
+		return
+	}
+
+	MarkToMarket(positions, quotes)
+}
+
 type UpdatePayload struct {
 	// We can just use the same payload as CreatePayload for updates.
 	CreatePayload
 	BrokerID *uuid.UUID `json:"broker_id"`
 }
 
-// FIXME: use transaction.
-
+// Update updates the Position and replaces its trades. A trade that takes it through zero is split
+// as in Create, and the rest of it opens a new Position with the trades after it.
 func (s *Service) Update(ctx context.Context, userID, positionID uuid.UUID, payload UpdatePayload) (*Position, service.Error, error) {
 	l := logger.FromCtx(ctx)
 
@@ -1419,6 +1983,19 @@ func (s *Service) Update(ctx context.Context, userID, positionID uuid.UUID, payl
 		return nil, service.ErrUnauthorized, fmt.Errorf("user is not allowed to update position %s", positionID)
 	}
 
+	svcErr, err := s.resolveLotMatchingMethod(ctx, userID, &payload.CreatePayload)
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	svcErr, err = s.resolveCorporateActions(ctx, &payload.CreatePayload)
+	if err != nil {
+		return nil, svcErr, err
+	}
+
+	splitTrades := splitTradesOnReversals(payload.Trades, payload.CorporateActions)
+	payload.Trades = splitTrades[0]
+
 	// Update the position fields, including trades.
 	updatedPosition, userErr, err := originalPosition.update(payload)
 	if err != nil {
@@ -1429,51 +2006,60 @@ func (s *Service) Update(ctx context.Context, userID, positionID uuid.UUID, payl
 		}
 	}
 
-	// Delete existing trades for the position.
-	// This is necessary because we are replacing all trades with the new ones. Simple and effective.
-	err = s.tradeRepository.DeleteByPositionID(ctx, positionID)
+	reversedPositions, svcErr, err := newReversedPositions(userID, payload.CreatePayload, splitTrades[1:])
 	if err != nil {
-		l.Errorw("failed to delete trades for position", "error", err, "position_id", originalPosition.ID)
-		return nil, service.ErrInternalServerError, fmt.Errorf("failed to delete trades for position: %w", err)
+		return nil, svcErr, err
 	}
 
-	// Create new trades for the position.
-	trades, err := s.tradeRepository.CreateForPosition(ctx, updatedPosition.Trades)
-	if err != nil {
-		l.Errorw("failed to create trades for position", "error", err, "position_id", originalPosition.ID)
-		return nil, service.ErrInternalServerError, fmt.Errorf("failed to create new trades for position: %w", err)
-	}
+	svcErr = service.ErrInternalServerError
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		// Delete existing trades for the position.
+		// This is necessary because we are replacing all trades with the new ones. Simple and effective.
+		err := s.tradeRepository.DeleteByPositionID(ctx, positionID)
+		if err != nil {
+			return fmt.Errorf("failed to delete trades for position: %w", err)
+		}
 
-	// Attach the newly created trades to the updated position.
-	updatedPosition.Trades = trades
+		// Create new trades for the position.
+		trades, err := s.tradeRepository.CreateForPosition(ctx, updatedPosition.Trades)
+		if err != nil {
+			return fmt.Errorf("failed to create new trades for position: %w", err)
+		}
 
-	// Update or create the journal entry for the position.
-	journalEntry, err := s.journalEntryService.UpsertForPosition(ctx, userID, positionID, payload.JournalContent)
-	if err != nil {
-		l.Errorw("failed to upsert journal entry for position", "error", err, "position_id", originalPosition.ID)
-		return nil, service.ErrInternalServerError, fmt.Errorf("failed to upsert journal entry for position: %w", err)
-	}
+		// Attach the newly created trades to the updated position.
+		updatedPosition.Trades = trades
 
-	err = s.syncUploads(ctx, userID, journalEntry.ID, payload.ActiveUploadIDs)
-	if err != nil {
-		l.Errorw("failed to sync uploads after creating a position", "error", err, "position_id", positionID)
-		// Not returning an error here, just logging it.
-	}
+		// Update or create the journal entry for the position.
+		journalEntry, err := s.journalEntryService.UpsertForPosition(ctx, userID, positionID, payload.JournalContent)
+		if err != nil {
+			return fmt.Errorf("failed to upsert journal entry for position: %w", err)
+		}
 
-	// Save the updated position in the repository.
-	err = s.positionRepository.Update(ctx, &updatedPosition)
-	if err != nil {
-		l.Errorw("failed to update position in repository", "error", err, "position_id", originalPosition.ID)
-		return nil, service.ErrInternalServerError, fmt.Errorf("failed to update position in repository: %w", err)
-	}
+		err = s.syncUploads(ctx, userID, journalEntry.ID, payload.ActiveUploadIDs)
+		if err != nil {
+			l.Errorw("failed to sync uploads after creating a position", "error", err, "position_id", positionID)
+			// Not returning an error here, just logging it.
+		}
 
-	svcErr, err := s.tagService.AttachTagToPosition(ctx, tag.AttachTagToPositionPayload{
-		PositionID: positionID,
-		TagIDs:     payload.TagIDs,
-	})
+		// Save the updated position in the repository.
+		err = s.positionRepository.Update(ctx, &updatedPosition)
+		if err != nil {
+			return fmt.Errorf("failed to update position in repository: %w", err)
+		}
+
+		tagSvcErr, err := s.tagService.AttachTagToPosition(ctx, tag.AttachTagToPositionPayload{
+			PositionID: positionID,
+			TagIDs:     payload.TagIDs,
+		})
+		if err != nil {
+			svcErr = tagSvcErr
+			return err
+		}
 
+		return createPositionsWithTrades(ctx, s.positionRepository, s.tradeRepository, reversedPositions)
+	})
 	if err != nil {
-		l.Errorw("failed to attach tags to position after creating a position", "error", err, "position_id", positionID)
+		l.Errorw("failed to update position", "error", err, "position_id", positionID)
 		return nil, svcErr, err
 	}
 
@@ -1510,6 +2096,151 @@ func (s *Service) Delete(ctx context.Context, userID, positionID uuid.UUID) (ser
 	return service.ErrNone, nil
 }
 
+// splitReversedPosition cuts the stored Position at the trade that takes it through zero, if any, and saves
+// its new trades and the Positions opened by the rest of them. See SplitReversedPosition.
+func (s *Service) splitReversedPosition(ctx context.Context, pos *Position) error {
+	reversedPositions, err := SplitReversedPosition(pos)
+	if err != nil {
+		return fmt.Errorf("split reversed position: %w", err)
+	}
+
+	if len(reversedPositions) == 0 {
+		return nil
+	}
+
+	logger.FromCtx(ctx).Infow("splitting position that a trade reverses", "position_id", pos.ID, "reversed_positions_count", len(reversedPositions))
+
+	return dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		return SaveSplitPosition(ctx, s.positionRepository, s.tradeRepository, pos, reversedPositions)
+	})
+}
+
+// SaveSplitPosition saves the new trades of a stored Position that SplitReversedPosition cut,
+// and creates the Positions opened by the rest of them.
+func SaveSplitPosition(ctx context.Context, positionRepository Writer, tradeRepository trade.Writer, pos *Position, reversedPositions []*Position) error {
+	if err := tradeRepository.DeleteByPositionID(ctx, pos.ID); err != nil {
+		return fmt.Errorf("trade repository delete by position id: %w", err)
+	}
+
+	if _, err := tradeRepository.CreateForPosition(ctx, pos.Trades); err != nil {
+		return fmt.Errorf("trade repository create for position: %w", err)
+	}
+
+	return createPositionsWithTrades(ctx, positionRepository, tradeRepository, reversedPositions)
+}
+
+// RecomputeForUser recomputes and saves all the positions of the user that follow the user's
+// default lot-matching method. It must be called whenever the user changes their default, in the
+// transaction that changes it, so that no position is left computed with the old method.
+func (s *Service) RecomputeForUser(ctx context.Context, userID uuid.UUID) (int, service.Error, error) {
+	l := logger.FromCtx(ctx)
+
+	searchPayload := SearchPayload{
+		Filters: SearchFilter{
+			CreatedBy: &userID,
+		},
+	}
+
+	positions, _, err := s.positionRepository.Search(ctx, searchPayload, true, false)
+	if err != nil {
+		return 0, service.ErrInternalServerError, fmt.Errorf("position repository search: %w", err)
+	}
+
+	recomputedCount := 0
+
+	for _, pos := range positions {
+		// This position has its own lot-matching method, so the user's default doesn't apply.
+		if pos.LotMatchingMethod != nil {
+			continue
+		}
+
+		if err := s.splitReversedPosition(ctx, pos); err != nil {
+			return recomputedCount, service.ErrInternalServerError, err
+		}
+
+		computeResult, err := Compute(pos.ComputePayload())
+		if err != nil {
+			l.Errorw("failed to recompute position", "error", err, "position_id", pos.ID)
+			return recomputedCount, service.ErrInternalServerError, fmt.Errorf("compute position %s: %w", pos.ID, err)
+		}
+
+		now := time.Now().UTC()
+		pos.UpdatedAt = &now
+
+		ApplyComputeResultToPosition(pos, computeResult)
+		pos.GrossPnLAmountAway = &computeResult.GrossPnLAmountAway
+		pos.NetPnLAmountAway = &computeResult.NetPnLAmountAway
+		pos.TotalChargesAmountAway = &computeResult.TotalChargesAmountAway
+
+		err = s.positionRepository.Update(ctx, pos)
+		if err != nil {
+			return recomputedCount, service.ErrInternalServerError, fmt.Errorf("position repository update: %w", err)
+		}
+
+		err = s.tradeRepository.UpdateRealisedStats(ctx, pos.Trades)
+		if err != nil {
+			return recomputedCount, service.ErrInternalServerError, fmt.Errorf("trade repository update realised stats: %w", err)
+		}
+
+		recomputedCount++
+	}
+
+	return recomputedCount, service.ErrNone, nil
+}
+
+// ComputeExcursion computes and stores the MAE, MFE and exit efficiency of a Position from the candles of its symbol.
+// The candles are parsed from `candleRows` (rows of a CSV file) if provided, otherwise they are fetched from the price store.
+func (s *Service) ComputeExcursion(ctx context.Context, userID, positionID uuid.UUID, tz *time.Location, candleRows [][]string) (*Position, service.Error, error) {
+	l := logger.FromCtx(ctx)
+
+	position, err := s.positionRepository.GetByID(ctx, userID, positionID)
+	if err != nil || position == nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Position not found with ID: %s", positionID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("failed to get position by ID: %w", err)
+	}
+
+	var candles []price.Candle
+
+	if len(candleRows) > 0 {
+		candles, err = price.ParseCandles(candleRows, tz)
+		if err != nil {
+			return nil, service.ErrBadRequest, err
+		}
+	} else {
+		// Start of the day the Position was opened, so that the candle the first trade falls in is included.
+		from := time.Date(position.OpenedAt.Year(), position.OpenedAt.Month(), position.OpenedAt.Day(), 0, 0, 0, 0, time.UTC)
+		to := time.Now().UTC()
+		if position.ClosedAt != nil {
+			to = *position.ClosedAt
+		}
+
+		candles, err = s.priceStore.GetCandles(ctx, position.Symbol, from, to)
+		if err != nil {
+			if err == price.ErrNoCandles || err == price.ErrStoreNotConfigured {
+				return nil, service.ErrBadRequest, err
+			}
+			return nil, service.ErrInternalServerError, fmt.Errorf("price store get candles: %w", err)
+		}
+	}
+
+	excursion, err := computeExcursion(position.Trades, position.Direction, candles)
+	if err != nil {
+		return nil, service.ErrBadRequest, err
+	}
+
+	applyExcursionToPosition(position, excursion)
+
+	err = s.positionRepository.Update(ctx, position)
+	if err != nil {
+		l.Errorw("failed to update position with excursion", "error", err, "position_id", position.ID)
+		return nil, service.ErrInternalServerError, fmt.Errorf("position repository update: %w", err)
+	}
+
+	return position, service.ErrNone, nil
+}
+
 func (s *Service) syncUploads(ctx context.Context, userID, journalEntryID uuid.UUID, activeUploadIDs []uuid.UUID) error {
 	err := s.uploadRepository.SyncJournalEntryUploads(ctx, userID, journalEntryID, activeUploadIDs)
 	if err != nil {
@@ -1531,6 +2262,10 @@ func (s *Service) Export(ctx context.Context, userID uuid.UUID, tz *time.Locatio
 		return nil, service.ErrInvalidInput, err
 	}
 
+	if payload.Filters.ProductType != nil && *payload.Filters.ProductType != "" && !payload.Filters.ProductType.IsValid() {
+		return nil, service.ErrBadRequest, errInvalidProductType
+	}
+
 	twelveMonthsAgo := time.Now().AddDate(-1, 0, 0)
 
 	// If the user is not a Pro user, we limit the time range to the last 12 months.
@@ -1614,7 +2349,7 @@ func WritePositionsToSheet(f *excelize.File, sheet string, positions []*Position
 		"#", "Account", "Broker",
 		"Opened At", "Closed At", "Duration", "Symbol", "Direction", "Status", "Instrument",
 		"Net R", "Gross R", "Gross PnL", "Net PnL", "Charges", "Charges %", "Net Return %",
-		"Tags",
+		"Tags", "MAE", "MFE", "Exit Efficiency %",
 	}
 
 	for i, h := range headers {
@@ -1703,5 +2438,16 @@ func WritePositionsToSheet(f *excelize.File, sheet string, positions []*Position
 		tagsStr := strings.Join(tagNames, ", ")
 
 		f.SetCellValue(sheet, fmt.Sprintf("R%d", row), tagsStr)
+
+		// Excursion, left empty if it wasn't computed.
+		if p.MAEAmount != nil {
+			f.SetCellValue(sheet, fmt.Sprintf("S%d", row), *p.MAEAmount)
+		}
+		if p.MFEAmount != nil {
+			f.SetCellValue(sheet, fmt.Sprintf("T%d", row), *p.MFEAmount)
+		}
+		if p.ExitEfficiencyPercentage != nil {
+			f.SetCellValue(sheet, fmt.Sprintf("U%d", row), *p.ExitEfficiencyPercentage)
+		}
 	}
 }
@@ -1,6 +1,13 @@
 package report
 
-import "github.com/shopspring/decimal"
+import (
+	"arthveda/internal/domain/price"
+	"arthveda/internal/domain/symbol"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 func aggregateOthers(base *symbolsPerformanceItem, item symbolsPerformanceItem) *symbolsPerformanceItem {
 	base.Symbol = "Others"
@@ -18,3 +25,71 @@ func aggregateOthers(base *symbolsPerformanceItem, item symbolsPerformanceItem)
 
 	return base
 }
+
+// daysToExpiryBuckets are the buckets of the days to expiry at entry of the derivatives report.
+// A bucket includes the days from its min up to the min of the next bucket.
+var daysToExpiryBuckets = []struct {
+	label string
+	min   int
+}{
+	{"0", 0},
+	{"1-2", 1},
+	{"3-7", 3},
+	{"8-30", 8},
+	{"31+", 31},
+}
+
+func daysToExpiryBucket(days int) string {
+	label := daysToExpiryBuckets[0].label
+	for _, b := range daysToExpiryBuckets {
+		if days >= b.min {
+			label = b.label
+		}
+	}
+	return label
+}
+
+type moneyness string
+
+const (
+	moneynessITM     moneyness = "itm"
+	moneynessATM     moneyness = "atm"
+	moneynessOTM     moneyness = "otm"
+	moneynessUnknown moneyness = "unknown"
+)
+
+// atmThreshold is how far, as a fraction of the strike, the underlying can be from the strike
+// for an option to be considered at the money.
+var atmThreshold = decimal.NewFromFloat(0.005)
+
+// getMoneyness returns the moneyness of an option with the strike when the underlying is at `underlyingPrice`.
+func getMoneyness(right symbol.OptionRight, strike, underlyingPrice decimal.Decimal) moneyness {
+	if !strike.IsPositive() || !underlyingPrice.IsPositive() {
+		return moneynessUnknown
+	}
+
+	band := strike.Mul(atmThreshold)
+	diff := underlyingPrice.Sub(strike)
+	if right == symbol.OptionRightPut {
+		diff = diff.Neg()
+	}
+
+	switch {
+	case diff.Abs().LessThanOrEqual(band):
+		return moneynessATM
+	case diff.IsPositive():
+		return moneynessITM
+	default:
+		return moneynessOTM
+	}
+}
+
+// priceAt returns the close of the last candle that started at or before `t`.
+// The candles must be sorted by time.
+func priceAt(candles []price.Candle, t time.Time) (decimal.Decimal, bool) {
+	i := sort.Search(len(candles), func(i int) bool { return candles[i].Time.After(t) })
+	if i == 0 {
+		return decimal.Zero, false
+	}
+	return candles[i-1].Close, true
+}
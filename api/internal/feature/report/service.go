@@ -2,12 +2,16 @@ package report
 
 import (
 	"arthveda/internal/common"
+	"arthveda/internal/domain/price"
 	"arthveda/internal/domain/subscription"
+	"arthveda/internal/domain/symbol"
 	"arthveda/internal/domain/types"
 	"arthveda/internal/feature/calendar"
 	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/strategy"
 	"arthveda/internal/feature/tag"
 	"arthveda/internal/logger"
+	"errors"
 	"fmt"
 	"slices"
 
@@ -25,16 +29,20 @@ type Service struct {
 	positionRepository position.ReadWriter
 	tagRepository      tag.ReadWriter
 	calendarService    *calendar.Service
+	strategyService    *strategy.Service
+	priceStore         price.Store
 }
 
 func NewService(
 	positionRepository position.ReadWriter, tagRepository tag.ReadWriter,
-	calendarService *calendar.Service,
+	calendarService *calendar.Service, strategyService *strategy.Service, priceStore price.Store,
 ) *Service {
 	return &Service{
 		positionRepository: positionRepository,
 		tagRepository:      tagRepository,
 		calendarService:    calendarService,
+		strategyService:    strategyService,
+		priceStore:         priceStore,
 	}
 }
 
@@ -69,7 +77,7 @@ type GetTabsResult struct {
 	CumulativePnLByTagGroup []cumulativePnLByTagGroup `json:"cumulative_pnl_by_tag_group"`
 }
 
-func (s *Service) GetTags(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer) (*GetTabsResult, service.Error, error) {
+func (s *Service) GetTags(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetTabsResult, service.Error, error) {
 	l := logger.Get()
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	tradeTimeRange := &common.DateRangeFilter{}
@@ -82,6 +90,7 @@ func (s *Service) GetTags(ctx context.Context, userID uuid.UUID, tz *time.Locati
 		Filters: position.SearchFilter{
 			CreatedBy: &userID,
 			TradeTime: tradeTimeRange,
+			Segment:   segment,
 		},
 		Sort: common.Sorting{
 			Field: "opened_at",
@@ -157,17 +166,7 @@ func (s *Service) GetTags(ctx context.Context, userID uuid.UUID, tz *time.Locati
 
 			bucketPeriod := common.GetBucketPeriodForRange(rangeStart, rangeEnd)
 
-			positionsFiltered := position.FilterPositionsWithRealisingTradesUpTo(positions, rangeEnd, tz)
-
-			for _, pos := range positionsFiltered {
-				_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-				if err != nil {
-					l.Errorw("failed to compute smart trades for position", "position_id", pos.ID, "error", err)
-					continue
-				}
-			}
-
-			cumulative := position.GetCumulativePnLBuckets(tagPositions, bucketPeriod, rangeStart, rangeEnd, tz)
+			cumulative := position.GetCumulativePnLBuckets(tagPositions, nil, nil, bucketPeriod, rangeStart, rangeEnd, tz)
 
 			group.Tags = append(group.Tags, cumulativePnLByTag{
 				TagGroup: tg.TagGroup.Name,
@@ -297,7 +296,7 @@ type GetTimeframesResult struct {
 	HoldingPeriod []HoldingPeriodItem `json:"holding_period"`
 }
 
-func (s *Service) GetTimeframes(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer) (*GetTimeframesResult, service.Error, error) {
+func (s *Service) GetTimeframes(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetTimeframesResult, service.Error, error) {
 	calendarResult, svcErr, err := s.calendarService.GetAll(ctx, userID, tz, enforcer)
 	if err != nil {
 		return nil, svcErr, err
@@ -362,6 +361,7 @@ func (s *Service) GetTimeframes(ctx context.Context, userID uuid.UUID, tz *time.
 		Filters: position.SearchFilter{
 			CreatedBy: &userID,
 			TradeTime: tradeTimeRange,
+			Segment:   segment,
 		},
 		Sort: common.Sorting{
 			Field: "opened_at",
@@ -387,11 +387,6 @@ func (s *Service) GetTimeframes(ctx context.Context, userID uuid.UUID, tz *time.
 			continue
 		}
 
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			continue
-		}
-
 		refTime := pos.OpenedAt.In(tz)
 		h := refTime.Hour()
 		hour := common.Hour(fmt.Sprintf("%02d_%02d", h, h+1))
@@ -583,7 +578,7 @@ type GetSymbolsResult struct {
 	TopTraded        []symbolsPerformanceItem `json:"top_traded"`
 }
 
-func (s *Service) GetSymbols(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer) (*GetSymbolsResult, service.Error, error) {
+func (s *Service) GetSymbols(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetSymbolsResult, service.Error, error) {
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	tradeTimeRange := &common.DateRangeFilter{}
 
@@ -595,6 +590,7 @@ func (s *Service) GetSymbols(ctx context.Context, userID uuid.UUID, tz *time.Loc
 		Filters: position.SearchFilter{
 			CreatedBy: &userID,
 			TradeTime: tradeTimeRange,
+			Segment:   segment,
 		},
 		Sort: common.Sorting{
 			Field: "opened_at",
@@ -639,11 +635,6 @@ func (s *Service) GetSymbols(ctx context.Context, userID uuid.UUID, tz *time.Loc
 			continue
 		}
 
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			continue
-		}
-
 		a, ok := symbolMap[pos.Symbol]
 		if !ok {
 			a = &agg{}
@@ -826,13 +817,26 @@ type instrumentPerformanceItem struct {
 	GrossPnL                 decimal.Decimal  `json:"gross_pnl"`
 	NetPnL                   decimal.Decimal  `json:"net_pnl"`
 	NetPnLPercentage         decimal.Decimal  `json:"net_pnl_percentage"`
+
+	// The performance of the instrument's intraday, BTST, swing and delivery positions.
+	ProductTypes []productTypePerformanceItem `json:"product_types"`
+}
+
+type productTypePerformanceItem struct {
+	ProductType position.ProductType `json:"product_type"`
+	// Of the positions of the instrument.
+	PositionsCount           int             `json:"positions_count"`
+	PositionsCountPercentage decimal.Decimal `json:"positions_count_percentage"`
+	WinRate                  decimal.Decimal `json:"win_rate"`
+	GrossPnL                 decimal.Decimal `json:"gross_pnl"`
+	NetPnL                   decimal.Decimal `json:"net_pnl"`
 }
 
 type GetInstrumentsResult struct {
 	Performance []instrumentPerformanceItem `json:"performance"`
 }
 
-func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer) (*GetInstrumentsResult, service.Error, error) {
+func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetInstrumentsResult, service.Error, error) {
 	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
 	tradeTimeRange := &common.DateRangeFilter{}
 
@@ -844,6 +848,7 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 		Filters: position.SearchFilter{
 			CreatedBy: &userID,
 			TradeTime: tradeTimeRange,
+			Segment:   segment,
 		},
 		Sort: common.Sorting{
 			Field: "opened_at",
@@ -867,6 +872,8 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 
 		grossPnL decimal.Decimal
 		netPnL   decimal.Decimal
+
+		byProductType map[position.ProductType]*instrumentAgg
 	}
 
 	aggMap := make(map[types.Instrument]*instrumentAgg)
@@ -880,11 +887,6 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 			continue
 		}
 
-		_, err := position.ComputeSmartTrades(pos.Trades, pos.Direction, pos.RiskAmount)
-		if err != nil {
-			continue
-		}
-
 		instr := pos.Instrument
 		if instr == "" {
 			continue
@@ -892,25 +894,35 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 
 		if _, ok := aggMap[instr]; !ok {
 			aggMap[instr] = &instrumentAgg{
-				instrument: instr,
-				grossPnL:   decimal.Zero,
-				netPnL:     decimal.Zero,
+				instrument:    instr,
+				grossPnL:      decimal.Zero,
+				netPnL:        decimal.Zero,
+				byProductType: map[position.ProductType]*instrumentAgg{},
 			}
 		}
 
 		agg := aggMap[instr]
 
-		agg.positionsCount++
-		totalPositions++
+		productType := pos.ProductType
+
+		if _, ok := agg.byProductType[productType]; !ok {
+			agg.byProductType[productType] = &instrumentAgg{instrument: instr}
+		}
 
-		// Net PnL (assuming pos.NetPnL exists)
-		agg.grossPnL = agg.grossPnL.Add(pos.GrossPnLAmount)
-		agg.netPnL = agg.netPnL.Add(pos.NetPnLAmount)
+		totalPositions++
 		totalNetPnL = totalNetPnL.Add(pos.NetPnLAmount)
 
-		// Win
-		if pos.GrossPnLAmount.GreaterThan(decimal.Zero) {
-			agg.wins++
+		for _, a := range []*instrumentAgg{agg, agg.byProductType[productType]} {
+			a.positionsCount++
+
+			// Net PnL (assuming pos.NetPnL exists)
+			a.grossPnL = a.grossPnL.Add(pos.GrossPnLAmount)
+			a.netPnL = a.netPnL.Add(pos.NetPnLAmount)
+
+			// Win
+			if pos.GrossPnLAmount.GreaterThan(decimal.Zero) {
+				a.wins++
+			}
 		}
 	}
 
@@ -946,6 +958,25 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 				Mul(decimal.NewFromInt(100))
 		}
 
+		productTypes := make([]productTypePerformanceItem, 0, len(agg.byProductType))
+		for _, productType := range []position.ProductType{position.ProductTypeIntraday, position.ProductTypeBTST, position.ProductTypeSwing, position.ProductTypeDelivery, position.ProductTypeCarryForward} {
+			productTypeAgg, ok := agg.byProductType[productType]
+			if !ok {
+				continue
+			}
+
+			productTypeCountDec := decimal.NewFromInt(int64(productTypeAgg.positionsCount))
+
+			productTypes = append(productTypes, productTypePerformanceItem{
+				ProductType:              productType,
+				PositionsCount:           productTypeAgg.positionsCount,
+				PositionsCountPercentage: productTypeCountDec.Div(positionsCountDec).Mul(decimal.NewFromInt(100)),
+				WinRate:                  decimal.NewFromInt(int64(productTypeAgg.wins)).Div(productTypeCountDec).Mul(decimal.NewFromInt(100)),
+				GrossPnL:                 productTypeAgg.grossPnL,
+				NetPnL:                   productTypeAgg.netPnL,
+			})
+		}
+
 		result = append(result, instrumentPerformanceItem{
 			Instrument:               agg.instrument,
 			PositionsCount:           agg.positionsCount,
@@ -954,6 +985,7 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 			GrossPnL:                 agg.grossPnL,
 			NetPnL:                   agg.netPnL,
 			NetPnLPercentage:         netPnLPct,
+			ProductTypes:             productTypes,
 		})
 	}
 
@@ -966,3 +998,353 @@ func (s *Service) GetInstruments(ctx context.Context, userID uuid.UUID, tz *time
 	}, service.ErrNone, nil
 
 }
+
+type strategyKindPerformanceItem struct {
+	position.GeneralStats
+
+	Kind            strategy.Kind   `json:"kind"`
+	StrategiesCount int             `json:"strategies_count"`
+	AvgMaxRisk      decimal.Decimal `json:"avg_max_risk"`
+}
+
+type GetStrategiesResult struct {
+	// The stats of all the Strategies, each Strategy is counted as one position.
+	Summary     position.GeneralStats         `json:"summary"`
+	Performance []strategyKindPerformanceItem `json:"performance"`
+}
+
+func (s *Service) GetStrategies(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetStrategiesResult, service.Error, error) {
+	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
+
+	strategies, err := s.strategyService.All(ctx, userID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	all := []*position.Position{}
+	positionsByKind := map[strategy.Kind][]*position.Position{}
+	maxRiskByKind := map[strategy.Kind][]decimal.Decimal{}
+
+	for _, st := range strategies {
+		if len(st.Legs) == 0 {
+			continue
+		}
+
+		// All the legs of a Strategy are on the same segment, see strategy.Service.Create.
+		if segment != nil && *segment != "" && st.Legs[0].Segment.OrDefault() != segment.OrDefault() {
+			continue
+		}
+
+		// If the user is not a Pro user, we limit the time range to the last 12 months.
+		if !enforcer.CanAccessAllPositions() && st.OpenedAt.Before(yearAgo) {
+			continue
+		}
+
+		p := st.AsPosition()
+		all = append(all, p)
+		positionsByKind[st.Kind] = append(positionsByKind[st.Kind], p)
+
+		if st.MaxRiskAmount != nil {
+			maxRiskByKind[st.Kind] = append(maxRiskByKind[st.Kind], *st.MaxRiskAmount)
+		}
+	}
+
+	result := &GetStrategiesResult{
+		Summary:     position.GetGeneralStats(all),
+		Performance: []strategyKindPerformanceItem{},
+	}
+
+	for kind, positions := range positionsByKind {
+		avgMaxRisk := decimal.Zero
+		if len(maxRiskByKind[kind]) > 0 {
+			avgMaxRisk = decimal.Sum(decimal.Zero, maxRiskByKind[kind]...).Div(decimal.NewFromInt(int64(len(maxRiskByKind[kind]))))
+		}
+
+		result.Performance = append(result.Performance, strategyKindPerformanceItem{
+			GeneralStats:    position.GetGeneralStats(positions),
+			Kind:            kind,
+			StrategiesCount: len(positions),
+			AvgMaxRisk:      avgMaxRisk,
+		})
+	}
+
+	slices.SortFunc(result.Performance, func(a, b strategyKindPerformanceItem) int {
+		return b.StrategiesCount - a.StrategiesCount
+	})
+
+	return result, service.ErrNone, nil
+}
+
+type derivativesPerformanceItem struct {
+	position.GeneralStats
+
+	// The underlying, days to expiry bucket or moneyness, depending on the grouping.
+	Key            string `json:"key"`
+	PositionsCount int    `json:"positions_count"`
+}
+
+type GetDerivativesResult struct {
+	ByUnderlying []derivativesPerformanceItem `json:"by_underlying"`
+	// The days to expiry of the contract when the position was opened.
+	ByDaysToExpiry []derivativesPerformanceItem `json:"by_days_to_expiry"`
+	// The moneyness of the option when the position was opened. Futures aren't included.
+	ByMoneyness []derivativesPerformanceItem `json:"by_moneyness"`
+}
+
+// GetDerivatives reports the performance of the closed future and option positions grouped by
+// their underlying, days to expiry and moneyness at entry. The moneyness is computed from the
+// candles of the underlying in the price store and is unknown if they aren't available.
+func (s *Service) GetDerivatives(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetDerivativesResult, service.Error, error) {
+	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
+
+	searchPositionPayload := position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy: &userID,
+			Segment:   segment,
+		},
+		Sort: common.Sorting{
+			Field: "opened_at",
+			Order: common.SortOrderASC,
+		},
+	}
+
+	if !enforcer.CanAccessAllPositions() {
+		searchPositionPayload.Filters.Opened = &common.DateRangeFilter{From: &yearAgo}
+	}
+
+	positions, _, err := s.positionRepository.Search(ctx, searchPositionPayload, false, false)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	type derivative struct {
+		position *position.Position
+		contract symbol.Contract
+	}
+
+	derivatives := []derivative{}
+	openedAtByUnderlying := map[string][]time.Time{}
+
+	for _, pos := range positions {
+		if pos.Status == position.StatusOpen {
+			continue
+		}
+
+		contract, ok := pos.Contract()
+		if !ok {
+			continue
+		}
+
+		derivatives = append(derivatives, derivative{pos, contract})
+
+		if contract.IsOption() {
+			openedAtByUnderlying[contract.Underlying] = append(openedAtByUnderlying[contract.Underlying], pos.OpenedAt)
+		}
+	}
+
+	// The candles of every underlying over the time its options were opened, to price it at entry.
+	candlesByUnderlying := map[string][]price.Candle{}
+	for underlying, openedAts := range openedAtByUnderlying {
+		// Positions are sorted by opened at, so the first and last are the range. We look back a few
+		// days before the first so that an entry on a Monday can be priced from Friday's candle.
+		from := openedAts[0].AddDate(0, 0, -7)
+		to := openedAts[len(openedAts)-1]
+
+		candles, err := s.priceStore.GetCandles(ctx, underlying, from, to)
+		if err != nil {
+			if !errors.Is(err, price.ErrNoCandles) {
+				logger.FromCtx(ctx).Warnw("failed to get candles of underlying for derivatives report", "underlying", underlying, "error", err.Error())
+			}
+			continue
+		}
+
+		candlesByUnderlying[underlying] = candles
+	}
+
+	byUnderlying := map[string][]*position.Position{}
+	byDaysToExpiry := map[string][]*position.Position{}
+	byMoneyness := map[string][]*position.Position{}
+
+	for _, d := range derivatives {
+		byUnderlying[d.contract.Underlying] = append(byUnderlying[d.contract.Underlying], d.position)
+
+		if days, ok := d.contract.DaysToExpiry(d.position.OpenedAt.In(tz)); ok && days >= 0 {
+			bucket := daysToExpiryBucket(days)
+			byDaysToExpiry[bucket] = append(byDaysToExpiry[bucket], d.position)
+		}
+
+		if d.contract.IsOption() {
+			m := moneynessUnknown
+			if underlyingPrice, ok := priceAt(candlesByUnderlying[d.contract.Underlying], d.position.OpenedAt); ok {
+				m = getMoneyness(*d.contract.Right, *d.contract.Strike, underlyingPrice)
+			}
+			byMoneyness[string(m)] = append(byMoneyness[string(m)], d.position)
+		}
+	}
+
+	toItems := func(groups map[string][]*position.Position) []derivativesPerformanceItem {
+		items := make([]derivativesPerformanceItem, 0, len(groups))
+		for key, positions := range groups {
+			items = append(items, derivativesPerformanceItem{
+				GeneralStats:   position.GetGeneralStats(positions),
+				Key:            key,
+				PositionsCount: len(positions),
+			})
+		}
+		return items
+	}
+
+	result := &GetDerivativesResult{
+		ByUnderlying:   toItems(byUnderlying),
+		ByDaysToExpiry: toItems(byDaysToExpiry),
+		ByMoneyness:    toItems(byMoneyness),
+	}
+
+	slices.SortFunc(result.ByUnderlying, func(a, b derivativesPerformanceItem) int {
+		return b.PositionsCount - a.PositionsCount
+	})
+
+	bucketOrder := map[string]int{}
+	for i, b := range daysToExpiryBuckets {
+		bucketOrder[b.label] = i
+	}
+	slices.SortFunc(result.ByDaysToExpiry, func(a, b derivativesPerformanceItem) int {
+		return bucketOrder[a.Key] - bucketOrder[b.Key]
+	})
+
+	moneynessOrder := map[string]int{string(moneynessITM): 0, string(moneynessATM): 1, string(moneynessOTM): 2, string(moneynessUnknown): 3}
+	slices.SortFunc(result.ByMoneyness, func(a, b derivativesPerformanceItem) int {
+		return moneynessOrder[a.Key] - moneynessOrder[b.Key]
+	})
+
+	return result, service.ErrNone, nil
+}
+
+type GetChargesResult struct {
+	Summary position.ChargesSummary  `json:"summary"`
+	Buckets []position.ChargesBucket `json:"buckets"`
+}
+
+// GetCharges returns where the charges of the user's trades go, by month.
+func (s *Service) GetCharges(ctx context.Context, userID uuid.UUID, tz *time.Location, enforcer *subscription.PlanEnforcer, segment *types.Segment) (*GetChargesResult, service.Error, error) {
+	yearAgo := time.Now().In(tz).AddDate(-1, 0, 0)
+	tradeTimeRange := &common.DateRangeFilter{}
+
+	if !enforcer.CanAccessAllPositions() {
+		tradeTimeRange.From = &yearAgo
+	}
+
+	searchPositionPayload := position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy: &userID,
+			TradeTime: tradeTimeRange,
+			Segment:   segment,
+		},
+		Sort: common.Sorting{
+			Field: "opened_at",
+			Order: common.SortOrderASC,
+		},
+	}
+
+	positions, _, err := s.positionRepository.Search(ctx, searchPositionPayload, true, false)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	if len(positions) == 0 {
+		return &GetChargesResult{Buckets: []position.ChargesBucket{}}, service.ErrNone, nil
+	}
+
+	rangeStart, rangeEnd := position.GetRangeBasedOnTrades(positions)
+	if tradeTimeRange.From != nil && rangeStart.Before(*tradeTimeRange.From) {
+		rangeStart = *tradeTimeRange.From
+	}
+
+	buckets := position.GetChargesBuckets(positions, common.BucketPeriodMonthly, rangeStart, rangeEnd, tz)
+
+	return &GetChargesResult{
+		Summary: position.SumChargesBuckets(buckets),
+		Buckets: buckets,
+	}, service.ErrNone, nil
+}
+
+// GetTaxPnL returns the P&L of the user's trades closed in the financial year by its head of income in the ITR.
+func (s *Service) GetTaxPnL(ctx context.Context, userID uuid.UUID, tz *time.Location, fy FinancialYear) (*TaxPnLResult, service.Error, error) {
+	positions, err := s.getPositionsOfFinancialYear(ctx, userID, tz, fy)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	// The FMV of grandfathered equity is its highest price on 31 January 2018.
+	fmvBySymbol := map[string]decimal.Decimal{}
+	fmvFrom := time.Date(2018, time.January, 31, 0, 0, 0, 0, grandfatheringCutoff.Location())
+
+	for _, pos := range positions {
+		if pos.Instrument != types.InstrumentEquity || !pos.OpenedAt.Before(grandfatheringCutoff) {
+			continue
+		}
+
+		if _, ok := fmvBySymbol[pos.Symbol]; ok {
+			continue
+		}
+
+		candles, err := s.priceStore.GetCandles(ctx, pos.Symbol, fmvFrom, grandfatheringCutoff.Add(-time.Nanosecond))
+		if err != nil {
+			if !errors.Is(err, price.ErrNoCandles) {
+				logger.FromCtx(ctx).Warnw("failed to get candles of symbol for tax pnl report", "symbol", pos.Symbol, "error", err.Error())
+			}
+			continue
+		}
+
+		high := decimal.Zero
+		for _, c := range candles {
+			high = decimal.Max(high, c.High)
+		}
+
+		if high.IsPositive() {
+			fmvBySymbol[pos.Symbol] = high
+		}
+	}
+
+	result, err := computeTaxPnL(positions, fy, tz, fmvBySymbol)
+	if err != nil {
+		return nil, service.ErrBadRequest, err
+	}
+
+	return result, service.ErrNone, nil
+}
+
+// GetTurnover returns the turnover of the user's business income in the financial year, by broker account,
+// and whether it crosses the thresholds of a tax audit.
+func (s *Service) GetTurnover(ctx context.Context, userID uuid.UUID, tz *time.Location, fy FinancialYear) (*TurnoverResult, service.Error, error) {
+	positions, err := s.getPositionsOfFinancialYear(ctx, userID, tz, fy)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	return computeTurnover(positions, fy, tz), service.ErrNone, nil
+}
+
+// getPositionsOfFinancialYear returns the user's positions with trades in the financial year, with all their trades.
+func (s *Service) getPositionsOfFinancialYear(ctx context.Context, userID uuid.UUID, tz *time.Location, fy FinancialYear) ([]*position.Position, error) {
+	from, to := fy.Range(tz)
+
+	before := to.Add(-time.Nanosecond)
+	searchPositionPayload := position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy: &userID,
+			TradeTime: &common.DateRangeFilter{From: &from, To: &before},
+		},
+		Sort: common.Sorting{
+			Field: "opened_at",
+			Order: common.SortOrderASC,
+		},
+	}
+
+	positions, _, err := s.positionRepository.Search(ctx, searchPositionPayload, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("position repository search: %w", err)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,514 @@
+package report
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/trade"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// FinancialYear is an Indian financial year, from 1 April of the year to 31 March of the next year.
+type FinancialYear int
+
+var ErrInvalidFinancialYear = errors.New("Financial year must be like 2024-25 or 2024")
+
+// ParseFinancialYear parses a FinancialYear like "2024-25", or just the year it starts in like "2024".
+func ParseFinancialYear(s string) (FinancialYear, error) {
+	start, end, hasEnd := strings.Cut(strings.TrimSpace(s), "-")
+
+	year, err := strconv.Atoi(start)
+	if err != nil || year < 2000 || year > 2100 {
+		return 0, ErrInvalidFinancialYear
+	}
+
+	fy := FinancialYear(year)
+	if hasEnd && end != fy.Label()[5:] {
+		return 0, ErrInvalidFinancialYear
+	}
+
+	return fy, nil
+}
+
+// Label returns the FinancialYear as it is written in the ITR, like "2024-25".
+func (fy FinancialYear) Label() string {
+	return fmt.Sprintf("%d-%02d", int(fy), (int(fy)+1)%100)
+}
+
+// Range returns the start of the FinancialYear and the start of the next one in `loc`.
+func (fy FinancialYear) Range(loc *time.Location) (time.Time, time.Time) {
+	start := time.Date(int(fy), time.April, 1, 0, 0, 0, 0, loc)
+	return start, start.AddDate(1, 0, 0)
+}
+
+// TaxCategory is the head of income that the P&L of a trade is taxed under in the ITR.
+type TaxCategory string
+
+const (
+	TaxCategorySpeculative    TaxCategory = "speculative"     // Intraday equity, a speculative business income.
+	TaxCategoryNonSpeculative TaxCategory = "non_speculative" // Futures and options, and the commodity and currency segments.
+	TaxCategorySTCG           TaxCategory = "stcg"            // Delivery equity held for 12 months or less.
+	TaxCategoryLTCG           TaxCategory = "ltcg"            // Delivery equity held for more than 12 months.
+)
+
+// grandfatheringCutoff is the date from which equity bought is no longer grandfathered, the day after
+// the FMV on 31 January 2018 that the cost of older equity is stepped up to. In IST.
+var grandfatheringCutoff = time.Date(2018, time.February, 1, 0, 0, 0, 0, time.FixedZone("IST", 5*60*60+30*60))
+
+// TaxBusinessIncome is the speculative or non-speculative business income of a financial year.
+type TaxBusinessIncome struct {
+	// The sum of the absolute profit or loss of each trade, as the ICAI guidance note on tax audit computes it.
+	Turnover    decimal.Decimal `json:"turnover"`
+	GrossPnL    decimal.Decimal `json:"gross_pnl"`
+	Charges     decimal.Decimal `json:"charges"`
+	NetPnL      decimal.Decimal `json:"net_pnl"`
+	TradesCount int             `json:"trades_count"`
+}
+
+// TaxCapitalGains are the short or long term capital gains of a financial year.
+type TaxCapitalGains struct {
+	SaleValue         decimal.Decimal `json:"sale_value"`
+	CostOfAcquisition decimal.Decimal `json:"cost_of_acquisition"`
+	// The charges of the purchases and sales, except the STT which can't be deducted from capital gains.
+	Expenses  decimal.Decimal `json:"expenses"`
+	Gain      decimal.Decimal `json:"gain"`
+	LotsCount int             `json:"lots_count"`
+}
+
+// TaxPnLEntry is a sold lot of delivery equity, or a closing trade of the other categories.
+type TaxPnLEntry struct {
+	Category   TaxCategory      `json:"category"`
+	Symbol     string           `json:"symbol"`
+	Instrument types.Instrument `json:"instrument"`
+	Segment    types.Segment    `json:"segment"`
+	OpenedAt   time.Time        `json:"opened_at"`
+	ClosedAt   time.Time        `json:"closed_at"`
+	Quantity   decimal.Decimal  `json:"quantity"`
+
+	// Only for capital gains. The cost is stepped up to the FMV on 31 January 2018 for grandfathered equity.
+	SaleValue         decimal.Decimal  `json:"sale_value"`
+	CostOfAcquisition decimal.Decimal  `json:"cost_of_acquisition"`
+	FMVOn31Jan2018    *decimal.Decimal `json:"fmv_on_31_jan_2018"`
+
+	// Only for business income.
+	Turnover decimal.Decimal `json:"turnover"`
+
+	Charges decimal.Decimal `json:"charges"`
+	PnL     decimal.Decimal `json:"pnl"`
+}
+
+type TaxPnLResult struct {
+	FinancialYear string    `json:"financial_year"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+
+	Speculative    TaxBusinessIncome `json:"speculative"`
+	NonSpeculative TaxBusinessIncome `json:"non_speculative"`
+	STCG           TaxCapitalGains   `json:"stcg"`
+	LTCG           TaxCapitalGains   `json:"ltcg"`
+
+	Entries []TaxPnLEntry `json:"entries"`
+
+	// The symbols of the grandfathered equity that we have no candles on 31 January 2018 for.
+	// Their cost of acquisition is the purchase price.
+	SymbolsWithoutFMV []string `json:"symbols_without_fmv"`
+}
+
+// taxLot is an open lot of an equity position, matched first in, first out as the Income Tax Act requires,
+// after the lots opened the same day as the sale. See closingLotIdx.
+type taxLot struct {
+	time  time.Time
+	qty   decimal.Decimal
+	price decimal.Decimal
+	// The charges and STT of the opening trade per unit.
+	chargesPerUnit decimal.Decimal
+	sttPerUnit     decimal.Decimal
+	// Whether the lot is of the bonus shares of a bonus issue, which weren't traded.
+	isBonus bool
+}
+
+// computeTaxPnL classifies the P&L of the trades closed in the financial year by its head of income.
+// The dates are in `loc`. The amounts are converted to the home currency with each position's FX rate.
+// Crypto is taxed as virtual digital assets and left out.
+//
+// The lots of equity are adjusted for the splits and bonus issues of their symbol: a split restates
+// the shares of the lots held across its ex-date, and a bonus issue adds a lot of bonus shares that were
+// acquired on the ex-date at no cost, as the Income Tax Act treats them. An error is returned if a
+// position sells more shares than its lots have, as its P&L can't be matched.
+func computeTaxPnL(positions []*position.Position, fy FinancialYear, loc *time.Location, fmvBySymbol map[string]decimal.Decimal) (*TaxPnLResult, error) {
+	from, to := fy.Range(loc)
+
+	result := &TaxPnLResult{
+		FinancialYear:     fy.Label(),
+		From:              from,
+		To:                to.Add(-time.Nanosecond),
+		Entries:           []TaxPnLEntry{},
+		SymbolsWithoutFMV: []string{},
+	}
+
+	isInYear := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	missingFMV := map[string]bool{}
+
+	for _, pos := range positions {
+		if pos.Instrument == types.InstrumentCrypto {
+			continue
+		}
+
+		fxRate := homeFxRate(pos)
+
+		if !isEquityDelivery(pos) {
+			addNonSpeculativeIncome(result, pos, fxRate, isInYear, loc)
+			continue
+		}
+
+		var lots []taxLot
+
+		// The corporate actions are applied to the lots held across their ex-dates, in order.
+		actions := make([]*corporateaction.CorporateAction, len(pos.CorporateActions))
+		copy(actions, pos.CorporateActions)
+		sort.SliceStable(actions, func(i, j int) bool {
+			return actions[i].ExDate.Before(actions[j].ExDate)
+		})
+
+		for _, t := range pos.Trades {
+			for len(actions) > 0 && !actions[0].AppliesTo(t.Time) {
+				lots = applyCorporateActionToLots(lots, actions[0], pos.Direction)
+				actions = actions[1:]
+			}
+
+			charges := t.ChargesAmount.Mul(fxRate)
+			stt := decimal.Zero
+			if t.ChargesBreakdown != nil {
+				stt = t.ChargesBreakdown.STT.Mul(fxRate)
+			}
+
+			isScaleIn := (pos.Direction == position.DirectionLong && t.Kind == types.TradeKindBuy) ||
+				(pos.Direction == position.DirectionShort && t.Kind == types.TradeKindSell)
+
+			if isScaleIn {
+				lots = append(lots, taxLot{
+					time:           t.Time,
+					qty:            t.Quantity,
+					price:          t.Price.Mul(fxRate),
+					chargesPerUnit: charges.Div(t.Quantity),
+					sttPerUnit:     stt.Div(t.Quantity),
+				})
+				continue
+			}
+
+			qtyLeft := t.Quantity
+			for qtyLeft.IsPositive() && len(lots) > 0 {
+				lotIdx := closingLotIdx(lots, t.Time, loc)
+				lot := &lots[lotIdx]
+				qty := decimal.Min(qtyLeft, lot.qty)
+
+				if isInYear(t.Time) {
+					share := qty.Div(t.Quantity)
+					entry := TaxPnLEntry{
+						Symbol:     pos.Symbol,
+						Instrument: pos.Instrument,
+						Segment:    pos.Segment.OrDefault(),
+						OpenedAt:   lot.time.In(loc),
+						ClosedAt:   t.Time.In(loc),
+						Quantity:   qty,
+					}
+
+					closePrice := t.Price.Mul(fxRate)
+					lotCharges := lot.chargesPerUnit.Mul(qty).Add(charges.Mul(share))
+					lotSTT := lot.sttPerUnit.Mul(qty).Add(stt.Mul(share))
+
+					// A short sale's value is at its opening price.
+					saleValue, cost := closePrice.Mul(qty), lot.price.Mul(qty)
+					if pos.Direction == position.DirectionShort {
+						saleValue, cost = cost, saleValue
+					}
+
+					if !lot.isBonus && isSameDay(lot.time, t.Time, loc) {
+						entry.Category = TaxCategorySpeculative
+						entry.Turnover = saleValue.Sub(cost).Abs()
+						entry.Charges = lotCharges
+						entry.PnL = saleValue.Sub(cost).Sub(lotCharges)
+
+						addBusinessIncome(&result.Speculative, entry, saleValue.Sub(cost))
+					} else {
+						entry.Category = TaxCategorySTCG
+						if pos.Direction == position.DirectionLong && t.Time.In(loc).After(lot.time.In(loc).AddDate(1, 0, 0)) {
+							entry.Category = TaxCategoryLTCG
+
+							if lot.time.Before(grandfatheringCutoff) {
+								fmv, ok := fmvBySymbol[pos.Symbol]
+								if ok {
+									// The FMV is of a share on 31 January 2018, before the splits since then.
+									fmv = fmv.Div(splitsMultiplier(pos.CorporateActions, grandfatheringCutoff, t.Time)).Mul(fxRate)
+									entry.FMVOn31Jan2018 = &fmv
+									cost = decimal.Max(cost, decimal.Min(fmv, closePrice).Mul(qty))
+								} else {
+									missingFMV[pos.Symbol] = true
+								}
+							}
+						}
+
+						entry.SaleValue = saleValue
+						entry.CostOfAcquisition = cost
+						entry.Charges = lotCharges.Sub(lotSTT)
+						entry.PnL = saleValue.Sub(cost).Sub(entry.Charges)
+
+						gains := &result.STCG
+						if entry.Category == TaxCategoryLTCG {
+							gains = &result.LTCG
+						}
+
+						gains.SaleValue = gains.SaleValue.Add(entry.SaleValue)
+						gains.CostOfAcquisition = gains.CostOfAcquisition.Add(entry.CostOfAcquisition)
+						gains.Expenses = gains.Expenses.Add(entry.Charges)
+						gains.Gain = gains.Gain.Add(entry.PnL)
+						gains.LotsCount++
+					}
+
+					result.Entries = append(result.Entries, entry)
+				}
+
+				lot.qty = lot.qty.Sub(qty)
+				qtyLeft = qtyLeft.Sub(qty)
+
+				if lot.qty.IsZero() {
+					lots = append(lots[:lotIdx], lots[lotIdx+1:]...)
+				}
+			}
+
+			if qtyLeft.IsPositive() {
+				return nil, fmt.Errorf("%s sold %s more shares on %s than were bought, so its P&L can't be matched. Please fix its trades.", pos.Symbol, qtyLeft.String(), t.Time.In(loc).Format(time.DateOnly))
+			}
+		}
+	}
+
+	for symbol := range missingFMV {
+		result.SymbolsWithoutFMV = append(result.SymbolsWithoutFMV, symbol)
+	}
+	sort.Strings(result.SymbolsWithoutFMV)
+
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].ClosedAt.Before(result.Entries[j].ClosedAt)
+	})
+
+	return result, nil
+}
+
+// closingLotIdx returns the index of the lot that a sale, or a short's purchase, is matched with next.
+// The latest lot opened the same day is matched first, as an intraday trade is a speculative business
+// income, the way the charges of an equity trade are split into intraday and delivery. The rest of it is
+// matched with the oldest lot.
+func closingLotIdx(lots []taxLot, closedAt time.Time, loc *time.Location) int {
+	for i := len(lots) - 1; i >= 0; i-- {
+		if !lots[i].isBonus && isSameDay(lots[i].time, closedAt, loc) {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// applyCorporateActionToLots returns the lots held across the ex-date of a split or bonus issue after it.
+// The shorted lots of a bonus issue are restated like a split, as the short seller owes the bonus shares.
+func applyCorporateActionToLots(lots []taxLot, action *corporateaction.CorporateAction, direction position.Direction) []taxLot {
+	multiplier := action.Multiplier()
+	if multiplier.Equal(decimal.NewFromInt(1)) {
+		return lots
+	}
+
+	if action.Kind == corporateaction.KindBonus && direction == position.DirectionLong {
+		bonusLots := []taxLot{}
+		for _, lot := range lots {
+			bonusLots = append(bonusLots, taxLot{
+				time:    action.ExDate,
+				qty:     lot.qty.Mul(multiplier.Sub(decimal.NewFromInt(1))),
+				isBonus: true,
+			})
+		}
+		return append(lots, bonusLots...)
+	}
+
+	for i := range lots {
+		lots[i].qty = lots[i].qty.Mul(multiplier)
+		lots[i].price = lots[i].price.Div(multiplier)
+		lots[i].chargesPerUnit = lots[i].chargesPerUnit.Div(multiplier)
+		lots[i].sttPerUnit = lots[i].sttPerUnit.Div(multiplier)
+	}
+	return lots
+}
+
+// splitsMultiplier returns what a share held at `from` is in shares at `to`, after the splits between them.
+// The bonus shares are lots of their own, see applyCorporateActionToLots.
+func splitsMultiplier(actions []*corporateaction.CorporateAction, from, to time.Time) decimal.Decimal {
+	multiplier := decimal.NewFromInt(1)
+	for _, a := range actions {
+		if a.Kind == corporateaction.KindSplit && a.AppliesTo(from) && !a.AppliesTo(to) {
+			multiplier = multiplier.Mul(a.Multiplier())
+		}
+	}
+	return multiplier
+}
+
+// addNonSpeculativeIncome adds the realised P&L of the closing trades of a derivatives, commodity or currency
+// position in the financial year, and the charges of all its trades in the year, as they are business expenses.
+func addNonSpeculativeIncome(result *TaxPnLResult, pos *position.Position, fxRate decimal.Decimal, isInYear func(time.Time) bool, loc *time.Location) {
+	for _, t := range pos.Trades {
+		if !isInYear(t.Time) {
+			continue
+		}
+
+		charges := t.ChargesAmount.Mul(fxRate)
+
+		if !isClosingTrade(t, pos.Direction) {
+			result.NonSpeculative.Charges = result.NonSpeculative.Charges.Add(charges)
+			result.NonSpeculative.NetPnL = result.NonSpeculative.NetPnL.Sub(charges)
+			continue
+		}
+
+		grossPnL := t.RealisedGrossPnL.Mul(fxRate)
+
+		entry := TaxPnLEntry{
+			Category:   TaxCategoryNonSpeculative,
+			Symbol:     pos.Symbol,
+			Instrument: pos.Instrument,
+			Segment:    pos.Segment.OrDefault(),
+			OpenedAt:   pos.OpenedAt.In(loc),
+			ClosedAt:   t.Time.In(loc),
+			Quantity:   t.Quantity,
+			Turnover:   grossPnL.Abs(),
+			Charges:    charges,
+			PnL:        grossPnL.Sub(charges),
+		}
+
+		addBusinessIncome(&result.NonSpeculative, entry, grossPnL)
+		result.Entries = append(result.Entries, entry)
+	}
+}
+
+func (income *TaxBusinessIncome) add(other TaxBusinessIncome) {
+	income.Turnover = income.Turnover.Add(other.Turnover)
+	income.GrossPnL = income.GrossPnL.Add(other.GrossPnL)
+	income.Charges = income.Charges.Add(other.Charges)
+	income.NetPnL = income.NetPnL.Add(other.NetPnL)
+	income.TradesCount += other.TradesCount
+}
+
+func addBusinessIncome(income *TaxBusinessIncome, entry TaxPnLEntry, grossPnL decimal.Decimal) {
+	income.Turnover = income.Turnover.Add(entry.Turnover)
+	income.GrossPnL = income.GrossPnL.Add(grossPnL)
+	income.Charges = income.Charges.Add(entry.Charges)
+	income.NetPnL = income.NetPnL.Add(entry.PnL)
+	income.TradesCount++
+}
+
+// isEquityDelivery returns whether the position is of shares in the equity segment, which are matched with
+// their lots. The other positions are derivatives, commodity or currency, a non-speculative business income.
+func isEquityDelivery(pos *position.Position) bool {
+	return pos.Instrument == types.InstrumentEquity && pos.Segment.OrDefault() == types.SegmentEquity
+}
+
+// homeFxRate returns the FX rate of the position to the home currency, 1 if it has none.
+func homeFxRate(pos *position.Position) decimal.Decimal {
+	if !pos.FxRate.IsPositive() {
+		return decimal.NewFromInt(1)
+	}
+	return pos.FxRate
+}
+
+func isClosingTrade(t *trade.Trade, direction position.Direction) bool {
+	return (direction == position.DirectionLong && t.Kind == types.TradeKindSell) ||
+		(direction == position.DirectionShort && t.Kind == types.TradeKindBuy)
+}
+
+func isSameDay(a, b time.Time, loc *time.Location) bool {
+	ay, am, ad := a.In(loc).Date()
+	by, bm, bd := b.In(loc).Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// WriteTaxPnLToSheets writes the summary of the tax P&L and its entries to the "Summary" and "Entries" sheets.
+func WriteTaxPnLToSheets(f *excelize.File, result *TaxPnLResult) {
+	summary := "Summary"
+	f.SetSheetName("Sheet1", summary)
+
+	rows := [][]any{
+		{"Financial Year", result.FinancialYear},
+		{},
+		{"Business Income", "Turnover", "Gross P&L", "Charges", "Net P&L", "Trades"},
+	}
+
+	for _, income := range []struct {
+		label string
+		TaxBusinessIncome
+	}{
+		{"Speculative (Intraday Equity)", result.Speculative},
+		{"Non-Speculative (F&O)", result.NonSpeculative},
+	} {
+		rows = append(rows, []any{
+			income.label, income.Turnover.StringFixed(2), income.GrossPnL.StringFixed(2),
+			income.Charges.StringFixed(2), income.NetPnL.StringFixed(2), income.TradesCount,
+		})
+	}
+
+	rows = append(rows, []any{}, []any{"Capital Gains", "Sale Value", "Cost of Acquisition", "Expenses", "Gain", "Lots"})
+
+	for _, gains := range []struct {
+		label string
+		TaxCapitalGains
+	}{
+		{"Short Term (STCG)", result.STCG},
+		{"Long Term (LTCG)", result.LTCG},
+	} {
+		rows = append(rows, []any{
+			gains.label, gains.SaleValue.StringFixed(2), gains.CostOfAcquisition.StringFixed(2),
+			gains.Expenses.StringFixed(2), gains.Gain.StringFixed(2), gains.LotsCount,
+		})
+	}
+
+	if len(result.SymbolsWithoutFMV) > 0 {
+		rows = append(rows, []any{}, []any{"Not grandfathered, no FMV on 31 Jan 2018", strings.Join(result.SymbolsWithoutFMV, ", ")})
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		f.SetSheetRow(summary, cell, &row)
+	}
+
+	entries := "Entries"
+	f.NewSheet(entries)
+
+	headers := []string{
+		"#", "Category", "Symbol", "Instrument", "Segment", "Opened At", "Closed At", "Quantity",
+		"Sale Value", "Cost of Acquisition", "FMV on 31 Jan 2018", "Turnover", "Charges", "P&L",
+	}
+
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(entries, cell, h)
+	}
+
+	for i, e := range result.Entries {
+		var fmv string
+		if e.FMVOn31Jan2018 != nil {
+			fmv = e.FMVOn31Jan2018.StringFixed(2)
+		}
+
+		row := []any{
+			i + 1, strings.ToUpper(string(e.Category)), e.Symbol, string(e.Instrument), string(e.Segment),
+			e.OpenedAt.Format(time.DateOnly), e.ClosedAt.Format(time.DateOnly), e.Quantity.String(),
+			e.SaleValue.StringFixed(2), e.CostOfAcquisition.StringFixed(2), fmv, e.Turnover.StringFixed(2),
+			e.Charges.StringFixed(2), e.PnL.StringFixed(2),
+		}
+
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(entries, cell, &row)
+	}
+}
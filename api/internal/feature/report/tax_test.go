@@ -0,0 +1,168 @@
+package report
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/corporateaction"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/trade"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestComputeTaxPnL(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, ist)
+	}
+
+	equity := func(trades ...*trade.Trade) *position.Position {
+		return &position.Position{Symbol: "INFY", Instrument: types.InstrumentEquity, Direction: position.DirectionLong, Trades: trades}
+	}
+
+	positions := []*position.Position{
+		// Intraday.
+		equity(
+			&trade.Trade{Kind: types.TradeKindBuy, Time: at(2024, 6, 3, 10), Quantity: d("100"), Price: d("100"), ChargesAmount: d("10")},
+			&trade.Trade{Kind: types.TradeKindSell, Time: at(2024, 6, 3, 14), Quantity: d("100"), Price: d("110"), ChargesAmount: d("12")},
+		),
+		// Delivery held for 6 months, the STT isn't an expense.
+		equity(
+			&trade.Trade{Kind: types.TradeKindBuy, Time: at(2024, 1, 10, 10), Quantity: d("10"), Price: d("500"), ChargesAmount: d("5"), ChargesBreakdown: &trade.ChargesBreakdown{STT: d("2")}},
+			&trade.Trade{Kind: types.TradeKindSell, Time: at(2024, 7, 10, 10), Quantity: d("10"), Price: d("600"), ChargesAmount: d("5"), ChargesBreakdown: &trade.ChargesBreakdown{STT: d("2")}},
+		),
+		// Grandfathered, the cost is stepped up to the FMV of 250. Half of it was sold in the previous year.
+		equity(
+			&trade.Trade{Kind: types.TradeKindBuy, Time: at(2017, 6, 1, 10), Quantity: d("20"), Price: d("100")},
+			&trade.Trade{Kind: types.TradeKindSell, Time: at(2024, 3, 1, 10), Quantity: d("10"), Price: d("280")},
+			&trade.Trade{Kind: types.TradeKindSell, Time: at(2024, 8, 1, 10), Quantity: d("10"), Price: d("300")},
+		),
+		{
+			Symbol: "NIFTY24MAY22000CE", Instrument: types.InstrumentOption, Direction: position.DirectionLong,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2024, 5, 1, 10), Quantity: d("50"), Price: d("100"), ChargesAmount: d("20")},
+				{Kind: types.TradeKindSell, Time: at(2024, 5, 2, 10), Quantity: d("50"), Price: d("80"), ChargesAmount: d("20"), RealisedGrossPnL: d("-1000")},
+			},
+		},
+	}
+
+	result, err := computeTaxPnL(positions, FinancialYear(2024), ist, map[string]decimal.Decimal{"INFY": d("250")})
+	if err != nil {
+		t.Fatalf("computeTaxPnL: %s", err)
+	}
+
+	if result.FinancialYear != "2024-25" {
+		t.Errorf("expected financial year 2024-25, got %s", result.FinancialYear)
+	}
+
+	if s := result.Speculative; !s.Turnover.Equal(d("1000")) || !s.Charges.Equal(d("22")) || !s.NetPnL.Equal(d("978")) {
+		t.Errorf("unexpected speculative income %+v", s)
+	}
+
+	if n := result.NonSpeculative; !n.Turnover.Equal(d("1000")) || !n.Charges.Equal(d("40")) || !n.NetPnL.Equal(d("-1040")) {
+		t.Errorf("unexpected non-speculative income %+v", n)
+	}
+
+	if g := result.STCG; !g.SaleValue.Equal(d("6000")) || !g.Expenses.Equal(d("6")) || !g.Gain.Equal(d("994")) {
+		t.Errorf("unexpected STCG %+v", g)
+	}
+
+	if g := result.LTCG; !g.CostOfAcquisition.Equal(d("2500")) || !g.Gain.Equal(d("500")) || g.LotsCount != 1 {
+		t.Errorf("unexpected LTCG %+v", g)
+	}
+
+	if len(result.Entries) != 4 || len(result.SymbolsWithoutFMV) != 0 {
+		t.Errorf("expected 4 entries and no symbols without FMV, got %d and %v", len(result.Entries), result.SymbolsWithoutFMV)
+	}
+
+	if _, err := ParseFinancialYear("2024-26"); err != ErrInvalidFinancialYear {
+		t.Errorf("expected ErrInvalidFinancialYear, got %v", err)
+	}
+}
+
+func TestComputeTaxPnL_CorporateActions(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, ist)
+	}
+
+	positions := []*position.Position{
+		// A 5:1 split restates the 10 shares bought to 50 at 200.
+		{
+			Symbol: "INFY", Instrument: types.InstrumentEquity, Direction: position.DirectionLong,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2024, 2, 1), Quantity: d("10"), Price: d("1000"), ChargesAmount: d("10")},
+				{Kind: types.TradeKindSell, Time: at(2024, 9, 2), Quantity: d("50"), Price: d("220")},
+			},
+			CorporateActions: []*corporateaction.CorporateAction{
+				{Symbol: "INFY", Kind: corporateaction.KindSplit, ExDate: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), NewShares: d("5"), HeldShares: d("1")},
+			},
+		},
+		// A 1:1 bonus adds 10 shares acquired on the ex-date at no cost, sold short term.
+		{
+			Symbol: "TCS", Instrument: types.InstrumentEquity, Direction: position.DirectionLong,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2023, 5, 2), Quantity: d("10"), Price: d("400")},
+				{Kind: types.TradeKindSell, Time: at(2024, 8, 1), Quantity: d("20"), Price: d("250")},
+			},
+			CorporateActions: []*corporateaction.CorporateAction{
+				{Symbol: "TCS", Kind: corporateaction.KindBonus, ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), NewShares: d("1"), HeldShares: d("1")},
+			},
+		},
+	}
+
+	result, err := computeTaxPnL(positions, FinancialYear(2024), ist, nil)
+	if err != nil {
+		t.Fatalf("computeTaxPnL: %s", err)
+	}
+
+	if g := result.STCG; !g.SaleValue.Equal(d("13500")) || !g.CostOfAcquisition.Equal(d("10000")) || !g.Gain.Equal(d("3490")) || g.LotsCount != 2 {
+		t.Errorf("unexpected STCG %+v", g)
+	}
+
+	if g := result.LTCG; !g.CostOfAcquisition.Equal(d("4000")) || !g.Gain.Equal(d("-1500")) || g.LotsCount != 1 {
+		t.Errorf("unexpected LTCG %+v", g)
+	}
+
+	// Without the split, 40 of the shares sold have no lot.
+	positions[0].CorporateActions = nil
+	if _, err := computeTaxPnL(positions, FinancialYear(2024), ist, nil); err == nil {
+		t.Error("expected an error for the shares sold without a lot")
+	}
+}
+
+func TestComputeTaxPnL_SameDayLots(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, ist)
+	}
+
+	// The sale on the day of the second buy is matched with it, and not with the older lot, so it's intraday.
+	positions := []*position.Position{
+		{
+			Symbol: "INFY", Instrument: types.InstrumentEquity, Direction: position.DirectionLong,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2024, 4, 15, 10), Quantity: d("10"), Price: d("500")},
+				{Kind: types.TradeKindBuy, Time: at(2024, 7, 10, 10), Quantity: d("10"), Price: d("600")},
+				{Kind: types.TradeKindSell, Time: at(2024, 7, 10, 14), Quantity: d("15"), Price: d("610")},
+				{Kind: types.TradeKindSell, Time: at(2024, 8, 1, 10), Quantity: d("5"), Price: d("650")},
+			},
+		},
+	}
+
+	result, err := computeTaxPnL(positions, FinancialYear(2024), ist, nil)
+	if err != nil {
+		t.Fatalf("computeTaxPnL: %s", err)
+	}
+
+	if s := result.Speculative; !s.Turnover.Equal(d("100")) || s.TradesCount != 1 {
+		t.Errorf("expected the 10 shares bought and sold the same day to be speculative, got %+v", s)
+	}
+
+	if g := result.STCG; !g.SaleValue.Equal(d("6300")) || !g.CostOfAcquisition.Equal(d("5000")) || g.LotsCount != 2 {
+		t.Errorf("expected the rest to be matched with the older lot, got %+v", g)
+	}
+}
@@ -0,0 +1,193 @@
+package report
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/position"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// Section 44AB audits business income above ₹1 crore of turnover,
+	// or ₹10 crore if no more than 5% of the receipts and payments are in cash.
+	taxAuditTurnoverThreshold        = decimal.NewFromInt(1_00_00_000)
+	taxAuditDigitalTurnoverThreshold = decimal.NewFromInt(10_00_00_000)
+
+	// Section 44AD presumes a profit of 6% of the turnover on digital receipts.
+	presumptiveProfitRate = decimal.NewFromFloat(0.06)
+)
+
+// TurnoverItem is the turnover of the business income of a broker account in a financial year, by the ICAI method.
+type TurnoverItem struct {
+	UserBrokerAccountID   *uuid.UUID `json:"user_broker_account_id"` // nil for the positions without a broker account.
+	UserBrokerAccountName string     `json:"user_broker_account_name"`
+	BrokerName            string     `json:"broker_name"`
+
+	// The sum of the absolute realised P&L of each closing trade of derivatives, commodity and currency.
+	NonSpeculativeTurnover decimal.Decimal `json:"non_speculative_turnover"`
+	// The premium received on the sell trades of options.
+	OptionsSellPremium decimal.Decimal `json:"options_sell_premium"`
+	// The sum of the absolute P&L of each intraday equity trade.
+	SpeculativeTurnover decimal.Decimal `json:"speculative_turnover"`
+
+	Turnover    decimal.Decimal `json:"turnover"`
+	NetPnL      decimal.Decimal `json:"net_pnl"`
+	TradesCount int             `json:"trades_count"`
+}
+
+func (item *TurnoverItem) add(other TurnoverItem) {
+	item.NonSpeculativeTurnover = item.NonSpeculativeTurnover.Add(other.NonSpeculativeTurnover)
+	item.OptionsSellPremium = item.OptionsSellPremium.Add(other.OptionsSellPremium)
+	item.SpeculativeTurnover = item.SpeculativeTurnover.Add(other.SpeculativeTurnover)
+	item.Turnover = item.Turnover.Add(other.Turnover)
+	item.NetPnL = item.NetPnL.Add(other.NetPnL)
+	item.TradesCount += other.TradesCount
+}
+
+type TurnoverThreshold struct {
+	Label    string          `json:"label"`
+	Amount   decimal.Decimal `json:"amount"`
+	Exceeded bool            `json:"exceeded"`
+}
+
+type TurnoverResult struct {
+	FinancialYear string    `json:"financial_year"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+
+	Total    TurnoverItem   `json:"total"`
+	Accounts []TurnoverItem `json:"accounts"`
+
+	Thresholds []TurnoverThreshold `json:"thresholds"`
+
+	// Whether the net P&L is below 6% of the turnover. Under section 44AD, declaring such a profit
+	// needs a tax audit if the total income is above the basic exemption limit.
+	ProfitBelowPresumptiveRate bool `json:"profit_below_presumptive_rate"`
+
+	// The equity symbols that sold more shares than were bought, so their lots couldn't be matched.
+	// Their intraday turnover is left out.
+	UnmatchedSymbols []string `json:"unmatched_symbols"`
+}
+
+// computeTurnover computes the turnover of the business income in the financial year by broker account,
+// from the realised P&L of each trade and the premium of option sells. The turnover of F&O is of their
+// trades, and only the intraday trades of equity are matched with their lots.
+func computeTurnover(positions []*position.Position, fy FinancialYear, loc *time.Location) *TurnoverResult {
+	from, to := fy.Range(loc)
+
+	result := &TurnoverResult{
+		FinancialYear:    fy.Label(),
+		From:             from,
+		To:               to.Add(-time.Nanosecond),
+		Accounts:         []TurnoverItem{},
+		UnmatchedSymbols: []string{},
+	}
+
+	isInYear := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+
+	var keys []string
+	positionsByAccount := map[string][]*position.Position{}
+	itemByAccount := map[string]*TurnoverItem{}
+
+	for _, pos := range positions {
+		key := ""
+		if pos.UserBrokerAccountID != nil {
+			key = pos.UserBrokerAccountID.String()
+		}
+
+		if _, ok := itemByAccount[key]; !ok {
+			item := &TurnoverItem{UserBrokerAccountID: pos.UserBrokerAccountID}
+			if pos.UserBrokerAccount != nil {
+				item.UserBrokerAccountName = pos.UserBrokerAccount.Name
+				item.BrokerName = pos.UserBrokerAccount.BrokerName
+			}
+
+			itemByAccount[key] = item
+			keys = append(keys, key)
+		}
+
+		positionsByAccount[key] = append(positionsByAccount[key], pos)
+	}
+
+	unmatched := map[string]bool{}
+
+	for _, key := range keys {
+		item := itemByAccount[key]
+		accountPositions := positionsByAccount[key]
+
+		income := &TaxPnLResult{}
+		for _, pos := range accountPositions {
+			if pos.Instrument == types.InstrumentCrypto {
+				continue
+			}
+
+			if !isEquityDelivery(pos) {
+				addNonSpeculativeIncome(income, pos, homeFxRate(pos), isInYear, loc)
+				continue
+			}
+
+			// A position whose lots can't be matched is left out, and not the turnover of the others with it.
+			taxPnL, err := computeTaxPnL([]*position.Position{pos}, fy, loc, nil)
+			if err != nil {
+				unmatched[pos.Symbol] = true
+				continue
+			}
+
+			income.Speculative.add(taxPnL.Speculative)
+		}
+
+		item.NonSpeculativeTurnover = income.NonSpeculative.Turnover
+		item.SpeculativeTurnover = income.Speculative.Turnover
+		item.NetPnL = income.NonSpeculative.NetPnL.Add(income.Speculative.NetPnL)
+		item.TradesCount = income.NonSpeculative.TradesCount + income.Speculative.TradesCount
+
+		for _, pos := range accountPositions {
+			if pos.Instrument != types.InstrumentOption {
+				continue
+			}
+
+			fxRate := homeFxRate(pos)
+
+			for _, t := range pos.Trades {
+				if t.Kind == types.TradeKindSell && isInYear(t.Time) {
+					item.OptionsSellPremium = item.OptionsSellPremium.Add(t.Quantity.Mul(t.Price).Mul(fxRate))
+				}
+			}
+		}
+
+		item.Turnover = item.NonSpeculativeTurnover.Add(item.OptionsSellPremium).Add(item.SpeculativeTurnover)
+
+		result.Total.add(*item)
+		result.Accounts = append(result.Accounts, *item)
+	}
+
+	sort.SliceStable(result.Accounts, func(i, j int) bool {
+		return result.Accounts[i].Turnover.GreaterThan(result.Accounts[j].Turnover)
+	})
+
+	for symbol := range unmatched {
+		result.UnmatchedSymbols = append(result.UnmatchedSymbols, symbol)
+	}
+	sort.Strings(result.UnmatchedSymbols)
+
+	result.Thresholds = []TurnoverThreshold{
+		{
+			Label:    "Section 44AB",
+			Amount:   taxAuditTurnoverThreshold,
+			Exceeded: result.Total.Turnover.GreaterThan(taxAuditTurnoverThreshold),
+		},
+		{
+			Label:    "Section 44AB, with 95% digital transactions",
+			Amount:   taxAuditDigitalTurnoverThreshold,
+			Exceeded: result.Total.Turnover.GreaterThan(taxAuditDigitalTurnoverThreshold),
+		},
+	}
+
+	result.ProfitBelowPresumptiveRate = result.Total.Turnover.IsPositive() &&
+		result.Total.NetPnL.LessThan(result.Total.Turnover.Mul(presumptiveProfitRate))
+
+	return result
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/trade"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestComputeTurnover(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, ist)
+	}
+
+	zerodhaID := uuid.New()
+	zerodha := &position.UserBrokerAccountSearchValue{ID: zerodhaID, Name: "Main", BrokerName: "Zerodha"}
+
+	positions := []*position.Position{
+		// A short option, its premium on the sell is turnover.
+		{
+			Symbol: "NIFTY24MAY22000PE", Instrument: types.InstrumentOption, Direction: position.DirectionShort,
+			UserBrokerAccountID: &zerodhaID, UserBrokerAccount: zerodha,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindSell, Time: at(2024, 5, 1), Quantity: d("50"), Price: d("100"), ChargesAmount: d("30")},
+				{Kind: types.TradeKindBuy, Time: at(2024, 5, 2), Quantity: d("50"), Price: d("60"), ChargesAmount: d("20"), RealisedGrossPnL: d("2000")},
+			},
+		},
+		// A losing future, the absolute loss is turnover.
+		{
+			Symbol: "NIFTY24MAYFUT", Instrument: types.InstrumentFuture, Direction: position.DirectionLong,
+			UserBrokerAccountID: &zerodhaID, UserBrokerAccount: zerodha,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2024, 5, 1), Quantity: d("50"), Price: d("22000")},
+				{Kind: types.TradeKindSell, Time: at(2024, 5, 3), Quantity: d("50"), Price: d("21950"), RealisedGrossPnL: d("-2500")},
+			},
+		},
+		// Shares sold without a lot are left out, and don't fail the turnover of the F&O.
+		{
+			Symbol: "INFY", Instrument: types.InstrumentEquity, Direction: position.DirectionLong,
+			UserBrokerAccountID: &zerodhaID, UserBrokerAccount: zerodha,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindSell, Time: at(2024, 6, 3), Quantity: d("10"), Price: d("1500")},
+			},
+		},
+		// A manual future closed in the previous year isn't counted.
+		{
+			Symbol: "BANKNIFTY24MARFUT", Instrument: types.InstrumentFuture, Direction: position.DirectionLong,
+			Trades: []*trade.Trade{
+				{Kind: types.TradeKindBuy, Time: at(2024, 3, 1), Quantity: d("15"), Price: d("47000")},
+				{Kind: types.TradeKindSell, Time: at(2024, 3, 5), Quantity: d("15"), Price: d("47100"), RealisedGrossPnL: d("1500")},
+			},
+		},
+	}
+
+	result := computeTurnover(positions, FinancialYear(2024), ist)
+
+	if len(result.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(result.Accounts))
+	}
+
+	account := result.Accounts[0]
+	if account.UserBrokerAccountID == nil || *account.UserBrokerAccountID != zerodhaID || account.BrokerName != "Zerodha" {
+		t.Errorf("expected the Zerodha account first, got %+v", account)
+	}
+
+	if !account.NonSpeculativeTurnover.Equal(d("4500")) || !account.OptionsSellPremium.Equal(d("5000")) || !account.Turnover.Equal(d("9500")) {
+		t.Errorf("unexpected turnover %+v", account)
+	}
+
+	if !account.NetPnL.Equal(d("-550")) || account.TradesCount != 2 {
+		t.Errorf("expected net P&L -550 of 2 trades, got %s of %d", account.NetPnL, account.TradesCount)
+	}
+
+	if !result.Total.Turnover.Equal(d("9500")) || !result.ProfitBelowPresumptiveRate {
+		t.Errorf("unexpected total %+v", result.Total)
+	}
+
+	if len(result.UnmatchedSymbols) != 1 || result.UnmatchedSymbols[0] != "INFY" {
+		t.Errorf("expected INFY to be unmatched, got %v", result.UnmatchedSymbols)
+	}
+
+	if len(result.Thresholds) != 2 || result.Thresholds[0].Exceeded || result.Thresholds[1].Exceeded {
+		t.Errorf("expected no thresholds to be exceeded, got %+v", result.Thresholds)
+	}
+}
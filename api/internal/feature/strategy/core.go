@@ -0,0 +1,516 @@
+// Package strategy groups option Positions into multi-leg strategies like spreads, straddles and iron condors.
+package strategy
+
+import (
+	"arthveda/internal/domain/symbol"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/position"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type Kind string
+
+const (
+	KindCustom         Kind = "custom"
+	KindBullCallSpread Kind = "bull_call_spread"
+	KindBearCallSpread Kind = "bear_call_spread"
+	KindBullPutSpread  Kind = "bull_put_spread"
+	KindBearPutSpread  Kind = "bear_put_spread"
+	KindStraddle       Kind = "straddle"
+	KindStrangle       Kind = "strangle"
+	KindIronCondor     Kind = "iron_condor"
+	KindIronButterfly  Kind = "iron_butterfly"
+)
+
+func (k Kind) IsValid() bool {
+	switch k {
+	case KindCustom, KindBullCallSpread, KindBearCallSpread, KindBullPutSpread, KindBearPutSpread,
+		KindStraddle, KindStrangle, KindIronCondor, KindIronButterfly:
+		return true
+	}
+	return false
+}
+
+type Strategy struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+
+	Name string `json:"name" db:"name"`
+	Kind Kind   `json:"kind" db:"kind"`
+
+	// The underlying and expiry of the legs. Underlying is empty and Expiry is `nil` if the legs
+	// don't share the same underlying and expiry.
+	Underlying string     `json:"underlying" db:"underlying"`
+	Expiry     *time.Time `json:"expiry" db:"expiry"`
+
+	// The risk used to compute the R-Factor of this Strategy. If zero, MaxRiskAmount is used instead.
+	RiskAmount decimal.Decimal `json:"risk_amount" db:"risk_amount"`
+
+	// Whether this Strategy was detected on import rather than created by the user.
+	IsAutoDetected bool `json:"is_auto_detected" db:"is_auto_detected"`
+
+	//
+	// Everything above is present in the strategy table but everything below isn't.
+	// The fields below are computed from the legs. See Compute.
+	//
+
+	Status             position.Status `json:"status"`
+	OpenedAt           time.Time       `json:"opened_at"`
+	ClosedAt           *time.Time      `json:"closed_at"`
+	GrossPnLAmount     decimal.Decimal `json:"gross_pnl_amount"`
+	NetPnLAmount       decimal.Decimal `json:"net_pnl_amount"`
+	TotalChargesAmount decimal.Decimal `json:"total_charges_amount"`
+	RFactor            decimal.Decimal `json:"r_factor"`
+
+	// The maximum loss of this Strategy if it were held to expiry, computed from the strikes and entry prices
+	// of the legs. `nil` if the loss is unlimited or the legs aren't options that we can decompose.
+	MaxRiskAmount *decimal.Decimal `json:"max_risk_amount"`
+
+	// The Positions that are the legs of this Strategy.
+	Legs []*position.Position `json:"legs"`
+}
+
+func New(userID uuid.UUID, name string, kind Kind, riskAmount decimal.Decimal, isAutoDetected bool) (*Strategy, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Strategy{
+		ID:             id,
+		CreatedBy:      userID,
+		CreatedAt:      time.Now().UTC(),
+		Name:           name,
+		Kind:           kind,
+		RiskAmount:     riskAmount,
+		IsAutoDetected: isAutoDetected,
+	}, nil
+}
+
+// optionContract is the contract of an option leg.
+type optionContract struct {
+	Underlying string
+	Expiry     time.Time // Zero if it can't be resolved from the symbol.
+	Strike     decimal.Decimal
+	Right      symbol.OptionRight
+}
+
+// optionContractOf returns the contract of the Position, or false if it isn't an option that we can decompose.
+func optionContractOf(p *position.Position) (optionContract, bool) {
+	if p.Instrument != types.InstrumentOption {
+		return optionContract{}, false
+	}
+
+	c, ok := p.Contract()
+	if !ok || !c.IsOption() {
+		return optionContract{}, false
+	}
+
+	oc := optionContract{Underlying: c.Underlying, Strike: *c.Strike, Right: *c.Right}
+	if c.Expiry != nil {
+		oc.Expiry = *c.Expiry
+	}
+
+	return oc, true
+}
+
+// leg is an option Position with its contract and the size and price it was entered at.
+type leg struct {
+	contract   optionContract
+	direction  position.Direction
+	quantity   decimal.Decimal
+	entryPrice decimal.Decimal // In the home currency.
+}
+
+// toLegs decomposes the Positions into option legs. It returns false if any Position is not an option
+// that we can decompose or has no trades attached.
+func toLegs(positions []*position.Position) ([]leg, bool) {
+	legs := []leg{}
+
+	for _, p := range positions {
+		contract, ok := optionContractOf(p)
+		if !ok {
+			return nil, false
+		}
+
+		scaleInKind := types.TradeKindBuy
+		if p.Direction == position.DirectionShort {
+			scaleInKind = types.TradeKindSell
+		}
+
+		qty := decimal.Zero
+		cost := decimal.Zero
+		for _, t := range p.Trades {
+			if t.Kind != scaleInKind {
+				continue
+			}
+			qty = qty.Add(t.Quantity)
+			cost = cost.Add(t.Price.Mul(t.Quantity))
+		}
+
+		if qty.IsZero() {
+			return nil, false
+		}
+
+		fx := p.FxRate
+		if !fx.IsPositive() {
+			fx = decimal.NewFromInt(1)
+		}
+
+		legs = append(legs, leg{
+			contract:   contract,
+			direction:  p.Direction,
+			quantity:   qty,
+			entryPrice: cost.Div(qty).Mul(fx),
+		})
+	}
+
+	return legs, true
+}
+
+// commonSeries returns the underlying and expiry shared by all the legs.
+func commonSeries(legs []leg) (string, time.Time, bool) {
+	if len(legs) == 0 {
+		return "", time.Time{}, false
+	}
+
+	for _, l := range legs[1:] {
+		if l.contract.Underlying != legs[0].contract.Underlying || !l.contract.Expiry.Equal(legs[0].contract.Expiry) {
+			return "", time.Time{}, false
+		}
+	}
+
+	return legs[0].contract.Underlying, legs[0].contract.Expiry, true
+}
+
+// DetectKind returns the Kind of strategy that the legs form, or KindCustom if they don't form a known one.
+func DetectKind(positions []*position.Position) Kind {
+	legs, ok := toLegs(positions)
+	if !ok {
+		return KindCustom
+	}
+
+	return detectKind(legs)
+}
+
+func detectKind(legs []leg) Kind {
+	if _, _, ok := commonSeries(legs); !ok {
+		return KindCustom
+	}
+
+	var calls, puts []leg
+	for _, l := range legs {
+		if l.contract.Right == symbol.OptionRightCall {
+			calls = append(calls, l)
+		} else {
+			puts = append(puts, l)
+		}
+	}
+
+	byStrike := func(legs []leg) {
+		sort.Slice(legs, func(i, j int) bool { return legs[i].contract.Strike.LessThan(legs[j].contract.Strike) })
+	}
+	byStrike(calls)
+	byStrike(puts)
+
+	// A vertical spread is one long and one short leg of the same right with different strikes.
+	isVertical := func(legs []leg) bool {
+		return len(legs) == 2 && legs[0].direction != legs[1].direction &&
+			!legs[0].contract.Strike.Equal(legs[1].contract.Strike) && legs[0].quantity.Equal(legs[1].quantity)
+	}
+
+	switch {
+	case len(legs) == 2 && isVertical(calls):
+		// Long the lower strike call.
+		if calls[0].direction == position.DirectionLong {
+			return KindBullCallSpread
+		}
+		return KindBearCallSpread
+
+	case len(legs) == 2 && isVertical(puts):
+		// Long the higher strike put.
+		if puts[1].direction == position.DirectionLong {
+			return KindBearPutSpread
+		}
+		return KindBullPutSpread
+
+	case len(calls) == 1 && len(puts) == 1:
+		if calls[0].direction != puts[0].direction || !calls[0].quantity.Equal(puts[0].quantity) {
+			return KindCustom
+		}
+		if calls[0].contract.Strike.Equal(puts[0].contract.Strike) {
+			return KindStraddle
+		}
+		return KindStrangle
+
+	case len(calls) == 2 && len(puts) == 2 && isVertical(calls) && isVertical(puts):
+		// Short the inner strikes and long the outer ones.
+		if calls[0].direction != position.DirectionShort || puts[1].direction != position.DirectionShort {
+			return KindCustom
+		}
+		if calls[0].contract.Strike.Equal(puts[1].contract.Strike) {
+			return KindIronButterfly
+		}
+		if calls[0].contract.Strike.GreaterThan(puts[1].contract.Strike) {
+			return KindIronCondor
+		}
+	}
+
+	return KindCustom
+}
+
+// maxRisk returns the maximum loss of the legs if they are held to expiry.
+// It returns false if the loss is unlimited.
+//
+// The payoff at expiry is piecewise linear in the price of the underlying with kinks at the strikes,
+// so its minimum is either at zero, at one of the strikes or, if it slopes down, at infinity.
+func maxRisk(legs []leg) (decimal.Decimal, bool) {
+	payoff := func(underlyingPrice decimal.Decimal) decimal.Decimal {
+		total := decimal.Zero
+		for _, l := range legs {
+			var intrinsic decimal.Decimal
+			if l.contract.Right == symbol.OptionRightCall {
+				intrinsic = decimal.Max(underlyingPrice.Sub(l.contract.Strike), decimal.Zero)
+			} else {
+				intrinsic = decimal.Max(l.contract.Strike.Sub(underlyingPrice), decimal.Zero)
+			}
+
+			pnl := intrinsic.Sub(l.entryPrice).Mul(l.quantity)
+			if l.direction == position.DirectionShort {
+				pnl = pnl.Neg()
+			}
+
+			total = total.Add(pnl)
+		}
+		return total
+	}
+
+	// Beyond the highest strike, only the calls change in value.
+	slope := decimal.Zero
+	for _, l := range legs {
+		if l.contract.Right != symbol.OptionRightCall {
+			continue
+		}
+		if l.direction == position.DirectionShort {
+			slope = slope.Sub(l.quantity)
+		} else {
+			slope = slope.Add(l.quantity)
+		}
+	}
+
+	if slope.IsNegative() {
+		return decimal.Zero, false
+	}
+
+	worst := payoff(decimal.Zero)
+	for _, l := range legs {
+		worst = decimal.Min(worst, payoff(l.contract.Strike))
+	}
+
+	if worst.IsPositive() {
+		return decimal.Zero, true
+	}
+
+	return worst.Neg(), true
+}
+
+// Compute sets the fields of the Strategy that are derived from its legs.
+// The legs must have their trades attached to compute the max risk.
+func Compute(s *Strategy, legs []*position.Position) {
+	s.Legs = legs
+	s.Status = position.StatusBreakeven
+	s.OpenedAt = time.Time{}
+	s.ClosedAt = nil
+	s.GrossPnLAmount = decimal.Zero
+	s.NetPnLAmount = decimal.Zero
+	s.TotalChargesAmount = decimal.Zero
+	s.RFactor = decimal.Zero
+	s.MaxRiskAmount = nil
+
+	if len(legs) == 0 {
+		return
+	}
+
+	isOpen := false
+	for _, p := range legs {
+		s.GrossPnLAmount = s.GrossPnLAmount.Add(p.GrossPnLAmount)
+		s.NetPnLAmount = s.NetPnLAmount.Add(p.NetPnLAmount)
+		s.TotalChargesAmount = s.TotalChargesAmount.Add(p.TotalChargesAmount)
+
+		if s.OpenedAt.IsZero() || p.OpenedAt.Before(s.OpenedAt) {
+			s.OpenedAt = p.OpenedAt
+		}
+
+		if p.Status == position.StatusOpen || p.ClosedAt == nil {
+			isOpen = true
+		} else if s.ClosedAt == nil || p.ClosedAt.After(*s.ClosedAt) {
+			closedAt := *p.ClosedAt
+			s.ClosedAt = &closedAt
+		}
+	}
+
+	switch {
+	case isOpen:
+		s.Status = position.StatusOpen
+		s.ClosedAt = nil
+	case s.NetPnLAmount.IsPositive():
+		s.Status = position.StatusWin
+	case s.NetPnLAmount.IsNegative():
+		s.Status = position.StatusLoss
+	}
+
+	if optionLegs, ok := toLegs(legs); ok {
+		if risk, ok := maxRisk(optionLegs); ok {
+			s.MaxRiskAmount = &risk
+		}
+	}
+
+	if risk := s.effectiveRiskAmount(); risk.IsPositive() {
+		s.RFactor = s.NetPnLAmount.Div(risk)
+	}
+}
+
+// effectiveRiskAmount is the RiskAmount if set, otherwise the MaxRiskAmount.
+func (s *Strategy) effectiveRiskAmount() decimal.Decimal {
+	if !s.RiskAmount.IsPositive() && s.MaxRiskAmount != nil {
+		return *s.MaxRiskAmount
+	}
+	return s.RiskAmount
+}
+
+// AsPosition returns a Position with the combined stats of this computed Strategy,
+// so that the stats of Positions, like position.GetGeneralStats, can be reused for Strategies.
+func (s *Strategy) AsPosition() *position.Position {
+	p := &position.Position{
+		ID:                 s.ID,
+		CreatedBy:          s.CreatedBy,
+		Symbol:             s.Name,
+		Instrument:         types.InstrumentOption,
+		Status:             s.Status,
+		OpenedAt:           s.OpenedAt,
+		ClosedAt:           s.ClosedAt,
+		GrossPnLAmount:     s.GrossPnLAmount,
+		NetPnLAmount:       s.NetPnLAmount,
+		TotalChargesAmount: s.TotalChargesAmount,
+		RiskAmount:         s.effectiveRiskAmount(),
+		RFactor:            s.RFactor,
+		FxRate:             decimal.NewFromInt(1),
+		StrategyID:         &s.ID,
+	}
+
+	if p.RiskAmount.IsPositive() {
+		p.GrossRFactor = s.GrossPnLAmount.Div(p.RiskAmount)
+	}
+
+	return p
+}
+
+// autoDetectEntryWindow is how far apart the entries of the legs of an auto-detected Strategy can be.
+const autoDetectEntryWindow = time.Minute
+
+// GroupForAutoDetection groups option Positions that aren't legs of a Strategy yet into candidate strategies.
+// Positions are grouped if they are from the same broker account, have the same underlying and expiry
+// and were entered within autoDetectEntryWindow of each other. Only groups of two or more legs are returned.
+func GroupForAutoDetection(positions []*position.Position) [][]*position.Position {
+	type seriesKey struct {
+		userBrokerAccountID uuid.UUID
+		segment             types.Segment
+		underlying          string
+		expiry              time.Time
+	}
+
+	candidates := []*position.Position{}
+	for _, p := range positions {
+		if p.StrategyID != nil {
+			continue
+		}
+		if _, ok := optionContractOf(p); !ok {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].OpenedAt.Before(candidates[j].OpenedAt) })
+
+	// The group that is currently being filled for every series.
+	current := map[seriesKey][]*position.Position{}
+	groups := [][]*position.Position{}
+
+	flush := func(key seriesKey) {
+		if len(current[key]) >= 2 {
+			groups = append(groups, current[key])
+		}
+		delete(current, key)
+	}
+
+	keys := []seriesKey{}
+	for _, p := range candidates {
+		contract, _ := optionContractOf(p)
+
+		key := seriesKey{segment: p.Segment.OrDefault(), underlying: contract.Underlying, expiry: contract.Expiry}
+		if p.UserBrokerAccountID != nil {
+			key.userBrokerAccountID = *p.UserBrokerAccountID
+		}
+
+		group, ok := current[key]
+		if ok && p.OpenedAt.Sub(group[0].OpenedAt) > autoDetectEntryWindow {
+			flush(key)
+			ok = false
+		}
+
+		if !ok {
+			keys = append(keys, key)
+		}
+
+		current[key] = append(current[key], p)
+	}
+
+	for _, key := range keys {
+		if _, ok := current[key]; ok {
+			flush(key)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i][0].OpenedAt.Before(groups[j][0].OpenedAt) })
+
+	return groups
+}
+
+var kindLabels = map[Kind]string{
+	KindCustom:         "Custom",
+	KindBullCallSpread: "Bull Call Spread",
+	KindBearCallSpread: "Bear Call Spread",
+	KindBullPutSpread:  "Bull Put Spread",
+	KindBearPutSpread:  "Bear Put Spread",
+	KindStraddle:       "Straddle",
+	KindStrangle:       "Strangle",
+	KindIronCondor:     "Iron Condor",
+	KindIronButterfly:  "Iron Butterfly",
+}
+
+// defaultName returns a name like "NIFTY 25 Jan Iron Condor" for a Strategy of the given legs.
+func defaultName(kind Kind, positions []*position.Position) string {
+	label := kindLabels[kind]
+
+	legs, ok := toLegs(positions)
+	if !ok {
+		return label
+	}
+
+	underlying, expiry, ok := commonSeries(legs)
+	if !ok {
+		return label
+	}
+
+	if expiry.IsZero() {
+		return underlying + " " + label
+	}
+
+	return underlying + " " + expiry.Format("02 Jan") + " " + label
+}
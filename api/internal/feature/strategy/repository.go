@@ -0,0 +1,149 @@
+package strategy
+
+import (
+	"arthveda/internal/dbx"
+	"arthveda/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Reader interface {
+	GetByID(ctx context.Context, userID, strategyID uuid.UUID) (*Strategy, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*Strategy, error)
+}
+
+type Writer interface {
+	Create(ctx context.Context, s *Strategy) error
+	Update(ctx context.Context, s *Strategy) error
+	Delete(ctx context.Context, strategyID uuid.UUID) error
+	// SetLegs makes the Positions the only legs of the Strategy.
+	SetLegs(ctx context.Context, userID, strategyID uuid.UUID, positionIDs []uuid.UUID) error
+}
+
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+type strategyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) ReadWriter {
+	return &strategyRepository{db}
+}
+
+const strategyColumns = `id, created_by, created_at, updated_at, name, kind, underlying, expiry, risk_amount, is_auto_detected`
+
+func scanStrategy(row pgx.Row) (*Strategy, error) {
+	var s Strategy
+
+	err := row.Scan(&s.ID, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt, &s.Name, &s.Kind, &s.Underlying, &s.Expiry, &s.RiskAmount, &s.IsAutoDetected)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (r *strategyRepository) GetByID(ctx context.Context, userID, strategyID uuid.UUID) (*Strategy, error) {
+	row := dbx.Conn(ctx, r.db).QueryRow(ctx, `
+		SELECT `+strategyColumns+`
+		FROM strategy
+		WHERE id = $1 AND created_by = $2
+	`, strategyID, userID)
+
+	s, err := scanStrategy(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	return s, nil
+}
+
+func (r *strategyRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*Strategy, error) {
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
+		SELECT `+strategyColumns+`
+		FROM strategy
+		WHERE created_by = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	defer rows.Close()
+
+	strategies := []*Strategy{}
+	for rows.Next() {
+		s, err := scanStrategy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		strategies = append(strategies, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return strategies, nil
+}
+
+func (r *strategyRepository) Create(ctx context.Context, s *Strategy) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO strategy (`+strategyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, s.ID, s.CreatedBy, s.CreatedAt, s.UpdatedAt, s.Name, s.Kind, s.Underlying, s.Expiry, s.RiskAmount, s.IsAutoDetected)
+	return err
+}
+
+func (r *strategyRepository) Update(ctx context.Context, s *Strategy) error {
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
+		UPDATE strategy
+		SET name = $1, kind = $2, underlying = $3, expiry = $4, risk_amount = $5, updated_at = $6
+		WHERE id = $7
+	`, s.Name, s.Kind, s.Underlying, s.Expiry, s.RiskAmount, s.UpdatedAt, s.ID)
+	return err
+}
+
+func (r *strategyRepository) Delete(ctx context.Context, strategyID uuid.UUID) error {
+	// The legs are detached by the foreign key's ON DELETE SET NULL.
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `DELETE FROM strategy WHERE id = $1`, strategyID)
+	return err
+}
+
+func (r *strategyRepository) SetLegs(ctx context.Context, userID, strategyID uuid.UUID, positionIDs []uuid.UUID) error {
+	tx, err := dbx.Conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE position SET strategy_id = NULL
+		WHERE strategy_id = $1 AND NOT (id = ANY($2))
+	`, strategyID, positionIDs)
+	if err != nil {
+		return fmt.Errorf("detach legs: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE position SET strategy_id = $1
+		WHERE id = ANY($2) AND created_by = $3
+	`, strategyID, positionIDs, userID)
+	if err != nil {
+		return fmt.Errorf("attach legs: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,493 @@
+package strategy
+
+import (
+	"arthveda/internal/common"
+	"arthveda/internal/dbx"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/logger"
+	"arthveda/internal/repository"
+	"arthveda/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type Service struct {
+	db                 *pgxpool.Pool
+	strategyRepository ReadWriter
+	positionRepository position.ReadWriter
+}
+
+func NewService(db *pgxpool.Pool, strategyRepository ReadWriter, positionRepository position.ReadWriter) *Service {
+	return &Service{
+		db,
+		strategyRepository,
+		positionRepository,
+	}
+}
+
+var (
+	errNotEnoughLegs      = errors.New("A strategy must have at least 2 positions")
+	errLegNotFound        = errors.New("One or more positions were not found")
+	errLegInOtherStrategy = errors.New("One or more positions are already part of another strategy")
+	errLegsOnManySegments = errors.New("All the positions of a strategy must be on the same segment")
+	errInvalidKind        = errors.New("Strategy kind is invalid")
+	errInvalidRiskAmount  = errors.New("Risk amount cannot be negative")
+)
+
+type CreatePayload struct {
+	Name string `json:"name"`
+	// If nil, the kind is detected from the legs.
+	Kind        *Kind           `json:"kind"`
+	RiskAmount  decimal.Decimal `json:"risk_amount"`
+	PositionIDs []uuid.UUID     `json:"position_ids"`
+}
+
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, payload CreatePayload) (*Strategy, service.Error, error) {
+	legs, errKind, err := s.getLegsForStrategy(ctx, userID, nil, payload.PositionIDs)
+	if err != nil {
+		return nil, errKind, err
+	}
+
+	if payload.RiskAmount.IsNegative() {
+		return nil, service.ErrBadRequest, errInvalidRiskAmount
+	}
+
+	kind := DetectKind(legs)
+	if payload.Kind != nil {
+		if !payload.Kind.IsValid() {
+			return nil, service.ErrBadRequest, errInvalidKind
+		}
+		kind = *payload.Kind
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		name = defaultName(kind, legs)
+	}
+
+	strategy, err := New(userID, name, kind, payload.RiskAmount, false)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("new strategy: %w", err)
+	}
+
+	setSeries(strategy, legs)
+
+	err = s.create(ctx, strategy, legs)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	Compute(strategy, legs)
+
+	return strategy, service.ErrNone, nil
+}
+
+// create saves the Strategy with its legs in a transaction, so that a Strategy without legs isn't left behind.
+func (s *Service) create(ctx context.Context, strategy *Strategy, legs []*position.Position) error {
+	err := dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		err := s.strategyRepository.Create(ctx, strategy)
+		if err != nil {
+			return fmt.Errorf("strategy repository create: %w", err)
+		}
+
+		err = s.strategyRepository.SetLegs(ctx, strategy.CreatedBy, strategy.ID, positionIDs(legs))
+		if err != nil {
+			return fmt.Errorf("strategy repository set legs: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range legs {
+		p.StrategyID = &strategy.ID
+	}
+
+	return nil
+}
+
+type UpdatePayload struct {
+	Name       *string          `json:"name"`
+	Kind       *Kind            `json:"kind"`
+	RiskAmount *decimal.Decimal `json:"risk_amount"`
+	// If not nil, these become the only legs of the Strategy.
+	PositionIDs []uuid.UUID `json:"position_ids"`
+}
+
+func (s *Service) Update(ctx context.Context, userID, strategyID uuid.UUID, payload UpdatePayload) (*Strategy, service.Error, error) {
+	strategy, err := s.strategyRepository.GetByID(ctx, userID, strategyID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Strategy not found with ID: %s", strategyID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("strategy repository get by id: %w", err)
+	}
+
+	if payload.Name != nil && strings.TrimSpace(*payload.Name) != "" {
+		strategy.Name = strings.TrimSpace(*payload.Name)
+	}
+
+	if payload.Kind != nil {
+		if !payload.Kind.IsValid() {
+			return nil, service.ErrBadRequest, errInvalidKind
+		}
+		strategy.Kind = *payload.Kind
+	}
+
+	if payload.RiskAmount != nil {
+		if payload.RiskAmount.IsNegative() {
+			return nil, service.ErrBadRequest, errInvalidRiskAmount
+		}
+		strategy.RiskAmount = *payload.RiskAmount
+	}
+
+	var legs []*position.Position
+
+	if payload.PositionIDs != nil {
+		var errKind service.Error
+		legs, errKind, err = s.getLegsForStrategy(ctx, userID, &strategy.ID, payload.PositionIDs)
+		if err != nil {
+			return nil, errKind, err
+		}
+
+		setSeries(strategy, legs)
+	} else {
+		legs, err = s.findLegs(ctx, userID, []uuid.UUID{strategy.ID})
+		if err != nil {
+			return nil, service.ErrInternalServerError, err
+		}
+	}
+
+	now := time.Now().UTC()
+	strategy.UpdatedAt = &now
+
+	err = dbx.WithTx(ctx, s.db, func(ctx context.Context) error {
+		if payload.PositionIDs != nil {
+			err := s.strategyRepository.SetLegs(ctx, userID, strategy.ID, payload.PositionIDs)
+			if err != nil {
+				return fmt.Errorf("strategy repository set legs: %w", err)
+			}
+		}
+
+		err := s.strategyRepository.Update(ctx, strategy)
+		if err != nil {
+			return fmt.Errorf("strategy repository update: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	Compute(strategy, legs)
+
+	return strategy, service.ErrNone, nil
+}
+
+func (s *Service) Get(ctx context.Context, userID, strategyID uuid.UUID) (*Strategy, service.Error, error) {
+	strategy, err := s.strategyRepository.GetByID(ctx, userID, strategyID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Strategy not found with ID: %s", strategyID)
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("strategy repository get by id: %w", err)
+	}
+
+	legs, err := s.findLegs(ctx, userID, []uuid.UUID{strategy.ID})
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	Compute(strategy, legs)
+
+	return strategy, service.ErrNone, nil
+}
+
+// Delete deletes the Strategy. Its legs are kept as standalone Positions.
+func (s *Service) Delete(ctx context.Context, userID, strategyID uuid.UUID) (service.Error, error) {
+	_, err := s.strategyRepository.GetByID(ctx, userID, strategyID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return service.ErrNotFound, fmt.Errorf("Strategy not found with ID: %s", strategyID)
+		}
+		return service.ErrInternalServerError, fmt.Errorf("strategy repository get by id: %w", err)
+	}
+
+	err = s.strategyRepository.Delete(ctx, strategyID)
+	if err != nil {
+		return service.ErrInternalServerError, fmt.Errorf("strategy repository delete: %w", err)
+	}
+
+	return service.ErrNone, nil
+}
+
+type SearchFilter struct {
+	Opened     *common.DateRangeFilter `json:"opened"`
+	Status     *position.Status        `json:"status"`
+	Kind       *Kind                   `json:"kind"`
+	Underlying *string                 `json:"underlying"`
+}
+
+type SearchPayload = common.SearchPayload[SearchFilter]
+
+const (
+	searchFieldOpened  common.SearchField = "opened"
+	searchFieldNetPnL  common.SearchField = "net_pnl"
+	searchFieldRFactor common.SearchField = "r_factor"
+)
+
+var allowedSortFields = []common.SearchField{
+	searchFieldOpened,
+	searchFieldNetPnL,
+	searchFieldRFactor,
+}
+
+// Search searches the Strategies of the user. The combined stats of a Strategy are computed from its legs,
+// so the Strategies are filtered, sorted and paginated after they are computed.
+func (s *Service) Search(ctx context.Context, userID uuid.UUID, tz *time.Location, payload SearchPayload) (*common.SearchResult[[]*Strategy], service.Error, error) {
+	err := payload.Init(allowedSortFields)
+	if err != nil {
+		return nil, service.ErrInvalidInput, err
+	}
+
+	strategies, err := s.All(ctx, userID)
+	if err != nil {
+		return nil, service.ErrInternalServerError, err
+	}
+
+	var openedFrom, openedTo time.Time
+	if payload.Filters.Opened != nil {
+		if payload.Filters.Opened.From != nil {
+			openedFrom = *payload.Filters.Opened.From
+		}
+		if payload.Filters.Opened.To != nil {
+			openedTo = *payload.Filters.Opened.To
+		}
+
+		openedFrom, openedTo, err = common.NormalizeDateRangeFromTimezone(openedFrom, openedTo, tz)
+		if err != nil {
+			return nil, service.ErrInternalServerError, fmt.Errorf("normalize opened date range: %w", err)
+		}
+	}
+
+	filtered := []*Strategy{}
+	for _, st := range strategies {
+		f := payload.Filters
+
+		if f.Status != nil && *f.Status != "" && *f.Status != "all" && st.Status != *f.Status {
+			continue
+		}
+		if f.Kind != nil && *f.Kind != "" && st.Kind != *f.Kind {
+			continue
+		}
+		if f.Underlying != nil && *f.Underlying != "" && !strings.HasPrefix(st.Underlying, strings.ToUpper(*f.Underlying)) {
+			continue
+		}
+		if f.Opened != nil && f.Opened.From != nil && st.OpenedAt.Before(openedFrom) {
+			continue
+		}
+		if f.Opened != nil && f.Opened.To != nil && st.OpenedAt.After(openedTo) {
+			continue
+		}
+
+		filtered = append(filtered, st)
+	}
+
+	slices.SortStableFunc(filtered, func(a, b *Strategy) int {
+		var cmp int
+		switch payload.Sort.Field {
+		case searchFieldNetPnL:
+			cmp = a.NetPnLAmount.Cmp(b.NetPnLAmount)
+		case searchFieldRFactor:
+			cmp = a.RFactor.Cmp(b.RFactor)
+		default:
+			cmp = a.OpenedAt.Compare(b.OpenedAt)
+		}
+
+		if payload.Sort.Order != common.SortOrderASC {
+			cmp = -cmp
+		}
+		return cmp
+	})
+
+	totalItems := len(filtered)
+	start := min(payload.Pagination.Offset(), totalItems)
+	end := min(start+payload.Pagination.Limit, totalItems)
+
+	return common.NewSearchResult(filtered[start:end], payload.Pagination.GetMeta(totalItems)), service.ErrNone, nil
+}
+
+// All returns all the Strategies of the user, computed from their legs.
+func (s *Service) All(ctx context.Context, userID uuid.UUID) ([]*Strategy, error) {
+	strategies, err := s.strategyRepository.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("strategy repository list: %w", err)
+	}
+
+	if len(strategies) == 0 {
+		return strategies, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(strategies))
+	for _, st := range strategies {
+		ids = append(ids, st.ID)
+	}
+
+	legs, err := s.findLegs(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	legsByStrategyID := map[uuid.UUID][]*position.Position{}
+	for _, p := range legs {
+		legsByStrategyID[*p.StrategyID] = append(legsByStrategyID[*p.StrategyID], p)
+	}
+
+	for _, st := range strategies {
+		Compute(st, legsByStrategyID[st.ID])
+	}
+
+	return strategies, nil
+}
+
+// AutoDetect creates Strategies for the option Positions that look like the legs of one.
+// It is called after the Positions are imported, with the imported Positions.
+func (s *Service) AutoDetect(ctx context.Context, userID uuid.UUID, positions []*position.Position) ([]*Strategy, service.Error, error) {
+	l := logger.FromCtx(ctx)
+
+	strategies := []*Strategy{}
+
+	for _, legs := range GroupForAutoDetection(positions) {
+		kind := DetectKind(legs)
+
+		// A custom group on the same series is a coincidence more often than a strategy.
+		if kind == KindCustom {
+			continue
+		}
+
+		strategy, err := New(userID, defaultName(kind, legs), kind, decimal.Zero, true)
+		if err != nil {
+			return nil, service.ErrInternalServerError, fmt.Errorf("new strategy: %w", err)
+		}
+
+		setSeries(strategy, legs)
+
+		err = s.create(ctx, strategy, legs)
+		if err != nil {
+			return nil, service.ErrInternalServerError, err
+		}
+
+		Compute(strategy, legs)
+
+		l.Debugw("auto detected strategy", "strategy_id", strategy.ID, "kind", strategy.Kind, "legs", len(legs))
+
+		strategies = append(strategies, strategy)
+	}
+
+	return strategies, service.ErrNone, nil
+}
+
+// getLegsForStrategy returns the Positions that will be the legs of the Strategy with `strategyID`
+// (nil for a new Strategy) after making sure they belong to the user and to no other Strategy.
+func (s *Service) getLegsForStrategy(ctx context.Context, userID uuid.UUID, strategyID *uuid.UUID, ids []uuid.UUID) ([]*position.Position, service.Error, error) {
+	ids = uniqueIDs(ids)
+
+	if len(ids) < 2 {
+		return nil, service.ErrBadRequest, errNotEnoughLegs
+	}
+
+	legs, _, err := s.positionRepository.Search(ctx, position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy: &userID,
+			IDs:       ids,
+		},
+	}, true, false)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("position repository search: %w", err)
+	}
+
+	if len(legs) != len(ids) {
+		return nil, service.ErrBadRequest, errLegNotFound
+	}
+
+	for _, p := range legs {
+		if p.StrategyID != nil && (strategyID == nil || *p.StrategyID != *strategyID) {
+			return nil, service.ErrConflict, errLegInOtherStrategy
+		}
+
+		// The reports filter Strategies by the segment of their legs.
+		if p.Segment.OrDefault() != legs[0].Segment.OrDefault() {
+			return nil, service.ErrBadRequest, errLegsOnManySegments
+		}
+	}
+
+	return legs, service.ErrNone, nil
+}
+
+func (s *Service) findLegs(ctx context.Context, userID uuid.UUID, strategyIDs []uuid.UUID) ([]*position.Position, error) {
+	legs, _, err := s.positionRepository.Search(ctx, position.SearchPayload{
+		Filters: position.SearchFilter{
+			CreatedBy:   &userID,
+			StrategyIDs: strategyIDs,
+		},
+		Sort: common.Sorting{
+			Field: "opened",
+			Order: common.SortOrderASC,
+		},
+	}, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("position repository search legs: %w", err)
+	}
+
+	return legs, nil
+}
+
+// setSeries sets the underlying and expiry of the Strategy if all the legs share them.
+func setSeries(strategy *Strategy, legs []*position.Position) {
+	strategy.Underlying = ""
+	strategy.Expiry = nil
+
+	optionLegs, ok := toLegs(legs)
+	if !ok {
+		return
+	}
+
+	if underlying, expiry, ok := commonSeries(optionLegs); ok {
+		strategy.Underlying = underlying
+		if !expiry.IsZero() {
+			strategy.Expiry = &expiry
+		}
+	}
+}
+
+func positionIDs(positions []*position.Position) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(positions))
+	for _, p := range positions {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	unique := []uuid.UUID{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
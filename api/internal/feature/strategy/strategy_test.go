@@ -0,0 +1,200 @@
+package strategy
+
+import (
+	"arthveda/internal/domain/symbol"
+	"arthveda/internal/domain/types"
+	"arthveda/internal/feature/position"
+	"arthveda/internal/feature/trade"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func optionLeg(symbol string, direction position.Direction, qty, price string, openedAt time.Time) *position.Position {
+	kind := types.TradeKindBuy
+	if direction == position.DirectionShort {
+		kind = types.TradeKindSell
+	}
+
+	return &position.Position{
+		Symbol:     symbol,
+		Instrument: types.InstrumentOption,
+		Direction:  direction,
+		Status:     position.StatusOpen,
+		OpenedAt:   openedAt,
+		FxRate:     decimal.NewFromInt(1),
+		Trades: []*trade.Trade{
+			{Kind: kind, Quantity: decimal.RequireFromString(qty), Price: decimal.RequireFromString(price), Time: openedAt},
+		},
+	}
+}
+
+func TestOptionContractOf(t *testing.T) {
+	at := time.Date(2024, 1, 10, 9, 20, 0, 0, time.UTC)
+
+	c, ok := optionContractOf(optionLeg("NIFTY24JAN22000CE", position.DirectionLong, "50", "120", at))
+	if !ok {
+		t.Fatal("expected to decompose the option")
+	}
+
+	if c.Underlying != "NIFTY" || !c.Expiry.Equal(time.Date(2024, 1, 25, 0, 0, 0, 0, time.UTC)) ||
+		!c.Strike.Equal(decimal.NewFromInt(22000)) || c.Right != symbol.OptionRightCall {
+		t.Errorf("got %+v", c)
+	}
+
+	future := optionLeg("NIFTY24JANFUT", position.DirectionLong, "50", "22000", at)
+	future.Instrument = types.InstrumentFuture
+	if _, ok := optionContractOf(future); ok {
+		t.Error("expected a future not to decompose as an option")
+	}
+
+	if _, ok := optionContractOf(optionLeg("RELIANCE", position.DirectionLong, "1", "2500", at)); ok {
+		t.Error("expected an unrecognised symbol not to decompose")
+	}
+}
+
+func TestDetectKindAndMaxRisk(t *testing.T) {
+	at := time.Date(2024, 1, 10, 9, 20, 0, 0, time.UTC)
+	long, short := position.DirectionLong, position.DirectionShort
+
+	tests := []struct {
+		name    string
+		legs    []*position.Position
+		kind    Kind
+		maxRisk string // Empty if unlimited.
+	}{
+		{
+			name: "bull call spread",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN22000CE", long, "50", "120", at),
+				optionLeg("NIFTY24JAN22200CE", short, "50", "50", at),
+			},
+			kind:    KindBullCallSpread,
+			maxRisk: "3500", // Net debit of 70.
+		},
+		{
+			name: "bull put spread",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN21800PE", long, "50", "40", at),
+				optionLeg("NIFTY24JAN22000PE", short, "50", "100", at),
+			},
+			kind:    KindBullPutSpread,
+			maxRisk: "7000", // Width of 200 less the credit of 60.
+		},
+		{
+			name: "short straddle",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN22000CE", short, "50", "150", at),
+				optionLeg("NIFTY24JAN22000PE", short, "50", "140", at),
+			},
+			kind: KindStraddle,
+		},
+		{
+			name: "long strangle",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN22200CE", long, "50", "60", at),
+				optionLeg("NIFTY24JAN21800PE", long, "50", "55", at),
+			},
+			kind:    KindStrangle,
+			maxRisk: "5750",
+		},
+		{
+			name: "iron condor",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN21700PE", long, "50", "20", at),
+				optionLeg("NIFTY24JAN21800PE", short, "50", "40", at),
+				optionLeg("NIFTY24JAN22200CE", short, "50", "45", at),
+				optionLeg("NIFTY24JAN22300CE", long, "50", "25", at),
+			},
+			kind:    KindIronCondor,
+			maxRisk: "3000", // Width of 100 less the credit of 40.
+		},
+		{
+			name: "different expiries",
+			legs: []*position.Position{
+				optionLeg("NIFTY24JAN22000CE", long, "50", "120", at),
+				optionLeg("NIFTY24FEB22200CE", short, "50", "50", at),
+			},
+			kind: KindCustom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kind := DetectKind(tt.legs); kind != tt.kind {
+				t.Errorf("expected kind %s, got %s", tt.kind, kind)
+			}
+
+			s := &Strategy{}
+			Compute(s, tt.legs)
+
+			if tt.maxRisk == "" {
+				if tt.kind != KindCustom && s.MaxRiskAmount != nil {
+					t.Errorf("expected unlimited max risk, got %s", s.MaxRiskAmount)
+				}
+				return
+			}
+
+			if s.MaxRiskAmount == nil || !s.MaxRiskAmount.Equal(decimal.RequireFromString(tt.maxRisk)) {
+				t.Errorf("expected max risk %s, got %v", tt.maxRisk, s.MaxRiskAmount)
+			}
+		})
+	}
+}
+
+func TestCompute_CombinedStats(t *testing.T) {
+	at := time.Date(2024, 1, 10, 9, 20, 0, 0, time.UTC)
+	closedAt := at.Add(time.Hour)
+
+	buy := optionLeg("NIFTY24JAN22000CE", position.DirectionLong, "50", "120", at)
+	buy.Status, buy.ClosedAt = position.StatusWin, &closedAt
+	buy.GrossPnLAmount, buy.NetPnLAmount, buy.TotalChargesAmount = decimal.NewFromInt(4000), decimal.NewFromInt(3900), decimal.NewFromInt(100)
+
+	sell := optionLeg("NIFTY24JAN22200CE", position.DirectionShort, "50", "50", at)
+	sell.Status, sell.ClosedAt = position.StatusLoss, &closedAt
+	sell.GrossPnLAmount, sell.NetPnLAmount, sell.TotalChargesAmount = decimal.NewFromInt(-1500), decimal.NewFromInt(-1600), decimal.NewFromInt(100)
+
+	s := &Strategy{}
+	Compute(s, []*position.Position{buy, sell})
+
+	if s.Status != position.StatusWin {
+		t.Errorf("expected status win, got %s", s.Status)
+	}
+
+	if !s.NetPnLAmount.Equal(decimal.NewFromInt(2300)) || !s.TotalChargesAmount.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("expected net PnL 2300 and charges 200, got %s and %s", s.NetPnLAmount, s.TotalChargesAmount)
+	}
+
+	// The max risk is the net debit of 3500.
+	if !s.RFactor.Equal(decimal.RequireFromString("2300").Div(decimal.NewFromInt(3500))) {
+		t.Errorf("unexpected R-Factor %s", s.RFactor)
+	}
+
+	if s.ClosedAt == nil || !s.ClosedAt.Equal(closedAt) {
+		t.Errorf("expected closed at %s, got %v", closedAt, s.ClosedAt)
+	}
+}
+
+func TestGroupForAutoDetection(t *testing.T) {
+	at := time.Date(2024, 1, 10, 9, 20, 0, 0, time.UTC)
+
+	positions := []*position.Position{
+		optionLeg("NIFTY24JAN22000CE", position.DirectionLong, "50", "120", at),
+		optionLeg("NIFTY24JAN22200CE", position.DirectionShort, "50", "50", at.Add(20*time.Second)),
+		// Same series, but entered much later.
+		optionLeg("NIFTY24JAN22500CE", position.DirectionShort, "50", "20", at.Add(time.Hour)),
+		// Different underlying.
+		optionLeg("BANKNIFTY24JAN48000PE", position.DirectionLong, "15", "200", at),
+	}
+
+	groups := GroupForAutoDetection(positions)
+
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Fatalf("expected one group of 2 legs, got %d groups", len(groups))
+	}
+
+	if groups[0][0].Symbol != "NIFTY24JAN22000CE" || groups[0][1].Symbol != "NIFTY24JAN22200CE" {
+		t.Errorf("unexpected legs %s, %s", groups[0][0].Symbol, groups[0][1].Symbol)
+	}
+}
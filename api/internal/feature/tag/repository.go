@@ -1,6 +1,7 @@
 package tag
 
 import (
+	"arthveda/internal/dbx"
 	"context"
 	"fmt"
 	"time"
@@ -45,7 +46,7 @@ func NewRepository(db *pgxpool.Pool) ReadWriter {
 }
 
 func (r *repository) CreateTagGroup(ctx context.Context, tg *TagGroup) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		INSERT INTO tag_group (id, user_id, name, description, created_at)
 		VALUES ($1, $2, $3, $4, $5)
 	`, tg.ID, tg.UserID, tg.Name, tg.Description, tg.CreatedAt)
@@ -53,7 +54,7 @@ func (r *repository) CreateTagGroup(ctx context.Context, tg *TagGroup) error {
 }
 
 func (r *repository) UpdateTagGroup(ctx context.Context, tg *TagGroup) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		UPDATE tag_group
 		SET name = $1, description = $2, updated_at = $3
 		WHERE id = $4
@@ -62,7 +63,7 @@ func (r *repository) UpdateTagGroup(ctx context.Context, tg *TagGroup) error {
 }
 
 func (r *repository) GetTagGroupByID(ctx context.Context, tagGroupID uuid.UUID) (*TagGroup, error) {
-	row := r.db.QueryRow(ctx, `
+	row := dbx.Conn(ctx, r.db).QueryRow(ctx, `
 		SELECT id, user_id, name, description, created_at, updated_at
 		FROM tag_group
 		WHERE id = $1
@@ -76,7 +77,7 @@ func (r *repository) GetTagGroupByID(ctx context.Context, tagGroupID uuid.UUID)
 }
 
 func (r *repository) CreateTag(ctx context.Context, tag *Tag) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		INSERT INTO tag (id, group_id, name, description, created_at)
 		VALUES ($1, $2, $3, $4, $5)
 	`, tag.ID, tag.GroupID, tag.Name, tag.Description, tag.CreatedAt)
@@ -84,14 +85,14 @@ func (r *repository) CreateTag(ctx context.Context, tag *Tag) error {
 }
 
 func (r *repository) DeleteTag(ctx context.Context, tagID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		DELETE FROM tag WHERE id = $1
 	`, tagID)
 	return err
 }
 
 func (r *repository) AttachTagToPosition(ctx context.Context, positionID, tagID uuid.UUID, createdAt time.Time) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		INSERT INTO position_tag (position_id, tag_id, created_at)
 		VALUES ($1, $2, $3)
 		ON CONFLICT DO NOTHING
@@ -113,7 +114,7 @@ func (r *repository) AttachTagsToPosition(ctx context.Context, positionID uuid.U
 		`, positionID, tagID, createdAt)
 	}
 
-	br := r.db.SendBatch(ctx, batch)
+	br := dbx.Conn(ctx, r.db).SendBatch(ctx, batch)
 	defer br.Close()
 
 	for range tagIDs {
@@ -126,14 +127,14 @@ func (r *repository) AttachTagsToPosition(ctx context.Context, positionID uuid.U
 }
 
 func (r *repository) RemoveAllTagsFromPosition(ctx context.Context, positionID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		DELETE FROM position_tag WHERE position_id = $1
 	`, positionID)
 	return err
 }
 
 func (r *repository) ListTagGroupsWithTags(ctx context.Context, userID uuid.UUID) ([]*TagGroupWithTags, error) {
-	rows, err := r.db.Query(ctx, `
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
 		SELECT tg.id, tg.user_id, tg.name, tg.description, tg.created_at, tg.updated_at,
 		       t.id, t.group_id, t.name, t.description, t.created_at, t.updated_at
 		FROM tag_group tg
@@ -203,7 +204,7 @@ func (r *repository) ListTagGroupsWithTags(ctx context.Context, userID uuid.UUID
 }
 
 func (r *repository) UpdateTag(ctx context.Context, tag *Tag) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		UPDATE tag
 		SET name = $1, description = $2, updated_at = $3
 		WHERE id = $4
@@ -235,7 +236,7 @@ func (r *repository) GetTagsByIDs(ctx context.Context, tagIDs []uuid.UUID) ([]*T
 		FROM tag
 		WHERE id = ANY($1)
 	`
-	rows, err := r.db.Query(ctx, query, tagIDs)
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, query, tagIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tags by IDs: %w", err)
 	}
@@ -255,7 +256,7 @@ func (r *repository) GetTagsByIDs(ctx context.Context, tagIDs []uuid.UUID) ([]*T
 }
 
 func (r *repository) GetTagsByPositionID(ctx context.Context, positionID uuid.UUID) ([]*Tag, error) {
-	rows, err := r.db.Query(ctx, `
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
 		SELECT t.id, t.group_id, t.name, t.description, t.created_at, t.updated_at
 		FROM tag t
 		JOIN position_tag pt ON pt.tag_id = t.id
@@ -287,7 +288,7 @@ func (r *repository) GetTagsByPositionIDs(ctx context.Context, positionIDs []uui
 		return []*TagWithPositionID{}, nil
 	}
 
-	rows, err := r.db.Query(ctx, `
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
 		SELECT pt.position_id, t.id, t.group_id, t.name, t.description, t.created_at, t.updated_at
 		FROM tag t
 		JOIN position_tag pt ON pt.tag_id = t.id
@@ -314,7 +315,7 @@ func (r *repository) GetTagsByPositionIDs(ctx context.Context, positionIDs []uui
 }
 
 func (r *repository) DeleteTagGroup(ctx context.Context, tagGroupID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		DELETE FROM tag_group WHERE id = $1
 	`, tagGroupID)
 	return err
@@ -27,18 +27,24 @@ type Trade struct {
 
 	ChargesAmount decimal.Decimal `json:"charges_amount" db:"charges_amount"`
 
+	// The components of ChargesAmount. Nil if the charges weren't calculated automatically.
+	ChargesBreakdown *ChargesBreakdown `json:"charges_breakdown" db:"charges_breakdown"`
+
 	// The ID of the trade in the broker's system, if applicable.
 	// This will help us to prevent duplicate trades.
 	BrokerTradeID *string `json:"broker_trade_id" db:"broker_trade_id"`
 
-	// These are the fields that are computed at runtime and are not stored in the database.
-	// They are used for dashboard analytics. I am still not sure if we should store them in the database.
-	// I am storing ChargesAmount, so I think we should store these too??
+	// The ID of the import batch that added this trade, if any.
+	ImportBatchID *uuid.UUID `json:"import_batch_id" db:"import_batch_id"`
+
+	// These are the realised stats of a scale-out trade, computed by the Position when it is
+	// created or updated and stored alongside the trade so that analytics don't have to recompute them.
+	// They are zero for a scale-in trade.
 	RealisedGrossPnL decimal.Decimal `json:"realised_gross_pnl" db:"realised_gross_pnl"`
 	RealisedNetPnL   decimal.Decimal `json:"realised_net_pnl" db:"realised_net_pnl"`
-	GrossROI         decimal.Decimal `json:"gross_roi" db:"roi"`
-	NetRFactor       decimal.Decimal `json:"r_factor"`
-	GrossRFactor     decimal.Decimal `json:"gross_r_factor"`
+	GrossROI         decimal.Decimal `json:"gross_roi" db:"gross_roi"`
+	NetRFactor       decimal.Decimal `json:"r_factor" db:"net_r_factor"`
+	GrossRFactor     decimal.Decimal `json:"gross_r_factor" db:"gross_r_factor"`
 
 	MatchedLots []MatchedLot `json:"matched_lots" db:"matched_lots"`
 }
@@ -50,6 +56,71 @@ type MatchedLot struct {
 	PnL      decimal.Decimal `json:"pnl" db:"pnl"`
 }
 
+// ChargesBreakdown is the charges of a trade by their components. The components aren't rounded,
+// so they can add up to a fraction more than the ChargesAmount of the trade.
+type ChargesBreakdown struct {
+	Brokerage decimal.Decimal `json:"brokerage"`
+	// STT, or CTT for commodities.
+	STT                       decimal.Decimal `json:"stt"`
+	ExchangeTransaction       decimal.Decimal `json:"exchange_transaction"`
+	Stamp                     decimal.Decimal `json:"stamp"`
+	SEBI                      decimal.Decimal `json:"sebi"`
+	DP                        decimal.Decimal `json:"dp"`
+	NSEInvestorProtectionFund decimal.Decimal `json:"nse_investor_protection_fund"`
+	GST                       decimal.Decimal `json:"gst"`
+
+	// The fees on US exchanges. The SEC fee, FINRA TAF and options regulatory fee are Regulatory.
+	Regulatory decimal.Decimal `json:"regulatory"`
+	Clearing   decimal.Decimal `json:"clearing"`
+}
+
+func (b ChargesBreakdown) Add(o ChargesBreakdown) ChargesBreakdown {
+	return ChargesBreakdown{
+		Brokerage:                 b.Brokerage.Add(o.Brokerage),
+		STT:                       b.STT.Add(o.STT),
+		ExchangeTransaction:       b.ExchangeTransaction.Add(o.ExchangeTransaction),
+		Stamp:                     b.Stamp.Add(o.Stamp),
+		SEBI:                      b.SEBI.Add(o.SEBI),
+		DP:                        b.DP.Add(o.DP),
+		NSEInvestorProtectionFund: b.NSEInvestorProtectionFund.Add(o.NSEInvestorProtectionFund),
+		GST:                       b.GST.Add(o.GST),
+		Regulatory:                b.Regulatory.Add(o.Regulatory),
+		Clearing:                  b.Clearing.Add(o.Clearing),
+	}
+}
+
+func (b ChargesBreakdown) Mul(d decimal.Decimal) ChargesBreakdown {
+	return ChargesBreakdown{
+		Brokerage:                 b.Brokerage.Mul(d),
+		STT:                       b.STT.Mul(d),
+		ExchangeTransaction:       b.ExchangeTransaction.Mul(d),
+		Stamp:                     b.Stamp.Mul(d),
+		SEBI:                      b.SEBI.Mul(d),
+		DP:                        b.DP.Mul(d),
+		NSEInvestorProtectionFund: b.NSEInvestorProtectionFund.Mul(d),
+		GST:                       b.GST.Mul(d),
+		Regulatory:                b.Regulatory.Mul(d),
+		Clearing:                  b.Clearing.Mul(d),
+	}
+}
+
+func (b ChargesBreakdown) Total() decimal.Decimal {
+	return b.Brokerage.Add(b.STT).Add(b.ExchangeTransaction).Add(b.Stamp).Add(b.SEBI).Add(b.DP).Add(b.NSEInvestorProtectionFund).Add(b.GST).Add(b.Regulatory).Add(b.Clearing)
+}
+
+// maxChargedParts is the most parts a trade is charged in apart, the intraday and delivery quantity of an equity trade.
+const maxChargedParts = 2
+
+// chargesBreakdownTolerance is how much the Total of a ChargesBreakdown can differ from the ChargesAmount
+// it was calculated with. The charges of each part are truncated to the cent while their breakdown isn't,
+// so the two differ by less than a cent for every part.
+var chargesBreakdownTolerance = decimal.New(maxChargedParts, -2)
+
+// AddsUpTo returns whether the components add up to the charges amount, within rounding.
+func (b ChargesBreakdown) AddsUpTo(amount decimal.Decimal) bool {
+	return b.Total().Sub(amount).Abs().LessThanOrEqual(chargesBreakdownTolerance)
+}
+
 type CreatePayload struct {
 	PositionID    uuid.UUID
 	Kind          types.TradeKind `json:"kind"`
@@ -57,7 +128,9 @@ type CreatePayload struct {
 	Quantity      decimal.Decimal `json:"quantity"`
 	Price         decimal.Decimal `json:"price"`
 	ChargesAmount decimal.Decimal `json:"charges_amount"`
-	BrokerTradeID *string         `json:"broker_trade_id"`
+	// As returned by the compute endpoint for the ChargesAmount.
+	ChargesBreakdown *ChargesBreakdown `json:"charges_breakdown"`
+	BrokerTradeID    *string           `json:"broker_trade_id"`
 }
 
 func New(payload CreatePayload) (*Trade, error) {
@@ -68,6 +141,13 @@ func New(payload CreatePayload) (*Trade, error) {
 		return nil, fmt.Errorf("uuid: %w", err)
 	}
 
+	// A breakdown that doesn't add up to the charges amount is of charges that were edited since,
+	// so it no longer applies.
+	chargesBreakdown := payload.ChargesBreakdown
+	if chargesBreakdown != nil && !chargesBreakdown.AddsUpTo(payload.ChargesAmount) {
+		chargesBreakdown = nil
+	}
+
 	trade := &Trade{
 		ID:            ID,
 		PositionID:    payload.PositionID,
@@ -78,6 +158,8 @@ func New(payload CreatePayload) (*Trade, error) {
 		Price:         payload.Price,
 		ChargesAmount: payload.ChargesAmount,
 		BrokerTradeID: payload.BrokerTradeID,
+
+		ChargesBreakdown: chargesBreakdown,
 	}
 
 	return trade, nil
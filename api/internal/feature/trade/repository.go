@@ -20,6 +20,8 @@ type Reader interface {
 
 type Writer interface {
 	CreateForPosition(ctx context.Context, trades []*Trade) ([]*Trade, error)
+	UpdateRealisedStats(ctx context.Context, trades []*Trade) error
+	UpdateCharges(ctx context.Context, trades []*Trade) error
 	DeleteByPositionID(ctx context.Context, positionID uuid.UUID) error
 }
 
@@ -71,14 +73,25 @@ func (r *tradeRepository) CreateForPosition(ctx context.Context, trades []*Trade
 			t.Quantity,
 			t.Price,
 			t.ChargesAmount,
+			t.ChargesBreakdown,
 			t.BrokerTradeID,
+			t.ImportBatchID,
+			t.RealisedGrossPnL,
+			t.RealisedNetPnL,
+			t.GrossROI,
+			t.GrossRFactor,
+			t.NetRFactor,
+			matchedLotsOrEmpty(t.MatchedLots),
 		}
 	}
 
-	_, err := r.db.CopyFrom(
+	_, err := dbx.Conn(ctx, r.db).CopyFrom(
 		ctx,
 		pgx.Identifier{"trade"},
-		[]string{"id", "position_id", "created_at", "updated_at", "kind", "time", "quantity", "price", "charges_amount", "broker_trade_id"},
+		[]string{
+			"id", "position_id", "created_at", "updated_at", "kind", "time", "quantity", "price", "charges_amount", "charges_breakdown", "broker_trade_id", "import_batch_id",
+			"realised_gross_pnl", "realised_net_pnl", "gross_roi", "gross_r_factor", "net_r_factor", "matched_lots",
+		},
 		pgx.CopyFromRows(rows),
 	)
 
@@ -89,8 +102,64 @@ func (r *tradeRepository) CreateForPosition(ctx context.Context, trades []*Trade
 	return trades, nil
 }
 
+// UpdateRealisedStats updates the realised stats of the trades, e.g. after their Position
+// was recomputed with a different lot-matching method.
+func (r *tradeRepository) UpdateRealisedStats(ctx context.Context, trades []*Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, t := range trades {
+		batch.Queue(`
+			UPDATE trade
+			SET realised_gross_pnl = $2, realised_net_pnl = $3, gross_roi = $4,
+				gross_r_factor = $5, net_r_factor = $6, matched_lots = $7
+			WHERE id = $1
+		`, t.ID, t.RealisedGrossPnL, t.RealisedNetPnL, t.GrossROI, t.GrossRFactor, t.NetRFactor, matchedLotsOrEmpty(t.MatchedLots))
+	}
+
+	br := dbx.Conn(ctx, r.db).SendBatch(ctx, batch)
+	defer br.Close()
+
+	for range trades {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateCharges updates the charges of the trades, e.g. after they were replaced with the actual charges.
+func (r *tradeRepository) UpdateCharges(ctx context.Context, trades []*Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, t := range trades {
+		batch.Queue(`
+			UPDATE trade
+			SET charges_amount = $2, charges_breakdown = $3, updated_at = $4
+			WHERE id = $1
+		`, t.ID, t.ChargesAmount, t.ChargesBreakdown, t.UpdatedAt)
+	}
+
+	br := dbx.Conn(ctx, r.db).SendBatch(ctx, batch)
+	defer br.Close()
+
+	for range trades {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (r *tradeRepository) DeleteByPositionID(ctx context.Context, positionID uuid.UUID) error {
-	tx, err := r.db.Begin(ctx)
+	tx, err := dbx.Conn(ctx, r.db).Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin: %w", err)
 	}
@@ -116,7 +185,7 @@ func (r *tradeRepository) FindByPositionID(ctx context.Context, positionID uuid.
 }
 
 func (r *tradeRepository) FindByPositionIDs(ctx context.Context, positionIDs []uuid.UUID) ([]*Trade, error) {
-	tx, err := r.db.Begin(ctx)
+	tx, err := dbx.Conn(ctx, r.db).Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("begin: %w", err)
 	}
@@ -150,7 +219,7 @@ func (r *tradeRepository) GetAllBrokerTradeIDs(ctx context.Context, userID, brok
 
 	sql, args := b.Build()
 
-	rows, err := r.db.Query(ctx, sql, args...)
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
@@ -188,7 +257,8 @@ func (r *tradeRepository) findTrades(ctx context.Context, tx pgx.Tx, f *filter)
 	}
 
 	sql := `
-	SELECT id, position_id, created_at, updated_at, kind, time, quantity, price, charges_amount, broker_trade_id
+	SELECT id, position_id, created_at, updated_at, kind, time, quantity, price, charges_amount, charges_breakdown, broker_trade_id, import_batch_id,
+		realised_gross_pnl, realised_net_pnl, gross_roi, gross_r_factor, net_r_factor, matched_lots
 	FROM trade ` + repository.WhereSQL(where)
 
 	rows, err := tx.Query(ctx, sql, args)
@@ -212,7 +282,15 @@ func (r *tradeRepository) findTrades(ctx context.Context, tx pgx.Tx, f *filter)
 			&trade.Quantity,
 			&trade.Price,
 			&trade.ChargesAmount,
+			&trade.ChargesBreakdown,
 			&trade.BrokerTradeID,
+			&trade.ImportBatchID,
+			&trade.RealisedGrossPnL,
+			&trade.RealisedNetPnL,
+			&trade.GrossROI,
+			&trade.GrossRFactor,
+			&trade.NetRFactor,
+			&trade.MatchedLots,
 		)
 
 		if err != nil {
@@ -228,3 +306,11 @@ func (r *tradeRepository) findTrades(ctx context.Context, tx pgx.Tx, f *filter)
 
 	return trades, nil
 }
+
+// matchedLotsOrEmpty makes sure that a scale-in trade is stored with an empty array of matched lots instead of NULL.
+func matchedLotsOrEmpty(lots []MatchedLot) []MatchedLot {
+	if lots == nil {
+		return []MatchedLot{}
+	}
+	return lots
+}
@@ -1,6 +1,7 @@
 package upload
 
 import (
+	"arthveda/internal/dbx"
 	"arthveda/internal/repository"
 	"context"
 	"fmt"
@@ -36,7 +37,7 @@ func NewRepository(db *pgxpool.Pool) *uploadRepository {
 }
 
 func (r *uploadRepository) Create(ctx context.Context, upload *Upload) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		INSERT INTO uploads (id, user_id, resource_type, resource_id, object_key, file_name, mime_type, size_bytes, status, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`, upload.ID, upload.UserID, upload.ResourceType, upload.ResourceID, upload.ObjectKey, upload.FileName, upload.MimeType, upload.SizeBytes,
@@ -49,7 +50,7 @@ func (r *uploadRepository) Create(ctx context.Context, upload *Upload) error {
 }
 
 func (r *uploadRepository) FindUploadByID(ctx context.Context, uploadID uuid.UUID) (*Upload, error) {
-	row := r.db.QueryRow(ctx, `
+	row := dbx.Conn(ctx, r.db).QueryRow(ctx, `
 		SELECT id, user_id, resource_type, resource_id, object_key, file_name, mime_type, size_bytes, status, created_at
 		FROM uploads
 		WHERE id = $1
@@ -73,7 +74,7 @@ func (r *uploadRepository) FindUploadByID(ctx context.Context, uploadID uuid.UUI
 
 func (r *uploadRepository) SyncJournalEntryUploads(ctx context.Context, userID, journalEntryID uuid.UUID, activeUploadIDs []uuid.UUID) error {
 	// Update the given upload IDs to link to the journal entry and set status to 'active'
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		UPDATE uploads
 		SET resource_id = $1, status = $2
 		WHERE id = ANY($3) AND user_id = $4
@@ -85,7 +86,7 @@ func (r *uploadRepository) SyncJournalEntryUploads(ctx context.Context, userID,
 	// Unlink any uploads previously linked to this journal entry that are not in the given upload IDs.
 	// This handles the case where an image was removed from the journal entry.
 	// The cron job will clean up unlinked uploads later.
-	_, err = r.db.Exec(ctx, `
+	_, err = dbx.Conn(ctx, r.db).Exec(ctx, `
 		UPDATE uploads
 		SET resource_id = NULL, status = $1
 		WHERE resource_type = $2 AND resource_id = $3 AND id != ALL($4) AND user_id = $5
@@ -98,7 +99,7 @@ func (r *uploadRepository) SyncJournalEntryUploads(ctx context.Context, userID,
 }
 
 func (r *uploadRepository) FindUploadsToCleanup(ctx context.Context) ([]*Upload, error) {
-	rows, err := r.db.Query(ctx, `
+	rows, err := dbx.Conn(ctx, r.db).Query(ctx, `
 		SELECT id, user_id, resource_type, resource_id, object_key, file_name, mime_type, size_bytes,
 		status, created_at
 		FROM uploads
@@ -130,7 +131,7 @@ func (r *uploadRepository) FindUploadsToCleanup(ctx context.Context) ([]*Upload,
 }
 
 func (r *uploadRepository) DeleteByIDs(ctx context.Context, uploadIDs []uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, `
 		DELETE FROM uploads
 		WHERE id = ANY($1)
 	`, uploadIDs)
@@ -142,7 +143,7 @@ func (r *uploadRepository) DeleteByIDs(ctx context.Context, uploadIDs []uuid.UUI
 }
 
 func (r *uploadRepository) GetTotalBytesUsed(ctx context.Context, userID uuid.UUID) (int64, error) {
-	row := r.db.QueryRow(ctx, `
+	row := dbx.Conn(ctx, r.db).QueryRow(ctx, `
 		SELECT COALESCE(SUM(size_bytes), 0) FROM uploads
 		WHERE user_id = $1 AND status = $2
 	`, userID, StatusActive)
@@ -1,6 +1,8 @@
 package userbrokeraccount
 
 import (
+	"arthveda/internal/domain/broker_integration"
+	"arthveda/internal/feature/charge"
 	"fmt"
 	"time"
 
@@ -19,6 +21,15 @@ type UserBrokerAccount struct {
 	LastSyncAt    *time.Time `json:"last_sync_at" db:"last_sync_at"`
 	LastLoginAt   *time.Time `json:"last_login_at" db:"last_login_at"`
 
+	// The user's own brokerage plan on the account. The broker's default plan is used if nil.
+	BrokeragePlan *charge.BrokeragePlan `json:"brokerage_plan" db:"brokerage_plan"`
+
+	// The fee model of the exchanges the account trades on. The Indian one is used if nil.
+	FeeModel *charge.FeeModel `json:"fee_model" db:"fee_model"`
+
+	// The user's mapping of the columns of the account's tradebook, if the broker's own adapter can't read it.
+	FileMapping *broker_integration.FileMapping `json:"file_mapping" db:"file_mapping"`
+
 	OAuthClientSecretBytes []byte `json:"-" db:"oauth_client_secret_bytes"`
 	OAuthClientSecretNonce []byte `json:"-" db:"oauth_client_secret_nonce"`
 	AccessTokenBytes       []byte `json:"-" db:"access_token_bytes"`
@@ -67,6 +78,21 @@ type UpdatePayload struct {
 	OAuthClientSecret string `json:"oauth_client_secret"`
 }
 
+type UpdateBrokeragePlanPayload struct {
+	// Removes the plan if nil.
+	BrokeragePlan *charge.BrokeragePlan `json:"brokerage_plan"`
+}
+
+type UpdateFeeModelPayload struct {
+	// Resets the account to the Indian fee model if nil.
+	FeeModel *charge.FeeModel `json:"fee_model"`
+}
+
+type UpdateFileMappingPayload struct {
+	// Removes the mapping if nil, and the broker's own adapter is used again.
+	FileMapping *broker_integration.FileMapping `json:"file_mapping"`
+}
+
 type ConnectPayload struct {
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
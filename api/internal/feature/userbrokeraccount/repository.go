@@ -53,8 +53,9 @@ func (r *userBrokerAccountRepository) Create(ctx context.Context, account *UserB
 	sql := `
 		INSERT INTO user_broker_account (
 			id, name, broker_id, user_id, created_at, last_login_at, 
-			oauth_client_secret_nonce, oauth_client_secret_bytes, access_token_bytes, access_token_bytes_nonce
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			oauth_client_secret_nonce, oauth_client_secret_bytes, access_token_bytes, access_token_bytes_nonce,
+			brokerage_plan, fee_model, file_mapping
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
 	_, err = tx.Exec(ctx, sql,
@@ -68,6 +69,9 @@ func (r *userBrokerAccountRepository) Create(ctx context.Context, account *UserB
 		account.OAuthClientSecretBytes,
 		account.AccessTokenBytes,
 		account.AccessTokenBytesNonce,
+		account.BrokeragePlan,
+		account.FeeModel,
+		account.FileMapping,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("insert: %w", err)
@@ -100,7 +104,10 @@ func (r *userBrokerAccountRepository) Update(ctx context.Context, account *UserB
 			oauth_client_secret_nonce = $9,
 			oauth_client_secret_bytes = $10,
 			access_token_bytes = $11,
-			access_token_bytes_nonce = $12
+			access_token_bytes_nonce = $12,
+			brokerage_plan = $13,
+			fee_model = $14,
+			file_mapping = $15
 		WHERE id = $1
 	`
 
@@ -117,6 +124,9 @@ func (r *userBrokerAccountRepository) Update(ctx context.Context, account *UserB
 		account.OAuthClientSecretBytes,
 		account.AccessTokenBytes,
 		account.AccessTokenBytesNonce,
+		account.BrokeragePlan,
+		account.FeeModel,
+		account.FileMapping,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("update: %w", err)
@@ -199,7 +209,7 @@ func (r *userBrokerAccountRepository) findAccounts(ctx context.Context, f filter
 		SELECT id, created_at, updated_at, name, broker_id, user_id, 
 		       oauth_client_id, last_sync_at, last_login_at, 
 		       oauth_client_secret_nonce, oauth_client_secret_bytes, access_token_bytes, 
-			   access_token_bytes_nonce
+			   access_token_bytes_nonce, brokerage_plan, fee_model, file_mapping
 		FROM user_broker_account
 	`
 
@@ -245,6 +255,9 @@ func (r *userBrokerAccountRepository) findAccounts(ctx context.Context, f filter
 			&account.OAuthClientSecretBytes,
 			&account.AccessTokenBytes,
 			&account.AccessTokenBytesNonce,
+			&account.BrokeragePlan,
+			&account.FeeModel,
+			&account.FileMapping,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
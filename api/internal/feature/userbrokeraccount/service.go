@@ -132,6 +132,108 @@ func (s *Service) Update(ctx context.Context, userID, accountID uuid.UUID, paylo
 	return updatedAccount, service.ErrNone, nil
 }
 
+// UpdateBrokeragePlan sets the user's own brokerage plan on the account. The charges of positions
+// synced or imported after are calculated with it, the existing positions are not recalculated.
+func (s *Service) UpdateBrokeragePlan(ctx context.Context, userID, accountID uuid.UUID, payload UpdateBrokeragePlanPayload) (*UserBrokerAccount, service.Error, error) {
+	if payload.BrokeragePlan != nil {
+		if err := payload.BrokeragePlan.Validate(); err != nil {
+			return nil, service.ErrBadRequest, err
+		}
+	}
+
+	account, err := s.userBrokerAccountRepository.GetByID(ctx, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("get account: %w", err)
+	}
+
+	// Verify ownership
+	if account.UserID != userID {
+		return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+	}
+
+	now := time.Now().UTC()
+	account.UpdatedAt = &now
+	account.BrokeragePlan = payload.BrokeragePlan
+
+	updatedAccount, err := s.userBrokerAccountRepository.Update(ctx, account)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("update: %w", err)
+	}
+
+	return updatedAccount, service.ErrNone, nil
+}
+
+// UpdateFeeModel sets the fee model of the exchanges the account trades on. Like the brokerage plan,
+// only the positions synced or imported after are charged with it.
+func (s *Service) UpdateFeeModel(ctx context.Context, userID, accountID uuid.UUID, payload UpdateFeeModelPayload) (*UserBrokerAccount, service.Error, error) {
+	if payload.FeeModel != nil {
+		if err := payload.FeeModel.Validate(); err != nil {
+			return nil, service.ErrBadRequest, err
+		}
+	}
+
+	account, err := s.userBrokerAccountRepository.GetByID(ctx, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("get account: %w", err)
+	}
+
+	// Verify ownership
+	if account.UserID != userID {
+		return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+	}
+
+	now := time.Now().UTC()
+	account.UpdatedAt = &now
+	account.FeeModel = payload.FeeModel
+
+	updatedAccount, err := s.userBrokerAccountRepository.Update(ctx, account)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("update: %w", err)
+	}
+
+	return updatedAccount, service.ErrNone, nil
+}
+
+// UpdateFileMapping sets the user's mapping of the columns of the account's tradebook,
+// which the later file imports to the account are read with.
+func (s *Service) UpdateFileMapping(ctx context.Context, userID, accountID uuid.UUID, payload UpdateFileMappingPayload) (*UserBrokerAccount, service.Error, error) {
+	if payload.FileMapping != nil {
+		if err := payload.FileMapping.Validate(); err != nil {
+			return nil, service.ErrBadRequest, err
+		}
+	}
+
+	account, err := s.userBrokerAccountRepository.GetByID(ctx, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+		}
+		return nil, service.ErrInternalServerError, fmt.Errorf("get account: %w", err)
+	}
+
+	// Verify ownership
+	if account.UserID != userID {
+		return nil, service.ErrNotFound, fmt.Errorf("Broker Account not found")
+	}
+
+	now := time.Now().UTC()
+	account.UpdatedAt = &now
+	account.FileMapping = payload.FileMapping
+
+	updatedAccount, err := s.userBrokerAccountRepository.Update(ctx, account)
+	if err != nil {
+		return nil, service.ErrInternalServerError, fmt.Errorf("update: %w", err)
+	}
+
+	return updatedAccount, service.ErrNone, nil
+}
+
 func (s *Service) Delete(ctx context.Context, userID, accountID uuid.UUID) (service.Error, error) {
 	account, err := s.userBrokerAccountRepository.GetByID(ctx, accountID)
 	if err != nil {
@@ -243,6 +345,14 @@ type SyncResult struct {
 	ImportableTrades []*types.ImportableTrade `json:"-"`
 }
 
+// segmentByKiteExchange maps the exchanges of Kite's trades to the Segments of their contracts.
+// The equity exchanges (NSE, BSE, NFO and BFO) aren't in it, as the empty Segment is the equity segment.
+var segmentByKiteExchange = map[string]types.Segment{
+	"MCX": types.SegmentCommodity,
+	"CDS": types.SegmentCurrency,
+	"BCD": types.SegmentCurrency,
+}
+
 func (s *Service) Sync(ctx context.Context, userID, ubaID uuid.UUID) (*SyncResult, service.Error, error) {
 	l := logger.FromCtx(ctx)
 
@@ -359,6 +469,7 @@ func (s *Service) Sync(ctx context.Context, userID, ubaID uuid.UUID) (*SyncResul
 		importableTrade := types.ImportableTrade{
 			Symbol:     symbol,
 			Instrument: instrument,
+			Segment:    segmentByKiteExchange[t.Exchange],
 			TradeKind:  tradeKind,
 			Quantity:   decimal.NewFromFloat(t.Quantity),
 			Price:      decimal.NewFromFloat(t.AveragePrice),
@@ -3,6 +3,7 @@ package userprofile
 
 import (
 	"arthveda/internal/feature/currency"
+	"arthveda/internal/feature/position"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,17 +16,21 @@ type UserProfile struct {
 	AvatarURL        string                `json:"avatar_url" db:"avatar_url"`
 	HomeCurrencyCode currency.CurrencyCode `json:"home_currency_code" db:"home_currency_code"`
 	Onboarded        bool                  `json:"onboarded" db:"onboarded"`
-	CreatedAt        time.Time             `json:"created_at" db:"created_at"`
-	UpdatedAt        *time.Time            `json:"updated_at" db:"updated_at"`
+
+	// The lot-matching method used for the positions that don't have their own.
+	LotMatchingMethod position.LotMatchingMethod `json:"lot_matching_method" db:"lot_matching_method"`
+	CreatedAt         time.Time                  `json:"created_at" db:"created_at"`
+	UpdatedAt         *time.Time                 `json:"updated_at" db:"updated_at"`
 }
 
 func NewUserProfile(userID uuid.UUID, email, name string) *UserProfile {
 	return &UserProfile{
-		UserID:           userID,
-		Email:            email,
-		Name:             name,
-		HomeCurrencyCode: "INR",
-		Onboarded:        false,
-		CreatedAt:        time.Now().UTC(),
+		UserID:            userID,
+		Email:             email,
+		Name:              name,
+		HomeCurrencyCode:  "INR",
+		Onboarded:         false,
+		LotMatchingMethod: position.LotMatchingMethodFIFO,
+		CreatedAt:         time.Now().UTC(),
 	}
 }
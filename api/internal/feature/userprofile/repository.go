@@ -1,6 +1,7 @@
 package userprofile
 
 import (
+	"arthveda/internal/dbx"
 	"arthveda/internal/repository"
 	"context"
 	"fmt"
@@ -42,7 +43,7 @@ func NewRepository(db *pgxpool.Pool) *userProfileRepository {
 }
 
 func (r *userProfileRepository) FindUserProfileByUserID(ctx context.Context, userID uuid.UUID) (*UserProfile, error) {
-	tx, err := r.db.Begin(ctx)
+	tx, err := dbx.Conn(ctx, r.db).Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("begin: %w", err)
 	}
@@ -78,7 +79,7 @@ func (r *userProfileRepository) findUserProfiles(ctx context.Context, tx pgx.Tx,
 
 	sql := `
 	SELECT user_id, email, name, avatar_url, created_at, updated_at,
-	home_currency_code, onboarded
+	home_currency_code, onboarded, lot_matching_method
 	FROM user_profile ` + repository.WhereSQL(where)
 
 	rows, err := tx.Query(ctx, sql, args)
@@ -92,7 +93,7 @@ func (r *userProfileRepository) findUserProfiles(ctx context.Context, tx pgx.Tx,
 	for rows.Next() {
 		var up UserProfile
 
-		err := rows.Scan(&up.UserID, &up.Email, &up.Name, &up.AvatarURL, &up.CreatedAt, &up.UpdatedAt, &up.HomeCurrencyCode, &up.Onboarded)
+		err := rows.Scan(&up.UserID, &up.Email, &up.Name, &up.AvatarURL, &up.CreatedAt, &up.UpdatedAt, &up.HomeCurrencyCode, &up.Onboarded, &up.LotMatchingMethod)
 		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
@@ -113,11 +114,11 @@ func (r *userProfileRepository) Update(ctx context.Context, userProfile *UserPro
 	sql := `
 		UPDATE user_profile
 		SET email = $1, name = $2, avatar_url = $3, updated_at = $4,
-		home_currency_code = $5, onboarded = $6
-		WHERE user_id = $7
+		home_currency_code = $5, onboarded = $6, lot_matching_method = $7
+		WHERE user_id = $8
 	`
 
-	_, err := r.db.Exec(ctx, sql, userProfile.Email, userProfile.Name, userProfile.AvatarURL, updatedAt, userProfile.HomeCurrencyCode, userProfile.Onboarded, userProfile.UserID)
+	_, err := dbx.Conn(ctx, r.db).Exec(ctx, sql, userProfile.Email, userProfile.Name, userProfile.AvatarURL, updatedAt, userProfile.HomeCurrencyCode, userProfile.Onboarded, userProfile.LotMatchingMethod, userProfile.UserID)
 	if err != nil {
 		return fmt.Errorf("update: %w", err)
 	}
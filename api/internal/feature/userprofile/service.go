@@ -1,6 +1,7 @@
 package userprofile
 
 import (
+	"arthveda/internal/apires"
 	"arthveda/internal/domain/subscription"
 	"arthveda/internal/feature/currency"
 	"arthveda/internal/feature/position"
@@ -162,3 +163,53 @@ func (s *Service) UpdateHomeCurrency(ctx context.Context, userID uuid.UUID, payl
 
 	return service.ErrNone, nil
 }
+
+// GetLotMatchingMethod returns the user's default lot-matching method, FIFO if the user has no profile yet.
+func (s *Service) GetLotMatchingMethod(ctx context.Context, userID uuid.UUID) (position.LotMatchingMethod, error) {
+	userProfile, err := s.userProfileRepository.FindUserProfileByUserID(ctx, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return position.LotMatchingMethodFIFO, nil
+		}
+
+		return "", fmt.Errorf("find user profile by user id: %w", err)
+	}
+
+	return userProfile.LotMatchingMethod.OrDefault(), nil
+}
+
+type UpdateLotMatchingMethodPayload struct {
+	LotMatchingMethod position.LotMatchingMethod `json:"lot_matching_method"`
+}
+
+// UpdateLotMatchingMethod updates the user's default lot-matching method.
+// It returns whether the method has changed so that the caller can recompute the positions.
+func (s *Service) UpdateLotMatchingMethod(ctx context.Context, userID uuid.UUID, payload UpdateLotMatchingMethodPayload) (bool, service.Error, error) {
+	if !payload.LotMatchingMethod.IsValid() {
+		return false, service.ErrInvalidInput, service.NewInputValidationErrorsWithError(
+			apires.NewApiError("Invalid lot matching method", "Lot matching method must be one of fifo, lifo or weighted_average", "lot_matching_method", payload.LotMatchingMethod),
+		)
+	}
+
+	userProfile, err := s.userProfileRepository.FindUserProfileByUserID(ctx, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return false, service.ErrNotFound, err
+		}
+
+		return false, service.ErrInternalServerError, fmt.Errorf("find user profile by user id: %w", err)
+	}
+
+	if userProfile.LotMatchingMethod == payload.LotMatchingMethod {
+		return false, service.ErrNone, nil
+	}
+
+	userProfile.LotMatchingMethod = payload.LotMatchingMethod
+
+	err = s.userProfileRepository.Update(ctx, userProfile)
+	if err != nil {
+		return false, service.ErrInternalServerError, fmt.Errorf("failed to update user profile: %w", err)
+	}
+
+	return true, service.ErrNone, nil
+}
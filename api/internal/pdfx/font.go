@@ -0,0 +1,139 @@
+package pdfx
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// font is what the text of a font's strings needs: the width of its character codes and their Unicode text.
+type font struct {
+	// The character codes of a Type0 font, like the Identity-H encoded ones, are two bytes.
+	twoByte bool
+	// `nil` if the font has no ToUnicode map. Its strings are read as Latin-1 then.
+	toUnicode map[uint32]string
+}
+
+// decode returns the text of a string shown with the font.
+func (f *font) decode(s []byte) string {
+	if f == nil || f.toUnicode == nil {
+		if f != nil && f.twoByte {
+			return decodeUTF16(s)
+		}
+
+		runes := make([]rune, len(s))
+		for i, b := range s {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+
+	width := 1
+	if f.twoByte {
+		width = 2
+	}
+
+	var b strings.Builder
+	for i := 0; i+width <= len(s); i += width {
+		code := uint32(s[i])
+		if width == 2 {
+			code = code<<8 | uint32(s[i+1])
+		}
+		b.WriteString(f.toUnicode[code])
+	}
+	return b.String()
+}
+
+var (
+	bfcharRe  = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	bfrangeRe = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	hexRe     = regexp.MustCompile(`<([0-9A-Fa-f\s]*)>`)
+	rangeRe   = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>\s*(<[0-9A-Fa-f\s]*>|\[[^\]]*\])`)
+)
+
+// parseToUnicode parses the character codes and their text from a ToUnicode CMap.
+func parseToUnicode(cmap []byte) map[uint32]string {
+	result := map[uint32]string{}
+	s := string(cmap)
+
+	for _, section := range bfcharRe.FindAllStringSubmatch(s, -1) {
+		pairs := hexRe.FindAllStringSubmatch(section[1], -1)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			code, ok := hexCode(pairs[i][1])
+			if !ok {
+				continue
+			}
+			result[code] = decodeUTF16(hexBytes(pairs[i+1][1]))
+		}
+	}
+
+	for _, section := range bfrangeRe.FindAllStringSubmatch(s, -1) {
+		for _, m := range rangeRe.FindAllStringSubmatch(section[1], -1) {
+			lo, ok1 := hexCode(m[1])
+			hi, ok2 := hexCode(m[2])
+			if !ok1 || !ok2 || hi < lo || hi-lo > 0xFFFF {
+				continue
+			}
+
+			// An array has the text of every code in the range.
+			if strings.HasPrefix(m[3], "[") {
+				for i, dst := range hexRe.FindAllStringSubmatch(m[3], -1) {
+					if lo+uint32(i) > hi {
+						break
+					}
+					result[lo+uint32(i)] = decodeUTF16(hexBytes(dst[1]))
+				}
+				continue
+			}
+
+			// Otherwise the text of the codes counts up from the text of the first one.
+			dst := []rune(decodeUTF16(hexBytes(strings.Trim(m[3], "<>"))))
+			if len(dst) == 0 {
+				continue
+			}
+			for code := lo; code <= hi; code++ {
+				text := append([]rune{}, dst...)
+				text[len(text)-1] += rune(code - lo)
+				result[code] = string(text)
+			}
+		}
+	}
+
+	return result
+}
+
+func hexCode(s string) (uint32, bool) {
+	b := hexBytes(s)
+	if len(b) == 0 || len(b) > 4 {
+		return 0, false
+	}
+
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code, true
+}
+
+// hexBytes decodes the digits of a hex string, ignoring the whitespace in it. A missing last digit is zero.
+func hexBytes(s string) []byte {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s)%2 == 1 {
+		s += "0"
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
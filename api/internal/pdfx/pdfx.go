@@ -0,0 +1,397 @@
+// Package pdfx reads the text of a PDF file, like a contract note, as rows of cells.
+//
+// It only reads what the text of a generated PDF needs: the objects, including the ones in object
+// streams, Flate compressed streams, the page tree, the ToUnicode maps of the fonts and the text
+// operators of the content streams. Scanned PDFs have no text, and encrypted ones aren't supported.
+package pdfx
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalid   = errors.New("File is not a valid PDF")
+	ErrEncrypted = errors.New("PDF is password protected. Remove the password and upload it again.")
+	ErrNoText    = errors.New("PDF has no text that could be read. Scanned PDFs aren't supported.")
+	ErrTooLarge  = errors.New("PDF is too large to be read")
+)
+
+// maxStreamSize is the most bytes a stream is decoded to. A statement's pages are a few KB each,
+// so a stream that decodes to more is an image, or a file made to exhaust the memory.
+const maxStreamSize = 16 << 20
+
+// ReadRows returns the lines of text of the pages, top to bottom, as rows. The text of a line is split
+// into cells where there is a gap wider than a character between two pieces of text, like between the
+// columns of a table.
+func ReadRows(data []byte) ([][]string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF")) {
+		return nil, ErrInvalid
+	}
+
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, ErrEncrypted
+	}
+
+	d, err := newDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, page := range d.pages() {
+		rows = append(rows, d.pageRows(page)...)
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrNoText
+	}
+
+	return rows, nil
+}
+
+// object is an indirect object of a PDF. Its dictionary is kept as text, and its stream is decoded.
+type object struct {
+	dict   string
+	stream []byte
+}
+
+type document struct {
+	objects map[int]*object
+	fonts   map[int]*font
+}
+
+var (
+	objectHeaderRe = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	streamRe       = regexp.MustCompile(`stream\r?\n`)
+	refRe          = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	inlineFontsRe  = regexp.MustCompile(`(?s)/Font\s*<<(.*?)>>`)
+	namedRefRe     = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s*(\d+)\s+\d+\s+R`)
+)
+
+func newDocument(data []byte) (*document, error) {
+	d := &document{objects: map[int]*object{}, fonts: map[int]*font{}}
+
+	for pos := 0; pos < len(data); {
+		loc := objectHeaderRe.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+
+		end := bytes.Index(data[start:], []byte("endobj"))
+		if end == -1 {
+			break
+		}
+		end += start
+
+		obj := &object{}
+
+		// The stream's bytes can have "endobj" in them, so the stream is cut out first.
+		if s := streamRe.FindIndex(data[start:end]); s != nil {
+			obj.dict = string(data[start : start+s[0]])
+			streamStart := start + s[1]
+
+			streamEnd := bytes.Index(data[streamStart:], []byte("endstream"))
+			if streamEnd == -1 {
+				break
+			}
+			streamEnd += streamStart
+
+			raw := bytes.TrimRight(data[streamStart:streamEnd], "\r\n")
+			if length, ok := d.directInt(obj.dict, "Length"); ok && length >= 0 && streamStart+length <= streamEnd {
+				raw = data[streamStart : streamStart+length]
+			}
+
+			var err error
+			obj.stream, err = decodeStream(obj.dict, raw)
+			if err != nil {
+				return nil, err
+			}
+
+			end = bytes.Index(data[streamEnd:], []byte("endobj"))
+			if end == -1 {
+				break
+			}
+			end += streamEnd
+		} else {
+			obj.dict = string(data[start:end])
+		}
+
+		d.objects[num] = obj
+		pos = end + len("endobj")
+	}
+
+	// The objects in object streams, which modern PDFs keep their pages and fonts in.
+	for _, obj := range d.objects {
+		if hasName(obj.dict, "Type", "ObjStm") && obj.stream != nil {
+			d.readObjectStream(obj)
+		}
+	}
+
+	return d, nil
+}
+
+func (d *document) readObjectStream(obj *object) {
+	n, ok := d.directInt(obj.dict, "N")
+	if !ok {
+		return
+	}
+
+	first, ok := d.directInt(obj.dict, "First")
+	if !ok || first < 0 || first > len(obj.stream) {
+		return
+	}
+
+	fields := strings.Fields(string(obj.stream[:first]))
+	if len(fields) < 2*n {
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		num, err1 := strconv.Atoi(fields[2*i])
+		offset, err2 := strconv.Atoi(fields[2*i+1])
+		if err1 != nil || err2 != nil || offset < 0 {
+			return
+		}
+
+		end := len(obj.stream)
+		if i+1 < n {
+			if next, err := strconv.Atoi(fields[2*i+3]); err == nil && next >= 0 {
+				end = first + next
+			}
+		}
+
+		// The offsets are relative to the first object, and are read from the file, so they're checked
+		// to be in the stream.
+		start := first + offset
+		if start > end || end > len(obj.stream) {
+			return
+		}
+
+		// An object stored directly in the file supersedes the compressed one.
+		if _, ok := d.objects[num]; !ok {
+			d.objects[num] = &object{dict: string(obj.stream[start:end])}
+		}
+	}
+}
+
+// decodeStream returns the decoded bytes of a stream, or nil if it's encoded with a filter
+// other than FlateDecode, like the images. It fails with ErrTooLarge if the stream decodes
+// to more than maxStreamSize bytes.
+func decodeStream(dict string, raw []byte) ([]byte, error) {
+	if !strings.Contains(dict, "/Filter") {
+		return raw, nil
+	}
+
+	if !strings.Contains(dict, "/FlateDecode") || strings.Contains(dict, "/DecodeParms") {
+		return nil, nil
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	defer r.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(r, maxStreamSize+1))
+	// A stream with a broken checksum still has its data.
+	if err != nil && !errors.Is(err, zlib.ErrChecksum) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	if len(decoded) > maxStreamSize {
+		return nil, ErrTooLarge
+	}
+
+	return decoded, nil
+}
+
+var regexps sync.Map
+
+// cachedRegexp compiles the pattern once, as the same keys are looked up in every object.
+func cachedRegexp(pattern string) *regexp.Regexp {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re := regexp.MustCompile(pattern)
+	regexps.Store(pattern, re)
+	return re
+}
+
+// hasName returns whether the dictionary has the key with the name as its value, like /Type /Page.
+func hasName(dict, key, name string) bool {
+	return cachedRegexp(`/` + key + `\s*/` + name + `\b`).MatchString(dict)
+}
+
+// ref returns the object number of the key's indirect reference in the dictionary.
+func ref(dict, key string) (int, bool) {
+	m := cachedRegexp(`/` + key + `\s*(\d+)\s+\d+\s+R`).FindStringSubmatch(dict)
+	if m == nil {
+		return 0, false
+	}
+
+	num, err := strconv.Atoi(m[1])
+	return num, err == nil
+}
+
+// refs returns the object numbers of the key's array of indirect references, or of its one reference.
+func refs(dict, key string) []int {
+	m := cachedRegexp(`/` + key + `\s*\[([^\]]*)\]`).FindStringSubmatch(dict)
+	if m == nil {
+		if num, ok := ref(dict, key); ok {
+			return []int{num}
+		}
+		return nil
+	}
+
+	nums := []int{}
+	for _, r := range refRe.FindAllStringSubmatch(m[1], -1) {
+		if num, err := strconv.Atoi(r[1]); err == nil {
+			nums = append(nums, num)
+		}
+	}
+	return nums
+}
+
+// directInt returns the key's integer in the dictionary, following an indirect reference to it.
+func (d *document) directInt(dict, key string) (int, bool) {
+	if num, ok := ref(dict, key); ok {
+		if obj, ok := d.objects[num]; ok {
+			dict = "/" + key + " " + obj.dict
+		}
+	}
+
+	m := cachedRegexp(`/` + key + `\s+(\d+)\b(\s+\d+\s+R)?`).FindStringSubmatch(dict)
+	if m == nil || m[2] != "" {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// pages returns the page objects in the order of the page tree, or in the order of their
+// object numbers if the PDF has no catalog.
+func (d *document) pages() []*object {
+	for _, obj := range d.objects {
+		if !hasName(obj.dict, "Type", "Catalog") {
+			continue
+		}
+
+		if root, ok := ref(obj.dict, "Pages"); ok {
+			pages := []*object{}
+			d.walkPages(root, &pages, map[int]bool{})
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+
+	nums := []int{}
+	for num, obj := range d.objects {
+		if hasName(obj.dict, "Type", "Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	pages := make([]*object, len(nums))
+	for i, num := range nums {
+		pages[i] = d.objects[num]
+	}
+	return pages
+}
+
+func (d *document) walkPages(num int, pages *[]*object, seen map[int]bool) {
+	obj, ok := d.objects[num]
+	if !ok || seen[num] {
+		return
+	}
+	seen[num] = true
+
+	if hasName(obj.dict, "Type", "Page") {
+		*pages = append(*pages, obj)
+		return
+	}
+
+	for _, kid := range refs(obj.dict, "Kids") {
+		d.walkPages(kid, pages, seen)
+	}
+}
+
+// pageFonts returns the fonts of the page's resources by their names, inherited from
+// the page tree if the page has no resources of its own.
+func (d *document) pageFonts(page *object) map[string]*font {
+	dict := page.dict
+	for i := 0; i < 32 && !strings.Contains(dict, "/Resources"); i++ {
+		parent, ok := ref(dict, "Parent")
+		if !ok || d.objects[parent] == nil {
+			break
+		}
+		dict = d.objects[parent].dict
+	}
+
+	if num, ok := ref(dict, "Resources"); ok && d.objects[num] != nil {
+		dict = d.objects[num].dict
+	}
+
+	var fontDict string
+	if num, ok := ref(dict, "Font"); ok && d.objects[num] != nil {
+		fontDict = d.objects[num].dict
+	} else if m := inlineFontsRe.FindStringSubmatch(dict); m != nil {
+		fontDict = m[1]
+	}
+
+	fonts := map[string]*font{}
+	for _, m := range namedRefRe.FindAllStringSubmatch(fontDict, -1) {
+		num, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		fonts[m[1]] = d.font(num)
+	}
+	return fonts
+}
+
+func (d *document) font(num int) *font {
+	if f, ok := d.fonts[num]; ok {
+		return f
+	}
+
+	f := &font{}
+	if obj, ok := d.objects[num]; ok {
+		f.twoByte = hasName(obj.dict, "Subtype", "Type0")
+		if cmapNum, ok := ref(obj.dict, "ToUnicode"); ok && d.objects[cmapNum] != nil {
+			f.toUnicode = parseToUnicode(d.objects[cmapNum].stream)
+		}
+	}
+
+	d.fonts[num] = f
+	return f
+}
+
+func (d *document) pageRows(page *object) [][]string {
+	fonts := d.pageFonts(page)
+
+	var content []byte
+	for _, num := range refs(page.dict, "Contents") {
+		if obj, ok := d.objects[num]; ok {
+			content = append(content, obj.stream...)
+			content = append(content, '\n')
+		}
+	}
+
+	return toRows(readText(content, fonts))
+}
@@ -0,0 +1,164 @@
+package pdfx
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPDF returns a PDF with the objects, numbered from 1, and a cross-reference table like a generated one.
+func buildPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return b.Bytes()
+}
+
+func stream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data string) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(data))
+	w.Close()
+	return b.Bytes()
+}
+
+func TestReadRows(t *testing.T) {
+	content := `BT
+/F1 10 Tf
+1 0 0 1 50 700 Tm
+(Segment) Tj
+1 0 0 1 150 700 Tm
+(Brokerage) Tj
+1 0 0 1 250 700 Tm
+[(Stamp) -250 (Duty)] TJ
+-200 -20 Td
+(NSE EQ) Tj
+1 0 0 1 150 680 Tm
+(20.00) Tj
+1 0 0 1 250 680 Tm
+(15.00) Tj
+ET
+q 1 0 0 1 0 -40 cm
+BT /F1 10 Tf 50 700 Td (\(Total\)) Tj 100 0 Td (20.00) Tj ET
+Q`
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		stream("", []byte(content)),
+	)
+
+	rows, err := ReadRows(data)
+	if err != nil {
+		t.Fatalf("ReadRows: %s", err)
+	}
+
+	want := [][]string{
+		{"Segment", "Brokerage", "Stamp Duty"},
+		{"NSE EQ", "20.00", "15.00"},
+		{"(Total)", "20.00"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestReadRows_CompressedWithToUnicode(t *testing.T) {
+	// The glyph IDs 1, 2 and 3 of an Identity-H font are "G", "S" and "T".
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfchar
+<0001> <0047>
+endbfchar
+1 beginbfrange
+<0002> <0003> <0053>
+endbfrange
+endcmap`
+
+	content := `BT /F2 12 Tf 72 720 Td <0002> Tj <00030001> Tj 0 -24 Td <0001> Tj ET`
+
+	// The page and the font are in an object stream, like in the PDFs of most generators.
+	objStm := "<< /Type /Page /Parent 2 0 R /Resources 4 0 R /Contents 5 0 R >>\n<< /Font << /F2 6 0 R >> >>\n"
+	header := fmt.Sprintf("3 0 4 %d ", len("<< /Type /Page /Parent 2 0 R /Resources 4 0 R /Contents 5 0 R >>\n"))
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		stream(fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(header)), deflate(header+objStm)),
+		"null",
+		stream("/Filter /FlateDecode", deflate(content)),
+		"<< /Type /Font /Subtype /Type0 /Encoding /Identity-H /ToUnicode 7 0 R >>",
+		stream("", []byte(cmap)),
+	)
+	// Objects 3 and 4 are only in the object stream.
+	data = bytes.Replace(data, []byte("3 0 obj\n<< /Type /ObjStm"), []byte("8 0 obj\n<< /Type /ObjStm"), 1)
+	data = bytes.Replace(data, []byte("4 0 obj\nnull"), []byte("9 0 obj\nnull"), 1)
+
+	rows, err := ReadRows(data)
+	if err != nil {
+		t.Fatalf("ReadRows: %s", err)
+	}
+
+	want := [][]string{{"STG"}, {"G"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestReadRows_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not a PDF", []byte("Segment,Brokerage\nNSE EQ,20"), ErrInvalid},
+		{"encrypted", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "<< /Filter /Standard /V 2 >>", "<< /Encrypt 2 0 R >>"), ErrEncrypted},
+		{
+			"scanned",
+			buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				stream("", []byte("q 595 0 0 842 0 0 cm /Im1 Do Q")),
+			),
+			ErrNoText,
+		},
+		{"corrupt stream", buildPDF(stream("/Filter /FlateDecode", []byte("not deflated"))), ErrInvalid},
+		{"stream decoding to too much", buildPDF(stream("/Filter /FlateDecode", deflate(strings.Repeat(" ", maxStreamSize+1)))), ErrTooLarge},
+		{"object stream with a negative offset", buildPDF(stream("/Type /ObjStm /N 1 /First 6", []byte("3 -50 << /Type /Catalog >>"))), ErrNoText},
+		{"object stream with a negative first", buildPDF(stream("/Type /ObjStm /N 1 /First -1", []byte("3 0 << /Type /Catalog >>"))), ErrNoText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadRows(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package pdfx
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// piece is a run of text shown at a point of the page.
+type piece struct {
+	x, y float64
+	size float64
+	text string
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenString
+	tokenName
+	tokenArray
+	tokenOperator
+	tokenOther
+)
+
+type token struct {
+	kind tokenKind
+	num  float64
+	str  []byte
+	// The strings and numbers of an array, like the operand of TJ.
+	array []token
+}
+
+// lexer reads the tokens of a content stream.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) != -1
+}
+
+func (l *lexer) next() (token, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) {
+			l.pos++
+			continue
+		}
+
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	if l.pos >= len(l.data) {
+		return token{}, false
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return token{kind: tokenString, str: l.literalString()}, true
+
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		// An inline dictionary, like the properties of marked content, has no text.
+		l.pos += 2
+		depth := 1
+		for l.pos < len(l.data) && depth > 0 {
+			if bytes.HasPrefix(l.data[l.pos:], []byte("<<")) {
+				depth++
+				l.pos += 2
+			} else if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				depth--
+				l.pos += 2
+			} else if l.data[l.pos] == '(' {
+				l.literalString()
+			} else {
+				l.pos++
+			}
+		}
+		return token{kind: tokenOther}, true
+
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end == -1 {
+			end = len(l.data) - l.pos
+		}
+		s := hexBytes(string(l.data[l.pos+1 : l.pos+end]))
+		l.pos += end + 1
+		return token{kind: tokenString, str: s}, true
+
+	case c == '[':
+		l.pos++
+		t := token{kind: tokenArray}
+		for {
+			for l.pos < len(l.data) && isWhitespace(l.data[l.pos]) {
+				l.pos++
+			}
+			if l.pos >= len(l.data) {
+				break
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				break
+			}
+
+			item, ok := l.next()
+			if !ok {
+				break
+			}
+			t.array = append(t.array, item)
+		}
+		return t, true
+
+	case c == '/':
+		start := l.pos + 1
+		l.pos++
+		for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenName, str: l.data[start:l.pos]}, true
+
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return token{kind: tokenOther}, true
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := l.data[start:l.pos]
+
+	if n, err := strconv.ParseFloat(string(word), 64); err == nil {
+		return token{kind: tokenNumber, num: n}, true
+	}
+
+	// The binary data of an inline image, between ID and EI, isn't made of tokens.
+	if string(word) == "ID" {
+		end := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if end == -1 {
+			l.pos = len(l.data)
+		} else {
+			l.pos += end + len("EI")
+		}
+		return token{kind: tokenOther}, true
+	}
+
+	return token{kind: tokenOperator, str: word}, true
+}
+
+// literalString reads a string in parentheses, which can have balanced parentheses and escapes in it.
+func (l *lexer) literalString() []byte {
+	l.pos++
+	depth := 1
+	var s []byte
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r':
+				// A line continuation.
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+			continue
+		}
+
+		s = append(s, c)
+	}
+
+	return s
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translation(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
+
+// readText returns the pieces of text the content stream shows, with their positions on the page.
+func readText(content []byte, fonts map[string]*font) []piece {
+	pieces := []piece{}
+
+	var (
+		ctm        = identity
+		stack      []matrix
+		tm         = identity
+		lineMatrix = identity
+		leading    float64
+		fontSize   float64 = 1
+		current    *font
+		operands   []token
+		// Whether the text is shown right after the text before it, without moving to a new position.
+		continues bool
+	)
+
+	number := func(i int) float64 {
+		if i < len(operands) && operands[i].kind == tokenNumber {
+			return operands[i].num
+		}
+		return 0
+	}
+
+	moveTo := func(m matrix) {
+		lineMatrix = m
+		tm = m
+		continues = false
+	}
+
+	nextLine := func() {
+		moveTo(translation(0, -leading).multiply(lineMatrix))
+	}
+
+	show := func(text string) {
+		if text == "" {
+			return
+		}
+
+		if continues && len(pieces) > 0 {
+			pieces[len(pieces)-1].text += text
+		} else {
+			m := tm.multiply(ctm)
+			size := fontSize * math.Hypot(m[2], m[3])
+			if size == 0 {
+				size = 1
+			}
+			pieces = append(pieces, piece{x: m[4], y: m[5], size: size, text: text})
+		}
+		continues = true
+	}
+
+	l := &lexer{data: content}
+	for {
+		t, ok := l.next()
+		if !ok {
+			break
+		}
+
+		if t.kind != tokenOperator {
+			operands = append(operands, t)
+			continue
+		}
+
+		switch string(t.str) {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if len(operands) >= 6 {
+				ctm = matrix{number(0), number(1), number(2), number(3), number(4), number(5)}.multiply(ctm)
+			}
+		case "BT":
+			moveTo(identity)
+		case "Tf":
+			if len(operands) >= 2 {
+				if operands[0].kind == tokenName {
+					current = fonts[string(operands[0].str)]
+				}
+				fontSize = number(1)
+			}
+		case "TL":
+			leading = number(0)
+		case "Tm":
+			if len(operands) >= 6 {
+				moveTo(matrix{number(0), number(1), number(2), number(3), number(4), number(5)})
+			}
+		case "Td":
+			moveTo(translation(number(0), number(1)).multiply(lineMatrix))
+		case "TD":
+			leading = -number(1)
+			moveTo(translation(number(0), number(1)).multiply(lineMatrix))
+		case "T*":
+			nextLine()
+		case "Tj":
+			if len(operands) > 0 && operands[len(operands)-1].kind == tokenString {
+				show(current.decode(operands[len(operands)-1].str))
+			}
+		case "'", "\"":
+			nextLine()
+			if len(operands) > 0 && operands[len(operands)-1].kind == tokenString {
+				show(current.decode(operands[len(operands)-1].str))
+			}
+		case "TJ":
+			if len(operands) > 0 && operands[len(operands)-1].kind == tokenArray {
+				var b strings.Builder
+				for _, item := range operands[len(operands)-1].array {
+					switch item.kind {
+					case tokenString:
+						b.WriteString(current.decode(item.str))
+					case tokenNumber:
+						// A wide enough adjustment, in thousandths of the font size, is a space between words.
+						if item.num < -200 {
+							b.WriteByte(' ')
+						}
+					}
+				}
+				show(b.String())
+			}
+		}
+
+		operands = operands[:0]
+	}
+
+	return pieces
+}
+
+// toRows groups the pieces into lines by their height on the page, top to bottom, and splits
+// each line into cells where the gap between two pieces is wider than a character.
+func toRows(pieces []piece) [][]string {
+	sort.SliceStable(pieces, func(i, j int) bool {
+		return pieces[i].y > pieces[j].y
+	})
+
+	lines := [][]piece{}
+	for _, p := range pieces {
+		if strings.TrimSpace(p.text) == "" {
+			continue
+		}
+
+		n := len(lines)
+		if n > 0 && math.Abs(lines[n-1][0].y-p.y) <= 0.5*math.Max(lines[n-1][0].size, p.size) {
+			lines[n-1] = append(lines[n-1], p)
+		} else {
+			lines = append(lines, []piece{p})
+		}
+	}
+
+	rows := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool {
+			return line[i].x < line[j].x
+		})
+
+		row := []string{}
+		var cell strings.Builder
+		var end float64
+
+		for i, p := range line {
+			// The width of the text is estimated, as the widths of the glyphs of the fonts aren't read.
+			if i > 0 && p.x-end > p.size {
+				row = append(row, strings.Join(strings.Fields(cell.String()), " "))
+				cell.Reset()
+			} else if i > 0 && p.x-end > 0.2*p.size {
+				cell.WriteByte(' ')
+			}
+
+			cell.WriteString(p.text)
+			end = math.Max(end, p.x+0.5*p.size*float64(utf8.RuneCountInString(p.text)))
+		}
+		row = append(row, strings.Join(strings.Fields(cell.String()), " "))
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
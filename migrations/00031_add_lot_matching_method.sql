@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TYPE LOT_MATCHING_METHOD AS ENUM('fifo', 'lifo', 'weighted_average');
+
+ALTER TABLE user_profile
+ADD COLUMN lot_matching_method LOT_MATCHING_METHOD NOT NULL DEFAULT 'fifo';
+
+-- NULL means the position uses the user's default lot matching method.
+ALTER TABLE position
+ADD COLUMN lot_matching_method LOT_MATCHING_METHOD;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE position DROP COLUMN lot_matching_method;
+ALTER TABLE user_profile DROP COLUMN lot_matching_method;
+
+DROP TYPE LOT_MATCHING_METHOD;
+
+-- +goose StatementEnd
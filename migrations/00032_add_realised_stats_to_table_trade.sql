@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Realised stats of a scale-out trade. These are 0 and an empty array for a scale-in trade.
+ALTER TABLE trade
+ADD COLUMN realised_gross_pnl NUMERIC(20, 8) NOT NULL DEFAULT 0,
+ADD COLUMN realised_net_pnl NUMERIC(20, 8) NOT NULL DEFAULT 0,
+ADD COLUMN gross_roi NUMERIC(20, 8) NOT NULL DEFAULT 0,
+ADD COLUMN gross_r_factor NUMERIC(20, 8) NOT NULL DEFAULT 0,
+ADD COLUMN net_r_factor NUMERIC(20, 8) NOT NULL DEFAULT 0,
+ADD COLUMN matched_lots JSONB NOT NULL DEFAULT '[]';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE trade
+DROP COLUMN realised_gross_pnl,
+DROP COLUMN realised_net_pnl,
+DROP COLUMN gross_roi,
+DROP COLUMN gross_r_factor,
+DROP COLUMN net_r_factor,
+DROP COLUMN matched_lots;
+
+-- +goose StatementEnd
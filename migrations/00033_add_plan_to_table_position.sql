@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE position
+ADD COLUMN planned_entry_price NUMERIC(20, 8),
+ADD COLUMN stop_loss_price NUMERIC(20, 8),
+ADD COLUMN target_price NUMERIC(20, 8),
+ADD COLUMN planned_r_factor NUMERIC(20, 8),
+ADD COLUMN exit_vs_plan_r_factor NUMERIC(20, 8);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE position
+DROP COLUMN planned_entry_price,
+DROP COLUMN stop_loss_price,
+DROP COLUMN target_price,
+DROP COLUMN planned_r_factor,
+DROP COLUMN exit_vs_plan_r_factor;
+
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE position
+ADD COLUMN mae_amount NUMERIC(14, 2),
+ADD COLUMN mfe_amount NUMERIC(14, 2),
+ADD COLUMN exit_efficiency_percentage NUMERIC(10, 2);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE position
+DROP COLUMN mae_amount,
+DROP COLUMN mfe_amount,
+DROP COLUMN exit_efficiency_percentage;
+
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE strategy (
+    id                  UUID PRIMARY KEY,
+    created_by          UUID NOT NULL REFERENCES user_profile(user_id) ON DELETE CASCADE,
+    name                VARCHAR(256) NOT NULL,
+    kind                VARCHAR(32) NOT NULL,
+    underlying          VARCHAR(64) NOT NULL DEFAULT '',
+    expiry              DATE,
+    risk_amount         NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    is_auto_detected    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ
+);
+
+CREATE INDEX idx_strategy_created_by ON strategy(created_by);
+
+ALTER TABLE position
+ADD COLUMN strategy_id UUID REFERENCES strategy(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_position_strategy_id ON position(strategy_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE position DROP COLUMN IF EXISTS strategy_id;
+DROP TABLE IF EXISTS strategy;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE position
+ADD COLUMN underlying VARCHAR(64),
+ADD COLUMN expiry DATE,
+ADD COLUMN strike NUMERIC(20, 8),
+ADD COLUMN option_right VARCHAR(8);
+
+CREATE INDEX idx_position_underlying ON position(underlying);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE position
+DROP COLUMN underlying,
+DROP COLUMN expiry,
+DROP COLUMN strike,
+DROP COLUMN option_right;
+
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE corporate_action (
+    id              UUID PRIMARY KEY,
+    symbol          VARCHAR(64) NOT NULL,
+    kind            VARCHAR(16) NOT NULL,
+    ex_date         DATE NOT NULL,
+    new_shares      NUMERIC(14, 4) NOT NULL,
+    held_shares     NUMERIC(14, 4) NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ,
+
+    UNIQUE (symbol, kind, ex_date)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS corporate_action;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE cash_flow (
+    id                      UUID PRIMARY KEY,
+    created_by              UUID NOT NULL REFERENCES user_profile(user_id) ON DELETE CASCADE,
+    position_id             UUID REFERENCES position(id) ON DELETE CASCADE,
+    user_broker_account_id  UUID REFERENCES user_broker_account(id) ON DELETE CASCADE,
+    kind                    VARCHAR(16) NOT NULL,
+    time                    TIMESTAMPTZ NOT NULL,
+    amount                  NUMERIC(20, 8) NOT NULL,
+    note                    TEXT NOT NULL DEFAULT '',
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at              TIMESTAMPTZ,
+
+    CHECK (position_id IS NOT NULL OR user_broker_account_id IS NOT NULL)
+);
+
+CREATE INDEX idx_cash_flow_created_by_time ON cash_flow(created_by, time);
+CREATE INDEX idx_cash_flow_position_id ON cash_flow(position_id);
+
+ALTER TABLE position
+ADD COLUMN income_amount NUMERIC(20, 8) NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE position DROP COLUMN IF EXISTS income_amount;
+DROP TABLE IF EXISTS cash_flow;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE charge_schedule (
+    id              UUID PRIMARY KEY,
+    effective_from  DATE NOT NULL,
+    version         INT NOT NULL,
+    note            TEXT NOT NULL DEFAULT '',
+    rates           JSONB NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    UNIQUE (effective_from, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS charge_schedule;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE position
+ADD COLUMN segment VARCHAR(16) NOT NULL DEFAULT 'equity';
+
+-- The positions don't record the exchange they were traded on, so the futures and options are
+-- put in the commodity (MCX) and currency (NSE-CDS) segments by the underlying their symbol starts with.
+UPDATE position
+SET segment = 'commodity'
+WHERE instrument IN ('future', 'option')
+    AND symbol ~ '^(GOLD|GOLDM|GOLDGUINEA|GOLDPETAL|GOLDTEN|SILVER|SILVERM|SILVERMIC|CRUDEOIL|CRUDEOILM|NATURALGAS|NATGASMINI|COPPER|ZINC|ZINCMINI|ALUMINIUM|ALUMINI|LEAD|LEADMINI|NICKEL|COTTON|COTTONCNDY|MENTHAOIL|KAPAS)[0-9]';
+
+UPDATE position
+SET segment = 'currency'
+WHERE instrument IN ('future', 'option')
+    AND symbol ~ '^(USDINR|EURINR|GBPINR|JPYINR|EURUSD|GBPUSD|USDJPY)[0-9]';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE position DROP COLUMN IF EXISTS segment;
+-- +goose StatementEnd
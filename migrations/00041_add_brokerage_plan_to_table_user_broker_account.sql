@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_broker_account
+ADD COLUMN brokerage_plan JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_broker_account DROP COLUMN IF EXISTS brokerage_plan;
+-- +goose StatementEnd
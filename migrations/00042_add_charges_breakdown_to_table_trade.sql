@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE trade
+ADD COLUMN charges_breakdown JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE trade DROP COLUMN IF EXISTS charges_breakdown;
+-- +goose StatementEnd
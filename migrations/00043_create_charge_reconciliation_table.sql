@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE charge_reconciliation (
+    id                      UUID PRIMARY KEY,
+    created_by              UUID NOT NULL REFERENCES user_profile(user_id) ON DELETE CASCADE,
+    user_broker_account_id  UUID NOT NULL REFERENCES user_broker_account(id) ON DELETE CASCADE,
+    date                    DATE NOT NULL,
+    segment                 VARCHAR(16) NOT NULL,
+    actual                  JSONB NOT NULL,
+    actual_amount           NUMERIC(20, 8) NOT NULL,
+    estimated               JSONB NOT NULL,
+    estimated_amount        NUMERIC(20, 8) NOT NULL,
+    difference_amount       NUMERIC(20, 8) NOT NULL,
+    is_discrepancy          BOOLEAN NOT NULL DEFAULT FALSE,
+    trades_count            INT NOT NULL DEFAULT 0,
+    applied_at              TIMESTAMPTZ,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at              TIMESTAMPTZ,
+
+    UNIQUE (user_broker_account_id, date, segment)
+);
+
+CREATE INDEX idx_charge_reconciliation_created_by_date ON charge_reconciliation(created_by, date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS charge_reconciliation;
+-- +goose StatementEnd
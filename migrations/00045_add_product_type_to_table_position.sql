@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE position
+ADD COLUMN product_type VARCHAR(16) NOT NULL DEFAULT 'open';
+
+-- Classify the existing closed positions by their opened and closed dates in IST, as the API does.
+-- Only equity is split into BTST, swing and delivery, the other instruments are carried forward.
+WITH held AS (
+    SELECT
+        id,
+        instrument,
+        (opened_at AT TIME ZONE 'Asia/Kolkata')::date AS opened_day,
+        (closed_at AT TIME ZONE 'Asia/Kolkata')::date AS closed_day
+    FROM position
+    WHERE closed_at IS NOT NULL
+)
+UPDATE position p
+SET product_type = CASE
+    WHEN held.closed_day <= held.opened_day THEN 'intraday'
+    WHEN held.instrument <> 'equity' THEN 'carry_forward'
+    WHEN held.closed_day <= held.opened_day + CASE EXTRACT(ISODOW FROM held.opened_day) WHEN 5 THEN 3 WHEN 6 THEN 2 ELSE 1 END THEN 'btst'
+    WHEN held.closed_day <= held.opened_day + 30 THEN 'swing'
+    ELSE 'delivery'
+END
+FROM held
+WHERE p.id = held.id;
+
+CREATE INDEX idx_position_product_type ON position (created_by, product_type);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_position_product_type;
+ALTER TABLE position DROP COLUMN IF EXISTS product_type;
+-- +goose StatementEnd
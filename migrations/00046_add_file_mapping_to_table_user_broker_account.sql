@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_broker_account
+ADD COLUMN file_mapping JSONB;
+
+-- The tradebooks of other brokers can be imported with the user's mapping of their columns.
+UPDATE broker SET supports_file_import = true WHERE name = 'Other';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE broker SET supports_file_import = false WHERE name = 'Other';
+
+ALTER TABLE user_broker_account DROP COLUMN IF EXISTS file_mapping;
+-- +goose StatementEnd
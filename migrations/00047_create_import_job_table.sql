@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE import_job (
+    id          UUID PRIMARY KEY,
+    created_by  UUID NOT NULL REFERENCES user_profile(user_id) ON DELETE CASCADE,
+    status      VARCHAR(16) NOT NULL,
+    progress    INT NOT NULL DEFAULT 0,
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    input       JSONB,
+    result      JSONB,
+    error       TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_job_created_by ON import_job(created_by);
+CREATE INDEX idx_import_job_unfinished ON import_job(status) WHERE status IN ('pending', 'running');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_job;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE import_batch (
+    id                       UUID PRIMARY KEY,
+    created_by               UUID NOT NULL REFERENCES user_profile(user_id) ON DELETE CASCADE,
+    source                   VARCHAR(16) NOT NULL,
+    user_broker_account_id   UUID NOT NULL REFERENCES user_broker_account(id) ON DELETE CASCADE,
+    broker_id                UUID NOT NULL REFERENCES broker(id),
+    file_name                TEXT,
+    sheet                    TEXT,
+    positions_created_count  INT NOT NULL DEFAULT 0,
+    positions_replaced_count INT NOT NULL DEFAULT 0,
+    trades_count             INT NOT NULL DEFAULT 0,
+    replaced_positions       JSONB NOT NULL DEFAULT '[]',
+    rolled_back_at           TIMESTAMPTZ,
+    created_at               TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at               TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_batch_created_by ON import_batch(created_by);
+CREATE INDEX idx_import_batch_user_broker_account_id ON import_batch(user_broker_account_id);
+
+ALTER TABLE position ADD COLUMN import_batch_id UUID REFERENCES import_batch(id) ON DELETE SET NULL;
+ALTER TABLE trade ADD COLUMN import_batch_id UUID REFERENCES import_batch(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_position_import_batch_id ON position(import_batch_id);
+CREATE INDEX idx_trade_import_batch_id ON trade(import_batch_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_trade_import_batch_id;
+DROP INDEX IF EXISTS idx_position_import_batch_id;
+ALTER TABLE trade DROP COLUMN IF EXISTS import_batch_id;
+ALTER TABLE position DROP COLUMN IF EXISTS import_batch_id;
+DROP TABLE IF EXISTS import_batch;
+-- +goose StatementEnd
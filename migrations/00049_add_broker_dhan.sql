@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO broker (id, name, supports_file_import) VALUES (gen_random_uuid(), 'Dhan', true);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM broker WHERE name = 'Dhan';
+-- +goose StatementEnd